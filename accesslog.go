@@ -0,0 +1,147 @@
+package oas2
+
+import (
+	"bufio"
+	"context"
+	"errors"
+	"io"
+	"net"
+	"net/http"
+	"net/url"
+	"strings"
+
+	"github.com/sirupsen/logrus"
+)
+
+// NewAccessLog returns new Middleware that logs each request with its
+// operation, status, duration and sizes of request and response bodies.
+// Values of sensitive query parameters are redacted. It can be applied with
+// MiddlewareOpt or around the router, as it picks up the operation the
+// request is routed to either way.
+func NewAccessLog(logger logrus.FieldLogger, options ...MiddlewareOption) Middleware {
+	return accessLog{
+		logger: logger,
+		opts:   newMiddlewareOptions(options),
+	}
+}
+
+type accessLog struct {
+	logger logrus.FieldLogger
+	opts   MiddlewareOptions
+}
+
+func (m accessLog) Apply(next http.Handler) http.Handler {
+	return http.HandlerFunc(func(w http.ResponseWriter, req *http.Request) {
+		start := m.opts.clock.Now()
+
+		// Sizes are measured from bytes actually read and written, so they
+		// are accurate for chunked bodies too.
+		body := &countingReadCloser{ReadCloser: req.Body}
+		if req.Body != nil && req.Body != http.NoBody {
+			req.Body = body
+		}
+		sw := &sizeResponseWriter{ResponseWriter: w, status: http.StatusOK}
+
+		state := &routedOperationState{}
+		req = req.WithContext(
+			context.WithValue(req.Context(), contextKeyRoutedOperation{}, state),
+		)
+
+		next.ServeHTTP(sw, req)
+
+		requestSize := body.n
+		if requestSize == 0 && req.ContentLength > 0 {
+			// The handler has not read the body.
+			requestSize = req.ContentLength
+		}
+
+		fields := logrus.Fields{
+			"method":        req.Method,
+			"path":          req.URL.Path,
+			"status":        sw.status,
+			"duration":      m.opts.clock.Now().Sub(start),
+			"request_size":  requestSize,
+			"response_size": sw.size,
+		}
+
+		query := req.URL.Query()
+		op := m.opts.operationResolver(req)
+		if op == nil {
+			op = state.op
+		}
+		if op != nil {
+			fields["operation_id"] = op.ID
+			for _, p := range op.Parameters {
+				if p.In == "query" && isSensitive(p) {
+					if _, ok := query[p.Name]; ok {
+						query.Set(p.Name, redacted)
+					}
+				}
+			}
+		}
+		if len(query) > 0 {
+			fields["query"] = redactedQuery(query)
+		}
+
+		m.logger.WithFields(fields).Info("oas2: request handled")
+	})
+}
+
+// redactedQuery encodes the query keeping redacted values readable.
+func redactedQuery(q url.Values) string {
+	return strings.Replace(q.Encode(), url.QueryEscape(redacted), redacted, -1)
+}
+
+// countingReadCloser counts bytes read from the underlying ReadCloser.
+type countingReadCloser struct {
+	io.ReadCloser
+	n int64
+}
+
+func (r *countingReadCloser) Read(p []byte) (int, error) {
+	n, err := r.ReadCloser.Read(p)
+	r.n += int64(n)
+	return n, err
+}
+
+// sizeResponseWriter records status and counts bytes written.
+type sizeResponseWriter struct {
+	http.ResponseWriter
+	status        int
+	statusWritten bool
+	size          int64
+}
+
+func (w *sizeResponseWriter) WriteHeader(status int) {
+	if !w.statusWritten {
+		w.status = status
+		w.statusWritten = true
+	}
+	w.ResponseWriter.WriteHeader(status)
+}
+
+func (w *sizeResponseWriter) Write(b []byte) (int, error) {
+	w.statusWritten = true
+	n, err := w.ResponseWriter.Write(b)
+	w.size += int64(n)
+	return n, err
+}
+
+// Flush implements http.Flusher, so handlers can stream responses, e.g.
+// NDJSON records, through the access log.
+func (w *sizeResponseWriter) Flush() {
+	w.statusWritten = true
+	if f, ok := w.ResponseWriter.(http.Flusher); ok {
+		f.Flush()
+	}
+}
+
+// Hijack implements http.Hijacker, so handlers can take over the
+// connection, e.g. to upgrade it to WebSocket.
+func (w *sizeResponseWriter) Hijack() (net.Conn, *bufio.ReadWriter, error) {
+	h, ok := w.ResponseWriter.(http.Hijacker)
+	if !ok {
+		return nil, nil, errors.New("oas2: response writer does not support hijacking")
+	}
+	return h.Hijack()
+}
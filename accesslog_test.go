@@ -0,0 +1,218 @@
+package oas2
+
+import (
+	"bufio"
+	"bytes"
+	"io"
+	"io/ioutil"
+	"net/http"
+	"net/http/httptest"
+	"strings"
+	"testing"
+
+	"github.com/sirupsen/logrus/hooks/test"
+)
+
+func TestAccessLog_Apply(t *testing.T) {
+	sw := parseSpec(`
+swagger: "2.0"
+info:
+  title: test
+  version: "1.0"
+basePath: /v1
+paths:
+  /echo:
+    post:
+      operationId: echo
+      parameters:
+      - name: token
+        in: query
+        type: string
+        format: password
+      - name: body
+        in: body
+        schema:
+          type: string
+      responses:
+        200:
+          description: ok
+`)
+
+	handlers := OperationHandlers{"echo": http.HandlerFunc(func(w http.ResponseWriter, req *http.Request) {
+		io.Copy(w, req.Body)
+		io.WriteString(w, "!")
+	})}
+
+	logger, hook := test.NewNullLogger()
+
+	router, err := NewRouter(sw, handlers, MiddlewareOpt(NewAccessLog(logger).Apply))
+	if err != nil {
+		t.Fatal(err)
+	}
+
+	server := httptest.NewServer(router)
+	defer server.Close()
+
+	// Wrap the reader to hide its length, so the body is sent chunked.
+	body := ioutil.NopCloser(strings.NewReader(`"hello"`))
+	resp, err := server.Client().Post(server.URL+"/v1/echo?token=s3cr3t", "application/json", body)
+	if err != nil {
+		t.Fatal(err)
+	}
+	resp.Body.Close()
+
+	entry := hook.LastEntry()
+	if entry == nil {
+		t.Fatal("Expected request to be logged")
+	}
+
+	expectedFields := map[string]interface{}{
+		"operation_id":  "echo",
+		"status":        http.StatusOK,
+		"request_size":  int64(7),
+		"response_size": int64(8),
+		"query":         "token=***",
+	}
+	for name, expected := range expectedFields {
+		if entry.Data[name] != expected {
+			t.Errorf("Expected %s to be %v but got %v", name, expected, entry.Data[name])
+		}
+	}
+}
+
+func TestAccessLog_Apply_aroundRouter(t *testing.T) {
+	sw := parseSpec(`
+swagger: "2.0"
+info:
+  title: test
+  version: "1.0"
+basePath: /v1
+paths:
+  /login:
+    get:
+      operationId: login
+      parameters:
+      - name: password
+        in: query
+        type: string
+        format: password
+      - name: user
+        in: query
+        type: string
+      responses:
+        200:
+          description: ok
+`)
+
+	handlers := OperationHandlers{"login": http.HandlerFunc(func(w http.ResponseWriter, req *http.Request) {})}
+
+	router, err := NewRouter(sw, handlers)
+	if err != nil {
+		t.Fatal(err)
+	}
+
+	logger, hook := test.NewNullLogger()
+	handler := NewAccessLog(logger).Apply(router)
+
+	req := httptest.NewRequest(http.MethodGet, "/v1/login?user=johndoe&password=s3cr3t", nil)
+	handler.ServeHTTP(httptest.NewRecorder(), req)
+
+	entry := hook.LastEntry()
+	if entry == nil {
+		t.Fatal("Expected request to be logged")
+	}
+
+	expectedFields := map[string]interface{}{
+		"operation_id": "login",
+		"query":        "password=***&user=johndoe",
+	}
+	for name, expected := range expectedFields {
+		if entry.Data[name] != expected {
+			t.Errorf("Expected %s to be %v but got %v", name, expected, entry.Data[name])
+		}
+	}
+}
+
+func TestAccessLog_Apply_ndjson(t *testing.T) {
+	sw := parseSpec(`
+swagger: "2.0"
+info:
+  title: test
+  version: "1.0"
+basePath: /v1
+paths:
+  /events:
+    get:
+      operationId: streamEvents
+      produces:
+      - application/x-ndjson
+      responses:
+        200:
+          description: ok
+          schema:
+            type: array
+            items:
+              type: object
+              required: [id]
+              properties:
+                id:
+                  type: integer
+`)
+
+	release := make(chan struct{})
+	handlers := OperationHandlers{"streamEvents": http.HandlerFunc(func(w http.ResponseWriter, req *http.Request) {
+		w.Header().Set("Content-Type", "application/x-ndjson")
+		io.WriteString(w, "{\"id\":1}\n")
+		w.(http.Flusher).Flush()
+
+		// The second record is written only after the client has got the
+		// first one, so the response must be streamed.
+		<-release
+		io.WriteString(w, "{\"id\":2}\n")
+	})}
+
+	logBuffer := &bytes.Buffer{}
+	router, err := NewRouter(sw, handlers, MiddlewareOpt(NewResponseBodyValidator(errorLogger(logBuffer)).Apply))
+	if err != nil {
+		t.Fatal(err)
+	}
+
+	logger, hook := test.NewNullLogger()
+	server := httptest.NewServer(NewAccessLog(logger).Apply(router))
+	defer server.Close()
+
+	resp, err := server.Client().Get(server.URL + "/v1/events")
+	if err != nil {
+		t.Fatal(err)
+	}
+	defer resp.Body.Close()
+
+	r := bufio.NewReader(resp.Body)
+	line, err := r.ReadString('\n')
+	if err != nil {
+		t.Fatal(err)
+	}
+	if line != "{\"id\":1}\n" {
+		t.Errorf("Expected first record to be streamed but got %q", line)
+	}
+
+	close(release)
+	if rest, err := ioutil.ReadAll(r); err != nil || string(rest) != "{\"id\":2}\n" {
+		t.Errorf("Expected second record to be streamed but got %q (%v)", rest, err)
+	}
+
+	// The access log is written after the response is completed.
+	server.Close()
+
+	if logBuffer.Len() != 0 {
+		t.Errorf("Expected no validation errors but got\n%s", logBuffer.String())
+	}
+
+	entry := hook.LastEntry()
+	if entry == nil {
+		t.Fatal("Expected request to be logged")
+	}
+	if entry.Data["response_size"] != int64(18) {
+		t.Errorf("Expected response_size to be %d but got %v", 18, entry.Data["response_size"])
+	}
+}
@@ -0,0 +1,122 @@
+package oas2
+
+import (
+	"bytes"
+	"net/http"
+	"regexp"
+	"sort"
+	"strings"
+)
+
+// allowOrder is the canonical order of methods in Allow header.
+var allowOrder = []string{
+	http.MethodGet,
+	http.MethodHead,
+	http.MethodPost,
+	http.MethodPut,
+	http.MethodPatch,
+	http.MethodDelete,
+	http.MethodOptions,
+}
+
+// sortMethods sorts methods in the canonical order. Unknown methods go last
+// in alphabetical order.
+func sortMethods(methods []string) {
+	rank := func(method string) int {
+		for i, m := range allowOrder {
+			if m == method {
+				return i
+			}
+		}
+		return len(allowOrder)
+	}
+	sort.Slice(methods, func(i, j int) bool {
+		ri, rj := rank(methods[i]), rank(methods[j])
+		if ri != rj {
+			return ri < rj
+		}
+		return methods[i] < methods[j]
+	})
+}
+
+// allowedMethods collects methods routed for each spec path template.
+type allowedMethods struct {
+	paths []allowedPath
+}
+
+type allowedPath struct {
+	template string
+	pattern  *regexp.Regexp
+	methods  []string
+}
+
+// add records the method as routed for the path template.
+func (a *allowedMethods) add(path, method string) {
+	for i := range a.paths {
+		if a.paths[i].template == path {
+			a.paths[i].methods = append(a.paths[i].methods, method)
+			sortMethods(a.paths[i].methods)
+			return
+		}
+	}
+
+	a.paths = append(a.paths, allowedPath{
+		template: path,
+		pattern:  pathTemplatePattern(path),
+		methods:  []string{method},
+	})
+}
+
+// lookup returns methods routed for the path, which is relative to the
+// basePath, or nil if the path matches no template. When several templates
+// match, the most specific one is used, like routers do, so "/pets/mine"
+// wins over "/pets/{id}".
+func (a *allowedMethods) lookup(path string) []string {
+	var match *allowedPath
+	for i := range a.paths {
+		p := &a.paths[i]
+		if !p.pattern.MatchString(path) {
+			continue
+		}
+		if match == nil || moreSpecificTemplate(p.template, match.template) {
+			match = p
+		}
+	}
+	if match == nil {
+		return nil
+	}
+	return match.methods
+}
+
+// moreSpecificTemplate reports whether path template a is more specific
+// than b. Segments are compared from left to right, and a static segment is
+// more specific than a templated one. Templates equally specific are
+// ordered lexically, so the result does not depend on the order they were
+// added.
+func moreSpecificTemplate(a, b string) bool {
+	as, bs := strings.Split(a, "/"), strings.Split(b, "/")
+	for i := 0; i < len(as) && i < len(bs); i++ {
+		at := pathTemplateParam.MatchString(as[i])
+		bt := pathTemplateParam.MatchString(bs[i])
+		if at != bt {
+			return !at
+		}
+	}
+	return a < b
+}
+
+// pathTemplatePattern returns a regexp that matches paths of the template,
+// e.g. "/pet/12" for "/pet/{petId}".
+func pathTemplatePattern(path string) *regexp.Regexp {
+	var b bytes.Buffer
+	b.WriteString("^")
+	last := 0
+	for _, loc := range pathTemplateParam.FindAllStringIndex(path, -1) {
+		b.WriteString(regexp.QuoteMeta(path[last:loc[0]]))
+		b.WriteString("[^/]+")
+		last = loc[1]
+	}
+	b.WriteString(regexp.QuoteMeta(path[last:]))
+	b.WriteString("$")
+	return regexp.MustCompile(b.String())
+}
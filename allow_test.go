@@ -0,0 +1,63 @@
+package oas2
+
+import (
+	"reflect"
+	"testing"
+)
+
+func TestAllowedMethods_lookup(t *testing.T) {
+	cases := []struct {
+		path            string
+		expectedMethods []string
+	}{
+		// static template wins over the templated one
+		{
+			path:            "/pets/mine",
+			expectedMethods: []string{"GET"},
+		},
+		{
+			path:            "/pets/12",
+			expectedMethods: []string{"GET", "DELETE"},
+		},
+		// static segment wins regardless of its position
+		{
+			path:            "/pets/12/toys",
+			expectedMethods: []string{"PUT"},
+		},
+		{
+			path:            "/pets/mine/toys",
+			expectedMethods: []string{"POST"},
+		},
+		{
+			path:            "/pets",
+			expectedMethods: nil,
+		},
+	}
+
+	templates := [][2]string{
+		{"/pets/{id}", "GET"},
+		{"/pets/{id}", "DELETE"},
+		{"/pets/mine", "GET"},
+		{"/pets/{id}/{kind}", "PATCH"},
+		{"/pets/{id}/toys", "PUT"},
+		{"/pets/mine/{kind}", "POST"},
+	}
+
+	// The result must not depend on the order templates are added in.
+	for _, reverse := range []bool{false, true} {
+		var a allowedMethods
+		for i := range templates {
+			tm := templates[i]
+			if reverse {
+				tm = templates[len(templates)-1-i]
+			}
+			a.add(tm[0], tm[1])
+		}
+
+		for _, c := range cases {
+			if methods := a.lookup(c.path); !reflect.DeepEqual(methods, c.expectedMethods) {
+				t.Errorf("Expected methods for %s to be %v but got %v", c.path, c.expectedMethods, methods)
+			}
+		}
+	}
+}
@@ -0,0 +1,71 @@
+package oas2
+
+import (
+	"encoding/json"
+	"fmt"
+	"net/http"
+)
+
+// AssertResponse validates the response body with the status against the
+// schema of the response of the request operation, see GetOperation. The
+// default response is used if the status has no response of its own. It is
+// meant to check handlers for contract compliance, e.g. in their tests,
+// without response body validator.
+//
+// The body is a value to encode to JSON, or an already encoded []byte or
+// json.RawMessage. Errors of the body are ValidationErrors.
+func AssertResponse(req *http.Request, status int, body interface{}) []error {
+	op := GetOperation(req)
+	if op == nil {
+		return []error{fmt.Errorf("request has no operation")}
+	}
+
+	if op.Responses == nil {
+		return []error{fmt.Errorf("operation %s: no response spec for status %d", op.ID, status)}
+	}
+	responseSpec, ok := op.Responses.StatusCodeResponses[status]
+	if !ok {
+		if op.Responses.Default == nil {
+			return []error{fmt.Errorf("operation %s: no response spec for status %d", op.ID, status)}
+		}
+		responseSpec = *op.Responses.Default
+	}
+
+	if responseSpec.Schema == nil {
+		return nil
+	}
+
+	data, err := plainJSON(body)
+	if err != nil {
+		return []error{fmt.Errorf("response body contains invalid json: %s", err)}
+	}
+
+	// Schemas compiled by the router have references resolved.
+	if cs, ok := getOperationSchemas(req).response[responseSpec.Schema]; ok {
+		return cs.validate(data, "body").Errors()
+	}
+	return ValidateBySchema(responseSpec.Schema, data)
+}
+
+// plainJSON returns the value as decoded from its JSON encoding, e.g. maps
+// for structs.
+func plainJSON(v interface{}) (interface{}, error) {
+	var b []byte
+	switch v := v.(type) {
+	case []byte:
+		b = v
+	case json.RawMessage:
+		b = v
+	default:
+		var err error
+		if b, err = json.Marshal(v); err != nil {
+			return nil, err
+		}
+	}
+
+	var data interface{}
+	if err := json.Unmarshal(b, &data); err != nil {
+		return nil, err
+	}
+	return data, nil
+}
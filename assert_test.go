@@ -0,0 +1,79 @@
+package oas2
+
+import (
+	"net/http"
+	"net/http/httptest"
+	"reflect"
+	"testing"
+)
+
+func TestAssertResponse(t *testing.T) {
+	type pet struct {
+		ID   int64  `json:"id"`
+		Name string `json:"name"`
+		Age  int32  `json:"age,omitempty"`
+	}
+
+	cases := []struct {
+		status         int
+		body           interface{}
+		expectedErrors []string
+	}{
+		{
+			status: http.StatusOK,
+			body:   pet{ID: 12, Name: "Kitty", Age: 3},
+		},
+		{
+			status: http.StatusOK,
+			body:   []byte(`{"id":12,"name":"Kitty","age":3}`),
+		},
+		// missing required field
+		{
+			status:         http.StatusOK,
+			body:           pet{ID: 12, Name: "Kitty"},
+			expectedErrors: []string{"age in body is required"},
+		},
+		// no schema
+		{
+			status: http.StatusBadRequest,
+			body:   "invalid id",
+		},
+		// no response
+		{
+			status:         http.StatusTeapot,
+			body:           pet{},
+			expectedErrors: []string{"operation getPetById: no response spec for status 418"},
+		},
+	}
+
+	doc := loadDoc()
+
+	var errs []error
+	var body interface{}
+	var status int
+	handlers := OperationHandlers{"getPetById": http.HandlerFunc(func(w http.ResponseWriter, req *http.Request) {
+		errs = AssertResponse(req, status, body)
+	})}
+
+	router, err := NewRouter(doc.Spec(), handlers)
+	if err != nil {
+		t.Fatal(err)
+	}
+
+	for _, c := range cases {
+		status, body = c.status, c.body
+		router.ServeHTTP(httptest.NewRecorder(), httptest.NewRequest(http.MethodGet, "/v2/pet/12", nil))
+
+		var actualErrors []string
+		for _, err := range errs {
+			actualErrors = append(actualErrors, err.Error())
+		}
+		if !reflect.DeepEqual(c.expectedErrors, actualErrors) {
+			t.Errorf("Expected errors for %v to be %v but got %v", c.body, c.expectedErrors, actualErrors)
+		}
+	}
+
+	if errs := AssertResponse(httptest.NewRequest(http.MethodGet, "/v2/pet/12", nil), http.StatusOK, nil); len(errs) != 1 {
+		t.Errorf("Expected an error for request without operation but got %v", errs)
+	}
+}
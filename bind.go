@@ -0,0 +1,158 @@
+package oas2
+
+import (
+	"fmt"
+	"math"
+	"net/http"
+	"reflect"
+	"time"
+)
+
+// BindQuery fills fields of the struct dst points to with query parameters
+// of the request operation, by the field "oas" tags. Values converted by the
+// query validator are reused, see GetQueryParam; other values are converted
+// by the parameter spec. Slice fields are filled from array parameters and
+// time.Time fields from string parameters of date and date-time formats.
+// Numbers are bound to fields of any integer or float type that holds them.
+//
+// It returns an error for each field that cannot be bound, e.g. for missing
+// required parameters or values of other types. An error without a field is
+// returned if dst is not a pointer to struct or the request has no operation.
+func BindQuery(req *http.Request, dst interface{}) []error {
+	dv := reflect.ValueOf(dst)
+	if dv.Kind() != reflect.Ptr || dv.Elem().Kind() != reflect.Struct {
+		return []error{fmt.Errorf("dst is not a pointer to struct (cannot modify)")}
+	}
+	dv = dv.Elem()
+
+	op := GetOperation(req)
+	if op == nil {
+		return []error{fmt.Errorf("request has no operation")}
+	}
+
+	converted, _ := req.Context().Value(contextKeyQueryValues{}).(map[string]interface{})
+	query := req.URL.Query()
+	fields := fieldMap(dv)
+
+	errs := make(ValidationErrors, 0)
+	for _, p := range op.Parameters {
+		if p.In != "query" {
+			continue
+		}
+
+		f, ok := fields[p.Name]
+		if !ok {
+			continue
+		}
+
+		value, ok := converted[p.Name]
+		if !ok {
+			vals, passed := query[p.Name]
+			switch {
+			case passed:
+				v, err := convertParam(p, vals, nil)
+				if err != nil {
+					if isSensitive(p) {
+						errs = append(errs, ValidationErrorf(p.Name, redacted, "%s", sensitiveMessage(p.Name, "type")))
+						continue
+					}
+					errs = append(errs, ValidationErrorf(p.Name, query.Get(p.Name), "param %s: %s", p.Name, err))
+					continue
+				}
+				value = v
+			case p.Required:
+				errs = append(errs, ValidationErrorf(p.Name, nil, "param %s is required", p.Name))
+				continue
+			case p.Default != nil:
+				value = p.Default
+			default:
+				continue
+			}
+		}
+
+		if err := bindValue(dv.FieldByIndex(f.Index), value); err != nil {
+			if isSensitive(p) {
+				errs = append(errs, ValidationErrorf(p.Name, redacted, "%s", sensitiveMessage(p.Name, "type")))
+				continue
+			}
+			errs = append(errs, ValidationErrorf(p.Name, value, "param %s: %s", p.Name, err))
+		}
+	}
+	return errs.Errors()
+}
+
+var timeType = reflect.TypeOf(time.Time{})
+
+// bindValue sets the field to the value converted by the parameter spec.
+func bindValue(field reflect.Value, value interface{}) error {
+	if !field.CanSet() {
+		return fmt.Errorf("field is not settable")
+	}
+
+	v := reflect.ValueOf(value)
+	if !v.IsValid() {
+		return fmt.Errorf("cannot bind nil")
+	}
+
+	if v.Type().AssignableTo(field.Type()) {
+		field.Set(v)
+		return nil
+	}
+
+	if s, ok := value.(string); ok && field.Type() == timeType {
+		// Values of date and date-time formats are kept as strings.
+		t, err := parseDate(s, "date-time")
+		if err != nil {
+			if t, err = parseDate(s, "date"); err != nil {
+				return fmt.Errorf("cannot bind %s to %s", s, field.Type())
+			}
+		}
+		field.Set(reflect.ValueOf(t))
+		return nil
+	}
+
+	switch field.Kind() {
+	case reflect.Slice:
+		elems, ok := value.([]interface{})
+		if !ok {
+			break
+		}
+		s := reflect.MakeSlice(field.Type(), len(elems), len(elems))
+		for i, e := range elems {
+			if err := bindValue(s.Index(i), e); err != nil {
+				return fmt.Errorf("item %d: %s", i, err)
+			}
+		}
+		field.Set(s)
+		return nil
+	case reflect.Int, reflect.Int8, reflect.Int16, reflect.Int32, reflect.Int64:
+		switch v.Kind() {
+		case reflect.Int, reflect.Int8, reflect.Int16, reflect.Int32, reflect.Int64:
+			if field.OverflowInt(v.Int()) {
+				return fmt.Errorf("value %d overflows %s", v.Int(), field.Type())
+			}
+			field.SetInt(v.Int())
+			return nil
+		case reflect.Float32, reflect.Float64:
+			// Defaults of integer parameters are decoded from the spec
+			// as floats.
+			f := v.Float()
+			if f != math.Trunc(f) || field.OverflowInt(int64(f)) {
+				return fmt.Errorf("value %v is not %s", f, field.Type())
+			}
+			field.SetInt(int64(f))
+			return nil
+		}
+	case reflect.Float32, reflect.Float64:
+		switch v.Kind() {
+		case reflect.Float32, reflect.Float64:
+			field.SetFloat(v.Float())
+			return nil
+		case reflect.Int, reflect.Int8, reflect.Int16, reflect.Int32, reflect.Int64:
+			field.SetFloat(float64(v.Int()))
+			return nil
+		}
+	}
+
+	return fmt.Errorf("cannot bind %T to %s", value, field.Type())
+}
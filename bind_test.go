@@ -0,0 +1,159 @@
+package oas2
+
+import (
+	"net/http"
+	"net/http/httptest"
+	"reflect"
+	"testing"
+	"time"
+)
+
+func TestBindQuery(t *testing.T) {
+	sw := parseSpec(`
+swagger: "2.0"
+info:
+  title: test
+  version: "1.0"
+basePath: /v1
+paths:
+  /pets:
+    get:
+      operationId: findPets
+      parameters:
+      - name: name
+        in: query
+        required: true
+        type: string
+      - name: limit
+        in: query
+        type: integer
+        format: int32
+        default: 20
+      - name: tags
+        in: query
+        type: array
+        items:
+          type: string
+      - name: ids
+        in: query
+        type: array
+        collectionFormat: multi
+        items:
+          type: integer
+      - name: born
+        in: query
+        type: string
+        format: date
+      - name: weight
+        in: query
+        type: number
+      responses:
+        200:
+          description: ok
+`)
+
+	type query struct {
+		Name   string    `oas:"name"`
+		Limit  int       `oas:"limit"`
+		Tags   []string  `oas:"tags"`
+		IDs    []int     `oas:"ids"`
+		Born   time.Time `oas:"born"`
+		Weight string    `oas:"weight"`
+	}
+
+	cases := []struct {
+		query          string
+		validate       bool
+		expectedQuery  query
+		expectedErrors []string
+	}{
+		// converted by the query validator
+		{
+			query:    "name=Kitty&limit=5&tags=cat,small&ids=1&ids=2&born=2018-03-01",
+			validate: true,
+			expectedQuery: query{
+				Name:  "Kitty",
+				Limit: 5,
+				Tags:  []string{"cat", "small"},
+				IDs:   []int{1, 2},
+				Born:  time.Date(2018, 3, 1, 0, 0, 0, 0, time.UTC),
+			},
+		},
+		// converted by the binder
+		{
+			query: "name=Kitty&tags=cat",
+			expectedQuery: query{
+				Name:  "Kitty",
+				Limit: 20,
+				Tags:  []string{"cat"},
+			},
+		},
+		// missing required and mismatched types
+		{
+			query:         "limit=x&weight=1.5",
+			expectedQuery: query{},
+			expectedErrors: []string{
+				"param name is required",
+				"param limit: cannot convert x to int32",
+				"param weight: cannot bind float64 to string",
+			},
+		},
+	}
+
+	for _, c := range cases {
+		var actual query
+		var errs []error
+		handlers := OperationHandlers{"findPets": http.HandlerFunc(func(w http.ResponseWriter, req *http.Request) {
+			errs = BindQuery(req, &actual)
+		})}
+
+		var options []RouterOption
+		if c.validate {
+			options = append(options, MiddlewareOpt(NewQueryValidator(writeErrorsToResponseWriter).Apply))
+		}
+
+		router, err := NewRouter(sw, handlers, options...)
+		if err != nil {
+			t.Fatal(err)
+		}
+
+		w := httptest.NewRecorder()
+		router.ServeHTTP(w, httptest.NewRequest(http.MethodGet, "/v1/pets?"+c.query, nil))
+
+		if !reflect.DeepEqual(c.expectedQuery, actual) {
+			t.Errorf("Expected query to be %#v but got %#v", c.expectedQuery, actual)
+		}
+
+		var actualErrors []string
+		for _, err := range errs {
+			actualErrors = append(actualErrors, err.Error())
+		}
+		if !reflect.DeepEqual(c.expectedErrors, actualErrors) {
+			t.Errorf("Expected errors to be %v but got %v", c.expectedErrors, actualErrors)
+		}
+	}
+}
+
+func TestGetQueryParam(t *testing.T) {
+	doc := loadDoc()
+
+	var username, password interface{}
+	handlers := OperationHandlers{"loginUser": http.HandlerFunc(func(w http.ResponseWriter, req *http.Request) {
+		username = GetQueryParam(req, "username")
+		password = GetQueryParam(req, "unknown")
+	})}
+
+	router, err := NewRouter(doc.Spec(), handlers, MiddlewareOpt(NewQueryValidator(writeErrorsToResponseWriter).Apply))
+	if err != nil {
+		t.Fatal(err)
+	}
+
+	router.ServeHTTP(httptest.NewRecorder(), httptest.NewRequest(http.MethodGet, "/v2/user/login?username=johndoe&password=123", nil))
+
+	if username != "johndoe" {
+		t.Errorf("Expected username to be %v but got %v", "johndoe", username)
+	}
+	if password != nil {
+		t.Errorf("Expected unknown parameter to be nil but got %v", password)
+	}
+}
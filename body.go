@@ -0,0 +1,525 @@
+package oas2
+
+import (
+	"bytes"
+	"context"
+	"encoding/json"
+	"encoding/xml"
+	"errors"
+	"fmt"
+	"io"
+	"io/ioutil"
+	"mime"
+	"mime/multipart"
+	"net/http"
+	"net/url"
+	"strings"
+
+	"github.com/go-openapi/spec"
+)
+
+// maxFormMemory is the maximum number of bytes of a multipart form stored
+// in memory. The rest is stored on disk in temporary files.
+const maxFormMemory = 32 << 20
+
+// hasBodyParams reports whether parameters describe a request body, i.e.
+// there are body or form data parameters.
+func hasBodyParams(ps []spec.Parameter) bool {
+	for _, p := range ps {
+		if p.In == "body" || p.In == "formData" {
+			return true
+		}
+	}
+	return false
+}
+
+// DefaultMaxJSONDepth is the default maximum nesting depth of JSON bodies.
+const DefaultMaxJSONDepth = 64
+
+// depthLimitReader reads JSON from r and stops with io.ErrUnexpectedEOF
+// once arrays and objects are nested deeper than max, before the decoder
+// reaches them. Depth is tracked by brackets outside of strings, so the JSON
+// is not required to be valid.
+type depthLimitReader struct {
+	r   io.Reader
+	max int
+
+	depth    int
+	inString bool
+	escaped  bool
+
+	// exceeded is set when the depth exceeds max.
+	exceeded bool
+}
+
+func (r *depthLimitReader) Read(p []byte) (int, error) {
+	if r.exceeded {
+		return 0, io.ErrUnexpectedEOF
+	}
+
+	n, err := r.r.Read(p)
+	for i := 0; i < n; i++ {
+		c := p[i]
+		switch {
+		case r.inString:
+			switch {
+			case r.escaped:
+				r.escaped = false
+			case c == '\\':
+				r.escaped = true
+			case c == '"':
+				r.inString = false
+			}
+		case c == '"':
+			r.inString = true
+		case c == '[' || c == '{':
+			r.depth++
+			if r.depth > r.max {
+				r.exceeded = true
+				return i, io.ErrUnexpectedEOF
+			}
+		case c == ']' || c == '}':
+			r.depth--
+		}
+	}
+	return n, err
+}
+
+// jsonDepthError returns an error to report to the client for the body
+// exceeding the depth limit.
+func jsonDepthError(max int) error {
+	return fmt.Errorf("Body exceeds maximum json nesting depth of %d", max)
+}
+
+// TrailingData is a way body validator handles data after the JSON value of
+// a body, e.g. a second value appended by a client by mistake.
+type TrailingData int
+
+const (
+	// TrailingDataIgnore passes the body to the handler as is. Only the
+	// first JSON value is validated. This is the default.
+	TrailingDataIgnore TrailingData = iota
+	// TrailingDataReject rejects bodies with anything but whitespace after
+	// the JSON value.
+	TrailingDataReject
+	// TrailingDataTrim passes only the JSON value to the handler, dropping
+	// anything after it.
+	TrailingDataTrim
+)
+
+// hasTrailingData reports whether there is anything but whitespace after
+// the value decoded by d.
+func hasTrailingData(d *json.Decoder) bool {
+	_, err := d.Token()
+	return err != io.EOF
+}
+
+// firstJSONValue returns the first JSON value of b without leading and
+// trailing data, or b if it does not start with a valid JSON value.
+func firstJSONValue(b []byte) []byte {
+	var v json.RawMessage
+	if err := json.NewDecoder(bytes.NewReader(b)).Decode(&v); err != nil {
+		return b
+	}
+	return v
+}
+
+// duplicateKey returns the first key duplicated in an object of the JSON
+// value read from r. JSON decoding takes the last value of duplicate keys,
+// which may hide client mistakes. Invalid JSON is not reported, it is left
+// to the decoder.
+func duplicateKey(r io.Reader) (string, bool) {
+	type object struct {
+		keys      map[string]struct{}
+		expectKey bool
+	}
+
+	d := json.NewDecoder(r)
+	d.UseNumber()
+
+	// Stack of objects and arrays the scan is in, nil for arrays.
+	var stack []*object
+	for {
+		tok, err := d.Token()
+		if err != nil {
+			return "", false
+		}
+
+		var top *object
+		if len(stack) > 0 {
+			top = stack[len(stack)-1]
+		}
+
+		if key, ok := tok.(string); ok && top != nil && top.expectKey {
+			if _, seen := top.keys[key]; seen {
+				return key, true
+			}
+			top.keys[key] = struct{}{}
+			top.expectKey = false
+			continue
+		}
+
+		switch tok {
+		case json.Delim('}'), json.Delim(']'):
+			stack = stack[:len(stack)-1]
+		default:
+			// The token starts a value, a key follows it in objects.
+			if top != nil {
+				top.expectKey = true
+			}
+			switch tok {
+			case json.Delim('{'):
+				stack = append(stack, &object{keys: make(map[string]struct{}), expectKey: true})
+			case json.Delim('['):
+				stack = append(stack, nil)
+			}
+		}
+
+		if len(stack) == 0 {
+			return "", false
+		}
+	}
+}
+
+// extConsumesSchema is a body parameter extension that maps media types the
+// operation consumes to schemas of bodies of the media type. The schemas are
+// used instead of the parameter schema, e.g. to accept two versions of the
+// body:
+//
+//	x-consumes-schema:
+//	  application/vnd.pet.v1+json:
+//	    $ref: "#/definitions/PetV1"
+//	  application/vnd.pet.v2+json:
+//	    $ref: "#/definitions/PetV2"
+const extConsumesSchema = "x-consumes-schema"
+
+// consumesSchemas returns schemas of the body parameter by media type
+// declared by x-consumes-schema extension, if any.
+func consumesSchemas(p spec.Parameter) (map[string]*spec.Schema, error) {
+	ext, ok := p.Extensions[extConsumesSchema]
+	if !ok {
+		return nil, nil
+	}
+
+	b, err := json.Marshal(ext)
+	if err != nil {
+		return nil, err
+	}
+	var raw map[string]*spec.Schema
+	if err := json.Unmarshal(b, &raw); err != nil {
+		return nil, fmt.Errorf("%s: %s", extConsumesSchema, err)
+	}
+
+	schemas := make(map[string]*spec.Schema, len(raw))
+	for mt, sch := range raw {
+		schemas[parseMediaType(mt)] = sch
+	}
+	return schemas, nil
+}
+
+// checkXML reads r and returns an error if it is not well-formed XML.
+func checkXML(r io.Reader) error {
+	d := xml.NewDecoder(r)
+	for {
+		if _, err := d.Token(); err != nil {
+			if err == io.EOF {
+				return nil
+			}
+			return err
+		}
+	}
+}
+
+// decodeForm reads form from r according to the content type, which can be
+// either urlencoded or multipart form. Multipart form must be within the
+// limits. Files of multipart form must be removed by the caller with
+// RemoveAll.
+func decodeForm(r io.Reader, contentType string, limits MultipartLimits) (*multipart.Form, error) {
+	mt, params, err := mime.ParseMediaType(contentType)
+	if err != nil || mt != mediaTypeMultipart {
+		b, err := ioutil.ReadAll(r)
+		if err != nil {
+			return nil, err
+		}
+		values, err := url.ParseQuery(string(b))
+		if err != nil {
+			return nil, err
+		}
+		return &multipart.Form{Value: values}, nil
+	}
+
+	if limits == (MultipartLimits{}) {
+		return multipart.NewReader(r, params["boundary"]).ReadForm(maxFormMemory)
+	}
+
+	l := newMultipartLimiter(r, params["boundary"], limits)
+	form, err := multipart.NewReader(l, params["boundary"]).ReadForm(maxFormMemory)
+	if l.err != nil {
+		if form != nil {
+			form.RemoveAll()
+		}
+		return nil, l.err
+	}
+	return form, err
+}
+
+// MultipartLimits limits multipart bodies. Zero values mean no limit.
+type MultipartLimits struct {
+	// MaxParts is the maximum number of parts.
+	MaxParts int
+	// MaxPartSize is the maximum size of a part content in bytes.
+	MaxPartSize int64
+	// MaxSize is the maximum size of the whole body in bytes.
+	MaxSize int64
+}
+
+// multipartLimitError is an error of a multipart body exceeding the limits,
+// which is responded with the status.
+type multipartLimitError struct {
+	status  int
+	message string
+}
+
+func (e *multipartLimitError) Error() string {
+	return e.message
+}
+
+// multipartLimiter passes multipart body through and counts its parts and
+// their sizes as the body is read, failing with *multipartLimitError as
+// soon as the body exceeds the limits. It scans the body for delimiters
+// itself, so the body is neither buffered nor parsed twice.
+type multipartLimiter struct {
+	r      io.Reader
+	limits MultipartLimits
+	err    *multipartLimitError
+
+	// delim is the delimiter of parts, and fail is its KMP failure
+	// function.
+	delim []byte
+	fail  []int
+
+	state   multipartState
+	size    int64 // bytes scanned
+	matched int   // length of the delimiter prefix matched
+	parts   int
+	start   int64  // offset of the current part content
+	headers []byte // headers of the current part
+	eol     int    // length of partHeadersEnd matched
+}
+
+// multipartState is a state of scanning multipart body.
+type multipartState int
+
+const (
+	multipartPreamble multipartState = iota
+	multipartDelimiter
+	multipartDash
+	multipartHeaders
+	multipartContent
+	multipartEpilogue
+)
+
+// partHeadersEnd ends headers of a part, including the line break after
+// the delimiter when there are no headers.
+const partHeadersEnd = "\r\n\r\n"
+
+// maxPartHeadersSize is the maximum size of part headers kept to name the
+// part in errors.
+const maxPartHeadersSize = 4 << 10
+
+func newMultipartLimiter(r io.Reader, boundary string, limits MultipartLimits) *multipartLimiter {
+	delim := []byte("\r\n--" + boundary)
+	fail := make([]int, len(delim))
+	for i, k := 1, 0; i < len(delim); i++ {
+		for k > 0 && delim[i] != delim[k] {
+			k = fail[k-1]
+		}
+		if delim[i] == delim[k] {
+			k++
+		}
+		fail[i] = k
+	}
+
+	return &multipartLimiter{
+		r:      r,
+		limits: limits,
+		delim:  delim,
+		fail:   fail,
+		// The first delimiter is not preceded by CRLF.
+		matched: 2,
+	}
+}
+
+func (l *multipartLimiter) Read(p []byte) (int, error) {
+	if l.err != nil {
+		return 0, l.err
+	}
+	n, err := l.r.Read(p)
+	for _, c := range p[:n] {
+		if l.err = l.scan(c); l.err != nil {
+			return 0, l.err
+		}
+	}
+	return n, err
+}
+
+// scan scans the next byte of the body.
+func (l *multipartLimiter) scan(c byte) *multipartLimitError {
+	l.size++
+	if l.limits.MaxSize > 0 && l.size > l.limits.MaxSize {
+		return &multipartLimitError{
+			status:  http.StatusRequestEntityTooLarge,
+			message: fmt.Sprintf("Body is too large, at most %d bytes are allowed", l.limits.MaxSize),
+		}
+	}
+
+	switch l.state {
+	case multipartPreamble, multipartContent:
+		for l.matched > 0 && l.delim[l.matched] != c {
+			l.matched = l.fail[l.matched-1]
+		}
+		if l.delim[l.matched] == c {
+			l.matched++
+		}
+		if l.matched == len(l.delim) {
+			l.matched = 0
+			l.state = multipartDelimiter
+			return nil
+		}
+		// Bytes matching the delimiter prefix may turn out to be the
+		// delimiter, so they are not counted yet.
+		if l.state == multipartContent && l.limits.MaxPartSize > 0 && l.size-int64(l.matched)-l.start > l.limits.MaxPartSize {
+			return &multipartLimitError{
+				status:  http.StatusRequestEntityTooLarge,
+				message: fmt.Sprintf("Body part %s is too large, at most %d bytes are allowed", partName(l.headers), l.limits.MaxPartSize),
+			}
+		}
+	case multipartDelimiter:
+		if c == '-' {
+			l.state = multipartDash
+			return nil
+		}
+		return l.startPart(c)
+	case multipartDash:
+		if c == '-' {
+			// The close delimiter.
+			l.state = multipartEpilogue
+			return nil
+		}
+		return l.startPart(c)
+	case multipartHeaders:
+		l.scanHeaders(c)
+	}
+	return nil
+}
+
+// startPart counts the part which headers start with c.
+func (l *multipartLimiter) startPart(c byte) *multipartLimitError {
+	l.parts++
+	if l.limits.MaxParts > 0 && l.parts > l.limits.MaxParts {
+		return &multipartLimitError{
+			status:  http.StatusBadRequest,
+			message: fmt.Sprintf("Body contains too many parts, at most %d are allowed", l.limits.MaxParts),
+		}
+	}
+	l.state = multipartHeaders
+	l.headers = l.headers[:0]
+	l.eol = 0
+	l.scanHeaders(c)
+	return nil
+}
+
+// scanHeaders keeps c as a byte of the part headers, which end with an
+// empty line.
+func (l *multipartLimiter) scanHeaders(c byte) {
+	if len(l.headers) < maxPartHeadersSize {
+		l.headers = append(l.headers, c)
+	}
+
+	switch {
+	case c == partHeadersEnd[l.eol]:
+		l.eol++
+	case c == '\r':
+		l.eol = 1
+	default:
+		l.eol = 0
+	}
+	if l.eol == len(partHeadersEnd) {
+		l.state = multipartContent
+		l.start = l.size
+	}
+}
+
+// partName returns the form name of the part with the headers.
+func partName(headers []byte) string {
+	for _, line := range strings.Split(string(headers), "\r\n") {
+		i := strings.IndexByte(line, ':')
+		if i < 0 || !strings.EqualFold(strings.TrimSpace(line[:i]), "Content-Disposition") {
+			continue
+		}
+		_, params, err := mime.ParseMediaType(line[i+1:])
+		if err == nil {
+			return params["name"]
+		}
+	}
+	return ""
+}
+
+// GetFormValue returns the first value of the form field by name from
+// a request which form was validated by body validator.
+func GetFormValue(req *http.Request, name string) string {
+	form, ok := req.Context().Value(contextKeyForm{}).(*multipart.Form)
+	if !ok || len(form.Value[name]) == 0 {
+		return ""
+	}
+	return form.Value[name][0]
+}
+
+// GetFormFile returns the first file of the form field by name from
+// a request which multipart form was validated by body validator. The file
+// is available until the handler returns.
+func GetFormFile(req *http.Request, name string) *multipart.FileHeader {
+	form, ok := req.Context().Value(contextKeyForm{}).(*multipart.Form)
+	if !ok || len(form.File[name]) == 0 {
+		return nil
+	}
+	return form.File[name][0]
+}
+
+type contextKeyForm struct{}
+
+// contextReader reads from r until the context is done. The context is
+// checked before each read, so a body sent slowly is not read past the
+// deadline. A read blocked in r is not interrupted, as closing the request
+// body does not unblock it either.
+type contextReader struct {
+	ctx context.Context
+	r   io.Reader
+	err error
+}
+
+func (r *contextReader) Read(p []byte) (int, error) {
+	if r.err != nil {
+		return 0, r.err
+	}
+	if err := r.ctx.Err(); err != nil {
+		r.err = err
+		return 0, err
+	}
+	return r.r.Read(p)
+}
+
+// errBodyAborted is the error of a body which reading was aborted.
+var errBodyAborted = errors.New("Body was not received before the request deadline")
+
+// abortedBodyErrHandler returns errHandler that responds with 408 Request
+// Timeout instead of errs if reading the body was aborted.
+func abortedBodyErrHandler(body *contextReader, errHandler func(w http.ResponseWriter, errs []error)) func(w http.ResponseWriter, errs []error) {
+	return func(w http.ResponseWriter, errs []error) {
+		if body.err != nil {
+			writeErrorsWithStatus(w, http.StatusRequestTimeout, errHandler, []error{errBodyAborted})
+			return
+		}
+		errHandler(w, errs)
+	}
+}
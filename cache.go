@@ -0,0 +1,107 @@
+package oas2
+
+import (
+	"container/list"
+	"crypto/sha256"
+	"sync"
+	"time"
+
+	"github.com/go-openapi/spec"
+)
+
+// validationCache is a LRU cache of validation results with TTL.
+type validationCache struct {
+	size  int
+	ttl   time.Duration
+	clock Clock
+
+	mu    sync.Mutex
+	ll    *list.List
+	items map[cacheKey]*list.Element
+}
+
+type validationCacheEntry struct {
+	key     cacheKey
+	errs    []error
+	expires time.Time
+}
+
+func newValidationCache(size int, ttl time.Duration, clock Clock) *validationCache {
+	return &validationCache{
+		size:  size,
+		ttl:   ttl,
+		clock: clock,
+		ll:    list.New(),
+		items: make(map[cacheKey]*list.Element, size),
+	}
+}
+
+// cacheKey identifies data validated for an operation. The operation is
+// identified by pointer, as operations may have no ID, and operations of
+// different specs may have the same ID.
+type cacheKey struct {
+	op        *spec.Operation
+	mediaType string
+	size      int
+	sum       [sha256.Size]byte
+}
+
+// validationCacheKey returns a cache key for the data of the media type
+// validated for the operation. The key includes data length to make hash
+// collisions even less likely to happen.
+func validationCacheKey(op *spec.Operation, mediaType string, data []byte) cacheKey {
+	return cacheKey{
+		op:        op,
+		mediaType: mediaType,
+		size:      len(data),
+		sum:       sha256.Sum256(data),
+	}
+}
+
+// get returns validation errors cached by key, if any.
+func (c *validationCache) get(key cacheKey) ([]error, bool) {
+	c.mu.Lock()
+	defer c.mu.Unlock()
+
+	el, ok := c.items[key]
+	if !ok {
+		return nil, false
+	}
+
+	entry := el.Value.(*validationCacheEntry)
+	if c.ttl > 0 && c.clock.Now().After(entry.expires) {
+		c.ll.Remove(el)
+		delete(c.items, key)
+		return nil, false
+	}
+
+	c.ll.MoveToFront(el)
+	return entry.errs, true
+}
+
+// add caches validation errors by key, evicting the least recently used
+// entry if the cache is full.
+func (c *validationCache) add(key cacheKey, errs []error) {
+	c.mu.Lock()
+	defer c.mu.Unlock()
+
+	entry := &validationCacheEntry{
+		key:     key,
+		errs:    errs,
+		expires: c.clock.Now().Add(c.ttl),
+	}
+
+	if el, ok := c.items[key]; ok {
+		el.Value = entry
+		c.ll.MoveToFront(el)
+		return
+	}
+
+	c.items[key] = c.ll.PushFront(entry)
+
+	if c.ll.Len() > c.size {
+		el := c.ll.Back()
+		c.ll.Remove(el)
+		delete(c.items, el.Value.(*validationCacheEntry).key)
+	}
+}
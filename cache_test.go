@@ -0,0 +1,70 @@
+package oas2
+
+import (
+	"fmt"
+	"reflect"
+	"testing"
+	"time"
+
+	"github.com/go-openapi/spec"
+)
+
+func TestValidationCache(t *testing.T) {
+	c := newValidationCache(2, time.Hour, realClock{})
+	op := &spec.Operation{}
+	key := func(data string) cacheKey {
+		return validationCacheKey(op, "application/json", []byte(data))
+	}
+
+	errs := []error{fmt.Errorf("name in body is required")}
+	c.add(key("a"), errs)
+	c.add(key("b"), nil)
+
+	if cached, ok := c.get(key("a")); !ok || !reflect.DeepEqual(errs, cached) {
+		t.Errorf("Expected %v to be cached but got %v", errs, cached)
+	}
+
+	// "b" is the least recently used, so it is evicted.
+	c.add(key("c"), nil)
+
+	if _, ok := c.get(key("b")); ok {
+		t.Error("Expected b to be evicted")
+	}
+	if _, ok := c.get(key("a")); !ok {
+		t.Error("Expected a to be cached")
+	}
+	if _, ok := c.get(key("c")); !ok {
+		t.Error("Expected c to be cached")
+	}
+}
+
+func TestValidationCache_ttl(t *testing.T) {
+	clock := &testClock{now: time.Date(2020, 1, 1, 0, 0, 0, 0, time.UTC)}
+	c := newValidationCache(2, time.Minute, clock)
+	key := validationCacheKey(&spec.Operation{}, "application/json", []byte("a"))
+
+	c.add(key, nil)
+
+	clock.now = clock.now.Add(time.Minute)
+	if _, ok := c.get(key); !ok {
+		t.Error("Expected a to be cached")
+	}
+
+	clock.now = clock.now.Add(time.Nanosecond)
+	if _, ok := c.get(key); ok {
+		t.Error("Expected a to be expired")
+	}
+}
+
+func TestValidationCacheKey(t *testing.T) {
+	op1, op2 := &spec.Operation{}, &spec.Operation{}
+	if validationCacheKey(op1, "application/json", []byte("{}")) == validationCacheKey(op2, "application/json", []byte("{}")) {
+		t.Error("Expected keys of different operations to differ")
+	}
+	if validationCacheKey(op1, "application/json", []byte("{}")) == validationCacheKey(op1, "application/xml", []byte("{}")) {
+		t.Error("Expected keys of different media types to differ")
+	}
+	if validationCacheKey(op1, "application/json", []byte("{}")) != validationCacheKey(op1, "application/json", []byte("{}")) {
+		t.Error("Expected keys of the same data to be equal")
+	}
+}
@@ -0,0 +1,91 @@
+// Package cbor provides support of application/cbor request bodies for oas2
+// body validator. Bodies are decoded to values of JSON types, so they are
+// validated against the body parameter schema like JSON bodies. It is a
+// separate package, so only users that need CBOR depend on the CBOR library.
+package cbor
+
+import (
+	"encoding/base64"
+	"fmt"
+	"io"
+	"io/ioutil"
+	"math"
+	"reflect"
+	"time"
+
+	"github.com/fxamacker/cbor/v2"
+
+	"github.com/hypnoglow/oas2"
+)
+
+// MediaType is the media type of CBOR bodies.
+const MediaType = "application/cbor"
+
+// DecoderOpt returns an option that makes oas2 body validator decode and
+// validate bodies of operations that consume application/cbor.
+func DecoderOpt() oas2.MiddlewareOption {
+	return oas2.BodyDecoderOpt(MediaType, Decode)
+}
+
+var decMode = func() cbor.DecMode {
+	dm, err := cbor.DecOptions{
+		DefaultMapType: reflect.TypeOf(map[string]interface{}(nil)),
+	}.DecMode()
+	if err != nil {
+		panic(err)
+	}
+	return dm
+}()
+
+// Decode decodes a CBOR data item from r to a value of JSON types. Maps must
+// have string keys. Byte strings are decoded as base64 strings and times as
+// RFC 3339 strings, as they are represented in JSON.
+func Decode(r io.Reader) (interface{}, error) {
+	b, err := ioutil.ReadAll(r)
+	if err != nil {
+		return nil, err
+	}
+
+	var v interface{}
+	if err := decMode.Unmarshal(b, &v); err != nil {
+		return nil, err
+	}
+	return jsonValue(v)
+}
+
+// jsonValue returns the decoded CBOR value as a value of JSON types.
+func jsonValue(v interface{}) (interface{}, error) {
+	switch v := v.(type) {
+	case nil, bool, string, int64, float64:
+		return v, nil
+	case uint64:
+		if v > math.MaxInt64 {
+			return float64(v), nil
+		}
+		return int64(v), nil
+	case []byte:
+		return base64.StdEncoding.EncodeToString(v), nil
+	case time.Time:
+		return v.Format(time.RFC3339Nano), nil
+	case []interface{}:
+		a := make([]interface{}, len(v))
+		for i, e := range v {
+			var err error
+			if a[i], err = jsonValue(e); err != nil {
+				return nil, err
+			}
+		}
+		return a, nil
+	case map[string]interface{}:
+		m := make(map[string]interface{}, len(v))
+		for k, e := range v {
+			var err error
+			if m[k], err = jsonValue(e); err != nil {
+				return nil, err
+			}
+		}
+		return m, nil
+	default:
+		return nil, fmt.Errorf("cbor: %T has no JSON representation", v)
+	}
+}
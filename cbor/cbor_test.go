@@ -0,0 +1,147 @@
+package cbor
+
+import (
+	"bytes"
+	"encoding/json"
+	"fmt"
+	"net/http"
+	"net/http/httptest"
+	"testing"
+
+	"github.com/fxamacker/cbor/v2"
+	"github.com/go-openapi/spec"
+
+	"github.com/hypnoglow/oas2"
+)
+
+const petSpec = `{
+  "swagger": "2.0",
+  "info": {"title": "test", "version": "1.0"},
+  "basePath": "/v1",
+  "paths": {
+    "/pets": {
+      "post": {
+        "operationId": "addPet",
+        "consumes": ["application/cbor"],
+        "parameters": [{
+          "name": "body",
+          "in": "body",
+          "required": true,
+          "schema": {
+            "type": "object",
+            "required": ["name", "age"],
+            "properties": {
+              "name": {"type": "string"},
+              "age": {"type": "integer", "minimum": 0},
+              "tags": {"type": "array", "items": {"type": "string"}},
+              "photo": {"type": "string", "format": "byte"}
+            }
+          }
+        }],
+        "responses": {"200": {"description": "ok"}}
+      }
+    }
+  }
+}`
+
+func TestDecoderOpt(t *testing.T) {
+	var sw spec.Swagger
+	if err := json.Unmarshal([]byte(petSpec), &sw); err != nil {
+		t.Fatal(err)
+	}
+
+	cases := []struct {
+		body           interface{}
+		expectedStatus int
+	}{
+		// valid body
+		{
+			body: map[string]interface{}{
+				"name":  "Kitty",
+				"age":   uint(3),
+				"tags":  []string{"cat"},
+				"photo": []byte{0xca, 0xfe},
+			},
+			expectedStatus: http.StatusOK,
+		},
+		// required property is missing
+		{
+			body:           map[string]interface{}{"name": "Kitty"},
+			expectedStatus: http.StatusBadRequest,
+		},
+		// property has wrong type
+		{
+			body:           map[string]interface{}{"name": "Kitty", "age": "three"},
+			expectedStatus: http.StatusBadRequest,
+		},
+		// minimum is violated
+		{
+			body:           map[string]interface{}{"name": "Kitty", "age": -1},
+			expectedStatus: http.StatusBadRequest,
+		},
+	}
+
+	handlers := oas2.OperationHandlers{"addPet": http.HandlerFunc(func(w http.ResponseWriter, req *http.Request) {
+		fmt.Fprint(w, "ok")
+	})}
+	errHandler := func(w http.ResponseWriter, errs []error) {
+		w.WriteHeader(http.StatusBadRequest)
+	}
+
+	router, err := oas2.NewRouter(&sw, handlers, oas2.MiddlewareOpt(oas2.NewBodyValidator(errHandler, DecoderOpt()).Apply))
+	if err != nil {
+		t.Fatal(err)
+	}
+
+	for _, c := range cases {
+		b, err := cbor.Marshal(c.body)
+		if err != nil {
+			t.Fatal(err)
+		}
+
+		req := httptest.NewRequest(http.MethodPost, "/v1/pets", bytes.NewReader(b))
+		req.Header.Set("Content-Type", MediaType)
+
+		w := httptest.NewRecorder()
+		router.ServeHTTP(w, req)
+
+		if w.Code != c.expectedStatus {
+			t.Errorf("Expected status for body %v to be %d but got %d", c.body, c.expectedStatus, w.Code)
+		}
+	}
+}
+
+func TestDecode(t *testing.T) {
+	b, err := cbor.Marshal(map[string]interface{}{
+		"n": uint64(42),
+		"b": []byte("hi"),
+		"a": []interface{}{int64(-1), 1.5, nil, true},
+	})
+	if err != nil {
+		t.Fatal(err)
+	}
+
+	v, err := Decode(bytes.NewReader(b))
+	if err != nil {
+		t.Fatal(err)
+	}
+
+	actual, err := json.Marshal(v)
+	if err != nil {
+		t.Fatal(err)
+	}
+
+	expected := `{"a":[-1,1.5,null,true],"b":"aGk=","n":42}`
+	if string(actual) != expected {
+		t.Errorf("Expected decoded body to be %s but got %s", expected, actual)
+	}
+
+	// Maps with non-string keys have no JSON representation.
+	b, err = cbor.Marshal(map[int]string{1: "one"})
+	if err != nil {
+		t.Fatal(err)
+	}
+	if _, err := Decode(bytes.NewReader(b)); err == nil {
+		t.Errorf("Expected error for map with integer keys but got nil")
+	}
+}
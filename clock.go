@@ -0,0 +1,25 @@
+package oas2
+
+import "time"
+
+// Clock tells the current time. Middlewares that depend on time use it, so
+// the time can be fixed, e.g. in tests.
+type Clock interface {
+	Now() time.Time
+}
+
+// realClock is the Clock of the system time.
+type realClock struct{}
+
+func (realClock) Now() time.Time {
+	return time.Now()
+}
+
+// ClockOpt returns an option that sets the clock middlewares tell the time
+// by, e.g. to expire cached validation results or to validate dates that
+// must be in the future. By default, the system time is used.
+func ClockOpt(clock Clock) MiddlewareOption {
+	return func(args *MiddlewareOptions) {
+		args.clock = clock
+	}
+}
@@ -0,0 +1,88 @@
+package oas2
+
+import (
+	"fmt"
+	"net/http"
+	"net/http/httptest"
+	"testing"
+	"time"
+)
+
+// testClock is a Clock that tells the time set by the test.
+type testClock struct {
+	now time.Time
+}
+
+func (c *testClock) Now() time.Time {
+	return c.now
+}
+
+func TestClockOpt(t *testing.T) {
+	sw := parseSpec(`
+swagger: "2.0"
+info:
+  title: test
+  version: "1.0"
+basePath: /v1
+paths:
+  /appointments:
+    post:
+      operationId: addAppointment
+      parameters:
+      - name: date
+        in: query
+        type: string
+        format: date
+        x-future: true
+      - name: at
+        in: query
+        type: string
+        format: date-time
+        x-future: true
+      responses:
+        200:
+          description: ok
+`)
+
+	cases := []struct {
+		query           string
+		expectedPayload string
+	}{
+		// dates in the future
+		{
+			query:           "date=2020-03-02&at=2020-03-01T12:00:01Z",
+			expectedPayload: "ok",
+		},
+		// dates now
+		{
+			query:           "at=2020-03-01T12:00:00Z",
+			expectedPayload: `{"errors":[{"message":"param at must be in the future","field":"at","value":"2020-03-01T12:00:00Z"}]}`,
+		},
+		// dates in the past
+		{
+			query:           "date=2020-03-01",
+			expectedPayload: `{"errors":[{"message":"param date must be in the future","field":"date","value":"2020-03-01"}]}`,
+		},
+	}
+
+	handlers := OperationHandlers{"addAppointment": http.HandlerFunc(func(w http.ResponseWriter, req *http.Request) {
+		fmt.Fprint(w, "ok")
+	})}
+
+	clock := &testClock{now: time.Date(2020, 3, 1, 12, 0, 0, 0, time.UTC)}
+	queryValidator := NewQueryValidator(writeErrorsToResponseWriter, ClockOpt(clock))
+
+	router, err := NewRouter(sw, handlers, MiddlewareOpt(queryValidator.Apply))
+	if err != nil {
+		t.Fatal(err)
+	}
+
+	for _, c := range cases {
+		w := httptest.NewRecorder()
+		router.ServeHTTP(w, httptest.NewRequest(http.MethodPost, "/v1/appointments?"+c.query, nil))
+
+		if c.expectedPayload != w.Body.String() {
+			t.Errorf("Expected response body for %s to be\n%s\nbut got\n%s", c.query, c.expectedPayload, w.Body.String())
+		}
+	}
+}
@@ -0,0 +1,461 @@
+package oas2
+
+import (
+	"fmt"
+	"sort"
+	"strings"
+
+	"github.com/go-openapi/spec"
+)
+
+// Change is a change of an API between two specs, see CompareSpecs.
+type Change struct {
+	// Operation names the changed operation by its id, or by its method and
+	// path if it has no id.
+	Operation string
+
+	// Message describes the change, e.g. "parameter limit in query removed".
+	Message string
+
+	// Breaking reports whether clients of the old API can fail with the new
+	// one.
+	Breaking bool
+}
+
+// String implements fmt.Stringer interface.
+func (c Change) String() string {
+	kind := "non-breaking"
+	if c.Breaking {
+		kind = "breaking"
+	}
+	return fmt.Sprintf("%s: operation %s: %s", kind, c.Operation, c.Message)
+}
+
+// CompareSpecs compares the old and the new specs and returns changes of
+// their operations, which are matched by method and path. Changes are
+// classified as breaking, e.g. removed operations, new required
+// parameters, constraints of requests tightened or constraints of responses
+// loosened, or non-breaking, e.g. new operations or optional parameters.
+// References are resolved before schemas are compared.
+func CompareSpecs(oldSpec, newSpec *spec.Swagger) []Change {
+	c := &specComparer{oldSpec: oldSpec, newSpec: newSpec}
+
+	forEachOperation(oldSpec, func(path, method string, oldPI spec.PathItem, oldOp *spec.Operation) {
+		c.op = operationName(method, path, oldOp)
+
+		var newPI spec.PathItem
+		var newOp *spec.Operation
+		if newSpec.Paths != nil {
+			newPI = newSpec.Paths.Paths[path]
+			newOp = pathItemOperation(newPI, method)
+		}
+		if newOp == nil {
+			c.add(true, "operation removed")
+			return
+		}
+		c.compareOperations(oldPI, oldOp, newPI, newOp)
+	})
+
+	forEachOperation(newSpec, func(path, method string, newPI spec.PathItem, newOp *spec.Operation) {
+		if oldSpec.Paths != nil {
+			if oldPI, ok := oldSpec.Paths.Paths[path]; ok && pathItemOperation(oldPI, method) != nil {
+				return
+			}
+		}
+		c.op = operationName(method, path, newOp)
+		c.add(false, "operation added")
+	})
+
+	return c.changes
+}
+
+// operationName returns the name of the operation in changes.
+func operationName(method, path string, op *spec.Operation) string {
+	if op.ID != "" {
+		return op.ID
+	}
+	return method + " " + path
+}
+
+// specComparer collects changes between the specs.
+type specComparer struct {
+	oldSpec, newSpec *spec.Swagger
+
+	// op is the name of the compared operation.
+	op      string
+	changes []Change
+}
+
+func (c *specComparer) add(breaking bool, format string, args ...interface{}) {
+	c.changes = append(c.changes, Change{
+		Operation: c.op,
+		Message:   fmt.Sprintf(format, args...),
+		Breaking:  breaking,
+	})
+}
+
+func (c *specComparer) compareOperations(oldPI spec.PathItem, oldOp *spec.Operation, newPI spec.PathItem, newOp *spec.Operation) {
+	oldEop, newEop := effectiveOperation(c.oldSpec, oldOp), effectiveOperation(c.newSpec, newOp)
+	c.compareMediaTypes("consumes", oldEop.Consumes, newEop.Consumes)
+	c.compareMediaTypes("produces", oldEop.Produces, newEop.Produces)
+
+	type key struct{ name, in string }
+
+	newParams := make(map[key]spec.Parameter)
+	for _, p := range effectiveParameters(c.newSpec, newPI, newOp) {
+		newParams[key{p.Name, p.In}] = p
+	}
+
+	oldParams := make(map[key]struct{})
+	for _, o := range effectiveParameters(c.oldSpec, oldPI, oldOp) {
+		oldParams[key{o.Name, o.In}] = struct{}{}
+		name := fmt.Sprintf("parameter %s in %s", o.Name, o.In)
+
+		n, ok := newParams[key{o.Name, o.In}]
+		if !ok {
+			// Clients do not have to send optional parameters.
+			c.add(o.Required, "%s removed", name)
+			continue
+		}
+		c.compareParams(name, o, n)
+	}
+
+	for _, n := range effectiveParameters(c.newSpec, newPI, newOp) {
+		if _, ok := oldParams[key{n.Name, n.In}]; ok {
+			continue
+		}
+		if n.Required {
+			c.add(true, "required parameter %s in %s added", n.Name, n.In)
+		} else {
+			c.add(false, "optional parameter %s in %s added", n.Name, n.In)
+		}
+	}
+
+	c.compareResponses(oldOp.Responses, newOp.Responses)
+}
+
+// compareMediaTypes compares media types the operation consumes or
+// produces. Clients can use any of them, so removing one is breaking.
+func (c *specComparer) compareMediaTypes(name string, oldTypes, newTypes []string) {
+	for _, mt := range oldTypes {
+		if !containsMediaType(newTypes, mt) {
+			c.add(true, "%s: media type %s removed", name, mt)
+		}
+	}
+	for _, mt := range newTypes {
+		if !containsMediaType(oldTypes, mt) {
+			c.add(false, "%s: media type %s added", name, mt)
+		}
+	}
+}
+
+func (c *specComparer) compareParams(name string, o, n spec.Parameter) {
+	if !o.Required && n.Required {
+		c.add(true, "%s became required", name)
+	}
+	if o.Required && !n.Required {
+		c.add(false, "%s became optional", name)
+	}
+
+	if o.In == "body" {
+		c.compareSchemas(name, "", c.expand(c.oldSpec, o.Schema), c.expand(c.newSpec, n.Schema), true)
+		return
+	}
+
+	if !c.compareTypes(name, o.Type, o.Format, n.Type, n.Format) {
+		return
+	}
+	if o.CollectionFormat != n.CollectionFormat {
+		c.add(true, "%s: collection format changed from %q to %q", name, o.CollectionFormat, n.CollectionFormat)
+	}
+	c.compareConstraints(name, spec.SchemaValidations{CommonValidations: o.CommonValidations}, spec.SchemaValidations{CommonValidations: n.CommonValidations}, true)
+	c.compareItems(name+": items", o.Items, n.Items)
+}
+
+func (c *specComparer) compareItems(name string, o, n *spec.Items) {
+	if o == nil || n == nil {
+		// Changed types of arrays are reported already.
+		return
+	}
+
+	if !c.compareTypes(name, o.Type, o.Format, n.Type, n.Format) {
+		return
+	}
+	if o.CollectionFormat != n.CollectionFormat {
+		c.add(true, "%s: collection format changed from %q to %q", name, o.CollectionFormat, n.CollectionFormat)
+	}
+	c.compareConstraints(name, spec.SchemaValidations{CommonValidations: o.CommonValidations}, spec.SchemaValidations{CommonValidations: n.CommonValidations}, true)
+	c.compareItems(name+": items", o.Items, n.Items)
+}
+
+// compareTypes reports changes of the type and the format, which are
+// breaking, and whether they are the same.
+func (c *specComparer) compareTypes(name, oldType, oldFormat, newType, newFormat string) bool {
+	if oldType != newType {
+		c.add(true, "%s: type changed from %s to %s", name, typeName(oldType), typeName(newType))
+		return false
+	}
+	if oldFormat != newFormat {
+		c.add(true, "%s: format changed from %s to %s", name, typeName(oldFormat), typeName(newFormat))
+		return false
+	}
+	return true
+}
+
+func typeName(typ string) string {
+	if typ == "" {
+		return "none"
+	}
+	return typ
+}
+
+func (c *specComparer) compareResponses(o, n *spec.Responses) {
+	if o == nil {
+		o = &spec.Responses{}
+	}
+	if n == nil {
+		n = &spec.Responses{}
+	}
+
+	c.compareResponse("default response", o.Default, n.Default)
+
+	statuses := make(map[int]struct{})
+	for status := range o.StatusCodeResponses {
+		statuses[status] = struct{}{}
+	}
+	for status := range n.StatusCodeResponses {
+		statuses[status] = struct{}{}
+	}
+	sorted := make([]int, 0, len(statuses))
+	for status := range statuses {
+		sorted = append(sorted, status)
+	}
+	sort.Ints(sorted)
+
+	for _, status := range sorted {
+		var or, nr *spec.Response
+		if r, ok := o.StatusCodeResponses[status]; ok {
+			or = &r
+		}
+		if r, ok := n.StatusCodeResponses[status]; ok {
+			nr = &r
+		}
+		c.compareResponse(fmt.Sprintf("response %d", status), or, nr)
+	}
+}
+
+func (c *specComparer) compareResponse(name string, o, n *spec.Response) {
+	switch {
+	case o == nil && n == nil:
+		return
+	case o == nil:
+		c.add(false, "%s added", name)
+	case n == nil:
+		c.add(true, "%s removed", name)
+	default:
+		c.compareSchemas(name, "", c.expand(c.oldSpec, o.Schema), c.expand(c.newSpec, n.Schema), false)
+	}
+}
+
+// expand returns the schema with references resolved against the spec, or
+// the schema itself if they cannot be resolved.
+func (c *specComparer) expand(sw *spec.Swagger, sch *spec.Schema) *spec.Schema {
+	if sch == nil {
+		return nil
+	}
+	expanded, err := expandSpecSchema(sw, sch)
+	if err != nil {
+		return sch
+	}
+	return expanded
+}
+
+// compareSchemas compares schemas of requests or responses at the field.
+// Requests accepted by the old schema must be accepted by the new one, and
+// responses valid by the new schema must be valid by the old one.
+func (c *specComparer) compareSchemas(prefix, field string, o, n *spec.Schema, request bool) {
+	name := prefix + ": schema" + fieldSuffix(field)
+	switch {
+	case o == nil && n == nil:
+		return
+	case o == nil:
+		c.add(request, "%s added", name)
+		return
+	case n == nil:
+		c.add(!request, "%s removed", name)
+		return
+	}
+
+	// Circular references stay unresolved.
+	if o.Ref.String() != "" || n.Ref.String() != "" {
+		if o.Ref.String() != n.Ref.String() {
+			c.add(true, "%s: reference changed from %q to %q", name, o.Ref.String(), n.Ref.String())
+		}
+		return
+	}
+
+	if !c.compareTypes(name, schemaTypeName(o), o.Format, schemaTypeName(n), n.Format) {
+		return
+	}
+	c.compareConstraints(name, o.Validations(), n.Validations(), request)
+
+	if schemaAllowsAdditional(o) && !schemaAllowsAdditional(n) {
+		c.add(request, "%s: additional properties forbidden", name)
+	}
+	if !schemaAllowsAdditional(o) && schemaAllowsAdditional(n) {
+		c.add(!request, "%s: additional properties allowed", name)
+	}
+
+	oldRequired, newRequired := stringSet(o.Required), stringSet(n.Required)
+	for _, prop := range n.Required {
+		if _, ok := oldRequired[prop]; !ok {
+			c.add(request, "%s: property %s became required", name, prop)
+		}
+	}
+	for _, prop := range o.Required {
+		if _, ok := newRequired[prop]; !ok {
+			c.add(!request, "%s: property %s became optional", name, prop)
+		}
+	}
+
+	for _, prop := range sortedProperties(o, n) {
+		op, inOld := o.Properties[prop]
+		np, inNew := n.Properties[prop]
+		switch {
+		case !inNew:
+			c.add(!request, "%s: property %s removed", name, prop)
+		case !inOld:
+			c.add(false, "%s: property %s added", name, prop)
+		default:
+			c.compareSchemas(prefix, joinField(field, prop), &op, &np, request)
+		}
+	}
+
+	if o.Items != nil && n.Items != nil && o.Items.Schema != nil && n.Items.Schema != nil {
+		c.compareSchemas(prefix, joinField(field, "items"), o.Items.Schema, n.Items.Schema, request)
+	}
+}
+
+func schemaTypeName(sch *spec.Schema) string {
+	types := append([]string(nil), sch.Type...)
+	sort.Strings(types)
+	return strings.Join(types, ",")
+}
+
+func schemaAllowsAdditional(sch *spec.Schema) bool {
+	return sch.AdditionalProperties == nil || sch.AdditionalProperties.Allows
+}
+
+func sortedProperties(schemas ...*spec.Schema) []string {
+	set := make(map[string]struct{})
+	for _, sch := range schemas {
+		for name := range sch.Properties {
+			set[name] = struct{}{}
+		}
+	}
+	names := make([]string, 0, len(set))
+	for name := range set {
+		names = append(names, name)
+	}
+	sort.Strings(names)
+	return names
+}
+
+func stringSet(ss []string) map[string]struct{} {
+	set := make(map[string]struct{}, len(ss))
+	for _, s := range ss {
+		set[s] = struct{}{}
+	}
+	return set
+}
+
+// compareConstraints reports constraint changes. Tightened constraints of
+// requests are breaking, as are loosened constraints of responses.
+func (c *specComparer) compareConstraints(name string, o, n spec.SchemaValidations, request bool) {
+	report := func(tightened bool, format string, args ...interface{}) {
+		c.add(tightened == request, "%s: %s", name, fmt.Sprintf(format, args...))
+	}
+
+	compareLimit := func(keyword string, o, n *float64, upper bool) {
+		switch {
+		case o == nil && n == nil:
+		case o == nil:
+			report(true, "%s %v added", keyword, *n)
+		case n == nil:
+			report(false, "%s %v removed", keyword, *o)
+		case *o != *n:
+			decreased := *n < *o
+			report(decreased == upper, "%s changed from %v to %v", keyword, *o, *n)
+		}
+	}
+	compareIntLimit := func(keyword string, o, n *int64, upper bool) {
+		compareLimit(keyword, intToFloat(o), intToFloat(n), upper)
+	}
+
+	compareLimit("maximum", o.Maximum, n.Maximum, true)
+	compareLimit("minimum", o.Minimum, n.Minimum, false)
+	if o.ExclusiveMaximum != n.ExclusiveMaximum {
+		report(n.ExclusiveMaximum, "exclusive maximum changed to %t", n.ExclusiveMaximum)
+	}
+	if o.ExclusiveMinimum != n.ExclusiveMinimum {
+		report(n.ExclusiveMinimum, "exclusive minimum changed to %t", n.ExclusiveMinimum)
+	}
+	compareIntLimit("maxLength", o.MaxLength, n.MaxLength, true)
+	compareIntLimit("minLength", o.MinLength, n.MinLength, false)
+	compareIntLimit("maxItems", o.MaxItems, n.MaxItems, true)
+	compareIntLimit("minItems", o.MinItems, n.MinItems, false)
+	compareIntLimit("maxProperties", o.MaxProperties, n.MaxProperties, true)
+	compareIntLimit("minProperties", o.MinProperties, n.MinProperties, false)
+
+	if o.UniqueItems != n.UniqueItems {
+		report(n.UniqueItems, "unique items changed to %t", n.UniqueItems)
+	}
+
+	// Values matching a pattern may not match another one.
+	switch {
+	case o.Pattern == n.Pattern:
+	case n.Pattern == "":
+		report(false, "pattern %q removed", o.Pattern)
+	case o.Pattern == "":
+		report(true, "pattern %q added", n.Pattern)
+	default:
+		report(true, "pattern changed from %q to %q", o.Pattern, n.Pattern)
+	}
+
+	switch {
+	case o.MultipleOf == nil && n.MultipleOf == nil:
+	case n.MultipleOf == nil:
+		report(false, "multipleOf %v removed", *o.MultipleOf)
+	case o.MultipleOf == nil:
+		report(true, "multipleOf %v added", *n.MultipleOf)
+	case *o.MultipleOf != *n.MultipleOf:
+		report(true, "multipleOf changed from %v to %v", *o.MultipleOf, *n.MultipleOf)
+	}
+
+	switch {
+	case len(o.Enum) == 0 && len(n.Enum) == 0:
+	case len(n.Enum) == 0:
+		report(false, "enum removed")
+	case len(o.Enum) == 0:
+		report(true, "enum added")
+	default:
+		for _, v := range o.Enum {
+			if !inEnum(v, n.Enum) {
+				report(true, "enum value %v removed", v)
+			}
+		}
+		for _, v := range n.Enum {
+			if !inEnum(v, o.Enum) {
+				report(false, "enum value %v added", v)
+			}
+		}
+	}
+}
+
+func intToFloat(v *int64) *float64 {
+	if v == nil {
+		return nil
+	}
+	f := float64(*v)
+	return &f
+}
@@ -0,0 +1,199 @@
+package oas2
+
+import (
+	"reflect"
+	"testing"
+)
+
+func TestCompareSpecs(t *testing.T) {
+	oldSpec := parseSpec(`
+swagger: "2.0"
+info:
+  title: test
+  version: "1.0"
+basePath: /v1
+consumes:
+- application/json
+paths:
+  /pets:
+    get:
+      operationId: findPets
+      parameters:
+      - name: limit
+        in: query
+        type: integer
+        maximum: 100
+      - name: name
+        in: query
+        type: string
+        maxLength: 10
+      - name: status
+        in: query
+        type: string
+        enum: [available, sold]
+      - name: tag
+        in: query
+        type: string
+      responses:
+        200:
+          description: ok
+          schema:
+            type: array
+            items:
+              $ref: "#/definitions/Pet"
+        404:
+          description: not found
+    post:
+      operationId: addPet
+      parameters:
+      - name: pet
+        in: body
+        required: true
+        schema:
+          $ref: "#/definitions/Pet"
+      responses:
+        201:
+          description: created
+  /pets/{id}:
+    delete:
+      operationId: deletePet
+      parameters:
+      - name: id
+        in: path
+        required: true
+        type: integer
+      responses:
+        204:
+          description: deleted
+definitions:
+  Pet:
+    type: object
+    required: [name]
+    properties:
+      name:
+        type: string
+      age:
+        type: integer
+`)
+
+	newSpec := parseSpec(`
+swagger: "2.0"
+info:
+  title: test
+  version: "2.0"
+basePath: /v1
+consumes:
+- application/json
+paths:
+  /pets:
+    get:
+      operationId: findPets
+      parameters:
+      - name: limit
+        in: query
+        type: integer
+        maximum: 50
+        required: true
+      - name: name
+        in: query
+        type: string
+        maxLength: 20
+      - name: status
+        in: query
+        type: string
+        enum: [available, pending]
+      - name: token
+        in: header
+        type: string
+        required: true
+      - name: sort
+        in: query
+        type: string
+      responses:
+        200:
+          description: ok
+          schema:
+            type: array
+            items:
+              $ref: "#/definitions/Pet"
+    post:
+      operationId: addPet
+      parameters:
+      - name: pet
+        in: body
+        required: true
+        schema:
+          $ref: "#/definitions/Pet"
+      responses:
+        201:
+          description: created
+  /pets/{id}:
+    get:
+      operationId: getPet
+      parameters:
+      - name: id
+        in: path
+        required: true
+        type: integer
+      responses:
+        200:
+          description: ok
+definitions:
+  Pet:
+    type: object
+    required: [name, kind]
+    properties:
+      name:
+        type: string
+      kind:
+        type: string
+`)
+
+	expected := []Change{
+		// request constraints
+		{Operation: "findPets", Message: "parameter limit in query became required", Breaking: true},
+		{Operation: "findPets", Message: "parameter limit in query: maximum changed from 100 to 50", Breaking: true},
+		{Operation: "findPets", Message: "parameter name in query: maxLength changed from 10 to 20", Breaking: false},
+		{Operation: "findPets", Message: "parameter status in query: enum value sold removed", Breaking: true},
+		{Operation: "findPets", Message: "parameter status in query: enum value pending added", Breaking: false},
+		// removed and added parameters
+		{Operation: "findPets", Message: "parameter tag in query removed", Breaking: false},
+		{Operation: "findPets", Message: "required parameter token in header added", Breaking: true},
+		{Operation: "findPets", Message: "optional parameter sort in query added", Breaking: false},
+		// response schemas, with references resolved
+		{Operation: "findPets", Message: "response 200: schema items: property kind became required", Breaking: false},
+		{Operation: "findPets", Message: "response 200: schema items: property age removed", Breaking: true},
+		{Operation: "findPets", Message: "response 200: schema items: property kind added", Breaking: false},
+		{Operation: "findPets", Message: "response 404 removed", Breaking: true},
+		// request schemas
+		{Operation: "addPet", Message: "parameter pet in body: schema: property kind became required", Breaking: true},
+		{Operation: "addPet", Message: "parameter pet in body: schema: property age removed", Breaking: false},
+		{Operation: "addPet", Message: "parameter pet in body: schema: property kind added", Breaking: false},
+		// operations
+		{Operation: "deletePet", Message: "operation removed", Breaking: true},
+		{Operation: "getPet", Message: "operation added", Breaking: false},
+	}
+
+	actual := CompareSpecs(oldSpec, newSpec)
+	if !reflect.DeepEqual(expected, actual) {
+		t.Errorf("Expected changes to be")
+		for _, c := range expected {
+			t.Errorf("  %s", c)
+		}
+		t.Errorf("but got")
+		for _, c := range actual {
+			t.Errorf("  %s", c)
+		}
+	}
+
+	if changes := CompareSpecs(oldSpec, oldSpec); len(changes) != 0 {
+		t.Errorf("Expected no changes of the same spec but got %v", changes)
+	}
+}
+
+func TestChange_String(t *testing.T) {
+	c := Change{Operation: "findPets", Message: "operation removed", Breaking: true}
+	if expected := "breaking: operation findPets: operation removed"; c.String() != expected {
+		t.Errorf("Expected change to be %q but got %q", expected, c.String())
+	}
+}
@@ -0,0 +1,115 @@
+package oas2
+
+import (
+	"bytes"
+	"compress/gzip"
+	"net/http"
+	"strconv"
+	"strings"
+)
+
+// DefaultCompressionMinSize is the default minimum size of response bodies
+// compressed by compression middleware.
+const DefaultCompressionMinSize = 1024
+
+// CompressionMinSizeOpt returns an option that sets the minimum size of
+// response bodies compressed by compression middleware, as compressing
+// small bodies saves little. By default, DefaultCompressionMinSize is used.
+func CompressionMinSizeOpt(size int) MiddlewareOption {
+	return func(args *MiddlewareOptions) {
+		args.compressionMinSize = size
+	}
+}
+
+// NewCompression returns new Middleware that compresses response bodies with
+// gzip if the request's Accept-Encoding header accepts it. The response is
+// buffered to be compressed, so responses exceeding the buffer limit, see
+// ResponseBufferLimitOpt, are sent uncompressed, as are small bodies, see
+// CompressionMinSizeOpt, and bodies of already compressed media types, e.g.
+// images.
+//
+// The middleware should be applied after response body validator, so the
+// validator gets uncompressed bodies.
+func NewCompression(options ...MiddlewareOption) Middleware {
+	return compression{
+		opts: newMiddlewareOptions(options),
+	}
+}
+
+type compression struct {
+	opts MiddlewareOptions
+}
+
+func (m compression) Apply(next http.Handler) http.Handler {
+	return http.HandlerFunc(func(w http.ResponseWriter, req *http.Request) {
+		if m.opts.operationResolver(req) == nil {
+			next.ServeHTTP(w, req)
+			return
+		}
+
+		// Responses differ by the header, so caches must not mix them up.
+		w.Header().Add("Vary", "Accept-Encoding")
+		if req.Method == http.MethodHead || !acceptsToken(req.Header.Get("Accept-Encoding"), "gzip") {
+			next.ServeHTTP(w, req)
+			return
+		}
+
+		br := newBufferedResponseRecorder(w, m.opts.responseBufferLimit)
+		next.ServeHTTP(br, req)
+		if br.Overflowed() {
+			// The payload is sent already.
+			return
+		}
+
+		payload := br.Payload()
+		if m.compresses(w.Header(), br.Status(), payload) {
+			var b bytes.Buffer
+			gw := gzip.NewWriter(&b)
+			gw.Write(payload)
+			gw.Close()
+
+			payload = b.Bytes()
+			w.Header().Set("Content-Encoding", "gzip")
+			w.Header().Set("Content-Length", strconv.Itoa(len(payload)))
+		}
+		br.flush(payload)
+	})
+}
+
+// compresses reports whether the response payload should be compressed.
+func (m compression) compresses(h http.Header, status int, payload []byte) bool {
+	if status == http.StatusNoContent || status == http.StatusNotModified || status == http.StatusPartialContent {
+		return false
+	}
+	if len(payload) == 0 || len(payload) < m.opts.compressionMinSize {
+		return false
+	}
+	if h.Get("Content-Encoding") != "" {
+		return false
+	}
+	return !isCompressedMediaType(parseMediaType(h.Get("Content-Type")))
+}
+
+// compressedMediaTypes lists media types that are compressed already.
+var compressedMediaTypes = map[string]bool{
+	"application/gzip":             true,
+	"application/x-gzip":           true,
+	"application/zip":              true,
+	"application/zstd":             true,
+	"application/x-bzip2":          true,
+	"application/x-7z-compressed":  true,
+	"application/x-rar-compressed": true,
+}
+
+// isCompressedMediaType reports whether the media type is compressed, so
+// compressing it again saves nothing.
+func isCompressedMediaType(mt string) bool {
+	if compressedMediaTypes[mt] {
+		return true
+	}
+	// Most images, audio and video are compressed, but not SVG images.
+	if strings.HasPrefix(mt, "image/") {
+		return mt != "image/svg+xml"
+	}
+	return strings.HasPrefix(mt, "audio/") || strings.HasPrefix(mt, "video/")
+}
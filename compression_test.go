@@ -0,0 +1,173 @@
+package oas2
+
+import (
+	"compress/gzip"
+	"fmt"
+	"io/ioutil"
+	"net/http"
+	"net/http/httptest"
+	"strings"
+	"testing"
+)
+
+func TestCompression_Apply(t *testing.T) {
+	sw := parseSpec(`
+swagger: "2.0"
+info:
+  title: test
+  version: "1.0"
+basePath: /v1
+produces:
+- application/json
+paths:
+  /pets:
+    get:
+      operationId: getPets
+      parameters:
+      - name: limit
+        in: query
+        type: integer
+      responses:
+        200:
+          description: ok
+          schema:
+            type: array
+            items:
+              type: object
+              required: [name]
+              properties:
+                name:
+                  type: string
+  /pets/photo:
+    get:
+      operationId: getPhoto
+      produces:
+      - image/png
+      responses:
+        200:
+          description: ok
+`)
+
+	pets := make([]string, 100)
+	for i := range pets {
+		pets[i] = fmt.Sprintf(`{"name":"pet%d"}`, i)
+	}
+
+	handlers := OperationHandlers{
+		"getPets": http.HandlerFunc(func(w http.ResponseWriter, req *http.Request) {
+			w.Header().Set("Content-Type", "application/json")
+			limit := len(pets)
+			if l, ok := GetQueryParam(req, "limit").(int64); ok {
+				limit = int(l)
+			}
+			fmt.Fprint(w, "["+strings.Join(pets[:limit], ",")+"]")
+		}),
+		"getPhoto": http.HandlerFunc(func(w http.ResponseWriter, req *http.Request) {
+			w.Header().Set("Content-Type", "image/png")
+			fmt.Fprint(w, strings.Repeat("x", 2048))
+		}),
+	}
+
+	var errs []error
+	responseValidator := NewResponseBodyValidator(func(w http.ResponseWriter, e []error) {
+		errs = append(errs, e...)
+	})
+
+	router, err := NewRouter(sw, handlers,
+		MiddlewareOpt(NewQueryValidator(writeErrorsToResponseWriter).Apply),
+		MiddlewareOpt(responseValidator.Apply),
+		MiddlewareOpt(NewCompression().Apply),
+	)
+	if err != nil {
+		t.Fatal(err)
+	}
+
+	cases := []struct {
+		url              string
+		acceptEncoding   string
+		expectedEncoding string
+		expectedPayload  string
+	}{
+		// gzip is accepted
+		{
+			url:              "/v1/pets",
+			acceptEncoding:   "gzip, deflate",
+			expectedEncoding: "gzip",
+			expectedPayload:  "[" + strings.Join(pets, ",") + "]",
+		},
+		{
+			url:              "/v1/pets",
+			acceptEncoding:   "br;q=1.0, *;q=0.5",
+			expectedEncoding: "gzip",
+			expectedPayload:  "[" + strings.Join(pets, ",") + "]",
+		},
+		// identity
+		{
+			url:             "/v1/pets",
+			expectedPayload: "[" + strings.Join(pets, ",") + "]",
+		},
+		{
+			url:             "/v1/pets",
+			acceptEncoding:  "identity",
+			expectedPayload: "[" + strings.Join(pets, ",") + "]",
+		},
+		{
+			url:             "/v1/pets",
+			acceptEncoding:  "*, gzip;q=0",
+			expectedPayload: "[" + strings.Join(pets, ",") + "]",
+		},
+		// small body
+		{
+			url:             "/v1/pets?limit=2",
+			acceptEncoding:  "gzip",
+			expectedPayload: `[{"name":"pet0"},{"name":"pet1"}]`,
+		},
+		// compressed media type
+		{
+			url:             "/v1/pets/photo",
+			acceptEncoding:  "gzip",
+			expectedPayload: strings.Repeat("x", 2048),
+		},
+	}
+
+	for _, c := range cases {
+		req := httptest.NewRequest(http.MethodGet, c.url, nil)
+		if c.acceptEncoding != "" {
+			req.Header.Set("Accept-Encoding", c.acceptEncoding)
+		}
+		w := httptest.NewRecorder()
+
+		router.ServeHTTP(w, req)
+
+		if actual := w.Header().Get("Content-Encoding"); actual != c.expectedEncoding {
+			t.Errorf("Expected Content-Encoding for %s with %q to be %q but got %q", c.url, c.acceptEncoding, c.expectedEncoding, actual)
+		}
+		if actual := w.Header().Get("Vary"); actual != "Accept-Encoding" {
+			t.Errorf("Expected Vary to be Accept-Encoding but got %q", actual)
+		}
+
+		payload := w.Body.String()
+		if c.expectedEncoding == "gzip" {
+			if expected := fmt.Sprint(w.Body.Len()); w.Header().Get("Content-Length") != expected {
+				t.Errorf("Expected Content-Length to be %s but got %s", expected, w.Header().Get("Content-Length"))
+			}
+			gr, err := gzip.NewReader(w.Body)
+			if err != nil {
+				t.Fatal(err)
+			}
+			b, err := ioutil.ReadAll(gr)
+			if err != nil {
+				t.Fatal(err)
+			}
+			payload = string(b)
+		}
+		if payload != c.expectedPayload {
+			t.Errorf("Expected response body for %s with %q to be\n%s\nbut got\n%s", c.url, c.acceptEncoding, c.expectedPayload, payload)
+		}
+	}
+
+	// The validator gets uncompressed bodies.
+	if len(errs) != 0 {
+		t.Errorf("Expected no response validation errors but got %v", errs)
+	}
+}
@@ -1,9 +1,19 @@
 package oas2
 
 import (
+	"encoding/base64"
+	"encoding/json"
 	"fmt"
+	"math"
+	"net/http"
+	"reflect"
+	"regexp"
 	"strconv"
 	"strings"
+	"time"
+	"unicode/utf8"
+
+	"github.com/go-openapi/spec"
 )
 
 // ConvertParameter converts parameter's value(s) according to parameter's type
@@ -11,8 +21,8 @@ import (
 // https://github.com/OAI/OpenAPI-Specification/blob/master/versions/2.0.md#parameterObject
 func ConvertParameter(vals []string, typ, format string) (value interface{}, err error) {
 	if typ == "array" {
-		// TODO
-		return nil, fmt.Errorf("type %s: NOT IMPLEMENTED", typ)
+		// Items are not known by the type and format.
+		return nil, fmt.Errorf("type %s: items are not declared, use ConvertArray", typ)
 	}
 
 	if typ == "file" {
@@ -30,6 +40,272 @@ func ConvertParameter(vals []string, typ, format string) (value interface{}, err
 	return ConvertPrimitive(vals[0], typ, format)
 }
 
+// convertParam converts parameter's value(s) according to the parameter spec.
+// Unlike ConvertParameter, it supports arrays of primitive items and unions
+// of types declared by x-types extension.
+// Numbers are parsed as formatted in the locale, if it is not nil.
+func convertParam(p spec.Parameter, vals []string, locale *NumberLocale) (interface{}, error) {
+	if types := paramTypes(p); len(types) > 0 {
+		return convertUnion(p, types, vals, locale)
+	}
+	return convertParamType(p, vals, locale)
+}
+
+// convertParamType is like convertParam but converts the values by the type
+// declared by the parameter only.
+func convertParamType(p spec.Parameter, vals []string, locale *NumberLocale) (interface{}, error) {
+	if p.Type == "boolean" && len(vals) == 1 {
+		if v, ok := convertListedBoolean(p.Extensions, vals[0]); ok {
+			return v, nil
+		}
+	}
+
+	if p.Type != "array" {
+		if locale == nil || p.Type == "file" || len(vals) != 1 {
+			return ConvertParameter(vals, p.Type, p.Format)
+		}
+		return ConvertPrimitiveLocale(vals[0], p.Type, p.Format, *locale)
+	}
+
+	if _, ok := p.Extensions[extItemsTuple]; ok {
+		return convertTuple(p, vals, locale)
+	}
+
+	return convertArrayValues(vals, p.CollectionFormat, p.Items, locale)
+}
+
+// ConvertArray converts value(s) of an array parameter according to the
+// collection format and items, which can be of any type and format
+// described in OAS 2.0, including nested arrays. Values of "multi"
+// collection format are the elements, otherwise the single value is split.
+func ConvertArray(vals []string, collectionFormat string, items *spec.Items) ([]interface{}, error) {
+	return convertArrayValues(vals, collectionFormat, items, nil)
+}
+
+// convertArrayValues is like ConvertArray but parses numbers as formatted
+// in the locale, if it is not nil.
+func convertArrayValues(vals []string, collectionFormat string, items *spec.Items, locale *NumberLocale) ([]interface{}, error) {
+	if items == nil {
+		return nil, fmt.Errorf("items of type array are not declared")
+	}
+
+	if collectionFormat == "multi" {
+		return convertItems(vals, items, locale)
+	}
+
+	if len(vals) != 1 {
+		return nil, fmt.Errorf(
+			"values count is %d, want 1",
+			len(vals),
+		)
+	}
+
+	return convertArray(vals[0], collectionFormat, items, locale)
+}
+
+// convertArray splits the value according to the collection format and
+// converts the elements according to items.
+func convertArray(val, collectionFormat string, items *spec.Items, locale *NumberLocale) ([]interface{}, error) {
+	elems, err := splitCollection(val, collectionFormat)
+	if err != nil {
+		return nil, err
+	}
+	return convertItems(elems, items, locale)
+}
+
+// splitCollection splits the value according to the collection format.
+func splitCollection(val, collectionFormat string) ([]string, error) {
+	if val == "" {
+		return []string{}, nil
+	}
+
+	var sep string
+	switch collectionFormat {
+	case "", "csv":
+		sep = ","
+	case "ssv":
+		sep = " "
+	case "tsv":
+		sep = "\t"
+	case "pipes":
+		sep = "|"
+	default:
+		return nil, fmt.Errorf(
+			"unknown collection format %s",
+			collectionFormat,
+		)
+	}
+
+	return strings.Split(val, sep), nil
+}
+
+// extTypes is a non-array parameter extension that lists types the value
+// may be of, for parameters migrated between types, e.g. identifiers that
+// are either integer ids or string slugs:
+//
+//	type: integer
+//	x-types: [integer, string]
+//
+// The types are tried in order and the first one the value converts to is
+// used. The format applies to the type declared by the parameter only.
+const extTypes = "x-types"
+
+// paramTypes returns types of the non-array parameter declared by x-types
+// extension.
+func paramTypes(p spec.Parameter) []string {
+	if p.Type == "array" {
+		return nil
+	}
+	types, _ := p.Extensions.GetStringSlice(extTypes)
+	return types
+}
+
+// convertUnion converts values of the parameter by the first of the types
+// they convert to. Otherwise, the error lists failures of all the types.
+func convertUnion(p spec.Parameter, types []string, vals []string, locale *NumberLocale) (interface{}, error) {
+	msgs := make([]string, 0, len(types))
+	for _, typ := range types {
+		if _, ok := primitiveTypes[typ]; !ok || typ == "array" {
+			msgs = append(msgs, fmt.Sprintf("%s: unsupported type", typ))
+			continue
+		}
+
+		tp := withType(p, typ)
+		value, err := convertParamType(tp, vals, locale)
+		if err == nil {
+			return value, nil
+		}
+		msgs = append(msgs, fmt.Sprintf("%s: %s", typ, err))
+	}
+	return nil, fmt.Errorf("value matches none of types %s: %s", strings.Join(types, ", "), strings.Join(msgs, "; "))
+}
+
+// withType returns the parameter of the type. Its format is kept if the type
+// is the declared one.
+func withType(p spec.Parameter, typ string) spec.Parameter {
+	if typ != p.Type {
+		p.Type = typ
+		p.Format = ""
+	}
+	return p
+}
+
+// withValueType returns the parameter of the type its converted value is
+// of, so the value is validated by the constraints of the matched type of
+// parameters declaring x-types. Other parameters are returned as is.
+func withValueType(p spec.Parameter, value interface{}) spec.Parameter {
+	if len(paramTypes(p)) == 0 {
+		return p
+	}
+
+	switch value.(type) {
+	case bool:
+		return withType(p, "boolean")
+	case int32, int64:
+		return withType(p, "integer")
+	case float32, float64:
+		return withType(p, "number")
+	default:
+		// Durations are strings of the declared format.
+		return withType(p, "string")
+	}
+}
+
+// extItemsTuple is an array parameter extension that lists types and
+// formats of the array items by position, for arrays whose positions have
+// different meanings, e.g. "?point=1.5,2":
+//
+//	x-items-tuple:
+//	- type: number
+//	- type: integer
+//	  format: int32
+//
+// Arrays of another length are not accepted. Items are not validated by
+// the parameter items spec.
+const extItemsTuple = "x-items-tuple"
+
+// itemsTuple returns items of the array parameter by position, declared by
+// x-items-tuple extension.
+func itemsTuple(p spec.Parameter) ([]spec.Items, error) {
+	b, err := json.Marshal(p.Extensions[extItemsTuple])
+	if err != nil {
+		return nil, fmt.Errorf("%s: %s", extItemsTuple, err)
+	}
+
+	var items []spec.Items
+	if err := json.Unmarshal(b, &items); err != nil || len(items) == 0 {
+		return nil, fmt.Errorf("%s must be a non-empty list of items", extItemsTuple)
+	}
+	return items, nil
+}
+
+// convertTuple converts values of the array parameter declared as a tuple
+// by x-items-tuple extension, each item by the items of its position.
+func convertTuple(p spec.Parameter, vals []string, locale *NumberLocale) ([]interface{}, error) {
+	items, err := itemsTuple(p)
+	if err != nil {
+		return nil, err
+	}
+
+	elems := vals
+	if p.CollectionFormat != "multi" {
+		if len(vals) != 1 {
+			return nil, fmt.Errorf(
+				"values count is %d, want 1",
+				len(vals),
+			)
+		}
+		if elems, err = splitCollection(vals[0], p.CollectionFormat); err != nil {
+			return nil, err
+		}
+	}
+
+	if len(elems) != len(items) {
+		return nil, fmt.Errorf("items count is %d, want %d", len(elems), len(items))
+	}
+
+	values := make([]interface{}, len(elems))
+	for i, elem := range elems {
+		v, err := convertItems([]string{elem}, &items[i], locale)
+		if err != nil {
+			return nil, fmt.Errorf("item %d: %s", i, err)
+		}
+		values[i] = v[0]
+	}
+	return values, nil
+}
+
+func convertItems(vals []string, items *spec.Items, locale *NumberLocale) ([]interface{}, error) {
+	if items.Ref.String() != "" {
+		return nil, fmt.Errorf("items reference %s is not resolved", items.Ref.String())
+	}
+
+	values := make([]interface{}, len(vals))
+	for i, val := range vals {
+		var err error
+		if items.Type == "boolean" {
+			if v, ok := convertListedBoolean(items.Extensions, val); ok {
+				values[i] = v
+				continue
+			}
+		}
+		if items.Type == "array" {
+			if items.Items == nil {
+				return nil, fmt.Errorf("items of type %s are not declared", items.Type)
+			}
+			values[i], err = convertArray(val, items.CollectionFormat, items.Items, locale)
+		} else if locale != nil {
+			values[i], err = ConvertPrimitiveLocale(val, items.Type, items.Format, *locale)
+		} else {
+			values[i], err = ConvertPrimitive(val, items.Type, items.Format)
+		}
+		if err != nil {
+			return nil, err
+		}
+	}
+	return values, nil
+}
+
 // ConvertPrimitive converts string values according to type and format described
 // in OAS 2.0.
 // https://github.com/OAI/OpenAPI-Specification/blob/master/versions/2.0.md#parameterObject
@@ -51,6 +327,222 @@ func ConvertPrimitive(val string, typ, format string) (value interface{}, err er
 	}
 }
 
+// FormatValue formats the value converted by ConvertParameter canonically,
+// e.g. "10" for integer converted from "010". Elements of arrays are joined
+// by commas.
+func FormatValue(value interface{}) string {
+	switch v := value.(type) {
+	case []interface{}:
+		elems := make([]string, len(v))
+		for i, e := range v {
+			elems[i] = FormatValue(e)
+		}
+		return strings.Join(elems, ",")
+	case float32:
+		return strconv.FormatFloat(float64(v), 'g', -1, 32)
+	case float64:
+		return strconv.FormatFloat(v, 'g', -1, 64)
+	case time.Time:
+		return v.Format(time.RFC3339Nano)
+	case []byte:
+		return base64.StdEncoding.EncodeToString(v)
+	default:
+		return fmt.Sprint(v)
+	}
+}
+
+// dateLayout is the layout of values of string parameters of date format,
+// full-date of RFC 3339.
+const dateLayout = "2006-01-02"
+
+// FormatParameter formats the value converted by ConvertParameter or
+// ConvertArray back to parameter's value(s) according to parameter's type,
+// format, collection format and items, e.g. to build request URLs. Elements
+// of arrays are formatted by their Go type, nested arrays by their items,
+// and joined by the collection format, or returned as values each for
+// "multi".
+func FormatParameter(value interface{}, typ, format, collectionFormat string, items *spec.Items) ([]string, error) {
+	if typ != "array" {
+		val, err := formatPrimitive(value, typ, format)
+		if err != nil {
+			return nil, err
+		}
+		return []string{val}, nil
+	}
+
+	vals, err := formatItems(value, items)
+	if err != nil {
+		return nil, err
+	}
+	if collectionFormat == "multi" {
+		return vals, nil
+	}
+
+	val, err := joinCollection(vals, collectionFormat)
+	if err != nil {
+		return nil, err
+	}
+	return []string{val}, nil
+}
+
+// formatItems formats elements of the array value according to items.
+func formatItems(value interface{}, items *spec.Items) ([]string, error) {
+	elems, ok := value.([]interface{})
+	if !ok {
+		return nil, fmt.Errorf("cannot format %T as array", value)
+	}
+	if items == nil {
+		return nil, fmt.Errorf("items of type array are not declared")
+	}
+
+	vals := make([]string, len(elems))
+	for i, e := range elems {
+		if items.Type != "array" {
+			vals[i] = FormatValue(e)
+			continue
+		}
+
+		nested, err := formatItems(e, items.Items)
+		if err != nil {
+			return nil, fmt.Errorf("item %d: %s", i, err)
+		}
+		if vals[i], err = joinCollection(nested, items.CollectionFormat); err != nil {
+			return nil, err
+		}
+	}
+	return vals, nil
+}
+
+// joinCollection joins the values according to the collection format, the
+// inverse of splitCollection.
+func joinCollection(vals []string, collectionFormat string) (string, error) {
+	var sep string
+	switch collectionFormat {
+	case "", "csv":
+		sep = ","
+	case "ssv":
+		sep = " "
+	case "tsv":
+		sep = "\t"
+	case "pipes":
+		sep = "|"
+	default:
+		return "", fmt.Errorf(
+			"unknown collection format %s",
+			collectionFormat,
+		)
+	}
+	return strings.Join(vals, sep), nil
+}
+
+// formatPrimitive formats the value converted by ConvertPrimitive according
+// to type and format. The value must be of the Go type ConvertPrimitive
+// converts to.
+func formatPrimitive(value interface{}, typ, format string) (string, error) {
+	zero := ZeroValue(typ, format)
+	if zero == nil {
+		return "", fmt.Errorf("unknown format %s for type %s", format, typ)
+	}
+	if reflect.TypeOf(value) != reflect.TypeOf(zero) {
+		return "", fmt.Errorf("cannot format %T as %s", value, typ)
+	}
+	return FormatValue(value), nil
+}
+
+// ZeroValue returns the zero value of the type and format, typed the same as
+// values converted by ConvertPrimitive, e.g. int64(0) for integer. Arrays
+// are nil []interface{}. It returns nil for unknown types and formats.
+func ZeroValue(typ, format string) interface{} {
+	switch typ {
+	case "string":
+		switch format {
+		case "duration":
+			return time.Duration(0)
+		case "", "password", "uri", "email", "hostname", "ipv4", "ipv6", "date", "date-time", "byte":
+			return ""
+		}
+	case "number":
+		switch format {
+		case "float":
+			return float32(0)
+		case "double", "":
+			return float64(0)
+		}
+	case "integer":
+		switch format {
+		case "int32":
+			return int32(0)
+		case "int64", "":
+			return int64(0)
+		}
+	case "boolean":
+		return false
+	case "array":
+		return []interface{}(nil)
+	}
+	return nil
+}
+
+// NumberLocale describes how numbers are formatted in a locale.
+type NumberLocale struct {
+	// Decimal is a decimal separator, e.g. ',' for "1,5".
+	Decimal rune
+
+	// Group is a separator of thousands groups, e.g. '.' for "1.000".
+	// Zero means that grouping is not allowed.
+	Group rune
+}
+
+// ConvertPrimitiveLocale is like ConvertPrimitive, but numbers and integers
+// are parsed as formatted in the locale. It is meant for clients that send
+// numbers formatted for humans, e.g. form posts.
+func ConvertPrimitiveLocale(val string, typ, format string, locale NumberLocale) (value interface{}, err error) {
+	if typ == "number" || typ == "integer" {
+		normalized, ok := locale.normalize(val)
+		if !ok {
+			return nil, fmt.Errorf("cannot convert %v to %s", val, typ)
+		}
+		val = normalized
+	}
+
+	return ConvertPrimitive(val, typ, format)
+}
+
+// normalize converts the number formatted in the locale to the form
+// accepted by strconv. Grouping is checked to be by thousands.
+func (l NumberLocale) normalize(val string) (string, bool) {
+	intPart, fracPart := val, ""
+	i := strings.IndexRune(val, l.Decimal)
+	if i >= 0 {
+		intPart, fracPart = val[:i], val[i+utf8.RuneLen(l.Decimal):]
+	}
+
+	sign := ""
+	if strings.HasPrefix(intPart, "-") || strings.HasPrefix(intPart, "+") {
+		sign, intPart = intPart[:1], intPart[1:]
+	}
+
+	if l.Group != 0 && strings.ContainsRune(intPart, l.Group) {
+		groups := strings.Split(intPart, string(l.Group))
+		for i, g := range groups {
+			if (i == 0 && (len(g) == 0 || len(g) > 3)) || (i > 0 && len(g) != 3) {
+				return "", false
+			}
+		}
+		intPart = strings.Join(groups, "")
+	}
+
+	if strings.ContainsAny(intPart+fracPart, ".,") || strings.ContainsRune(intPart+fracPart, l.Group) {
+		// Separators of other locales are not allowed.
+		return "", false
+	}
+
+	if i < 0 {
+		return sign + intPart, true
+	}
+	return sign + intPart + "." + fracPart, true
+}
+
 var evaluatesAsTrue = map[string]struct{}{
 	"true":     {},
 	"1":        {},
@@ -66,10 +558,33 @@ var evaluatesAsTrue = map[string]struct{}{
 
 func convertString(val, format string) (interface{}, error) {
 	switch format {
-	case "":
+	case "", "password":
+		// Password is an opaque string, it only hints that the value is
+		// sensitive.
+		return val, nil
+	case "uri", "email", "hostname", "ipv4", "ipv6":
+		// Values of standard formats are strings checked by validation.
+		return val, nil
+	case "date", "date-time":
+		// Dates are kept as strings, so they are passed on as sent.
+		if _, err := parseDate(val, format); err != nil {
+			return nil, fmt.Errorf("cannot convert %v to %s", val, format)
+		}
+		return val, nil
+	case "duration":
+		d, err := convertDuration(val)
+		if err != nil {
+			return nil, err
+		}
+		return d, nil
+	case "byte":
+		// Bytes are kept base64 encoded.
+		if _, err := base64.StdEncoding.DecodeString(val); err != nil {
+			return nil, fmt.Errorf("cannot convert %v to byte", val)
+		}
 		return val, nil
 	default:
-		// TODO: parse formats byte, binary, date, date-time
+		// TODO: parse format binary
 		return nil, fmt.Errorf(
 			"unknown format %s for type string",
 			format,
@@ -77,6 +592,75 @@ func convertString(val, format string) (interface{}, error) {
 	}
 }
 
+// parseDate parses the value of a string parameter of date or date-time
+// format.
+func parseDate(val, format string) (time.Time, error) {
+	if format == "date" {
+		return time.Parse(dateLayout, val)
+	}
+	return time.Parse(time.RFC3339, val)
+}
+
+// convertDuration converts the duration in Go syntax, e.g. "2h45m", or in
+// ISO 8601 syntax, e.g. "PT2H45M".
+func convertDuration(val string) (time.Duration, error) {
+	if d, err := time.ParseDuration(val); err == nil {
+		return d, nil
+	}
+	if d, ok := parseISODuration(val); ok {
+		return d, nil
+	}
+	return 0, fmt.Errorf("cannot convert %v to duration, want e.g. 2h45m or PT2H45M", val)
+}
+
+// isoDuration matches ISO 8601 durations of weeks, days, hours, minutes and
+// seconds. Years and months are not supported, as their length varies.
+var isoDuration = regexp.MustCompile(`^([-+]?)P(?:(\d+)W)?(?:(\d+)D)?(?:T(?:(\d+)H)?(?:(\d+)M)?(?:(\d+(?:[.,]\d+)?)S)?)?$`)
+
+// parseISODuration parses the duration in ISO 8601 syntax.
+func parseISODuration(val string) (time.Duration, bool) {
+	m := isoDuration.FindStringSubmatch(val)
+	if m == nil || strings.HasSuffix(val, "P") || strings.HasSuffix(val, "T") {
+		// At least one component is required.
+		return 0, false
+	}
+
+	units := []time.Duration{7 * 24 * time.Hour, 24 * time.Hour, time.Hour, time.Minute, time.Second}
+	var d time.Duration
+	for i, unit := range units {
+		component := strings.Replace(m[i+2], ",", ".", 1)
+		if component == "" {
+			continue
+		}
+		n, err := strconv.ParseFloat(component, 64)
+		if err != nil {
+			return 0, false
+		}
+		// Durations that overflow are rejected, like time.ParseDuration
+		// does.
+		v := n * float64(unit)
+		if v >= math.MaxInt64 || time.Duration(v) > math.MaxInt64-d {
+			return 0, false
+		}
+		d += time.Duration(v)
+	}
+
+	if m[1] == "-" {
+		d = -d
+	}
+	return d, true
+}
+
+// convertHTTPDate converts the value of a header like If-Unmodified-Since,
+// formatted as HTTP-date.
+func convertHTTPDate(val string) (time.Time, error) {
+	t, err := http.ParseTime(val)
+	if err != nil {
+		return time.Time{}, fmt.Errorf("cannot convert %v to HTTP date", val)
+	}
+	return t, nil
+}
+
 func convertInteger(val, format string) (interface{}, error) {
 	switch format {
 	case "int32":
@@ -119,12 +703,72 @@ func convertNumber(val, format string) (interface{}, error) {
 		return f, nil
 	default:
 		return nil, fmt.Errorf(
-			"unknown format %s for type integer",
+			"unknown format %s for type number",
 			format,
 		)
 	}
 }
 
+// withKnownFormats returns a copy of the parameter with formats of the
+// parameter and its items that are unknown to oas2 dropped, so values are
+// converted as of the base type.
+func withKnownFormats(p spec.Parameter) spec.Parameter {
+	p.Format = knownFormat(p.Type, p.Format)
+
+	var items *spec.Items
+	for from, to := p.Items, &items; from != nil; from, to = from.Items, &(*to).Items {
+		c := *from
+		c.Format = knownFormat(c.Type, c.Format)
+		*to = &c
+	}
+	p.Items = items
+
+	return p
+}
+
+// knownFormat returns the format, or an empty one if the format of the
+// primitive type is unknown.
+func knownFormat(typ, format string) string {
+	switch typ {
+	case "string", "number", "integer":
+		if ZeroValue(typ, format) == nil {
+			return ""
+		}
+	}
+	return format
+}
+
+const (
+	// extTrueValues is a parameter and items extension that lists values
+	// recognized as true for the boolean parameter or items.
+	extTrueValues = "x-true-values"
+
+	// extFalseValues is a parameter and items extension that lists values
+	// recognized as false for the boolean parameter or items.
+	extFalseValues = "x-false-values"
+)
+
+// convertListedBoolean converts the boolean value by the values listed in
+// the extensions of the parameter or items, case-insensitive. It returns
+// false ok if the value is not listed.
+func convertListedBoolean(extensions spec.Extensions, val string) (value interface{}, ok bool) {
+	for _, ext := range []struct {
+		key   string
+		value bool
+	}{
+		{extTrueValues, true},
+		{extFalseValues, false},
+	} {
+		listed, _ := extensions.GetStringSlice(ext.key)
+		for _, l := range listed {
+			if strings.EqualFold(l, val) {
+				return ext.value, true
+			}
+		}
+	}
+	return nil, false
+}
+
 func convertBoolean(val string) (interface{}, error) {
 	_, ok := evaluatesAsTrue[strings.ToLower(val)]
 	return ok, nil
@@ -1,8 +1,13 @@
 package oas2
 
 import (
+	"net/url"
 	"reflect"
 	"testing"
+	"time"
+
+	"github.com/go-openapi/spec"
+	"github.com/go-openapi/swag"
 )
 
 func TestConvertParameter(t *testing.T) {
@@ -61,6 +66,507 @@ func TestConvertParameter(t *testing.T) {
 	}
 }
 
+func TestConvertParam_array(t *testing.T) {
+	integers := &spec.Items{SimpleSchema: spec.SimpleSchema{Type: "integer"}}
+
+	cases := []struct {
+		collectionFormat string
+		items            *spec.Items
+		values           []string
+		expectedValue    interface{}
+		expectError      bool
+	}{
+		// csv by default
+		{
+			items:         integers,
+			values:        []string{"1,2,3"},
+			expectedValue: []interface{}{int64(1), int64(2), int64(3)},
+		},
+		{
+			collectionFormat: "pipes",
+			items:            integers,
+			values:           []string{"1|2"},
+			expectedValue:    []interface{}{int64(1), int64(2)},
+		},
+		{
+			collectionFormat: "multi",
+			items:            integers,
+			values:           []string{"1", "2"},
+			expectedValue:    []interface{}{int64(1), int64(2)},
+		},
+		// nested arrays
+		{
+			collectionFormat: "pipes",
+			items: &spec.Items{
+				SimpleSchema: spec.SimpleSchema{Type: "array", Items: integers},
+			},
+			values: []string{"1,2|3"},
+			expectedValue: []interface{}{
+				[]interface{}{int64(1), int64(2)},
+				[]interface{}{int64(3)},
+			},
+		},
+		// element is not convertible
+		{
+			items:       integers,
+			values:      []string{"1,two"},
+			expectError: true,
+		},
+		// unresolved reference
+		{
+			items: &spec.Items{
+				Refable: spec.Refable{Ref: spec.MustCreateRef("#/definitions/ID")},
+			},
+			values:      []string{"1"},
+			expectError: true,
+		},
+	}
+
+	for _, c := range cases {
+		p := spec.Parameter{
+			SimpleSchema: spec.SimpleSchema{
+				Type:             "array",
+				Items:            c.items,
+				CollectionFormat: c.collectionFormat,
+			},
+		}
+
+		v, err := convertParam(p, c.values, nil)
+
+		if err != nil && !c.expectError {
+			t.Errorf("Unexpected error: %v", err)
+		}
+		if err == nil && c.expectError {
+			t.Error("Expected error, but got nil")
+		}
+
+		if !c.expectError && !reflect.DeepEqual(c.expectedValue, v) {
+			t.Errorf("Expected value to be %#v but got %#v", c.expectedValue, v)
+		}
+	}
+}
+
+func TestConvertArray(t *testing.T) {
+	items := func(typ, format string) *spec.Items {
+		return &spec.Items{SimpleSchema: spec.SimpleSchema{Type: typ, Format: format}}
+	}
+
+	flags := items("boolean", "")
+	flags.Extensions = spec.Extensions{"x-false-values": []interface{}{"off", "yes"}}
+
+	cases := []struct {
+		collectionFormat string
+		items            *spec.Items
+		values           []string
+		expectedValue    interface{}
+		expectError      bool
+	}{
+		// boolean items
+		{
+			items:         items("boolean", ""),
+			values:        []string{"true,false,1"},
+			expectedValue: []interface{}{true, false, true},
+		},
+		{
+			collectionFormat: "multi",
+			items:            items("boolean", ""),
+			values:           []string{"yes", "0"},
+			expectedValue:    []interface{}{true, false},
+		},
+		// values listed in the items extensions
+		{
+			items:         flags,
+			values:        []string{"on,off,yes"},
+			expectedValue: []interface{}{true, false, false},
+		},
+		// integer items
+		{
+			items:         items("integer", "int32"),
+			values:        []string{"1,-2"},
+			expectedValue: []interface{}{int32(1), int32(-2)},
+		},
+		{
+			items:       items("integer", "int32"),
+			values:      []string{"1,1.5"},
+			expectError: true,
+		},
+		// number items
+		{
+			collectionFormat: "ssv",
+			items:            items("number", "float"),
+			values:           []string{"1.5 2"},
+			expectedValue:    []interface{}{float32(1.5), float32(2)},
+		},
+		{
+			items:         items("number", ""),
+			values:        []string{"0.25,1e3"},
+			expectedValue: []interface{}{float64(0.25), float64(1000)},
+		},
+		// string items of a format
+		{
+			collectionFormat: "pipes",
+			items:            items("string", "date"),
+			values:           []string{"2018-03-01|2018-03-02"},
+			expectedValue:    []interface{}{"2018-03-01", "2018-03-02"},
+		},
+		// a single value is expected unless collection format is multi
+		{
+			items:       items("string", ""),
+			values:      []string{"a", "b"},
+			expectError: true,
+		},
+		// items are not declared
+		{
+			values:      []string{"a"},
+			expectError: true,
+		},
+	}
+
+	for _, c := range cases {
+		v, err := ConvertArray(c.values, c.collectionFormat, c.items)
+
+		if err != nil && !c.expectError {
+			t.Errorf("Unexpected error for %v: %v", c.values, err)
+		}
+		if err == nil && c.expectError {
+			t.Errorf("Expected error for %v, but got nil", c.values)
+		}
+
+		if !c.expectError && !reflect.DeepEqual(c.expectedValue, v) {
+			t.Errorf("Expected value of %v to be %#v but got %#v", c.values, c.expectedValue, v)
+		}
+	}
+}
+
+func TestConvertParam_tuple(t *testing.T) {
+	tuple := []interface{}{
+		map[string]interface{}{"type": "number"},
+		map[string]interface{}{"type": "integer", "format": "int32"},
+	}
+
+	cases := []struct {
+		collectionFormat string
+		values           []string
+		expectedValue    interface{}
+		expectedError    string
+	}{
+		{
+			values:        []string{"1.5,2"},
+			expectedValue: []interface{}{float64(1.5), int32(2)},
+		},
+		{
+			collectionFormat: "multi",
+			values:           []string{"1.5", "2"},
+			expectedValue:    []interface{}{float64(1.5), int32(2)},
+		},
+		// wrong length
+		{
+			values:        []string{"1.5"},
+			expectedError: "items count is 1, want 2",
+		},
+		{
+			values:        []string{"1.5,2,3"},
+			expectedError: "items count is 3, want 2",
+		},
+		// item is not convertible
+		{
+			values:        []string{"1.5,2.5"},
+			expectedError: "item 1: cannot convert 2.5 to int32",
+		},
+	}
+
+	for _, c := range cases {
+		p := spec.Parameter{
+			VendorExtensible: spec.VendorExtensible{
+				Extensions: spec.Extensions{extItemsTuple: tuple},
+			},
+			SimpleSchema: spec.SimpleSchema{
+				Type:             "array",
+				Items:            &spec.Items{SimpleSchema: spec.SimpleSchema{Type: "string"}},
+				CollectionFormat: c.collectionFormat,
+			},
+		}
+
+		v, err := convertParam(p, c.values, nil)
+
+		if c.expectedError != "" {
+			if err == nil || err.Error() != c.expectedError {
+				t.Errorf("Expected error to be %q but got %v", c.expectedError, err)
+			}
+			continue
+		}
+		if err != nil {
+			t.Errorf("Unexpected error: %v", err)
+		}
+		if !reflect.DeepEqual(c.expectedValue, v) {
+			t.Errorf("Expected value to be %#v but got %#v", c.expectedValue, v)
+		}
+	}
+}
+
+func TestConvertParam_booleanExtensions(t *testing.T) {
+	p := spec.Parameter{
+		VendorExtensible: spec.VendorExtensible{
+			Extensions: spec.Extensions{
+				"x-true-values":  []interface{}{"Y"},
+				"x-false-values": []interface{}{"N", "yes"},
+			},
+		},
+		SimpleSchema: spec.SimpleSchema{
+			Type: "boolean",
+		},
+	}
+
+	cases := []struct {
+		value         string
+		expectedValue interface{}
+	}{
+		// listed in the extensions, case-insensitive
+		{value: "Y", expectedValue: true},
+		{value: "y", expectedValue: true},
+		{value: "n", expectedValue: false},
+		// the extension overrides the default set
+		{value: "yes", expectedValue: false},
+		// not listed, the default set is used
+		{value: "true", expectedValue: true},
+		{value: "nope", expectedValue: false},
+	}
+
+	for _, c := range cases {
+		v, err := convertParam(p, []string{c.value}, nil)
+		if err != nil {
+			t.Errorf("Unexpected error: %v", err)
+		}
+		if !reflect.DeepEqual(c.expectedValue, v) {
+			t.Errorf("Expected value of %q to be %v but got %v", c.value, c.expectedValue, v)
+		}
+	}
+}
+
+func TestConvertParam_types(t *testing.T) {
+	cases := []struct {
+		types         []interface{}
+		value         string
+		expectedValue interface{}
+		expectedError string
+	}{
+		// the first convertible type is used
+		{
+			types:         []interface{}{"integer", "string"},
+			value:         "12",
+			expectedValue: int64(12),
+		},
+		{
+			types:         []interface{}{"integer", "string"},
+			value:         "kitty-12",
+			expectedValue: "kitty-12",
+		},
+		{
+			types:         []interface{}{"string", "integer"},
+			value:         "12",
+			expectedValue: "12",
+		},
+		// none of the types is convertible
+		{
+			types:         []interface{}{"integer", "number", "file"},
+			value:         "kitty",
+			expectedError: "value matches none of types integer, number, file: integer: cannot convert kitty to int64; number: cannot convert kitty to double; file: unsupported type",
+		},
+	}
+
+	for _, c := range cases {
+		p := spec.Parameter{
+			VendorExtensible: spec.VendorExtensible{
+				Extensions: spec.Extensions{extTypes: c.types},
+			},
+			SimpleSchema: spec.SimpleSchema{
+				Type: "integer",
+			},
+		}
+
+		v, err := convertParam(p, []string{c.value}, nil)
+
+		if c.expectedError != "" {
+			if err == nil || err.Error() != c.expectedError {
+				t.Errorf("Expected error to be %q but got %v", c.expectedError, err)
+			}
+			continue
+		}
+		if err != nil {
+			t.Errorf("Unexpected error: %v", err)
+		}
+		if !reflect.DeepEqual(c.expectedValue, v) {
+			t.Errorf("Expected value of %q to be %#v but got %#v", c.value, c.expectedValue, v)
+		}
+	}
+
+	// Values are validated by the constraints of the matched type.
+	ps := []spec.Parameter{{
+		ParamProps: spec.ParamProps{Name: "id", In: "query"},
+		VendorExtensible: spec.VendorExtensible{
+			Extensions: spec.Extensions{extTypes: []interface{}{"integer", "string"}},
+		},
+		SimpleSchema: spec.SimpleSchema{Type: "integer"},
+		CommonValidations: spec.CommonValidations{
+			Minimum:   swag.Float64(1),
+			MaxLength: swag.Int64(5),
+		},
+	}}
+
+	for value, expectedErrors := range map[string][]error{
+		"12":     nil,
+		"kitty":  nil,
+		"0":      {ValidationErrorf("id", int64(0), "id in query should be greater than or equal to 1")},
+		"kitten": {ValidationErrorf("id", "kitten", "id in query should be at most 5 chars long")},
+	} {
+		errs := ValidateQuery(ps, url.Values{"id": {value}})
+		if !reflect.DeepEqual(expectedErrors, errs) {
+			t.Errorf("Expected errors of %q to be %v but got %v", value, expectedErrors, errs)
+		}
+	}
+}
+
+func TestZeroValue(t *testing.T) {
+	cases := []struct {
+		typ           string
+		format        string
+		expectedValue interface{}
+	}{
+		{typ: "string", expectedValue: ""},
+		{typ: "string", format: "email", expectedValue: ""},
+		{typ: "string", format: "password", expectedValue: ""},
+		{typ: "string", format: "date", expectedValue: ""},
+		{typ: "string", format: "date-time", expectedValue: ""},
+		{typ: "string", format: "byte", expectedValue: ""},
+		{typ: "string", format: "duration", expectedValue: time.Duration(0)},
+		{typ: "integer", expectedValue: int64(0)},
+		{typ: "integer", format: "int32", expectedValue: int32(0)},
+		{typ: "integer", format: "int64", expectedValue: int64(0)},
+		{typ: "number", expectedValue: float64(0)},
+		{typ: "number", format: "float", expectedValue: float32(0)},
+		{typ: "number", format: "double", expectedValue: float64(0)},
+		{typ: "boolean", expectedValue: false},
+		{typ: "array", expectedValue: []interface{}(nil)},
+		{typ: "integer", format: "uuid", expectedValue: nil},
+		{typ: "file", expectedValue: nil},
+	}
+
+	for _, c := range cases {
+		v := ZeroValue(c.typ, c.format)
+		if !reflect.DeepEqual(c.expectedValue, v) {
+			t.Errorf("Expected zero value of %s %s to be %#v but got %#v", c.typ, c.format, c.expectedValue, v)
+		}
+	}
+
+	// Zero values are typed as converted values.
+	for _, typ := range []string{"string", "integer", "number", "boolean"} {
+		v, err := ConvertPrimitive("1", typ, "")
+		if err != nil {
+			t.Fatalf("Unexpected error: %v", err)
+		}
+		if reflect.TypeOf(v) != reflect.TypeOf(ZeroValue(typ, "")) {
+			t.Errorf("Expected zero value of %s to be %T but got %T", typ, v, ZeroValue(typ, ""))
+		}
+	}
+}
+
+func TestConvertPrimitiveLocale(t *testing.T) {
+	de := NumberLocale{Decimal: ',', Group: '.'}
+	en := NumberLocale{Decimal: '.', Group: ','}
+
+	cases := []struct {
+		value         string
+		typ           string
+		format        string
+		locale        NumberLocale
+		expectedValue interface{}
+		expectError   bool
+	}{
+		// comma decimal separator
+		{
+			value:         "1,5",
+			typ:           "number",
+			locale:        de,
+			expectedValue: float64(1.5),
+		},
+		// grouped thousands
+		{
+			value:         "-1.234.567,25",
+			typ:           "number",
+			locale:        de,
+			expectedValue: float64(-1234567.25),
+		},
+		{
+			value:         "1,234,567",
+			typ:           "integer",
+			locale:        en,
+			expectedValue: int64(1234567),
+		},
+		{
+			value:         "1.000",
+			typ:           "integer",
+			format:        "int32",
+			locale:        de,
+			expectedValue: int32(1000),
+		},
+		// decimal separator of another locale
+		{
+			value:       "1.5",
+			typ:         "number",
+			locale:      de,
+			expectError: true,
+		},
+		// groups are not by thousands
+		{
+			value:       "12.34",
+			typ:         "integer",
+			locale:      de,
+			expectError: true,
+		},
+		// grouping is not allowed
+		{
+			value:       "1.000",
+			typ:         "integer",
+			locale:      NumberLocale{Decimal: ','},
+			expectError: true,
+		},
+		// integer with fraction
+		{
+			value:       "1,5",
+			typ:         "integer",
+			locale:      de,
+			expectError: true,
+		},
+		// strings are not affected
+		{
+			value:         "1,5",
+			typ:           "string",
+			locale:        de,
+			expectedValue: "1,5",
+		},
+	}
+
+	for _, c := range cases {
+		v, err := ConvertPrimitiveLocale(c.value, c.typ, c.format, c.locale)
+
+		if err != nil && !c.expectError {
+			t.Errorf("Unexpected error: %v", err)
+		}
+		if err == nil && c.expectError {
+			t.Errorf("Expected error for %q, but got nil", c.value)
+		}
+
+		if !reflect.DeepEqual(c.expectedValue, v) {
+			t.Errorf("Expected value to be %v (%T) but got %v (%T)", c.expectedValue, c.expectedValue, v, v)
+		}
+	}
+
+	// Default conversion stays strict.
+	if _, err := ConvertPrimitive("1,5", "number", ""); err == nil {
+		t.Errorf("Expected error for strict conversion, but got nil")
+	}
+}
+
 func TestConvertPrimitive(t *testing.T) {
 	cases := []struct {
 		value         string
@@ -75,6 +581,12 @@ func TestConvertPrimitive(t *testing.T) {
 			format:        "",
 			expectedValue: "Igor",
 		},
+		{
+			value:         "s3cr3t",
+			typ:           "string",
+			format:        "password",
+			expectedValue: "s3cr3t",
+		},
 		{
 			value:         "123",
 			typ:           "integer",
@@ -119,6 +631,96 @@ func TestConvertPrimitive(t *testing.T) {
 			typ:           "boolean",
 			expectedValue: false,
 		},
+		{
+			value:         "2018-03-01",
+			typ:           "string",
+			format:        "date",
+			expectedValue: "2018-03-01",
+		},
+		{
+			value:         "2018-03-01T10:00:00Z",
+			typ:           "string",
+			format:        "date-time",
+			expectedValue: "2018-03-01T10:00:00Z",
+		},
+		{
+			value:         "aGVsbG8=",
+			typ:           "string",
+			format:        "byte",
+			expectedValue: "aGVsbG8=",
+		},
+		{
+			value:         "300ms",
+			typ:           "string",
+			format:        "duration",
+			expectedValue: 300 * time.Millisecond,
+		},
+		{
+			value:         "2h45m",
+			typ:           "string",
+			format:        "duration",
+			expectedValue: 2*time.Hour + 45*time.Minute,
+		},
+		{
+			value:         "PT1H30M",
+			typ:           "string",
+			format:        "duration",
+			expectedValue: time.Hour + 30*time.Minute,
+		},
+		{
+			value:         "P1W2DT0,5S",
+			typ:           "string",
+			format:        "duration",
+			expectedValue: 9*24*time.Hour + 500*time.Millisecond,
+		},
+		{
+			value:         "-PT15M",
+			typ:           "string",
+			format:        "duration",
+			expectedValue: -15 * time.Minute,
+		},
+		{
+			// years are not supported
+			value:       "P1Y",
+			typ:         "string",
+			format:      "duration",
+			expectError: true,
+		},
+		{
+			// overflows
+			value:       "P99999999999W",
+			typ:         "string",
+			format:      "duration",
+			expectError: true,
+		},
+		{
+			// overflows in total
+			value:       "P15000WT2000000H",
+			typ:         "string",
+			format:      "duration",
+			expectError: true,
+		},
+		{
+			// no components
+			value:       "PT",
+			typ:         "string",
+			format:      "duration",
+			expectError: true,
+		},
+		{
+			// wrong value for string duration
+			value:       "soon",
+			typ:         "string",
+			format:      "duration",
+			expectError: true,
+		},
+		{
+			// wrong value for string date
+			value:       "01.03.2018",
+			typ:         "string",
+			format:      "date",
+			expectError: true,
+		},
 		{
 			// unknown string format
 			value:       "some",
@@ -196,3 +798,153 @@ func TestConvertPrimitive(t *testing.T) {
 		}
 	}
 }
+
+func TestFormatValue(t *testing.T) {
+	cases := []struct {
+		value    interface{}
+		expected string
+	}{
+		{value: "john", expected: "john"},
+		{value: int64(10), expected: "10"},
+		{value: float32(1.5), expected: "1.5"},
+		{value: float64(0.25), expected: "0.25"},
+		{value: true, expected: "true"},
+		{value: []interface{}{int64(1), int64(2)}, expected: "1,2"},
+		{value: time.Date(2018, 3, 1, 10, 0, 0, 0, time.UTC), expected: "2018-03-01T10:00:00Z"},
+		{value: []byte("hi"), expected: "aGk="},
+	}
+
+	for _, c := range cases {
+		if actual := FormatValue(c.value); actual != c.expected {
+			t.Errorf("Expected formatted %#v to be %s but got %s", c.value, c.expected, actual)
+		}
+	}
+}
+
+func TestFormatParameter(t *testing.T) {
+	cases := []struct {
+		values           []string
+		typ              string
+		format           string
+		collectionFormat string
+		items            *spec.Items
+	}{
+		{values: []string{"john"}, typ: "string"},
+		{values: []string{"2018-03-01"}, typ: "string", format: "date"},
+		{values: []string{"2018-03-01T10:00:00.5+03:00"}, typ: "string", format: "date-time"},
+		{values: []string{"aGVsbG8="}, typ: "string", format: "byte"},
+		{values: []string{"1h30m0s"}, typ: "string", format: "duration"},
+		{values: []string{"-12"}, typ: "integer", format: "int32"},
+		{values: []string{"9007199254740993"}, typ: "integer"},
+		{values: []string{"1.5"}, typ: "number", format: "float"},
+		{values: []string{"true"}, typ: "boolean"},
+		{
+			values: []string{"1,2,3"},
+			typ:    "array",
+			items:  &spec.Items{SimpleSchema: spec.SimpleSchema{Type: "integer"}},
+		},
+		{
+			values:           []string{"a b"},
+			typ:              "array",
+			collectionFormat: "ssv",
+			items:            &spec.Items{SimpleSchema: spec.SimpleSchema{Type: "string"}},
+		},
+		{
+			values:           []string{"2018-03-01T10:00:00Z|2018-03-02T10:00:00Z"},
+			typ:              "array",
+			collectionFormat: "pipes",
+			items:            &spec.Items{SimpleSchema: spec.SimpleSchema{Type: "string", Format: "date-time"}},
+		},
+		{
+			values:           []string{"aGVsbG8=", "d29ybGQ="},
+			typ:              "array",
+			collectionFormat: "multi",
+			items:            &spec.Items{SimpleSchema: spec.SimpleSchema{Type: "string", Format: "byte"}},
+		},
+		// items are formatted by their format
+		{
+			values: []string{"2020-01-02,2020-01-03"},
+			typ:    "array",
+			items:  &spec.Items{SimpleSchema: spec.SimpleSchema{Type: "string", Format: "date"}},
+		},
+		// nested arrays are joined by their collection format
+		{
+			values: []string{"1|2,3|4"},
+			typ:    "array",
+			items: &spec.Items{SimpleSchema: spec.SimpleSchema{
+				Type:             "array",
+				CollectionFormat: "pipes",
+				Items:            spec.NewItems().Typed("integer", ""),
+			}},
+		},
+		{
+			values:           []string{"a,b", "c"},
+			typ:              "array",
+			collectionFormat: "multi",
+			items: &spec.Items{SimpleSchema: spec.SimpleSchema{
+				Type:  "array",
+				Items: spec.NewItems().Typed("string", ""),
+			}},
+		},
+	}
+
+	for _, c := range cases {
+		p := spec.Parameter{
+			SimpleSchema: spec.SimpleSchema{
+				Type:             c.typ,
+				Format:           c.format,
+				CollectionFormat: c.collectionFormat,
+				Items:            c.items,
+			},
+		}
+
+		value, err := convertParam(p, c.values, nil)
+		if err != nil {
+			t.Fatalf("Unexpected error: %v", err)
+		}
+
+		actual, err := FormatParameter(value, c.typ, c.format, c.collectionFormat, c.items)
+		if err != nil {
+			t.Errorf("Unexpected error: %v", err)
+			continue
+		}
+
+		if !reflect.DeepEqual(c.values, actual) {
+			t.Errorf("Expected formatted %#v to be %v but got %v", value, c.values, actual)
+		}
+	}
+}
+
+func TestFormatParameter_errors(t *testing.T) {
+	strings := spec.NewItems().Typed("string", "")
+
+	cases := []struct {
+		value            interface{}
+		typ              string
+		format           string
+		collectionFormat string
+		items            *spec.Items
+		expectedError    string
+	}{
+		{value: "12", typ: "integer", expectedError: "cannot format string as integer"},
+		{value: int32(12), typ: "integer", format: "int64", expectedError: "cannot format int32 as integer"},
+		{value: time.Now(), typ: "string", format: "date", expectedError: "cannot format time.Time as string"},
+		{value: "x", typ: "string", format: "xml", expectedError: "unknown format xml for type string"},
+		{value: "a,b", typ: "array", items: strings, expectedError: "cannot format string as array"},
+		{value: []interface{}{"a"}, typ: "array", expectedError: "items of type array are not declared"},
+		{value: []interface{}{"a"}, typ: "array", items: strings, collectionFormat: "json", expectedError: "unknown collection format json"},
+		{
+			value:         []interface{}{"a"},
+			typ:           "array",
+			items:         &spec.Items{SimpleSchema: spec.SimpleSchema{Type: "array", Items: strings}},
+			expectedError: "item 0: cannot format string as array",
+		},
+	}
+
+	for _, c := range cases {
+		_, err := FormatParameter(c.value, c.typ, c.format, c.collectionFormat, c.items)
+		if err == nil || err.Error() != c.expectedError {
+			t.Errorf("Expected error to be %q but got %v", c.expectedError, err)
+		}
+	}
+}
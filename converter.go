@@ -0,0 +1,44 @@
+package oas2
+
+import (
+	"fmt"
+
+	"github.com/go-openapi/spec"
+)
+
+// extConvert is a parameter extension that names a converter registered
+// with ConverterOpt, which is used to convert raw values of the parameter
+// instead of the default conversion.
+const extConvert = "x-convert"
+
+// Converter converts raw values of a parameter. The values hold a single
+// element unless the parameter is repeated. The result is validated against
+// the parameter spec, so it must be of the type the parameter declares, e.g.
+// []interface{} for arrays.
+type Converter func(p spec.Parameter, vals []string) (interface{}, error)
+
+// ConverterOpt returns an option that registers a converter by name, so
+// parameters can name it in "x-convert" extension to override the default
+// conversion, see ConvertParameter.
+func ConverterOpt(name string, c Converter) MiddlewareOption {
+	return func(args *MiddlewareOptions) {
+		if args.converters == nil {
+			args.converters = make(map[string]Converter)
+		}
+		args.converters[name] = c
+	}
+}
+
+// converter returns the converter named by the parameter extension. An error
+// is returned if the named converter is not registered.
+func (opts MiddlewareOptions) converter(p spec.Parameter) (Converter, error) {
+	name, ok := p.Extensions.GetString(extConvert)
+	if !ok {
+		return nil, nil
+	}
+	c, ok := opts.converters[name]
+	if !ok {
+		return nil, fmt.Errorf("unknown converter %s", name)
+	}
+	return c, nil
+}
@@ -0,0 +1,116 @@
+package oas2
+
+import (
+	"fmt"
+	"net/http"
+	"net/http/httptest"
+	"strconv"
+	"strings"
+	"testing"
+
+	"github.com/go-chi/chi"
+	"github.com/go-openapi/spec"
+)
+
+func TestConverterOpt(t *testing.T) {
+	sw := parseSpec(`
+swagger: "2.0"
+info:
+  title: test
+  version: "1.0"
+basePath: /v1
+paths:
+  /pets/{id}:
+    get:
+      operationId: getPet
+      parameters:
+      - name: id
+        in: path
+        type: integer
+        required: true
+        x-convert: pet-id
+      - name: tags
+        in: query
+        type: array
+        items:
+          type: integer
+        maxItems: 3
+        x-convert: colon-list
+      - name: legacy
+        in: query
+        type: string
+        x-convert: legacy
+      responses:
+        200:
+          description: ok
+`)
+
+	handlers := OperationHandlers{"getPet": http.HandlerFunc(func(w http.ResponseWriter, req *http.Request) {
+		fmt.Fprintf(w, "%v %v", GetPathParam(req, "id"), GetQueryParam(req, "tags"))
+	})}
+
+	options := []MiddlewareOption{
+		ConverterOpt("pet-id", func(p spec.Parameter, vals []string) (interface{}, error) {
+			return strconv.ParseInt(strings.TrimPrefix(vals[0], "pet-"), 10, 64)
+		}),
+		ConverterOpt("colon-list", func(p spec.Parameter, vals []string) (interface{}, error) {
+			var res []interface{}
+			for _, s := range strings.Split(vals[0], ":") {
+				v, err := strconv.ParseInt(s, 10, 64)
+				if err != nil {
+					return nil, fmt.Errorf("cannot convert %s to integer", s)
+				}
+				res = append(res, v)
+			}
+			return res, nil
+		}),
+	}
+
+	router, err := NewRouter(
+		sw,
+		handlers,
+		MiddlewareOpt(NewPathParameterExtractor(chi.URLParam, options...).Apply),
+		MiddlewareOpt(NewQueryValidator(writeErrorsToResponseWriter, options...).Apply),
+	)
+	if err != nil {
+		t.Fatal(err)
+	}
+
+	testCases := []struct {
+		target          string
+		expectedPayload string
+	}{
+		// Converters override the default conversion.
+		{
+			target:          "/v1/pets/pet-12?tags=1:2:3",
+			expectedPayload: "12 [1 2 3]",
+		},
+		// Errors of converters are reported.
+		{
+			target:          "/v1/pets/pet-12?tags=1:x",
+			expectedPayload: `{"errors":[{"message":"param tags: cannot convert x to integer","field":"tags","value":"1:x"}]}`,
+		},
+		// Converted values are validated against the spec.
+		{
+			target:          "/v1/pets/pet-12?tags=1:2:3:4",
+			expectedPayload: `{"errors":[{"message":"tags in query should have at most 3 items","field":"tags","value":[1,2,3,4]}]}`,
+		},
+		// Parameters naming unknown converters are not converted.
+		{
+			target:          "/v1/pets/pet-12?legacy=abc",
+			expectedPayload: `{"errors":[{"message":"param legacy: unknown converter legacy","field":"legacy","value":"abc"}]}`,
+		},
+	}
+
+	for _, tc := range testCases {
+		t.Run(tc.target, func(t *testing.T) {
+			req := httptest.NewRequest(http.MethodGet, tc.target, nil)
+			w := httptest.NewRecorder()
+			router.ServeHTTP(w, req)
+
+			if strings.TrimSpace(w.Body.String()) != tc.expectedPayload {
+				t.Errorf("Expected response body to be\n%s\nbut got\n%s", tc.expectedPayload, w.Body.String())
+			}
+		})
+	}
+}
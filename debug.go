@@ -0,0 +1,173 @@
+package oas2
+
+import (
+	"encoding/json"
+	"fmt"
+	"net/http"
+	"sort"
+	"strconv"
+
+	"github.com/go-openapi/spec"
+)
+
+// DefaultDebugPath is the default path the debug handler serves at.
+const DefaultDebugPath = "/debug/oas2"
+
+// DebugOptions is options for the debug handler.
+type DebugOptions struct {
+	path  string
+	allow func(req *http.Request) bool
+}
+
+// DebugOption is an option for the debug handler.
+type DebugOption func(*DebugOptions)
+
+// DebugPathOpt returns an option that sets the path the debug handler serves
+// at. By default, DefaultDebugPath is used.
+func DebugPathOpt(path string) DebugOption {
+	return func(args *DebugOptions) {
+		args.path = path
+	}
+}
+
+// DebugAllowOpt returns an option that sets a function reporting whether the
+// request may see the debug information, e.g. by checking the environment or
+// the client address. By default, all requests are denied, so the debug
+// information is not exposed unless allowed explicitly.
+func DebugAllowOpt(allow func(req *http.Request) bool) DebugOption {
+	return func(args *DebugOptions) {
+		args.allow = allow
+	}
+}
+
+// DebugHandler returns a http.Handler that serves the effective request and
+// response contract of each spec operation as JSON, for client developers
+// and support: method, path, effective parameters with their constraints,
+// see EffectiveParameters, and body and response schemas with references
+// resolved. Requests to other paths and denied requests, see DebugAllowOpt,
+// are responded with 404 Not Found. It returns an error if a schema cannot
+// be compiled, see CompileSchema.
+func DebugHandler(sw *spec.Swagger, options ...DebugOption) (http.Handler, error) {
+	opts := DebugOptions{
+		path:  DefaultDebugPath,
+		allow: func(req *http.Request) bool { return false },
+	}
+	for _, o := range options {
+		o(&opts)
+	}
+
+	ops, err := debugOperations(sw)
+	if err != nil {
+		return nil, err
+	}
+	payload, err := json.Marshal(struct {
+		Operations []debugOperation `json:"operations"`
+	}{ops})
+	if err != nil {
+		return nil, fmt.Errorf("oas2 debug: %s", err)
+	}
+
+	return debugHandler{opts: opts, payload: payload}, nil
+}
+
+type debugHandler struct {
+	opts    DebugOptions
+	payload []byte
+}
+
+func (h debugHandler) ServeHTTP(w http.ResponseWriter, req *http.Request) {
+	if req.URL.Path != h.opts.path || !h.opts.allow(req) {
+		http.NotFound(w, req)
+		return
+	}
+	if req.Method != http.MethodGet && req.Method != http.MethodHead {
+		w.Header().Set("Allow", "GET, HEAD")
+		http.Error(w, http.StatusText(http.StatusMethodNotAllowed), http.StatusMethodNotAllowed)
+		return
+	}
+
+	w.Header().Set("Content-Type", "application/json")
+	w.Header().Set("Cache-Control", "no-store")
+	w.Write(h.payload)
+}
+
+// debugOperation is the effective contract of an operation.
+type debugOperation struct {
+	ID         string                   `json:"id"`
+	Method     string                   `json:"method"`
+	Path       string                   `json:"path"`
+	Consumes   []string                 `json:"consumes,omitempty"`
+	Produces   []string                 `json:"produces,omitempty"`
+	Parameters []spec.Parameter         `json:"parameters"`
+	Body       *spec.Schema             `json:"body,omitempty"`
+	Responses  map[string]debugResponse `json:"responses"`
+}
+
+type debugResponse struct {
+	Description string       `json:"description"`
+	Schema      *spec.Schema `json:"schema,omitempty"`
+}
+
+// debugOperations returns contracts of the spec operations sorted by path
+// and method.
+func debugOperations(sw *spec.Swagger) ([]debugOperation, error) {
+	var errs []error
+	compile := func(op *spec.Operation, name string, sch *spec.Schema, prepare func(*spec.Schema) *spec.Schema) *spec.Schema {
+		if sch == nil {
+			return nil
+		}
+		cs, err := compileSpecSchema(sw, sch, prepare)
+		if err != nil {
+			errs = append(errs, fmt.Errorf("operation %s: %s: %s", op.ID, name, err))
+			return nil
+		}
+		return cs.schema
+	}
+
+	ops := make([]debugOperation, 0)
+	forEachOperation(sw, func(path, method string, pi spec.PathItem, op *spec.Operation) {
+		eop := effectiveOperation(sw, op)
+		dop := debugOperation{
+			ID:         op.ID,
+			Method:     method,
+			Path:       mountedPath(sw.BasePath, path),
+			Consumes:   eop.Consumes,
+			Produces:   eop.Produces,
+			Parameters: make([]spec.Parameter, 0, len(eop.Parameters)),
+			Responses:  make(map[string]debugResponse),
+		}
+
+		for _, p := range eop.Parameters {
+			if p.In == "body" {
+				dop.Body = compile(op, "parameter "+p.Name, p.Schema, requestSchema)
+				p.Schema = dop.Body
+			}
+			dop.Parameters = append(dop.Parameters, p)
+		}
+
+		if op.Responses != nil {
+			if r := op.Responses.Default; r != nil {
+				dop.Responses["default"] = debugResponse{
+					Description: r.Description,
+					Schema:      compile(op, "default response", r.Schema, nil),
+				}
+			}
+			for status, r := range op.Responses.StatusCodeResponses {
+				dop.Responses[strconv.Itoa(status)] = debugResponse{
+					Description: r.Description,
+					Schema:      compile(op, fmt.Sprintf("response %d", status), r.Schema, nil),
+				}
+			}
+		}
+
+		ops = append(ops, dop)
+	})
+
+	if len(errs) > 0 {
+		sort.Slice(errs, func(i, j int) bool {
+			return errs[i].Error() < errs[j].Error()
+		})
+		return nil, fmt.Errorf("oas2 debug: %s", specErrors(errs))
+	}
+	return ops, nil
+}
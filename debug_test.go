@@ -0,0 +1,169 @@
+package oas2
+
+import (
+	"encoding/json"
+	"net/http"
+	"net/http/httptest"
+	"reflect"
+	"testing"
+)
+
+func TestDebugHandler(t *testing.T) {
+	sw := parseSpec(`
+swagger: "2.0"
+info:
+  title: test
+  version: "1.0"
+basePath: /v1
+produces:
+- application/json
+paths:
+  /pets/{id}:
+    parameters:
+    - name: id
+      in: path
+      type: integer
+      required: true
+      minimum: 1
+    put:
+      operationId: updatePet
+      parameters:
+      - $ref: "#/parameters/dryRun"
+      - name: pet
+        in: body
+        required: true
+        schema:
+          $ref: "#/definitions/Pet"
+      responses:
+        200:
+          description: updated
+          schema:
+            $ref: "#/definitions/Pet"
+        default:
+          description: error
+parameters:
+  dryRun:
+    name: dry_run
+    in: query
+    type: boolean
+definitions:
+  Pet:
+    type: object
+    required: [id, name]
+    properties:
+      id:
+        type: integer
+        readOnly: true
+      name:
+        type: string
+        maxLength: 10
+`)
+
+	handler, err := DebugHandler(sw, DebugAllowOpt(func(req *http.Request) bool {
+		return req.Header.Get("X-Debug") == "yes"
+	}))
+	if err != nil {
+		t.Fatal(err)
+	}
+
+	t.Run("denied", func(t *testing.T) {
+		req := httptest.NewRequest(http.MethodGet, DefaultDebugPath, nil)
+		w := httptest.NewRecorder()
+		handler.ServeHTTP(w, req)
+
+		if w.Code != http.StatusNotFound {
+			t.Errorf("Expected status to be %d but got %d", http.StatusNotFound, w.Code)
+		}
+	})
+
+	t.Run("other path", func(t *testing.T) {
+		req := httptest.NewRequest(http.MethodGet, "/debug", nil)
+		req.Header.Set("X-Debug", "yes")
+		w := httptest.NewRecorder()
+		handler.ServeHTTP(w, req)
+
+		if w.Code != http.StatusNotFound {
+			t.Errorf("Expected status to be %d but got %d", http.StatusNotFound, w.Code)
+		}
+	})
+
+	t.Run("allowed", func(t *testing.T) {
+		req := httptest.NewRequest(http.MethodGet, DefaultDebugPath, nil)
+		req.Header.Set("X-Debug", "yes")
+		w := httptest.NewRecorder()
+		handler.ServeHTTP(w, req)
+
+		if w.Code != http.StatusOK {
+			t.Fatalf("Expected status to be %d but got %d", http.StatusOK, w.Code)
+		}
+
+		var payload struct {
+			Operations []struct {
+				ID         string   `json:"id"`
+				Method     string   `json:"method"`
+				Path       string   `json:"path"`
+				Produces   []string `json:"produces"`
+				Parameters []struct {
+					Name    string   `json:"name"`
+					In      string   `json:"in"`
+					Minimum *float64 `json:"minimum"`
+				} `json:"parameters"`
+				Body struct {
+					Required   []string `json:"required"`
+					Properties map[string]struct {
+						MaxLength *int `json:"maxLength"`
+					} `json:"properties"`
+				} `json:"body"`
+				Responses map[string]struct {
+					Description string `json:"description"`
+					Schema      *struct {
+						Required []string `json:"required"`
+					} `json:"schema"`
+				} `json:"responses"`
+			} `json:"operations"`
+		}
+		if err := json.Unmarshal(w.Body.Bytes(), &payload); err != nil {
+			t.Fatal(err)
+		}
+
+		if len(payload.Operations) != 1 {
+			t.Fatalf("Expected 1 operation but got %d", len(payload.Operations))
+		}
+		op := payload.Operations[0]
+
+		if op.ID != "updatePet" || op.Method != http.MethodPut || op.Path != "/v1/pets/{id}" {
+			t.Errorf("Expected operation to be updatePet PUT /v1/pets/{id} but got %s %s %s", op.ID, op.Method, op.Path)
+		}
+		if !reflect.DeepEqual(op.Produces, []string{"application/json"}) {
+			t.Errorf("Expected inherited produces but got %v", op.Produces)
+		}
+
+		// Parameters are merged with the path item ones and resolved.
+		var names []string
+		for _, p := range op.Parameters {
+			names = append(names, p.In+":"+p.Name)
+			if p.Name == "id" && (p.Minimum == nil || *p.Minimum != 1) {
+				t.Errorf("Expected id parameter to keep minimum 1 but got %v", p.Minimum)
+			}
+		}
+		if expected := []string{"query:dry_run", "body:pet", "path:id"}; !reflect.DeepEqual(names, expected) {
+			t.Errorf("Expected parameters to be %v but got %v", expected, names)
+		}
+
+		// Body schema is resolved, and readOnly properties are not required
+		// in requests.
+		if !reflect.DeepEqual(op.Body.Required, []string{"name"}) {
+			t.Errorf("Expected body to require [name] but got %v", op.Body.Required)
+		}
+		if ml := op.Body.Properties["name"].MaxLength; ml == nil || *ml != 10 {
+			t.Errorf("Expected name maxLength to be 10 but got %v", ml)
+		}
+
+		if r := op.Responses["200"]; r.Schema == nil || !reflect.DeepEqual(r.Schema.Required, []string{"id", "name"}) {
+			t.Errorf("Expected response 200 schema to require [id name] but got %+v", r.Schema)
+		}
+		if r := op.Responses["default"]; r.Description != "error" || r.Schema != nil {
+			t.Errorf("Expected default response without schema but got %+v", r)
+		}
+	})
+}
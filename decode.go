@@ -46,7 +46,7 @@ func DecodeQuery(ps []spec.Parameter, q url.Values, dst interface{}) error {
 		}
 
 		// Convert value by type+format in parameter.
-		v, err := ConvertParameter(vals, p.Type, p.Format)
+		v, err := convertParam(p, vals, nil)
 		if err != nil {
 			return fmt.Errorf(
 				"cannot use values %v as parameter %s with type %s and format %s",
@@ -0,0 +1,124 @@
+package oas2
+
+import (
+	"bytes"
+	"fmt"
+	"io"
+	"io/ioutil"
+	"net/http"
+	"net/url"
+
+	"github.com/go-openapi/spec"
+)
+
+// NewDefaultsInjector returns new Middleware that sets values of query,
+// header and formData parameters that declare defaults in OpenAPI 2.0 spec
+// and are not passed by the client. Values passed are never overwritten.
+// Defaults of formData parameters are injected to url-encoded bodies of at
+// most 10 MB only.
+// Path parameters are always passed, so their defaults are ignored.
+//
+// The middleware must run before validators, so they see the defaults.
+func NewDefaultsInjector(options ...MiddlewareOption) Middleware {
+	return defaultsInjector{
+		opts: newMiddlewareOptions(options),
+	}
+}
+
+type defaultsInjector struct {
+	opts MiddlewareOptions
+}
+
+func (m defaultsInjector) Apply(next http.Handler) http.Handler {
+	return http.HandlerFunc(func(w http.ResponseWriter, req *http.Request) {
+		op := m.opts.operationResolver(req)
+		if op == nil {
+			next.ServeHTTP(w, req)
+			return
+		}
+
+		query := req.URL.Query()
+		if m.injectValues(req, op.Parameters, "query", query) {
+			req.URL.RawQuery = query.Encode()
+		}
+
+		for _, p := range op.Parameters {
+			if p.In != "header" || p.Default == nil || req.Header.Get(p.Name) != "" {
+				continue
+			}
+			if vals, ok := m.defaultValues(req, p); ok {
+				for _, val := range vals {
+					req.Header.Add(p.Name, val)
+				}
+			}
+		}
+
+		if req.Body != nil && req.Body != http.NoBody &&
+			parseMediaType(req.Header.Get("Content-Type")) == mediaTypeForm {
+			m.injectForm(req, op.Parameters)
+		}
+
+		next.ServeHTTP(w, req)
+	})
+}
+
+// maxDefaultsFormSize is the maximum size of url-encoded bodies defaults
+// are injected to, the same as net/http limits url-encoded forms to.
+const maxDefaultsFormSize = 10 << 20
+
+// injectForm injects defaults of formData parameters to the url-encoded
+// body. Bodies that cannot be read or parsed, or are larger than
+// maxDefaultsFormSize, are left to validators as is.
+func (m defaultsInjector) injectForm(req *http.Request, ps []spec.Parameter) {
+	body := req.Body
+	b, err := ioutil.ReadAll(io.LimitReader(body, maxDefaultsFormSize+1))
+	if err != nil || len(b) > maxDefaultsFormSize {
+		req.Body = struct {
+			io.Reader
+			io.Closer
+		}{io.MultiReader(bytes.NewReader(b), body), body}
+		return
+	}
+	body.Close()
+
+	form, err := url.ParseQuery(string(b))
+	if err == nil && m.injectValues(req, ps, "formData", form) {
+		b = []byte(form.Encode())
+		req.ContentLength = int64(len(b))
+	}
+	req.Body = ioutil.NopCloser(bytes.NewReader(b))
+}
+
+// injectValues sets defaults of parameters located in "in" missing in vals.
+// It reports whether any default is set.
+func (m defaultsInjector) injectValues(req *http.Request, ps []spec.Parameter, in string, vals url.Values) bool {
+	injected := false
+	for _, p := range ps {
+		if p.In != in || p.Default == nil {
+			continue
+		}
+		if _, ok := vals[p.Name]; ok {
+			continue
+		}
+		if defaults, ok := m.defaultValues(req, p); ok {
+			vals[p.Name] = defaults
+			injected = true
+		}
+	}
+	return injected
+}
+
+// defaultValues returns the default of the parameter formatted as values.
+// Defaults that cannot be formatted are reported by SpecMismatchFn.
+func (m defaultsInjector) defaultValues(req *http.Request, p spec.Parameter) ([]string, bool) {
+	if p.Type != "array" {
+		return []string{FormatValue(p.Default)}, true
+	}
+
+	vals, err := FormatParameter(p.Default, p.Type, p.Format, p.CollectionFormat, p.Items)
+	if err != nil {
+		m.opts.specMismatchFn(req, fmt.Errorf("parameter %s: default: %s", p.Name, err))
+		return nil, false
+	}
+	return vals, true
+}
@@ -0,0 +1,111 @@
+package oas2
+
+import (
+	"fmt"
+	"net/http"
+	"net/http/httptest"
+	"strings"
+	"testing"
+)
+
+func TestDefaultsInjector_Apply(t *testing.T) {
+	sw := parseSpec(`
+swagger: "2.0"
+info:
+  title: test
+  version: "1.0"
+basePath: /v1
+paths:
+  /pets:
+    post:
+      operationId: addPet
+      consumes:
+      - application/x-www-form-urlencoded
+      parameters:
+      - name: X-Request-Priority
+        in: header
+        type: integer
+        default: 5
+      - name: limit
+        in: query
+        type: integer
+        default: 20
+      - name: name
+        in: formData
+        type: string
+        required: true
+      - name: status
+        in: formData
+        type: string
+        default: available
+      - name: tags
+        in: formData
+        type: array
+        items:
+          type: string
+        default: [cat, small]
+      responses:
+        200:
+          description: ok
+`)
+
+	cases := []struct {
+		header          string
+		query           string
+		body            string
+		expectedPayload string
+	}{
+		// defaults are injected
+		{
+			body:            "name=Kitty",
+			expectedPayload: "priority=5 limit=20 status=available tags=cat,small",
+		},
+		// values passed are not overwritten
+		{
+			header:          "1",
+			query:           "limit=10",
+			body:            "name=Kitty&status=sold&tags=dog",
+			expectedPayload: "priority=1 limit=10 status=sold tags=dog",
+		},
+		// defaults are not injected to too large bodies
+		{
+			body:            "name=" + strings.Repeat("a", maxDefaultsFormSize),
+			expectedPayload: "priority=5 limit=20 status= tags=",
+		},
+	}
+
+	handlers := OperationHandlers{"addPet": http.HandlerFunc(func(w http.ResponseWriter, req *http.Request) {
+		fmt.Fprintf(w, "priority=%s limit=%s status=%s tags=%s",
+			req.Header.Get("X-Request-Priority"),
+			req.URL.Query().Get("limit"),
+			req.FormValue("status"),
+			req.FormValue("tags"),
+		)
+	})}
+
+	router, err := NewRouter(
+		sw,
+		handlers,
+		MiddlewareOpt(NewBodyValidator(writeErrorsToResponseWriter).Apply),
+		MiddlewareOpt(NewQueryValidator(writeErrorsToResponseWriter).Apply),
+		MiddlewareOpt(NewDefaultsInjector().Apply),
+	)
+	if err != nil {
+		t.Fatal(err)
+	}
+
+	for _, c := range cases {
+		req := httptest.NewRequest(http.MethodPost, "/v1/pets?"+c.query, strings.NewReader(c.body))
+		req.Header.Set("Content-Type", "application/x-www-form-urlencoded")
+		if c.header != "" {
+			req.Header.Set("X-Request-Priority", c.header)
+		}
+		w := httptest.NewRecorder()
+
+		router.ServeHTTP(w, req)
+
+		if w.Body.String() != c.expectedPayload {
+			t.Errorf("Expected response body to be\n%s\nbut got\n%s", c.expectedPayload, w.Body.String())
+		}
+	}
+}
@@ -0,0 +1,100 @@
+package oas2
+
+import (
+	"encoding/json"
+	"fmt"
+	"sort"
+
+	"github.com/go-openapi/spec"
+)
+
+// ResponseExample returns the example of the operation response with status
+// code for the media type, as declared in the spec. JSON examples are
+// returned encoded as JSON, string examples of other media types are
+// returned as is.
+func ResponseExample(op *spec.Operation, status int, mediaType string) ([]byte, error) {
+	if op.Responses == nil {
+		return nil, fmt.Errorf("operation %s has no responses", op.ID)
+	}
+
+	response, ok := op.Responses.StatusCodeResponses[status]
+	if !ok {
+		if op.Responses.Default == nil {
+			return nil, fmt.Errorf("operation %s has no response for status %d", op.ID, status)
+		}
+		response = *op.Responses.Default
+	}
+
+	example, ok := response.Examples[mediaType]
+	if !ok {
+		return nil, fmt.Errorf(
+			"operation %s has no example of response with status %d for media type %s",
+			op.ID, status, mediaType,
+		)
+	}
+
+	if s, ok := example.(string); ok && !isJSONMediaType(parseMediaType(mediaType)) {
+		return []byte(s), nil
+	}
+
+	return json.Marshal(example)
+}
+
+// ValidateResponseExamples checks examples of responses of all the spec
+// operations and returns errors if any. Each example must have a media type
+// the operation produces, and JSON examples must be valid against the
+// response schema. The spec is expected to be expanded, see
+// loads.Document.Expanded.
+func ValidateResponseExamples(sw *spec.Swagger) []error {
+	var errs []error
+
+	forEachOperation(sw, func(path, method string, pi spec.PathItem, op *spec.Operation) {
+		produces := op.Produces
+		if len(produces) == 0 {
+			produces = sw.Produces
+		}
+
+		forEachResponse(op, func(name string, r spec.Response) {
+			errs = append(errs, validateExamples(r, produces, fmt.Sprintf("operation %s: %s", op.ID, name))...)
+		})
+	})
+
+	return errs
+}
+
+// validateExamples checks examples of the response. name describes the
+// response in errors.
+func validateExamples(r spec.Response, produces []string, name string) (errs []error) {
+	mediaTypes := make([]string, 0, len(r.Examples))
+	for mt := range r.Examples {
+		mediaTypes = append(mediaTypes, mt)
+	}
+	sort.Strings(mediaTypes)
+
+	for _, mt := range mediaTypes {
+		if len(produces) > 0 && !containsMediaType(produces, mt) {
+			errs = append(errs, fmt.Errorf("%s: example media type %s is not produced", name, mt))
+		}
+
+		if r.Schema == nil || !isJSONMediaType(parseMediaType(mt)) {
+			continue
+		}
+		for _, err := range ValidateBySchema(r.Schema, r.Examples[mt]) {
+			errs = append(errs, fmt.Errorf("%s: example for %s: %s", name, mt, err))
+		}
+	}
+
+	return errs
+}
+
+// containsMediaType reports whether the media type is in the list, ignoring
+// parameters.
+func containsMediaType(list []string, mediaType string) bool {
+	mt := parseMediaType(mediaType)
+	for _, l := range list {
+		if parseMediaType(l) == mt {
+			return true
+		}
+	}
+	return false
+}
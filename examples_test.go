@@ -0,0 +1,124 @@
+package oas2
+
+import (
+	"fmt"
+	"reflect"
+	"testing"
+)
+
+func TestResponseExample(t *testing.T) {
+	doc := loadDoc()
+	op := doc.Spec().Paths.Paths["/pet/{petId}"].Get
+
+	cases := []struct {
+		status          int
+		mediaType       string
+		expectedExample []byte
+		expectedError   error
+	}{
+		// json example
+		{
+			status:          200,
+			mediaType:       "application/json",
+			expectedExample: []byte(`{"age":3,"id":12,"name":"Kitty"}`),
+		},
+		// plain text example
+		{
+			status:          200,
+			mediaType:       "text/plain",
+			expectedExample: []byte("Kitty, 3 y.o."),
+		},
+		// no example for media type
+		{
+			status:        200,
+			mediaType:     "application/xml",
+			expectedError: fmt.Errorf("operation getPetById has no example of response with status 200 for media type application/xml"),
+		},
+		// no response for status
+		{
+			status:        500,
+			mediaType:     "application/json",
+			expectedError: fmt.Errorf("operation getPetById has no response for status 500"),
+		},
+	}
+
+	for _, c := range cases {
+		example, err := ResponseExample(op, c.status, c.mediaType)
+		if !reflect.DeepEqual(c.expectedError, err) {
+			t.Errorf("Expected error to be %v but got %v", c.expectedError, err)
+		}
+		if !reflect.DeepEqual(c.expectedExample, example) {
+			t.Errorf("Expected example to be %s but got %s", c.expectedExample, example)
+		}
+	}
+}
+
+func TestValidateResponseExamples(t *testing.T) {
+	sw := parseSpec(`
+swagger: "2.0"
+info:
+  title: test
+  version: "1.0"
+basePath: /v1
+produces:
+- application/json
+paths:
+  /pets/{id}:
+    get:
+      operationId: getPet
+      parameters:
+      - name: id
+        in: path
+        type: integer
+        required: true
+      responses:
+        200:
+          description: ok
+          schema:
+            type: object
+            required: [name]
+            properties:
+              name:
+                type: string
+          examples:
+            application/json:
+              age: 3
+            text/plain: Kitty
+        default:
+          description: error
+          schema:
+            type: object
+            properties:
+              message:
+                type: string
+          examples:
+            application/json:
+              message: not found
+  /pets:
+    get:
+      operationId: listPets
+      produces:
+      - application/json
+      - text/plain
+      responses:
+        200:
+          description: ok
+          schema:
+            type: array
+            items:
+              type: string
+          examples:
+            application/json: [Kitty, Rex]
+            text/plain: Kitty, Rex
+`)
+
+	expectedErrors := []error{
+		fmt.Errorf("operation getPet: response 200: example for application/json: name in body is required"),
+		fmt.Errorf("operation getPet: response 200: example media type text/plain is not produced"),
+	}
+
+	errs := ValidateResponseExamples(sw)
+	if !reflect.DeepEqual(expectedErrors, errs) {
+		t.Errorf("Expected errors to be\n%v\nbut got\n%v", expectedErrors, errs)
+	}
+}
@@ -0,0 +1,132 @@
+package oas2
+
+import (
+	"fmt"
+	"net/http"
+	"reflect"
+	"strings"
+	"unicode"
+	"unicode/utf8"
+)
+
+// HandlersFromStruct returns OperationHandlers built from methods and tagged
+// fields of v, which must be a struct or a pointer to a struct.
+//
+// Each exported method handles the operation named after the method with
+// the first letter in lower case, e.g. method GetPetById handles operation
+// getPetById. Methods must be of signature
+// func(http.ResponseWriter, *http.Request) or func() http.Handler. The latter
+// are called once to get non-nil handlers. ServeHTTP method is ignored.
+//
+// Fields tagged with `oas2:"operationId"` handle the operation named in the
+// tag. Such fields must be non-nil values of a type implementing
+// http.Handler.
+//
+// An error is returned for methods and fields not matching these rules and
+// for operations handled more than once.
+func HandlersFromStruct(v interface{}) (OperationHandlers, error) {
+	if v == nil {
+		return nil, fmt.Errorf("oas2: cannot build handlers from nil, struct is expected")
+	}
+
+	rv := reflect.ValueOf(v)
+	rt := rv.Type()
+	if rt.Kind() == reflect.Ptr && rt.Elem().Kind() == reflect.Struct && !rv.IsNil() {
+		rt = rt.Elem()
+	} else if rt.Kind() != reflect.Struct {
+		return nil, fmt.Errorf("oas2: cannot build handlers from %T, struct is expected", v)
+	}
+
+	handlers := make(OperationHandlers)
+	var errs []string
+	add := func(id OperationID, h http.Handler, source string) {
+		if _, ok := handlers[id]; ok {
+			errs = append(errs, fmt.Sprintf("%s: operation %s is handled more than once", source, id))
+			return
+		}
+		handlers[id] = h
+	}
+
+	// Methods of a pointer receiver are only available on the pointer.
+	for i := 0; i < rv.NumMethod(); i++ {
+		m := rv.Type().Method(i)
+		if m.Name == "ServeHTTP" {
+			continue
+		}
+
+		h, err := methodHandler(rv.Method(i))
+		if err != nil {
+			errs = append(errs, fmt.Sprintf("method %s: %s", m.Name, err))
+			continue
+		}
+		add(methodOperationID(m.Name), h, "method "+m.Name)
+	}
+
+	sv := reflect.Indirect(rv)
+	for i := 0; i < rt.NumField(); i++ {
+		f := rt.Field(i)
+		id, ok := f.Tag.Lookup("oas2")
+		if !ok {
+			continue
+		}
+
+		if f.PkgPath != "" {
+			errs = append(errs, fmt.Sprintf("field %s: field is not exported", f.Name))
+			continue
+		}
+		h, ok := sv.Field(i).Interface().(http.Handler)
+		if !ok || isNilHandler(sv.Field(i)) {
+			errs = append(errs, fmt.Sprintf("field %s: %s is not a non-nil http.Handler", f.Name, f.Type))
+			continue
+		}
+		add(OperationID(id), h, "field "+f.Name)
+	}
+
+	if len(errs) > 0 {
+		return nil, fmt.Errorf("oas2: cannot build handlers from %s: %s", rt, strings.Join(errs, "; "))
+	}
+	return handlers, nil
+}
+
+var (
+	handlerType       = reflect.TypeOf((*http.Handler)(nil)).Elem()
+	handlerFuncType   = reflect.TypeOf(http.HandlerFunc(nil))
+	handlerGetterType = reflect.TypeOf(func() http.Handler { return nil })
+)
+
+// methodHandler returns the handler of the method bound to its receiver.
+func methodHandler(m reflect.Value) (http.Handler, error) {
+	switch {
+	case m.Type().ConvertibleTo(handlerFuncType):
+		return m.Convert(handlerFuncType).Interface().(http.HandlerFunc), nil
+	case m.Type() == handlerGetterType:
+		h := m.Call(nil)[0]
+		if isNilHandler(h) {
+			return nil, fmt.Errorf("returned handler is nil")
+		}
+		return h.Interface().(http.Handler), nil
+	default:
+		return nil, fmt.Errorf(
+			"signature %s does not match http.HandlerFunc or func() http.Handler", m.Type(),
+		)
+	}
+}
+
+// isNilHandler reports whether the value of a handler type is nil.
+func isNilHandler(v reflect.Value) bool {
+	if !v.Type().Implements(handlerType) {
+		return true
+	}
+	switch v.Kind() {
+	case reflect.Ptr, reflect.Interface, reflect.Func, reflect.Map, reflect.Slice, reflect.Chan:
+		return v.IsNil()
+	}
+	return false
+}
+
+// methodOperationID returns the operation id handled by the method, which
+// is the method name with the first letter in lower case.
+func methodOperationID(name string) OperationID {
+	r, size := utf8.DecodeRuneInString(name)
+	return OperationID(string(unicode.ToLower(r)) + name[size:])
+}
@@ -0,0 +1,136 @@
+package oas2
+
+import (
+	"fmt"
+	"net/http"
+	"net/http/httptest"
+	"sort"
+	"testing"
+)
+
+type petHandlers struct {
+	name string
+
+	// Deletes are handled by a plain handler.
+	Delete http.Handler `oas2:"deletePet"`
+}
+
+func (h *petHandlers) GetPetById(w http.ResponseWriter, req *http.Request) {
+	fmt.Fprintf(w, "%s: pet", h.name)
+}
+
+func (h *petHandlers) AddPet() http.Handler {
+	return http.HandlerFunc(func(w http.ResponseWriter, req *http.Request) {
+		fmt.Fprintf(w, "%s: pet added", h.name)
+	})
+}
+
+type invalidHandlers struct {
+	Update http.Handler `oas2:"updatePet"`
+	Find   string       `oas2:"findPets"`
+}
+
+func (invalidHandlers) GetPetById(w http.ResponseWriter) {}
+
+func (invalidHandlers) UpdatePet(w http.ResponseWriter, req *http.Request) {}
+
+func TestHandlersFromStruct(t *testing.T) {
+	h := &petHandlers{
+		name: "store",
+		Delete: http.HandlerFunc(func(w http.ResponseWriter, req *http.Request) {
+			fmt.Fprint(w, "pet deleted")
+		}),
+	}
+
+	handlers, err := HandlersFromStruct(h)
+	if err != nil {
+		t.Fatal(err)
+	}
+
+	expected := map[OperationID]string{
+		"getPetById": "store: pet",
+		"addPet":     "store: pet added",
+		"deletePet":  "pet deleted",
+	}
+	if len(handlers) != len(expected) {
+		t.Errorf("Expected %d handlers but got %d", len(expected), len(handlers))
+	}
+	for id, payload := range expected {
+		handler, ok := handlers[id]
+		if !ok {
+			t.Errorf("Expected handler for operation %s", id)
+			continue
+		}
+
+		w := httptest.NewRecorder()
+		handler.ServeHTTP(w, httptest.NewRequest(http.MethodGet, "/", nil))
+		if w.Body.String() != payload {
+			t.Errorf("Expected response body of %s to be %q but got %q", id, payload, w.Body.String())
+		}
+	}
+
+	// Methods of a pointer receiver are not available on the value.
+	handlers, err = HandlersFromStruct(*h)
+	if err != nil {
+		t.Fatal(err)
+	}
+	if ids := handlerIDs(handlers); fmt.Sprint(ids) != "[deletePet]" {
+		t.Errorf("Expected handlers of the value to be [deletePet] but got %v", ids)
+	}
+}
+
+func TestHandlersFromStruct_errors(t *testing.T) {
+	cases := []struct {
+		v             interface{}
+		expectedError string
+	}{
+		{
+			v:             nil,
+			expectedError: "oas2: cannot build handlers from nil, struct is expected",
+		},
+		{
+			v:             http.NotFoundHandler(),
+			expectedError: "oas2: cannot build handlers from http.HandlerFunc, struct is expected",
+		},
+		{
+			v:             (*petHandlers)(nil),
+			expectedError: "oas2: cannot build handlers from *oas2.petHandlers, struct is expected",
+		},
+		{
+			v: invalidHandlers{
+				Update: http.NotFoundHandler(),
+			},
+			expectedError: "oas2: cannot build handlers from oas2.invalidHandlers: " +
+				"method GetPetById: signature func(http.ResponseWriter) does not match http.HandlerFunc or func() http.Handler; " +
+				"field Update: operation updatePet is handled more than once; " +
+				"field Find: string is not a non-nil http.Handler",
+		},
+		{
+			v: invalidHandlers{},
+			expectedError: "oas2: cannot build handlers from oas2.invalidHandlers: " +
+				"method GetPetById: signature func(http.ResponseWriter) does not match http.HandlerFunc or func() http.Handler; " +
+				"field Update: http.Handler is not a non-nil http.Handler; " +
+				"field Find: string is not a non-nil http.Handler",
+		},
+	}
+
+	for _, c := range cases {
+		_, err := HandlersFromStruct(c.v)
+		if err == nil {
+			t.Errorf("Expected error for %T", c.v)
+			continue
+		}
+		if err.Error() != c.expectedError {
+			t.Errorf("Expected error to be\n%s\nbut got\n%s", c.expectedError, err)
+		}
+	}
+}
+
+func handlerIDs(handlers OperationHandlers) []string {
+	var ids []string
+	for id := range handlers {
+		ids = append(ids, id.String())
+	}
+	sort.Strings(ids)
+	return ids
+}
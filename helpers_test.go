@@ -36,6 +36,26 @@ func loadDoc() *loads.Document {
 	return doc
 }
 
+// parseSpec parses a spec from YAML source without validating it, so it can
+// be used to check how invalid specs are handled.
+func parseSpec(src string) *spec.Swagger {
+	yml, err := swag.BytesToYAMLDoc([]byte(src))
+	if err != nil {
+		log.Fatalln(err)
+	}
+	jsn, err := swag.YAMLToJSON(yml)
+	if err != nil {
+		log.Fatalln(err)
+	}
+
+	doc, err := loads.Analyzed(jsn, "2.0")
+	if err != nil {
+		log.Fatalln(err)
+	}
+
+	return doc.Spec()
+}
+
 var sp = []byte(`
 swagger: "2.0"
 info:
@@ -67,6 +87,7 @@ paths:
       operationId: "addPet"
       consumes:
       - "application/json"
+      - "application/xml"
       produces:
       - "application/json"
       parameters:
@@ -86,6 +107,55 @@ paths:
       - petstore_auth:
         - "write:pets"
         - "read:pets"
+  /pets:
+    post:
+      tags:
+      - "pet"
+      summary: "Add new pets to the store"
+      operationId: "addPets"
+      consumes:
+      - "application/json"
+      produces:
+      - "application/json"
+      parameters:
+      - in: "body"
+        name: "body"
+        description: "Pets that need to be added to the store"
+        required: true
+        schema:
+          type: "array"
+          items:
+            $ref: "#/definitions/Pet"
+      responses:
+        405:
+          description: "Invalid input"
+  /pet/{petId}/name:
+    put:
+      tags:
+      - "pet"
+      summary: "Rename a pet"
+      operationId: "renamePet"
+      consumes:
+      - "application/json"
+      produces:
+      - "application/json"
+      parameters:
+      - name: "petId"
+        in: "path"
+        description: "ID of pet to rename"
+        required: true
+        type: "integer"
+        format: "int64"
+      - in: "body"
+        name: "body"
+        description: "New pet name"
+        required: true
+        schema:
+          type: "string"
+          minLength: 3
+      responses:
+        405:
+          description: "Invalid input"
   /pet/{petId}:
     get:
       tags:
@@ -110,12 +180,47 @@ paths:
           description: "successful operation"
           schema:
             $ref: "#/definitions/Pet"
+          examples:
+            application/json:
+              id: 12
+              name: "Kitty"
+              age: 3
+            text/plain: "Kitty, 3 y.o."
         400:
           description: "Invalid ID supplied"
         404:
           description: "Pet not found"
       security:
       - api_key: []
+    post:
+      tags:
+      - "pet"
+      summary: "Updates a pet in the store with form data"
+      operationId: "updatePetWithForm"
+      consumes:
+      - "application/x-www-form-urlencoded"
+      produces:
+      - "application/json"
+      parameters:
+      - name: "petId"
+        in: "path"
+        description: "ID of pet that needs to be updated"
+        required: true
+        type: "integer"
+        format: "int64"
+      - name: "name"
+        in: "formData"
+        description: "Updated name of the pet"
+        required: true
+        type: "string"
+      - name: "status"
+        in: "formData"
+        description: "Updated status of the pet"
+        required: false
+        type: "string"
+      responses:
+        405:
+          description: "Invalid input"
   /user/login:
     get:
       tags:
@@ -184,7 +289,7 @@ definitions:
       tags:
         type: "array"
         items:
-          type: "array"
+          type: "string"
       status:
         type: "string"
         description: "pet status in the store"
@@ -0,0 +1,121 @@
+package oas2
+
+import (
+	"fmt"
+	"net/http"
+	"strconv"
+	"strings"
+)
+
+// NewAcceptCharsetValidator returns new Middleware that responds with
+// 406 Not Acceptable and the body written by errHandler to requests whose
+// Accept-Charset header forbids UTF-8, the only charset the server emits.
+func NewAcceptCharsetValidator(errHandler func(w http.ResponseWriter, errs []error), options ...MiddlewareOption) Middleware {
+	return acceptCharsetValidator{
+		errHandler: errHandler,
+		opts:       newMiddlewareOptions(options),
+	}
+}
+
+type acceptCharsetValidator struct {
+	errHandler func(w http.ResponseWriter, errs []error)
+	opts       MiddlewareOptions
+}
+
+func (m acceptCharsetValidator) Apply(next http.Handler) http.Handler {
+	return http.HandlerFunc(func(w http.ResponseWriter, req *http.Request) {
+		if m.opts.operationResolver(req) == nil {
+			next.ServeHTTP(w, req)
+			return
+		}
+
+		// Any charset is acceptable when the header is not sent.
+		charsets := req.Header.Get("Accept-Charset")
+		if strings.TrimSpace(charsets) != "" && !acceptsToken(charsets, "utf-8") {
+			writeErrorsWithStatus(w, http.StatusNotAcceptable, operationErrHandler(req, m.errHandler), []error{
+				ValidationErrorf("Accept-Charset", charsets, "Header Accept-Charset must allow utf-8"),
+			})
+			return
+		}
+
+		next.ServeHTTP(w, req)
+	})
+}
+
+// parseQuality parses a header list element like "utf-8;q=0.5" to the value
+// and its quality.
+func parseQuality(s string) (value string, q float64) {
+	q = 1
+	parts := strings.Split(s, ";")
+	value = strings.TrimSpace(parts[0])
+	for _, param := range parts[1:] {
+		param = strings.TrimSpace(param)
+		if strings.HasPrefix(param, "q=") {
+			if f, err := strconv.ParseFloat(param[2:], 64); err == nil {
+				q = f
+			}
+		}
+	}
+	return value, q
+}
+
+// acceptsToken reports whether the header value, a list of tokens with
+// qualities like Accept-Charset or Accept-Encoding, allows the token.
+// Explicit preference of the token wins over the wildcard.
+func acceptsToken(header, token string) bool {
+	wildcard := false
+	for _, part := range strings.Split(header, ",") {
+		value, q := parseQuality(part)
+		switch {
+		case strings.EqualFold(value, token):
+			return q > 0
+		case value == "*":
+			wildcard = q > 0
+		}
+	}
+	return wildcard
+}
+
+// extContentLanguage is an operation extension that declares the language of
+// the operation responses.
+const extContentLanguage = "x-content-language"
+
+// NewContentLanguage returns new Middleware that sets Content-Language header
+// of responses to the language declared by the operation "x-content-language"
+// extension. When the handler sets another language, SpecMismatchFn is called.
+func NewContentLanguage(options ...MiddlewareOption) Middleware {
+	return contentLanguage{
+		opts: newMiddlewareOptions(options),
+	}
+}
+
+type contentLanguage struct {
+	opts MiddlewareOptions
+}
+
+func (m contentLanguage) Apply(next http.Handler) http.Handler {
+	return http.HandlerFunc(func(w http.ResponseWriter, req *http.Request) {
+		op := m.opts.operationResolver(req)
+		if op == nil {
+			next.ServeHTTP(w, req)
+			return
+		}
+
+		lang, ok := op.Extensions.GetString(extContentLanguage)
+		if !ok {
+			next.ServeHTTP(w, req)
+			return
+		}
+
+		// Handlers can override the header, which is checked below.
+		w.Header().Set("Content-Language", lang)
+
+		next.ServeHTTP(w, req)
+
+		if actual := w.Header().Get("Content-Language"); actual != lang {
+			m.opts.specMismatchFn(req, fmt.Errorf(
+				"response content language is %s, want %s", actual, lang,
+			))
+		}
+	})
+}
@@ -0,0 +1,124 @@
+package oas2
+
+import (
+	"fmt"
+	"net/http"
+	"net/http/httptest"
+	"reflect"
+	"testing"
+)
+
+func TestAcceptCharsetValidator_Apply(t *testing.T) {
+	cases := []struct {
+		acceptCharset   string
+		expectedStatus  int
+		expectedPayload string
+	}{
+		// no preference
+		{
+			expectedStatus: http.StatusOK,
+		},
+		{
+			acceptCharset:  "iso-8859-1, utf-8;q=0.5",
+			expectedStatus: http.StatusOK,
+		},
+		{
+			acceptCharset:  "iso-8859-1, *;q=0.1",
+			expectedStatus: http.StatusOK,
+		},
+		// UTF-8 is not accepted
+		{
+			acceptCharset:   "iso-8859-1",
+			expectedStatus:  http.StatusNotAcceptable,
+			expectedPayload: `{"errors":[{"message":"Header Accept-Charset must allow utf-8","field":"Accept-Charset","value":"iso-8859-1"}]}`,
+		},
+		// UTF-8 is forbidden explicitly
+		{
+			acceptCharset:   "*, utf-8;q=0",
+			expectedStatus:  http.StatusNotAcceptable,
+			expectedPayload: `{"errors":[{"message":"Header Accept-Charset must allow utf-8","field":"Accept-Charset","value":"*, utf-8;q=0"}]}`,
+		},
+	}
+
+	doc := loadDoc()
+
+	handlers := OperationHandlers{"getPetById": http.HandlerFunc(func(w http.ResponseWriter, req *http.Request) {
+		fmt.Fprint(w, `{"id":12,"name":"Kitty","age":3}`)
+	})}
+
+	router, err := NewRouter(doc.Spec(), handlers, MiddlewareOpt(NewAcceptCharsetValidator(writeErrorsToResponseWriter).Apply))
+	if err != nil {
+		t.Fatal(err)
+	}
+
+	for _, c := range cases {
+		req := httptest.NewRequest(http.MethodGet, "/v2/pet/12", nil)
+		if c.acceptCharset != "" {
+			req.Header.Set("Accept-Charset", c.acceptCharset)
+		}
+
+		w := httptest.NewRecorder()
+		router.ServeHTTP(w, req)
+
+		if w.Code != c.expectedStatus {
+			t.Errorf("Expected status for Accept-Charset %q to be %d but got %d", c.acceptCharset, c.expectedStatus, w.Code)
+		}
+		if c.expectedPayload != "" && w.Body.String() != c.expectedPayload {
+			t.Errorf("Expected response body to be\n%s\nbut got\n%s", c.expectedPayload, w.Body.String())
+		}
+	}
+}
+
+func TestContentLanguage_Apply(t *testing.T) {
+	sw := parseSpec(`
+swagger: "2.0"
+info:
+  title: test
+  version: "1.0"
+basePath: /v1
+paths:
+  /greeting:
+    get:
+      operationId: greet
+      x-content-language: de
+      responses:
+        200:
+          description: ok
+`)
+
+	var lang string
+	handlers := OperationHandlers{"greet": http.HandlerFunc(func(w http.ResponseWriter, req *http.Request) {
+		if lang != "" {
+			w.Header().Set("Content-Language", lang)
+		}
+		fmt.Fprint(w, "Hallo")
+	})}
+
+	var mismatches []string
+	mw := NewContentLanguage(SpecMismatchOpt(func(req *http.Request, err error) {
+		mismatches = append(mismatches, err.Error())
+	}))
+
+	router, err := NewRouter(sw, handlers, MiddlewareOpt(mw.Apply))
+	if err != nil {
+		t.Fatal(err)
+	}
+
+	w := httptest.NewRecorder()
+	router.ServeHTTP(w, httptest.NewRequest(http.MethodGet, "/v1/greeting", nil))
+
+	if actual := w.Header().Get("Content-Language"); actual != "de" {
+		t.Errorf("Expected Content-Language to be %s but got %s", "de", actual)
+	}
+	if len(mismatches) != 0 {
+		t.Errorf("Expected no spec mismatches but got %v", mismatches)
+	}
+
+	lang = "en"
+	router.ServeHTTP(httptest.NewRecorder(), httptest.NewRequest(http.MethodGet, "/v1/greeting", nil))
+
+	expectedMismatches := []string{"response content language is en, want de"}
+	if !reflect.DeepEqual(expectedMismatches, mismatches) {
+		t.Errorf("Expected spec mismatches to be %v but got %v", expectedMismatches, mismatches)
+	}
+}
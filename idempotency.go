@@ -0,0 +1,280 @@
+package oas2
+
+import (
+	"bytes"
+	"context"
+	"crypto/sha256"
+	"encoding/hex"
+	"errors"
+	"fmt"
+	"io"
+	"io/ioutil"
+	"net/http"
+	"sync"
+)
+
+const (
+	// idempotencyKeyHeader is the header clients send the idempotency key in.
+	idempotencyKeyHeader = "Idempotency-Key"
+
+	// idempotentReplayedHeader marks responses replayed from the store.
+	idempotentReplayedHeader = "Idempotent-Replayed"
+
+	// extIdempotencyKey is an operation extension that makes the idempotency
+	// key required for the operation when set to true.
+	extIdempotencyKey = "x-idempotency-key"
+
+	// maxIdempotencyKeyLen is the maximum length of an idempotency key.
+	maxIdempotencyKeyLen = 255
+)
+
+// DefaultIdempotencyBodyLimit is the default maximum size of request bodies
+// fingerprinted by idempotency middleware.
+const DefaultIdempotencyBodyLimit = 10 << 20
+
+// IdempotencyBodyLimitOpt returns an option that limits the size of request
+// bodies idempotency middleware buffers to fingerprint requests. Requests
+// with larger bodies are responded with 413 Request Entity Too Large. Zero
+// means no limit. By default, DefaultIdempotencyBodyLimit is used.
+func IdempotencyBodyLimitOpt(limit int64) MiddlewareOption {
+	return func(args *MiddlewareOptions) {
+		args.fingerprintLimit = limit
+	}
+}
+
+// IdempotentResponse is a response stored for an idempotency key.
+type IdempotentResponse struct {
+	Status int
+	Header http.Header
+	Body   []byte
+
+	// Fingerprint identifies the request the response is stored for by its
+	// method, path, query and body. Empty fingerprint matches any request.
+	Fingerprint string
+}
+
+// IdempotencyStore stores responses by idempotency keys, so requests
+// repeated with the same key get the same response. Keys are unique per
+// client, so implementations usually scope them, e.g. by the authenticated
+// user taken from the request.
+type IdempotencyStore interface {
+	// Get returns the response stored for the key.
+	Get(req *http.Request, key string) (*IdempotentResponse, bool)
+
+	// Put stores the response for the key.
+	Put(req *http.Request, key string, resp *IdempotentResponse)
+}
+
+// NewIdempotency returns new Middleware that reads the idempotency key from
+// Idempotency-Key header and sets it to the request's context, see
+// GetIdempotencyKey. The key is required for operations with
+// "x-idempotency-key" extension set to true. Keys must be 1 to 255 printable
+// ASCII characters. Requests with a missing or malformed key are responded
+// with 400 Bad Request and the body written by errHandler.
+//
+// If store is not nil, responses with status below 500 are stored by the
+// key, and requests repeated with the key are responded with the stored
+// response marked by Idempotent-Replayed header, without calling the
+// handler. Responses exceeding ResponseBufferLimitOpt are not stored, and
+// requests with bodies exceeding IdempotencyBodyLimitOpt are rejected.
+// Requests repeated with the key but a different method, path, query or
+// body are responded with 422 Unprocessable Entity, and requests with the
+// key of a request still in progress are responded with 409 Conflict, both
+// with the body written by errHandler. Keys in progress are tracked by the
+// middleware regardless of the store scope, so clients should use random
+// keys, e.g. UUIDs.
+func NewIdempotency(store IdempotencyStore, errHandler func(w http.ResponseWriter, errs []error), options ...MiddlewareOption) Middleware {
+	return idempotency{
+		store:      store,
+		errHandler: errHandler,
+		opts:       newMiddlewareOptions(options),
+		inFlight:   &idempotencyKeys{keys: make(map[string]struct{})},
+	}
+}
+
+type idempotency struct {
+	store      IdempotencyStore
+	errHandler func(w http.ResponseWriter, errs []error)
+	opts       MiddlewareOptions
+	inFlight   *idempotencyKeys
+}
+
+// idempotencyKeys tracks keys of requests in progress.
+type idempotencyKeys struct {
+	mu   sync.Mutex
+	keys map[string]struct{}
+}
+
+// acquire marks the key as in progress, and reports false if it already is.
+func (k *idempotencyKeys) acquire(key string) bool {
+	k.mu.Lock()
+	defer k.mu.Unlock()
+	if _, ok := k.keys[key]; ok {
+		return false
+	}
+	k.keys[key] = struct{}{}
+	return true
+}
+
+func (k *idempotencyKeys) release(key string) {
+	k.mu.Lock()
+	delete(k.keys, key)
+	k.mu.Unlock()
+}
+
+func (m idempotency) Apply(next http.Handler) http.Handler {
+	return http.HandlerFunc(func(w http.ResponseWriter, req *http.Request) {
+		op := m.opts.operationResolver(req)
+		if op == nil {
+			next.ServeHTTP(w, req)
+			return
+		}
+
+		errHandler := operationErrHandler(req, m.errHandler)
+
+		key := req.Header.Get(idempotencyKeyHeader)
+		if key == "" {
+			if required, _ := op.Extensions.GetBool(extIdempotencyKey); required {
+				writeErrorsWithStatus(w, http.StatusBadRequest, errHandler, []error{
+					ValidationErrorf(idempotencyKeyHeader, nil, "Header %s is required", idempotencyKeyHeader),
+				})
+				return
+			}
+			next.ServeHTTP(w, req)
+			return
+		}
+
+		if !validIdempotencyKey(key) {
+			writeErrorsWithStatus(w, http.StatusBadRequest, errHandler, []error{
+				ValidationErrorf(idempotencyKeyHeader, key, "Header %s must be 1 to %d printable ASCII characters", idempotencyKeyHeader, maxIdempotencyKeyLen),
+			})
+			return
+		}
+
+		req = req.WithContext(
+			context.WithValue(req.Context(), contextKeyIdempotencyKey{}, key),
+		)
+
+		if m.store == nil {
+			next.ServeHTTP(w, req)
+			return
+		}
+
+		if !m.inFlight.acquire(key) {
+			writeErrorsWithStatus(w, http.StatusConflict, errHandler, []error{
+				ValidationErrorf(idempotencyKeyHeader, key, "Request with header %s is in progress", idempotencyKeyHeader),
+			})
+			return
+		}
+		defer m.inFlight.release(key)
+
+		fingerprint, err := requestFingerprint(req, m.opts.fingerprintLimit)
+		if err == errBodyTooLarge {
+			writeErrorsWithStatus(w, http.StatusRequestEntityTooLarge, errHandler, []error{
+				fmt.Errorf("Body is too large, at most %d bytes are allowed", m.opts.fingerprintLimit),
+			})
+			return
+		}
+		if err != nil {
+			writeErrorsWithStatus(w, http.StatusBadRequest, errHandler, []error{
+				fmt.Errorf("Body cannot be read"),
+			})
+			return
+		}
+
+		if resp, ok := m.store.Get(req, key); ok {
+			if resp.Fingerprint != "" && resp.Fingerprint != fingerprint {
+				writeErrorsWithStatus(w, http.StatusUnprocessableEntity, errHandler, []error{
+					ValidationErrorf(idempotencyKeyHeader, key, "Header %s is reused for a different request", idempotencyKeyHeader),
+				})
+				return
+			}
+
+			for name, vals := range resp.Header {
+				w.Header()[name] = append([]string(nil), vals...)
+			}
+			w.Header().Set(idempotentReplayedHeader, "true")
+			w.WriteHeader(resp.Status)
+			w.Write(resp.Body)
+			// The stored response has been validated when stored.
+			MarkResponseValidated(req)
+			return
+		}
+
+		rr := NewLimitedResponseRecorder(w, m.opts.responseBufferLimit)
+		next.ServeHTTP(rr, req)
+
+		if rr.Status() >= http.StatusInternalServerError || overflowed(rr) {
+			return
+		}
+		m.store.Put(req, key, &IdempotentResponse{
+			Status:      rr.Status(),
+			Header:      cloneHeader(rr.Header()),
+			Body:        append([]byte(nil), rr.Payload()...),
+			Fingerprint: fingerprint,
+		})
+	})
+}
+
+// errBodyTooLarge is returned by requestFingerprint for bodies exceeding
+// the limit.
+var errBodyTooLarge = errors.New("body is too large")
+
+// requestFingerprint returns the hash of the request method, path, query
+// and body. The body is hashed while it is buffered, which stops with
+// errBodyTooLarge once the body exceeds limit, unless limit is zero. The
+// body is replaced, so it can be read again.
+func requestFingerprint(req *http.Request, limit int64) (string, error) {
+	h := sha256.New()
+	io.WriteString(h, req.Method+" "+req.URL.Path+"?"+req.URL.RawQuery+"\n")
+
+	if req.Body != nil && req.Body != http.NoBody {
+		var r io.Reader = req.Body
+		if limit > 0 {
+			r = io.LimitReader(r, limit+1)
+		}
+		var b bytes.Buffer
+		n, err := io.Copy(io.MultiWriter(h, &b), r)
+		if err != nil {
+			return "", err
+		}
+		if limit > 0 && n > limit {
+			return "", errBodyTooLarge
+		}
+		req.Body.Close()
+		req.Body = ioutil.NopCloser(&b)
+	}
+
+	return hex.EncodeToString(h.Sum(nil)), nil
+}
+
+// GetIdempotencyKey returns the idempotency key of the request, or an empty
+// string if there is none.
+func GetIdempotencyKey(req *http.Request) string {
+	key, _ := req.Context().Value(contextKeyIdempotencyKey{}).(string)
+	return key
+}
+
+type contextKeyIdempotencyKey struct{}
+
+// validIdempotencyKey reports whether the key is 1 to maxIdempotencyKeyLen
+// printable ASCII characters.
+func validIdempotencyKey(key string) bool {
+	if len(key) == 0 || len(key) > maxIdempotencyKeyLen {
+		return false
+	}
+	for i := 0; i < len(key); i++ {
+		if key[i] < 0x21 || key[i] > 0x7e {
+			return false
+		}
+	}
+	return true
+}
+
+func cloneHeader(h http.Header) http.Header {
+	c := make(http.Header, len(h))
+	for name, vals := range h {
+		c[name] = append([]string(nil), vals...)
+	}
+	return c
+}
@@ -0,0 +1,228 @@
+package oas2
+
+import (
+	"fmt"
+	"net/http"
+	"net/http/httptest"
+	"strings"
+	"testing"
+)
+
+type memoryIdempotencyStore map[string]*IdempotentResponse
+
+func (s memoryIdempotencyStore) Get(req *http.Request, key string) (*IdempotentResponse, bool) {
+	resp, ok := s[key]
+	return resp, ok
+}
+
+func (s memoryIdempotencyStore) Put(req *http.Request, key string, resp *IdempotentResponse) {
+	s[key] = resp
+}
+
+func TestIdempotency_Apply(t *testing.T) {
+	sw := parseSpec(`
+swagger: "2.0"
+info:
+  title: test
+  version: "1.0"
+basePath: /v1
+paths:
+  /payments:
+    post:
+      operationId: createPayment
+      x-idempotency-key: true
+      responses:
+        201:
+          description: created
+  /refunds:
+    post:
+      operationId: createRefund
+      responses:
+        201:
+          description: created
+`)
+
+	cases := []struct {
+		path             string
+		key              string
+		body             string
+		expectedStatus   int
+		expectedPayload  string
+		expectedReplayed string
+	}{
+		// missing required key
+		{
+			path:            "/v1/payments",
+			expectedStatus:  http.StatusBadRequest,
+			expectedPayload: `{"errors":[{"message":"Header Idempotency-Key is required","field":"Idempotency-Key"}]}`,
+		},
+		// malformed key
+		{
+			path:            "/v1/payments",
+			key:             "two words",
+			expectedStatus:  http.StatusBadRequest,
+			expectedPayload: `{"errors":[{"message":"Header Idempotency-Key must be 1 to 255 printable ASCII characters","field":"Idempotency-Key","value":"two words"}]}`,
+		},
+		// first request
+		{
+			path:            "/v1/payments",
+			key:             "a1",
+			expectedStatus:  http.StatusCreated,
+			expectedPayload: "payment 1 for a1",
+		},
+		// replay
+		{
+			path:             "/v1/payments",
+			key:              "a1",
+			expectedStatus:   http.StatusCreated,
+			expectedPayload:  "payment 1 for a1",
+			expectedReplayed: "true",
+		},
+		// key reused for a different body
+		{
+			path:            "/v1/payments",
+			key:             "a1",
+			body:            `{"amount":100}`,
+			expectedStatus:  http.StatusUnprocessableEntity,
+			expectedPayload: `{"errors":[{"message":"Header Idempotency-Key is reused for a different request","field":"Idempotency-Key","value":"a1"}]}`,
+		},
+		// another key
+		{
+			path:            "/v1/payments",
+			key:             "b2",
+			expectedStatus:  http.StatusCreated,
+			expectedPayload: "payment 2 for b2",
+		},
+		// body too large to fingerprint
+		{
+			path:            "/v1/payments",
+			key:             "c3",
+			body:            `{"amount":100,"comment":"a long comment"}`,
+			expectedStatus:  http.StatusRequestEntityTooLarge,
+			expectedPayload: `{"errors":[{"message":"Body is too large, at most 32 bytes are allowed"}]}`,
+		},
+		// key is optional
+		{
+			path:            "/v1/refunds",
+			expectedStatus:  http.StatusCreated,
+			expectedPayload: "refund",
+		},
+	}
+
+	var payments int
+	handlers := OperationHandlers{
+		"createPayment": http.HandlerFunc(func(w http.ResponseWriter, req *http.Request) {
+			payments++
+			w.WriteHeader(http.StatusCreated)
+			fmt.Fprintf(w, "payment %d for %s", payments, GetIdempotencyKey(req))
+		}),
+		"createRefund": http.HandlerFunc(func(w http.ResponseWriter, req *http.Request) {
+			w.WriteHeader(http.StatusCreated)
+			fmt.Fprint(w, "refund")
+		}),
+	}
+
+	idempotency := NewIdempotency(memoryIdempotencyStore{}, writeErrorsToResponseWriter, IdempotencyBodyLimitOpt(32))
+
+	router, err := NewRouter(sw, handlers, MiddlewareOpt(idempotency.Apply))
+	if err != nil {
+		t.Fatal(err)
+	}
+
+	for _, c := range cases {
+		body := c.body
+		if body == "" {
+			body = "{}"
+		}
+		req := httptest.NewRequest(http.MethodPost, c.path, strings.NewReader(body))
+		if c.key != "" {
+			req.Header.Set("Idempotency-Key", c.key)
+		}
+		w := httptest.NewRecorder()
+
+		router.ServeHTTP(w, req)
+
+		if w.Code != c.expectedStatus {
+			t.Errorf("Expected status to be %d but got %d", c.expectedStatus, w.Code)
+		}
+		if w.Body.String() != c.expectedPayload {
+			t.Errorf("Expected response body to be\n%s\nbut got\n%s", c.expectedPayload, w.Body.String())
+		}
+		if actual := w.Header().Get("Idempotent-Replayed"); actual != c.expectedReplayed {
+			t.Errorf("Expected Idempotent-Replayed header to be %q but got %q", c.expectedReplayed, actual)
+		}
+	}
+}
+
+func TestIdempotency_Apply_inProgress(t *testing.T) {
+	sw := parseSpec(`
+swagger: "2.0"
+info:
+  title: test
+  version: "1.0"
+basePath: /v1
+paths:
+  /payments:
+    post:
+      operationId: createPayment
+      responses:
+        201:
+          description: created
+`)
+
+	started := make(chan struct{})
+	release := make(chan struct{})
+	handlers := OperationHandlers{
+		"createPayment": http.HandlerFunc(func(w http.ResponseWriter, req *http.Request) {
+			close(started)
+			<-release
+			w.WriteHeader(http.StatusCreated)
+		}),
+	}
+
+	idempotency := NewIdempotency(memoryIdempotencyStore{}, writeErrorsToResponseWriter)
+
+	router, err := NewRouter(sw, handlers, MiddlewareOpt(idempotency.Apply))
+	if err != nil {
+		t.Fatal(err)
+	}
+
+	newRequest := func() *http.Request {
+		req := httptest.NewRequest(http.MethodPost, "/v1/payments", strings.NewReader("{}"))
+		req.Header.Set("Idempotency-Key", "a1")
+		return req
+	}
+
+	first := httptest.NewRecorder()
+	done := make(chan struct{})
+	go func() {
+		router.ServeHTTP(first, newRequest())
+		close(done)
+	}()
+	<-started
+
+	w := httptest.NewRecorder()
+	router.ServeHTTP(w, newRequest())
+
+	if w.Code != http.StatusConflict {
+		t.Errorf("Expected status to be %d but got %d", http.StatusConflict, w.Code)
+	}
+	expectedPayload := `{"errors":[{"message":"Request with header Idempotency-Key is in progress","field":"Idempotency-Key","value":"a1"}]}`
+	if w.Body.String() != expectedPayload {
+		t.Errorf("Expected response body to be\n%s\nbut got\n%s", expectedPayload, w.Body.String())
+	}
+
+	close(release)
+	<-done
+	if first.Code != http.StatusCreated {
+		t.Errorf("Expected status of the first request to be %d but got %d", http.StatusCreated, first.Code)
+	}
+
+	// The key is released, so the request is replayed.
+	w = httptest.NewRecorder()
+	router.ServeHTTP(w, newRequest())
+
+	if w.Code != http.StatusCreated || w.Header().Get("Idempotent-Replayed") != "true" {
+		t.Errorf("Expected request to be replayed but got %d %v", w.Code, w.Header())
+	}
+}
@@ -0,0 +1,104 @@
+package oas2
+
+import (
+	"fmt"
+	"mime"
+	"strings"
+)
+
+const (
+	mediaTypeJSON      = "application/json"
+	mediaTypeXML       = "application/xml"
+	mediaTypeTextXML   = "text/xml"
+	mediaTypeForm      = "application/x-www-form-urlencoded"
+	mediaTypeMultipart = "multipart/form-data"
+	mediaTypeNDJSON    = "application/x-ndjson"
+)
+
+// consumedMediaType returns the media type the request body should be
+// decoded as, and reports whether the operation consumes it. It is the
+// request's content type, or the first media type the operation consumes
+// when the content type is not set. JSON is assumed when consumes is not
+// specified.
+func consumedMediaType(contentType string, consumes []string) (string, bool) {
+	if len(consumes) == 0 {
+		return mediaTypeJSON, true
+	}
+	if contentType == "" {
+		return parseMediaType(consumes[0]), true
+	}
+
+	mt := parseMediaType(contentType)
+	for _, c := range consumes {
+		if parseMediaType(c) == mt {
+			return mt, true
+		}
+	}
+
+	return mt, false
+}
+
+// parseMediaType returns the media type without parameters in lower case.
+func parseMediaType(contentType string) string {
+	mt, _, err := mime.ParseMediaType(contentType)
+	if err != nil {
+		return strings.ToLower(strings.TrimSpace(contentType))
+	}
+	return mt
+}
+
+// mediaTypeTops lists registered top-level media types.
+var mediaTypeTops = map[string]bool{
+	"application": true,
+	"audio":       true,
+	"example":     true,
+	"font":        true,
+	"image":       true,
+	"message":     true,
+	"model":       true,
+	"multipart":   true,
+	"text":        true,
+	"video":       true,
+}
+
+// checkMediaType returns an error if s is not a well-formed media type of
+// a registered top-level type, which catches typos like "aplication/json".
+// Wildcards and "x-" extension types are allowed.
+func checkMediaType(s string) error {
+	mt, _, err := mime.ParseMediaType(s)
+	if err != nil {
+		return err
+	}
+
+	parts := strings.SplitN(mt, "/", 2)
+	if len(parts) != 2 || parts[1] == "" {
+		return fmt.Errorf("subtype is missing")
+	}
+	if top := parts[0]; top != "*" && !mediaTypeTops[top] && !strings.HasPrefix(top, "x-") {
+		return fmt.Errorf("unknown top-level type %s", top)
+	}
+	return nil
+}
+
+func isJSONMediaType(mt string) bool {
+	return mt == mediaTypeJSON || strings.HasSuffix(mt, "+json")
+}
+
+func isXMLMediaType(mt string) bool {
+	return mt == mediaTypeXML || mt == mediaTypeTextXML || strings.HasSuffix(mt, "+xml")
+}
+
+func isFormMediaType(mt string) bool {
+	return mt == mediaTypeForm || mt == mediaTypeMultipart
+}
+
+// producesNDJSON reports whether the operation produces newline delimited
+// JSON streams.
+func producesNDJSON(produces []string) bool {
+	for _, p := range produces {
+		if parseMediaType(p) == mediaTypeNDJSON {
+			return true
+		}
+	}
+	return false
+}
@@ -0,0 +1,49 @@
+package oas2
+
+import (
+	"net/http"
+	"strings"
+)
+
+// methodOverrideHeader is the header clients use to signal the method when
+// they can only issue GET and POST requests.
+const methodOverrideHeader = "X-HTTP-Method-Override"
+
+// NewMethodOverride returns new Middleware that replaces the method of POST
+// requests with the one from X-HTTP-Method-Override header. Only the methods
+// given are allowed as overrides, by default PUT, PATCH and DELETE. Other
+// overrides are ignored.
+//
+// The middleware must run before routing, see MethodOverrideOpt.
+func NewMethodOverride(methods ...string) Middleware {
+	if len(methods) == 0 {
+		methods = []string{http.MethodPut, http.MethodPatch, http.MethodDelete}
+	}
+
+	allowed := make(map[string]struct{}, len(methods))
+	for _, method := range methods {
+		allowed[strings.ToUpper(method)] = struct{}{}
+	}
+
+	return methodOverride{allowed: allowed}
+}
+
+type methodOverride struct {
+	allowed map[string]struct{}
+}
+
+func (m methodOverride) Apply(next http.Handler) http.Handler {
+	return http.HandlerFunc(func(w http.ResponseWriter, req *http.Request) {
+		if req.Method != http.MethodPost {
+			next.ServeHTTP(w, req)
+			return
+		}
+
+		method := strings.ToUpper(strings.TrimSpace(req.Header.Get(methodOverrideHeader)))
+		if _, ok := m.allowed[method]; ok {
+			req.Method = method
+		}
+
+		next.ServeHTTP(w, req)
+	})
+}
@@ -8,6 +8,13 @@ import (
 	"io"
 	"io/ioutil"
 	"net/http"
+	"net/url"
+	"sort"
+	"strconv"
+	"time"
+
+	"github.com/go-openapi/spec"
+	"github.com/go-openapi/strfmt"
 )
 
 // MiddlewareFn describes middleware function.
@@ -18,50 +25,668 @@ type Middleware interface {
 	Apply(next http.Handler) http.Handler
 }
 
+// MiddlewareOptions is options for oas2 middlewares.
+type MiddlewareOptions struct {
+	operationResolver   OperationResolver
+	specMismatchFn      SpecMismatchFn
+	responseBufferLimit int
+	passthroughHeader   string
+	queryAllowlist      map[string]struct{}
+	strictBody          bool
+	cacheSize           int
+	cacheTTL            time.Duration
+	statusRanges        []StatusRange
+	numberLocale        *NumberLocale
+	defaultResponse     bool
+	successFallback     bool
+	transformers        []ResponseTransformer
+	formats             strfmt.Registry
+	maxJSONDepth        int
+	paramHeader         ParamHeaderFunc
+	normalizers         map[string]Normalizer
+	converters          map[string]Converter
+	trailingData        TrailingData
+	repeatedParam       RepeatedParam
+	canonicalQuery      bool
+	rejectDuplicateKeys bool
+	failFast            bool
+	streamArrays        bool
+	lenientFormats      bool
+	multipartLimits     MultipartLimits
+	compressionMinSize  int
+	bodyDecoders        map[string]BodyDecoder
+	responseValidators  map[string]ResponseValidator
+	acceptedVersions    []string
+	uncheckedSecurity   bool
+	pathParamExtractor  func(r *http.Request, key string) string
+	fingerprintLimit    int64
+	clock               Clock
+}
+
+// MiddlewareOption is an option for oas2 middlewares.
+type MiddlewareOption func(*MiddlewareOptions)
+
+// OperationResolverOpt returns an option that sets a function that resolves
+// the operation of a request. By default, the operation set by oas2 router
+// is used, see GetOperation.
+func OperationResolverOpt(resolver OperationResolver) MiddlewareOption {
+	return func(args *MiddlewareOptions) {
+		args.operationResolver = resolver
+	}
+}
+
+// QueryAllowlistOpt returns an option that sets names of query parameters
+// that strict query middleware and query validator always accept, e.g. "_"
+// used as a cache-buster. Pass it to both when they are used together.
+func QueryAllowlistOpt(names ...string) MiddlewareOption {
+	return func(args *MiddlewareOptions) {
+		for _, name := range names {
+			args.queryAllowlist[name] = struct{}{}
+		}
+	}
+}
+
+// StrictBodyOpt returns an option that makes body validator reject request
+// bodies for operations that declare neither body nor form data parameters,
+// e.g. a body sent with GET request. Otherwise such bodies are passed
+// through without validation.
+func StrictBodyOpt(strict bool) MiddlewareOption {
+	return func(args *MiddlewareOptions) {
+		args.strictBody = strict
+	}
+}
+
+// BodyValidationCacheOpt returns an option that makes body validator cache
+// validation results of JSON bodies, so identical bodies sent to the same
+// operation are not validated again. The cache keeps at most size results
+// for ttl each. Zero ttl means results do not expire.
+func BodyValidationCacheOpt(size int, ttl time.Duration) MiddlewareOption {
+	return func(args *MiddlewareOptions) {
+		args.cacheSize = size
+		args.cacheTTL = ttl
+	}
+}
+
+// MaxJSONDepthOpt returns an option that limits the nesting depth of arrays
+// and objects in JSON bodies accepted by body validator. Deeper bodies are
+// rejected before they are decoded completely, which protects against stack
+// exhaustion. Zero means no limit. By default, DefaultMaxJSONDepth is used.
+func MaxJSONDepthOpt(depth int) MiddlewareOption {
+	return func(args *MiddlewareOptions) {
+		args.maxJSONDepth = depth
+	}
+}
+
+// TrailingDataOpt returns an option that sets how body validator handles data
+// after the JSON value of a body. By default, TrailingDataIgnore is used.
+func TrailingDataOpt(mode TrailingData) MiddlewareOption {
+	return func(args *MiddlewareOptions) {
+		args.trailingData = mode
+	}
+}
+
+// BodyDecoder decodes a request body to a value of JSON types, e.g.
+// map[string]interface{} for objects, so it is validated against the body
+// parameter schema.
+type BodyDecoder func(r io.Reader) (interface{}, error)
+
+// BodyDecoderOpt returns an option that makes body validator decode bodies
+// of the media type with the decoder and validate them against the body
+// parameter schema, like JSON bodies. It is used to support media types
+// that have no built-in support, see e.g. package cbor.
+func BodyDecoderOpt(mediaType string, decoder BodyDecoder) MiddlewareOption {
+	return func(args *MiddlewareOptions) {
+		if args.bodyDecoders == nil {
+			args.bodyDecoders = make(map[string]BodyDecoder)
+		}
+		args.bodyDecoders[parseMediaType(mediaType)] = decoder
+	}
+}
+
+// ResponseValidator validates a response payload against the response
+// schema and returns errors if any.
+type ResponseValidator func(sch *spec.Schema, payload []byte) []error
+
+// ResponseValidatorOpt returns an option that makes response body validator
+// validate responses written with the media type in Content-Type header by
+// the validator. It replaces the built-in validation for the media type:
+// JSON responses are validated against the schema, XML responses are only
+// checked to be well-formed, and responses of other media types are
+// validated as JSON.
+func ResponseValidatorOpt(mediaType string, validator ResponseValidator) MiddlewareOption {
+	return func(args *MiddlewareOptions) {
+		if args.responseValidators == nil {
+			args.responseValidators = make(map[string]ResponseValidator)
+		}
+		args.responseValidators[parseMediaType(mediaType)] = validator
+	}
+}
+
+// FailFastOpt returns an option that makes query and body validators report
+// only the first validation error. Query and form parameters are not
+// validated after the first error, which saves work for heavily invalid
+// requests. By default, all errors are reported.
+func FailFastOpt(enabled bool) MiddlewareOption {
+	return func(args *MiddlewareOptions) {
+		args.failFast = enabled
+	}
+}
+
+// LenientFormatsOpt returns an option that makes query and form data
+// validators convert values of formats unknown to oas2, e.g. custom or
+// vendor formats, as values of their base type: strings, int64 integers or
+// float64 numbers. Such formats are still validated if they are registered
+// in the formats registry, see FormatsOpt. By default, values of unknown
+// formats are rejected.
+func LenientFormatsOpt(enabled bool) MiddlewareOption {
+	return func(args *MiddlewareOptions) {
+		args.lenientFormats = enabled
+	}
+}
+
+// convertParam converts values of the parameter in the number locale. Values
+// of unknown formats are converted as of their base type if lenient formats
+// are enabled. Parameters naming a converter are converted by it.
+func (opts MiddlewareOptions) convertParam(p spec.Parameter, vals []string) (interface{}, error) {
+	c, err := opts.converter(p)
+	if err != nil {
+		return nil, err
+	}
+	if c != nil {
+		return c(p, vals)
+	}
+	if opts.lenientFormats {
+		p = withKnownFormats(p)
+	}
+	return convertParam(p, vals, opts.numberLocale)
+}
+
+// MultipartLimitsOpt returns an option that limits multipart bodies validated
+// by body validator. Bodies with too many parts are responded with 400 Bad
+// Request, and bodies with too large parts or of too large size with
+// 413 Request Entity Too Large. By default, multipart bodies are not limited.
+func MultipartLimitsOpt(limits MultipartLimits) MiddlewareOption {
+	return func(args *MiddlewareOptions) {
+		args.multipartLimits = limits
+	}
+}
+
+// StreamArraysOpt returns an option that makes body validator decode JSON
+// bodies declared as arrays item by item, validating each item against the
+// items schema as soon as it is read, so the decoded array is never held in
+// memory. Errors are named by indices of the invalid items, e.g. "3.name".
+// Arrays with uniqueItems, or declared by a reference, are decoded at once.
+func StreamArraysOpt(enabled bool) MiddlewareOption {
+	return func(args *MiddlewareOptions) {
+		args.streamArrays = enabled
+	}
+}
+
+// RejectDuplicateKeysOpt returns an option that makes body validator reject
+// JSON bodies with duplicate keys in objects. By default, the last value of
+// a duplicate key is taken, as by encoding/json.
+func RejectDuplicateKeysOpt(reject bool) MiddlewareOption {
+	return func(args *MiddlewareOptions) {
+		args.rejectDuplicateKeys = reject
+	}
+}
+
+// RepeatedParam is a way query validator handles values of parameters that
+// are not arrays repeated in the query, e.g. "?id=1&id=2".
+type RepeatedParam int
+
+const (
+	// RepeatedParamReject rejects repeated values. This is the default.
+	RepeatedParamReject RepeatedParam = iota
+	// RepeatedParamFirst takes the first of repeated values.
+	RepeatedParamFirst
+	// RepeatedParamLast takes the last of repeated values.
+	RepeatedParamLast
+)
+
+// RepeatedParamOpt returns an option that sets how query validator handles
+// repeated values of parameters that are not arrays. The value taken
+// replaces the repeated ones, so handlers get it. By default,
+// RepeatedParamReject is used.
+func RepeatedParamOpt(mode RepeatedParam) MiddlewareOption {
+	return func(args *MiddlewareOptions) {
+		args.repeatedParam = mode
+	}
+}
+
+// CanonicalQueryOpt returns an option that makes query validator rewrite
+// the query of valid requests in a canonical form, with parameters sorted by
+// name, so requests with the same parameters in different order get the
+// same URL, e.g. for cache keys. Repeated values of a parameter keep their
+// order, as it is significant for arrays.
+func CanonicalQueryOpt(enabled bool) MiddlewareOption {
+	return func(args *MiddlewareOptions) {
+		args.canonicalQuery = enabled
+	}
+}
+
+// collapseRepeated replaces repeated values of parameters located in "in"
+// that are not arrays with the value taken by the repeated param mode. It
+// reports whether any values are replaced.
+func (opts MiddlewareOptions) collapseRepeated(ps []spec.Parameter, in string, vals url.Values) (changed bool) {
+	if opts.repeatedParam == RepeatedParamReject {
+		return false
+	}
+
+	for _, p := range ps {
+		if p.In != in || p.Type == "array" || len(vals[p.Name]) < 2 {
+			continue
+		}
+
+		if opts.repeatedParam == RepeatedParamFirst {
+			vals[p.Name] = vals[p.Name][:1]
+		} else {
+			vals[p.Name] = vals[p.Name][len(vals[p.Name])-1:]
+		}
+		changed = true
+	}
+	return changed
+}
+
+// ParamHeaderFunc returns the name of the request header to pass the value
+// of the parameter in. Empty name means the value is not passed.
+type ParamHeaderFunc func(p spec.Parameter) string
+
+// ParamHeaderPrefix returns ParamHeaderFunc that names headers by the
+// parameter name with the prefix, e.g. "X-Param-Id" for parameter "id" with
+// prefix "X-Param-".
+func ParamHeaderPrefix(prefix string) ParamHeaderFunc {
+	return func(p spec.Parameter) string {
+		return http.CanonicalHeaderKey(prefix + p.Name)
+	}
+}
+
+// ParamHeadersOpt returns an option that makes query validator and path
+// parameter extractor pass converted values of parameters in request
+// headers named by fn, e.g. for an upstream the request is proxied to.
+// Values are formatted canonically, e.g. "10" for integer "010", and
+// arrays are joined by commas. Headers of parameters not sent by the client
+// are removed, so they cannot be spoofed.
+func ParamHeadersOpt(fn ParamHeaderFunc) MiddlewareOption {
+	return func(args *MiddlewareOptions) {
+		args.paramHeader = fn
+	}
+}
+
+// setParamHeader sets the request header of the parameter to the value,
+// or removes it if the value is nil.
+func (opts MiddlewareOptions) setParamHeader(req *http.Request, p spec.Parameter, value interface{}) {
+	if opts.paramHeader == nil {
+		return
+	}
+
+	name := opts.paramHeader(p)
+	if name == "" {
+		return
+	}
+
+	if value == nil {
+		req.Header.Del(name)
+		return
+	}
+	req.Header.Set(name, FormatValue(value))
+}
+
+// AcceptedVersionsOpt returns an option that sets API versions version gate
+// accepts instead of the spec's info.version, e.g. to keep serving clients
+// of the previous version.
+func AcceptedVersionsOpt(versions ...string) MiddlewareOption {
+	return func(args *MiddlewareOptions) {
+		args.acceptedVersions = append(args.acceptedVersions, versions...)
+	}
+}
+
+// SpecMismatchFn is called when something cannot be checked against the spec,
+// e.g. there is no spec for the response status. err describes the reason.
+type SpecMismatchFn func(req *http.Request, err error)
+
+// SpecMismatchOpt returns an option that sets a function to call when
+// something cannot be checked against the spec.
+func SpecMismatchOpt(fn SpecMismatchFn) MiddlewareOption {
+	return func(args *MiddlewareOptions) {
+		args.specMismatchFn = fn
+	}
+}
+
+// ResponseBufferLimitOpt returns an option that limits the number of bytes of
+// a response body buffered for validation. Responses exceeding the limit are
+// passed through without validation. Zero means no limit.
+func ResponseBufferLimitOpt(limit int) MiddlewareOption {
+	return func(args *MiddlewareOptions) {
+		args.responseBufferLimit = limit
+	}
+}
+
+// ResponsePassthroughHeaderOpt returns an option that makes response body
+// validator pass through responses to requests with the header set, without
+// buffering and validation, e.g. for internal or latency-sensitive traffic.
+// The header must be stripped from external requests, otherwise clients can
+// bypass the validation. By default, no header is honored, see also
+// DisableResponseValidation.
+func ResponsePassthroughHeaderOpt(name string) MiddlewareOption {
+	return func(args *MiddlewareOptions) {
+		args.passthroughHeader = name
+	}
+}
+
+// NumberLocaleOpt returns an option that makes query and form data
+// validation accept numbers formatted in the locale, e.g. "1,5" for 1.5.
+// By default, numbers are parsed strictly.
+func NumberLocaleOpt(locale NumberLocale) MiddlewareOption {
+	return func(args *MiddlewareOptions) {
+		args.numberLocale = &locale
+	}
+}
+
+// standardFormats are string formats of JSON Schema validated by default.
+var standardFormats = []string{"uri", "email", "hostname", "ipv4", "ipv6"}
+
+// FormatValidationOpt returns an option that enables or disables validation
+// of standard string formats uri, email, hostname, ipv4 and ipv6 in
+// parameters and schemas. Format validation is optional in JSON Schema, so
+// it can be disabled, e.g. for clients that send loosely formatted values.
+// By default, it is enabled.
+func FormatValidationOpt(enabled bool) MiddlewareOption {
+	return func(args *MiddlewareOptions) {
+		if enabled {
+			args.formats = strfmt.Default
+			return
+		}
+
+		formats := strfmt.NewFormats()
+		for _, name := range standardFormats {
+			formats.DelByName(name)
+		}
+		args.formats = formats
+	}
+}
+
+// StatusRange is an inclusive range of HTTP status codes, e.g.
+// StatusRange{200, 299} for all successful statuses.
+type StatusRange struct {
+	Min int
+	Max int
+}
+
+func (r StatusRange) contains(status int) bool {
+	return status >= r.Min && status <= r.Max
+}
+
+// ValidateStatusOpt returns an option that makes response body validator
+// validate only responses with status in one of the ranges. By default,
+// responses with any status are validated.
+func ValidateStatusOpt(ranges ...StatusRange) MiddlewareOption {
+	return func(args *MiddlewareOptions) {
+		args.statusRanges = append(args.statusRanges, ranges...)
+	}
+}
+
+// DefaultResponseOpt returns an option that makes response body validator
+// validate responses with statuses not declared for the operation against
+// the operation's default response, if any. It is useful to check that
+// error responses, usually described by the default response, have
+// consistent shape.
+func DefaultResponseOpt(enabled bool) MiddlewareOption {
+	return func(args *MiddlewareOptions) {
+		args.defaultResponse = enabled
+	}
+}
+
+// SuccessFallbackOpt returns an option that makes response body validator
+// validate responses with 2xx statuses not declared for the operation
+// against the nearest declared 2xx response, e.g. 201 against 200. It takes
+// precedence over the default response, see DefaultResponseOpt. Otherwise
+// such responses are reported by SpecMismatchFn.
+func SuccessFallbackOpt(enabled bool) MiddlewareOption {
+	return func(args *MiddlewareOptions) {
+		args.successFallback = enabled
+	}
+}
+
+// UncheckedSecurityOpt returns an option that makes API key authentication
+// pass requests through when the operation has only security requirements
+// that are not checked, e.g. oauth2 ones authenticated by another
+// middleware. By default, such requests are responded with 401 Unauthorized,
+// so the operation is not left unprotected by mistake.
+func UncheckedSecurityOpt(passthrough bool) MiddlewareOption {
+	return func(args *MiddlewareOptions) {
+		args.uncheckedSecurity = passthrough
+	}
+}
+
+// PathParamExtractorOpt returns an option that sets the function extracting
+// path parameters from requests to ValidateOperation, e.g. by the route
+// variables of the router it is mounted on. By default, path parameters
+// are not extracted and validated.
+func PathParamExtractorOpt(extractor func(r *http.Request, key string) string) MiddlewareOption {
+	return func(args *MiddlewareOptions) {
+		args.pathParamExtractor = extractor
+	}
+}
+
+// ResponseTransformer transforms a decoded JSON response body before it is
+// validated and sent, e.g. to strip internal fields.
+type ResponseTransformer func(req *http.Request, body interface{}) (interface{}, error)
+
+// ResponseTransformerOpt returns an option that adds a transformer of
+// response bodies to response body validator. Transformers are chained in
+// the order they are added. Transformed bodies are validated. When
+// a transformer fails, the original body is sent. Responses exceeding the
+// buffer limit are not transformed.
+func ResponseTransformerOpt(t ResponseTransformer) MiddlewareOption {
+	return func(args *MiddlewareOptions) {
+		args.transformers = append(args.transformers, t)
+	}
+}
+
+// validatesStatus reports whether responses with the status are validated.
+func (opts MiddlewareOptions) validatesStatus(status int) bool {
+	if len(opts.statusRanges) == 0 {
+		return true
+	}
+	for _, r := range opts.statusRanges {
+		if r.contains(status) {
+			return true
+		}
+	}
+	return false
+}
+
+func newMiddlewareOptions(options []MiddlewareOption) MiddlewareOptions {
+	// Default options.
+	opts := MiddlewareOptions{
+		operationResolver:  GetOperation,
+		specMismatchFn:     func(req *http.Request, err error) {},
+		queryAllowlist:     make(map[string]struct{}),
+		formats:            strfmt.Default,
+		maxJSONDepth:       DefaultMaxJSONDepth,
+		compressionMinSize: DefaultCompressionMinSize,
+		fingerprintLimit:   DefaultIdempotencyBodyLimit,
+		clock:              realClock{},
+	}
+
+	// Apply argument options.
+	for _, o := range options {
+		o(&opts)
+	}
+
+	return opts
+}
+
 // TODO: don't use raw errHandler, make validator less complex
 // NewQueryValidator returns new Middleware that validates request query
 // parameters against OpenAPI 2.0 spec.
-func NewQueryValidator(errHandler func(w http.ResponseWriter, errs []error)) Middleware {
+func NewQueryValidator(errHandler func(w http.ResponseWriter, errs []error), options ...MiddlewareOption) Middleware {
 	return queryValidatorMiddleware{
 		errHandler:      errHandler,
 		continueOnError: false, // TODO: make controllable
+		opts:            newMiddlewareOptions(options),
 	}
 }
 
 type queryValidatorMiddleware struct {
 	errHandler      func(w http.ResponseWriter, errs []error)
 	continueOnError bool
+	opts            MiddlewareOptions
 }
 
 func (m queryValidatorMiddleware) Apply(next http.Handler) http.Handler {
 	return http.HandlerFunc(func(w http.ResponseWriter, req *http.Request) {
-		op := GetOperation(req)
+		op := m.opts.operationResolver(req)
 		if op == nil {
 			next.ServeHTTP(w, req)
 			return
 		}
 
-		if errs := ValidateQuery(op.Parameters, req.URL.Query()); len(errs) > 0 {
-			m.errHandler(w, errs)
+		errHandler := operationErrHandler(req, m.errHandler)
+
+		// Normalized values replace the raw ones, so handlers get them.
+		query := req.URL.Query()
+		collapsed := m.opts.collapseRepeated(op.Parameters, "query", query)
+		if m.opts.normalizeValues(req, op.Parameters, "query", query) || collapsed {
+			req.URL.RawQuery = query.Encode()
+		}
+
+		errs := validateValues(op.Parameters, "query", req.URL.Query(), m.opts)
+		observeValidation(req, op, "query", errs)
+		if len(errs) > 0 {
+			errHandler(w, errs)
 			if !m.continueOnError {
 				return
 			}
 		}
 
+		// Converted values are kept, so handlers get them without
+		// converting again, see GetQueryParam.
+		query = req.URL.Query()
+		if m.opts.canonicalQuery && len(errs) == 0 {
+			// Encoding sorts parameters by name.
+			req.URL.RawQuery = query.Encode()
+		}
+		values := make(map[string]interface{})
+		for _, p := range op.Parameters {
+			if p.In != "query" {
+				continue
+			}
+			var value interface{}
+			if vals, ok := query[p.Name]; ok {
+				if v, err := m.opts.convertParam(p, vals); err == nil {
+					value = v
+					values[p.Name] = v
+				}
+			}
+			m.opts.setParamHeader(req, p, value)
+		}
+		req = req.WithContext(
+			context.WithValue(req.Context(), contextKeyQueryValues{}, values),
+		)
+
+		next.ServeHTTP(w, req)
+	})
+}
+
+// GetQueryParam returns a query parameter by name from a request, converted
+// according to its spec by the query validator. It returns nil if the
+// parameter is not passed or the request was not validated.
+func GetQueryParam(req *http.Request, name string) interface{} {
+	values, _ := req.Context().Value(contextKeyQueryValues{}).(map[string]interface{})
+	return values[name]
+}
+
+type contextKeyQueryValues struct{}
+
+// NewStrictQuery returns new Middleware that rejects requests with query
+// parameters not declared for the operation in OpenAPI 2.0 spec, without
+// validating values of the declared ones. Query validator rejects such
+// parameters too, so this middleware is meant for services that do not
+// validate query values, or to reject them before other middlewares run.
+// Use QueryAllowlistOpt to accept some undeclared parameters.
+func NewStrictQuery(errHandler func(w http.ResponseWriter, errs []error), options ...MiddlewareOption) Middleware {
+	return strictQueryMiddleware{
+		errHandler: errHandler,
+		opts:       newMiddlewareOptions(options),
+	}
+}
+
+type strictQueryMiddleware struct {
+	errHandler func(w http.ResponseWriter, errs []error)
+	opts       MiddlewareOptions
+}
+
+func (m strictQueryMiddleware) Apply(next http.Handler) http.Handler {
+	return http.HandlerFunc(func(w http.ResponseWriter, req *http.Request) {
+		op := m.opts.operationResolver(req)
+		if op == nil {
+			next.ServeHTTP(w, req)
+			return
+		}
+
+		errHandler := operationErrHandler(req, m.errHandler)
+
+		q := req.URL.Query()
+		for _, p := range op.Parameters {
+			if p.In == "query" {
+				delete(q, p.Name)
+			}
+		}
+		for name := range m.opts.queryAllowlist {
+			delete(q, name)
+		}
+
+		names := make([]string, 0, len(q))
+		for name := range q {
+			names = append(names, name)
+		}
+		sort.Strings(names)
+
+		errs := make(ValidationErrors, 0, len(names))
+		for _, name := range names {
+			errs = append(errs, ValidationErrorf(name, q.Get(name), "parameter %s is unknown", name))
+		}
+		observeValidation(req, op, "query", errs.Errors())
+		if len(errs) > 0 {
+			errHandler(w, errs.Errors())
+			return
+		}
+
 		next.ServeHTTP(w, req)
 	})
 }
 
 // NewBodyValidator returns new Middleware that validates request body
-// against parameters defined in OpenAPI 2.0 spec.
-func NewBodyValidator(errHandler func(w http.ResponseWriter, errs []error)) Middleware {
-	return bodyValidatorMiddleware{
+// against parameters defined in OpenAPI 2.0 spec. The request context is
+// checked between reads of the body, and once it is done, reading stops and
+// the request is responded with 408 Request Timeout and the body written by
+// errHandler. A read already blocked on a stalled client is not interrupted,
+// so bound it with the server's ReadTimeout. Requests with a content type
+// the operation does not consume, or a media type that has no decoder, see
+// BodyDecoderOpt, are responded with 415 Unsupported Media Type, as their
+// body cannot be validated. Form bodies are not kept to be read again, so
+// files are not held in memory; handlers get the parsed form by the request
+// form methods, e.g. FormValue, or by GetFormValue and GetFormFile.
+func NewBodyValidator(errHandler func(w http.ResponseWriter, errs []error), options ...MiddlewareOption) Middleware {
+	opts := newMiddlewareOptions(options)
+	m := bodyValidatorMiddleware{
 		errHandler: errHandler,
+		opts:       opts,
+		schemas:    &schemaCache{prepare: requestSchema, formats: opts.formats},
+	}
+	if m.opts.cacheSize > 0 {
+		m.cache = newValidationCache(m.opts.cacheSize, m.opts.cacheTTL, m.opts.clock)
 	}
+	return m
 }
 
 type bodyValidatorMiddleware struct {
 	errHandler func(w http.ResponseWriter, errs []error)
+	opts       MiddlewareOptions
+	cache      *validationCache
+	schemas    *schemaCache
 }
 
 func (m bodyValidatorMiddleware) Apply(next http.Handler) http.Handler {
@@ -71,51 +696,409 @@ func (m bodyValidatorMiddleware) Apply(next http.Handler) http.Handler {
 			return
 		}
 
-		op := GetOperation(req)
+		op := m.opts.operationResolver(req)
 		if op == nil {
 			next.ServeHTTP(w, req)
 			return
 		}
 
+		errHandler := operationErrHandler(req, m.errHandler)
+
+		if !hasBodyParams(op.Parameters) {
+			if m.opts.strictBody {
+				errs := []error{fmt.Errorf("Body is not allowed for the operation")}
+				observeValidation(req, op, "body", errs)
+				errHandler(w, errs)
+				return
+			}
+			next.ServeHTTP(w, req)
+			return
+		}
+
 		// Read req.Body using io.TeeReader, so it can be read again
-		// in the actual request handler.
+		// in the actual request handler. Forms are not kept, handlers get
+		// the parsed form instead.
 
 		var b bytes.Buffer
-		tr := io.TeeReader(req.Body, &b)
+		body := &contextReader{ctx: req.Context(), r: req.Body}
+		tr := io.TeeReader(body, &b)
 		defer req.Body.Close()
 
-		var body interface{}
-		if err := json.NewDecoder(tr).Decode(&body); err != nil {
-			m.errHandler(w, []error{fmt.Errorf("Body contains invalid json")})
+		// Reading is aborted when the request is done, e.g. its deadline
+		// passes while a slow client sends the body. A read in progress
+		// is not interrupted. Errors of decoding the partial body are
+		// replaced by the timeout then.
+		errHandler = abortedBodyErrHandler(body, errHandler)
+		// Errors replaced by the timeout are observed as the timeout too.
+		observe := func(in string, errs []error) {
+			if len(errs) > 0 && body.err != nil {
+				errs = []error{errBodyAborted}
+			}
+			observeValidation(req, op, in, errs)
+		}
+
+		// Select the decoder by the media type the operation consumes.
+		mediaType, ok := consumedMediaType(req.Header.Get("Content-Type"), op.Consumes)
+		if !ok {
+			errs := []error{fmt.Errorf("Content type %s is not consumed by the operation", mediaType)}
+			observe("body", errs)
+			writeErrorsWithStatus(w, http.StatusUnsupportedMediaType, errHandler, errs)
 			return
 		}
+		switch {
+		case m.opts.bodyDecoders[mediaType] != nil:
+			errs := m.validateDecodedBody(op, getOperationSchemas(req), mediaType, m.opts.bodyDecoders[mediaType], tr)
+			observe("body", errs)
+			if len(errs) > 0 {
+				errHandler(w, errs)
+				return
+			}
+		case isJSONMediaType(mediaType):
+			var r io.Reader = tr
+			var errs []error
+			if m.opts.rejectDuplicateKeys {
+				// The body is scanned before decoding, as decoding
+				// loses duplicates.
+				body, err := ioutil.ReadAll(tr)
+				if err != nil {
+					errs = []error{fmt.Errorf("Body contains invalid json")}
+				} else if key, ok := duplicateKey(bytes.NewReader(body)); ok {
+					errs = []error{ValidationErrorf(key, nil, "Body contains duplicate key %s", key)}
+				}
+				r = bytes.NewReader(body)
+			}
+			if len(errs) == 0 {
+				errs = m.validateJSON(op, getOperationSchemas(req), mediaType, r)
+			}
+			if len(errs) == 0 && m.opts.trailingData == TrailingDataTrim {
+				// Read the rest of the body, so the handler gets only
+				// the JSON value.
+				if _, err := io.Copy(ioutil.Discard, tr); err != nil {
+					errs = []error{fmt.Errorf("Body contains invalid json")}
+				}
+			}
+			observe("body", errs)
+			if len(errs) > 0 {
+				errHandler(w, errs)
+				return
+			}
 
-		// Validate body
-		if errs := ValidateBody(op.Parameters, body); len(errs) > 0 {
-			m.errHandler(w, errs)
+			if m.opts.trailingData == TrailingDataTrim {
+				value := firstJSONValue(b.Bytes())
+				b.Reset()
+				b.Write(value)
+				req.ContentLength = int64(len(value))
+			}
+		case isXMLMediaType(mediaType):
+			// Validation of XML against a schema is not supported, so
+			// only check that the body is well-formed.
+			var errs []error
+			if err := checkXML(tr); err != nil {
+				errs = []error{fmt.Errorf("Body contains invalid xml")}
+			}
+			observe("body", errs)
+			if len(errs) > 0 {
+				errHandler(w, errs)
+				return
+			}
+		case isFormMediaType(mediaType):
+			form, err := decodeForm(body, req.Header.Get("Content-Type"), m.opts.multipartLimits)
+			if lerr, ok := err.(*multipartLimitError); ok {
+				errs := []error{lerr}
+				observe("formData", errs)
+				writeErrorsWithStatus(w, lerr.status, errHandler, errs)
+				return
+			}
+			if err != nil {
+				errs := []error{fmt.Errorf("Body contains invalid form data")}
+				observe("formData", errs)
+				errHandler(w, errs)
+				return
+			}
+			defer form.RemoveAll()
+
+			// Normalized values replace the raw ones, so handlers get them.
+			m.opts.normalizeValues(req, op.Parameters, "formData", form.Value)
+
+			// Keep the values, as validation consumes them.
+			values := make(url.Values, len(form.Value))
+			for name, vals := range form.Value {
+				values[name] = vals
+			}
+
+			// Report errors of both fields and files at once.
+			errs := validateValues(op.Parameters, "formData", values, m.opts)
+			if len(errs) == 0 || !m.opts.failFast {
+				errs = append(errs, ValidateFormFiles(op.Parameters, form.File)...)
+			}
+			if m.opts.failFast && len(errs) > 1 {
+				errs = errs[:1]
+			}
+			observe("formData", errs)
+			if len(errs) > 0 {
+				errHandler(w, errs)
+				return
+			}
+
+			req = req.WithContext(
+				context.WithValue(req.Context(), contextKeyForm{}, form),
+			)
+			// The body is consumed, so the parsed form is set for the
+			// request form methods, e.g. FormValue.
+			req.PostForm = form.Value
+			if form.File != nil {
+				req.MultipartForm = form
+			}
+			req.Body = http.NoBody
+		default:
+			errs := []error{fmt.Errorf("Body of content type %s cannot be validated", mediaType)}
+			observe("body", errs)
+			writeErrorsWithStatus(w, http.StatusUnsupportedMediaType, errHandler, errs)
 			return
 		}
 
 		// Replace the body so it can be read again.
-		req.Body = ioutil.NopCloser(&b)
+		req.Body = ioutil.NopCloser(io.MultiReader(&b, req.Body))
+
+		next.ServeHTTP(w, req)
+	})
+}
+
+// validateJSON decodes JSON body from r and validates it, using the cache
+// if enabled.
+func (m bodyValidatorMiddleware) validateJSON(op *spec.Operation, schemas *operationSchemas, mediaType string, r io.Reader) []error {
+	if m.cache == nil {
+		return m.validateJSONBody(op, schemas, mediaType, r)
+	}
+
+	b, err := ioutil.ReadAll(r)
+	if err != nil {
+		return []error{fmt.Errorf("Body contains invalid json")}
+	}
+
+	key := validationCacheKey(op, mediaType, b)
+	if errs, ok := m.cache.get(key); ok {
+		return errs
+	}
+
+	errs := m.validateJSONBody(op, schemas, mediaType, bytes.NewReader(b))
+	m.cache.add(key, errs)
+	return errs
+}
+
+// validateJSONBody decodes JSON body of the media type from r and validates
+// it against the operation body parameters. Schemas of the parameters are
+// compiled by the router or once by the middleware, and reused. Schemas
+// declared for the media type by x-consumes-schema extension are used
+// instead of the parameter schemas.
+func (m bodyValidatorMiddleware) validateJSONBody(op *spec.Operation, schemas *operationSchemas, mediaType string, r io.Reader) []error {
+	rejectTrailing := m.opts.trailingData == TrailingDataReject
+	if m.opts.streamArrays {
+		if p, ok := streamedArrayParam(op); ok {
+			return m.validateJSONArray(p, schemas, r, rejectTrailing)
+		}
+	}
+
+	_, errs := decodeAndValidateBody(op, r, m.opts.maxJSONDepth, rejectTrailing, m.bodyParamValidator(schemas, mediaType))
+	if m.opts.failFast && len(errs) > 1 {
+		// The schema is validated at once, only the errors are dropped.
+		errs = errs[:1]
+	}
+	return errs
+}
+
+// validateJSONArray decodes JSON array body from r item by item and
+// validates each item against the items schema of the body parameter.
+func (m bodyValidatorMiddleware) validateJSONArray(p spec.Parameter, schemas *operationSchemas, r io.Reader, rejectTrailing bool) []error {
+	var limit *depthLimitReader
+	if m.opts.maxJSONDepth > 0 {
+		limit = &depthLimitReader{r: r, max: m.opts.maxJSONDepth}
+		r = limit
+	}
+	invalid := func() []error {
+		if limit != nil && limit.exceeded {
+			return []error{jsonDepthError(m.opts.maxJSONDepth)}
+		}
+		return []error{fmt.Errorf("Body contains invalid json")}
+	}
+
+	d := json.NewDecoder(r)
+	d.UseNumber()
+
+	tok, err := d.Token()
+	if err == io.EOF {
+		return missingBodyErrors([]spec.Parameter{p})
+	}
+	if err != nil {
+		return invalid()
+	}
+	if tok != json.Delim('[') {
+		return []error{ValidationErrorf(p.Name, nil, "%s in body must be of type array", p.Name)}
+	}
+
+	items := p.Schema.Items.Schema
+	errs := make(ValidationErrors, 0)
+	n := 0
+	for ; d.More(); n++ {
+		var item interface{}
+		if err := d.Decode(&item); err != nil {
+			return invalid()
+		}
+
+		errs = append(errs, indexedErrors(m.schemas.validate(schemas.request, items, plainNumbers(item), strconv.Itoa(n)), n)...)
+		if m.opts.failFast && len(errs) > 0 {
+			return errs[:1].Errors()
+		}
+	}
+	if _, err := d.Token(); err != nil {
+		return invalid()
+	}
+	if rejectTrailing && hasTrailingData(d) {
+		return []error{fmt.Errorf("Body contains data after the json value")}
+	}
+
+	if p.Schema.MinItems != nil && int64(n) < *p.Schema.MinItems {
+		errs = append(errs, ValidationErrorf(p.Name, nil, "%s in body should have at least %d items", p.Name, *p.Schema.MinItems))
+	}
+	if p.Schema.MaxItems != nil && int64(n) > *p.Schema.MaxItems {
+		errs = append(errs, ValidationErrorf(p.Name, nil, "%s in body should have at most %d items", p.Name, *p.Schema.MaxItems))
+	}
+	if m.opts.failFast && len(errs) > 1 {
+		errs = errs[:1]
+	}
+	return errs.Errors()
+}
+
+// streamedArrayParam returns the body parameter of the operation if its
+// array body can be validated item by item.
+func streamedArrayParam(op *spec.Operation) (spec.Parameter, bool) {
+	var body []spec.Parameter
+	for _, p := range op.Parameters {
+		if p.In == "body" {
+			body = append(body, p)
+		}
+	}
+	if len(body) != 1 {
+		return spec.Parameter{}, false
+	}
+
+	p := body[0]
+	if _, ok := p.Extensions[extConsumesSchema]; ok {
+		// The schema depends on the media type.
+		return spec.Parameter{}, false
+	}
+
+	sch := p.Schema
+	if sch == nil || !sch.Type.Contains("array") || len(sch.Type) != 1 ||
+		sch.Items == nil || sch.Items.Schema == nil || sch.UniqueItems {
+		return spec.Parameter{}, false
+	}
+	return p, true
+}
+
+// indexedErrors names errors of the array item with the index, so that e.g.
+// "name" becomes "3.name".
+func indexedErrors(errs ValidationErrors, index int) ValidationErrors {
+	prefix := strconv.Itoa(index)
+	for i, err := range errs {
+		se, ok := err.(schemaErr)
+		if !ok {
+			continue
+		}
+		if se.field == "" {
+			// The item itself is named by the index already.
+			se.field = prefix
+		} else {
+			se.field = joinField(prefix, se.field)
+			se.message = prefix + "." + se.message
+		}
+		se.path = jsonPointer(se.field)
+		errs[i] = se
+	}
+	return errs
+}
+
+// validateDecodedBody decodes body of the media type from r with the
+// decoder and validates it against the operation body parameters, as
+// validateJSONBody does.
+func (m bodyValidatorMiddleware) validateDecodedBody(op *spec.Operation, schemas *operationSchemas, mediaType string, decode BodyDecoder, r io.Reader) []error {
+	body, err := decode(r)
+	if err != nil {
+		return []error{fmt.Errorf("Body contains invalid %s", mediaType)}
+	}
+
+	validateParam := m.bodyParamValidator(schemas, mediaType)
+	errs := make(ValidationErrors, 0)
+	for _, p := range op.Parameters {
+		if p.In == "body" {
+			errs = append(errs, validateParam(p, body)...)
+		}
+	}
+	if m.opts.failFast && len(errs) > 1 {
+		errs = errs[:1]
+	}
+	return errs.Errors()
+}
+
+// bodyParamValidator returns a function that validates a decoded body of
+// the media type against the body parameter.
+func (m bodyValidatorMiddleware) bodyParamValidator(schemas *operationSchemas, mediaType string) func(p spec.Parameter, body interface{}) ValidationErrors {
+	return func(p spec.Parameter, body interface{}) ValidationErrors {
+		if sch, ok := schemas.consumes[p.Name][mediaType]; ok {
+			return m.schemas.validate(schemas.request, sch, body, p.Name)
+		}
 
+		if schemas.consumes == nil {
+			// Not compiled by the router, so the extension is parsed for
+			// each request and the schema is not cached.
+			if byMediaType, err := consumesSchemas(p); err == nil {
+				if sch, ok := byMediaType[mediaType]; ok {
+					return validatebySchema(requestSchema(sch), body, p.Name, m.opts.formats)
+				}
+			}
+		}
+
+		return m.schemas.validate(schemas.request, p.Schema, body, p.Name)
+	}
+}
+
+// operationErrHandler returns the error handler registered for the request's
+// operation, or fallback if there is none.
+func operationErrHandler(req *http.Request, fallback func(w http.ResponseWriter, errs []error)) func(w http.ResponseWriter, errs []error) {
+	if errHandler, ok := req.Context().Value(contextKeyErrHandler{}).(func(w http.ResponseWriter, errs []error)); ok {
+		return errHandler
+	}
+	return fallback
+}
+
+type contextKeyErrHandler struct{}
+
+func errHandlerMiddleware(next http.Handler, errHandler func(w http.ResponseWriter, errs []error)) http.Handler {
+	return http.HandlerFunc(func(w http.ResponseWriter, req *http.Request) {
+		req = req.WithContext(
+			context.WithValue(req.Context(), contextKeyErrHandler{}, errHandler),
+		)
 		next.ServeHTTP(w, req)
 	})
 }
 
 // NewPathParameterExtractor returns new Middleware that extracts parameters
 // defined in OpenAPI 2.0 spec as path parameters from path.
-func NewPathParameterExtractor(extractor func(r *http.Request, key string) string) Middleware {
-	return pathParameterExtractor{extractor}
+func NewPathParameterExtractor(extractor func(r *http.Request, key string) string, options ...MiddlewareOption) Middleware {
+	return pathParameterExtractor{
+		extractor: extractor,
+		opts:      newMiddlewareOptions(options),
+	}
 }
 
 type pathParameterExtractor struct {
 	extractor func(r *http.Request, key string) string
+	opts      MiddlewareOptions
 }
 
 func (m pathParameterExtractor) Apply(next http.Handler) http.Handler {
 	return http.HandlerFunc(func(w http.ResponseWriter, req *http.Request) {
-		op := GetOperation(req)
+		op := m.opts.operationResolver(req)
 		if op == nil {
 			next.ServeHTTP(w, req)
 			return
@@ -126,18 +1109,35 @@ func (m pathParameterExtractor) Apply(next http.Handler) http.Handler {
 				continue
 			}
 
-			value, err := ConvertPrimitive(m.extractor(req, p.Name), p.Type, p.Format)
+			value, err := m.convert(p, m.opts.normalize(req, p, m.extractor(req, p.Name)))
 			if err == nil {
 				req = req.WithContext(
 					context.WithValue(req.Context(), contextKeyPathParam(p.Name), value),
 				)
 			}
+			m.opts.setParamHeader(req, p, value)
 		}
 
 		next.ServeHTTP(w, req)
 	})
 }
 
+// convert converts the path parameter value by the converter the parameter
+// names, by the types it declares, or as a primitive otherwise.
+func (m pathParameterExtractor) convert(p spec.Parameter, val string) (interface{}, error) {
+	c, err := m.opts.converter(p)
+	if err != nil {
+		return nil, err
+	}
+	if c != nil {
+		return c(p, []string{val})
+	}
+	if types := paramTypes(p); len(types) > 0 {
+		return convertUnion(p, types, []string{val}, nil)
+	}
+	return ConvertPrimitive(val, p.Type, p.Format)
+}
+
 // GetPathParam returns a path parameter by name from a request.
 // For example, a handler defined on a path "/pet/{id}" gets a request with
 // path "/pet/12" - in this case GetPathParam(req, "id") returns 12.
@@ -148,30 +1148,82 @@ func GetPathParam(req *http.Request, name string) interface{} {
 type contextKeyPathParam string
 
 // NewResponseBodyValidator returns new Middleware that validates response body
-// against schema defined in OpenAPI 2.0 spec.
-func NewResponseBodyValidator(errHandler func(w http.ResponseWriter, errs []error)) Middleware {
-	return responseBodyValidator{errHandler}
+// against schema defined in OpenAPI 2.0 spec. The validation depends on the
+// media type of the response, see ResponseValidatorOpt. Operations that
+// produce newline delimited JSON are validated record by record as the
+// stream is written.
+func NewResponseBodyValidator(errHandler func(w http.ResponseWriter, errs []error), options ...MiddlewareOption) Middleware {
+	opts := newMiddlewareOptions(options)
+	return responseBodyValidator{
+		errHandler: errHandler,
+		opts:       opts,
+		schemas:    &schemaCache{formats: opts.formats},
+	}
 }
 
 type responseBodyValidator struct {
 	errHandler func(w http.ResponseWriter, errs []error)
+	opts       MiddlewareOptions
+	schemas    *schemaCache
 }
 
 func (m responseBodyValidator) Apply(next http.Handler) http.Handler {
 	return http.HandlerFunc(func(w http.ResponseWriter, req *http.Request) {
-		op := GetOperation(req)
+		op := m.opts.operationResolver(req)
 		if op == nil {
 			next.ServeHTTP(w, req)
 			return
 		}
 
-		rr := NewResponseRecorder(w)
+		if m.passthrough(req) {
+			next.ServeHTTP(w, req)
+			return
+		}
+
+		if producesNDJSON(op.Produces) {
+			m.serveNDJSON(w, req, op, next)
+			return
+		}
+
+		var rr ResponseRecorder
+		var br *bufferedResponseRecorder
+		if len(m.opts.transformers) > 0 {
+			// Hold the response back, so it can be transformed.
+			br = newBufferedResponseRecorder(w, m.opts.responseBufferLimit)
+			rr = br
+		} else {
+			rr = NewLimitedResponseRecorder(w, m.opts.responseBufferLimit)
+		}
+
+		state := &responseValidationState{}
+		req = req.WithContext(
+			context.WithValue(req.Context(), contextKeyResponseValidation{}, state),
+		)
 
 		next.ServeHTTP(rr, req)
 
-		responseSpec, ok := op.Responses.StatusCodeResponses[rr.Status()]
+		var body interface{}
+		if br != nil {
+			payload := br.Payload()
+			if !br.Overflowed() {
+				transformed, tbody, err := m.transform(req, payload)
+				if err != nil {
+					m.opts.specMismatchFn(req, fmt.Errorf("response transformer: %s", err))
+				} else if transformed != nil {
+					payload, body = transformed, tbody
+					w.Header().Del("Content-Length")
+				}
+			}
+			br.flush(payload)
+		}
+
+		if state.validated || !m.opts.validatesStatus(rr.Status()) {
+			return
+		}
+
+		responseSpec, ok := m.responseSpec(op, rr.Status())
 		if !ok {
-			// TODO: should notify package user that there is no response spec.
+			m.opts.specMismatchFn(req, fmt.Errorf("no response spec for status %d", rr.Status()))
 			return
 		}
 
@@ -180,14 +1232,218 @@ func (m responseBodyValidator) Apply(next http.Handler) http.Handler {
 			return
 		}
 
-		var body interface{}
-		if err := json.Unmarshal(rr.Payload(), &body); err != nil {
-			// TODO: should notify package user about the error.
+		if overflowed(rr) {
+			m.opts.specMismatchFn(req, fmt.Errorf(
+				"response body exceeds %d bytes, validation skipped",
+				m.opts.responseBufferLimit,
+			))
+			return
+		}
+
+		// Transformed bodies are JSON, whatever the media type is.
+		mediaType := parseMediaType(rr.Header().Get("Content-Type"))
+		if validator := m.opts.responseValidators[mediaType]; validator != nil && body == nil {
+			errs := validator(responseSpec.Schema, rr.Payload())
+			observeValidation(req, op, "response", errs)
+			if len(errs) > 0 {
+				m.errHandler(w, errs)
+			}
+			return
+		}
+
+		if isXMLMediaType(mediaType) && body == nil {
+			// Validation of XML against a schema is not supported, so
+			// only check that the body is well-formed.
+			if err := checkXML(bytes.NewReader(rr.Payload())); err != nil {
+				m.opts.specMismatchFn(req, fmt.Errorf("response body contains invalid xml: %s", err))
+			}
 			return
 		}
 
-		if errs := ValidateBySchema(responseSpec.Schema, body); len(errs) > 0 {
+		if body == nil {
+			if err := json.Unmarshal(rr.Payload(), &body); err != nil {
+				m.opts.specMismatchFn(req, fmt.Errorf("response body contains invalid json: %s", err))
+				return
+			}
+		}
+
+		errs := m.schemas.validate(getOperationSchemas(req).response, responseSpec.Schema, body, "body").Errors()
+		observeValidation(req, op, "response", errs)
+		if len(errs) > 0 {
 			m.errHandler(w, errs)
 		}
 	})
 }
+
+// serveNDJSON serves a newline delimited JSON stream validating each record
+// against the response schema, or its items schema if it is an array, as
+// soon as the record is written. The first record that does not match is
+// reported by SpecMismatchFn, the stream is not interrupted.
+func (m responseBodyValidator) serveNDJSON(w http.ResponseWriter, req *http.Request, op *spec.Operation, next http.Handler) {
+	state := &responseValidationState{}
+	req = req.WithContext(
+		context.WithValue(req.Context(), contextKeyResponseValidation{}, state),
+	)
+
+	compiled := getOperationSchemas(req).response
+	failed := false
+	rr := newNDJSONResponseRecorder(w, func(status, n int, record []byte) {
+		if failed || state.validated || !m.opts.validatesStatus(status) {
+			return
+		}
+
+		responseSpec, ok := m.responseSpec(op, status)
+		if !ok || responseSpec.Schema == nil {
+			return
+		}
+
+		var v interface{}
+		if err := json.Unmarshal(record, &v); err != nil {
+			failed = true
+			m.opts.specMismatchFn(req, fmt.Errorf("ndjson record %d contains invalid json: %s", n, err))
+			return
+		}
+
+		if errs := m.schemas.validate(compiled, recordSchema(responseSpec.Schema), v, "record"); len(errs) > 0 {
+			failed = true
+			m.opts.specMismatchFn(req, fmt.Errorf("ndjson record %d does not match the schema: %s", n, errs[0]))
+		}
+	})
+
+	next.ServeHTTP(rr, req)
+	rr.finish()
+}
+
+// recordSchema returns the schema of stream records described by the
+// response schema.
+func recordSchema(sch *spec.Schema) *spec.Schema {
+	if sch.Type.Contains("array") && sch.Items != nil && sch.Items.Schema != nil {
+		return sch.Items.Schema
+	}
+	return sch
+}
+
+// transform applies response transformers to the JSON payload and returns
+// the transformed payload and body. It returns nil payload if the payload
+// is not JSON, so it is not transformed.
+func (m responseBodyValidator) transform(req *http.Request, payload []byte) ([]byte, interface{}, error) {
+	var body interface{}
+	if err := json.Unmarshal(payload, &body); err != nil {
+		return nil, nil, nil
+	}
+
+	for _, t := range m.opts.transformers {
+		var err error
+		if body, err = t(req, body); err != nil {
+			return nil, nil, err
+		}
+	}
+
+	transformed, err := json.Marshal(body)
+	if err != nil {
+		return nil, nil, err
+	}
+
+	// Decode the transformed payload again, so it is validated exactly as
+	// it is sent.
+	body = nil
+	if err := json.Unmarshal(transformed, &body); err != nil {
+		return nil, nil, err
+	}
+
+	return transformed, body, nil
+}
+
+// responseSpec returns the spec of the operation response with the status.
+func (m responseBodyValidator) responseSpec(op *spec.Operation, status int) (spec.Response, bool) {
+	if op.Responses == nil {
+		return spec.Response{}, false
+	}
+
+	if responseSpec, ok := op.Responses.StatusCodeResponses[status]; ok {
+		return responseSpec, true
+	}
+
+	if m.opts.successFallback && isSuccess(status) {
+		if responseSpec, ok := nearestSuccessResponse(op.Responses, status); ok {
+			return responseSpec, true
+		}
+	}
+
+	if m.opts.defaultResponse && op.Responses.Default != nil {
+		return *op.Responses.Default, true
+	}
+
+	return spec.Response{}, false
+}
+
+// isSuccess reports whether the status is 2xx.
+func isSuccess(status int) bool {
+	return status >= 200 && status < 300
+}
+
+// nearestSuccessResponse returns the declared 2xx response with the status
+// nearest to the status, the lower one of equally near.
+func nearestSuccessResponse(responses *spec.Responses, status int) (spec.Response, bool) {
+	nearest := 0
+	for s := range responses.StatusCodeResponses {
+		if !isSuccess(s) {
+			continue
+		}
+		if nearest == 0 || distance(s, status) < distance(nearest, status) ||
+			(distance(s, status) == distance(nearest, status) && s < nearest) {
+			nearest = s
+		}
+	}
+	if nearest == 0 {
+		return spec.Response{}, false
+	}
+	return responses.StatusCodeResponses[nearest], true
+}
+
+func distance(a, b int) int {
+	if a > b {
+		return a - b
+	}
+	return b - a
+}
+
+// MarkResponseValidated marks the response to the request as validated, so
+// response body validator skips it. It is meant for middlewares that reject
+// requests with their own responses, e.g. authentication, which are not
+// described in the spec.
+func MarkResponseValidated(req *http.Request) {
+	if state, ok := req.Context().Value(contextKeyResponseValidation{}).(*responseValidationState); ok {
+		state.validated = true
+	}
+}
+
+// DisableResponseValidation returns a copy of the request that response body
+// validator passes through without buffering and validation of the response,
+// e.g. for a debug flag or an internal call. It is meant for middlewares
+// applied before the validator; response transformers are not applied to
+// such responses either.
+func DisableResponseValidation(req *http.Request) *http.Request {
+	return req.WithContext(
+		context.WithValue(req.Context(), contextKeyResponsePassthrough{}, true),
+	)
+}
+
+type contextKeyResponsePassthrough struct{}
+
+// passthrough reports whether the response to the request is passed through,
+// see DisableResponseValidation and ResponsePassthroughHeaderOpt.
+func (m responseBodyValidator) passthrough(req *http.Request) bool {
+	if disabled, _ := req.Context().Value(contextKeyResponsePassthrough{}).(bool); disabled {
+		return true
+	}
+	return m.opts.passthroughHeader != "" && req.Header.Get(m.opts.passthroughHeader) != ""
+}
+
+// responseValidationState is shared between response body validator and
+// the handlers it wraps, so they can affect the validation of the response.
+type responseValidationState struct {
+	validated bool
+}
+
+type contextKeyResponseValidation struct{}
@@ -2,16 +2,24 @@ package oas2
 
 import (
 	"bytes"
+	"context"
 	"encoding/json"
 	"fmt"
+	"io"
 	"io/ioutil"
 	"log"
+	"mime/multipart"
 	"net/http"
 	"net/http/httptest"
+	"reflect"
+	"strconv"
 	"strings"
 	"testing"
+	"testing/iotest"
+	"time"
 
 	"github.com/go-chi/chi"
+	"github.com/go-openapi/spec"
 )
 
 func TestQueryValidatorMiddleware_Apply(t *testing.T) {
@@ -87,53 +95,2157 @@ func TestQueryValidatorMiddleware_Apply(t *testing.T) {
 	server.Close()
 }
 
+func TestBodyValidatorMiddleware_Apply_nonObject(t *testing.T) {
+	cases := []struct {
+		method          string
+		url             string
+		body            string
+		expectedPayload string
+	}{
+		// array body
+		{
+			method:          http.MethodPost,
+			url:             "/v2/pets",
+			body:            `[{"name":"johndoe","age":7},{"name":"janedoe","age":3}]`,
+			expectedPayload: "ok",
+		},
+		// array body with an invalid item
+		{
+			method:          http.MethodPost,
+			url:             "/v2/pets",
+			body:            `[{"name":"johndoe","age":7},{"age":3}]`,
+			expectedPayload: `{"errors":[{"message":"name in body is required","field":"name"}]}`,
+		},
+		// object body instead of array
+		{
+			method:          http.MethodPost,
+			url:             "/v2/pets",
+			body:            `{"name":"johndoe","age":7}`,
+			expectedPayload: `{"errors":[{"message":"body in body must be of type array: \"object\""}]}`,
+		},
+		// primitive body
+		{
+			method:          http.MethodPut,
+			url:             "/v2/pet/12/name",
+			body:            `"Rex"`,
+			expectedPayload: "ok",
+		},
+		// primitive body violating constraints
+		{
+			method:          http.MethodPut,
+			url:             "/v2/pet/12/name",
+			body:            `"Re"`,
+			expectedPayload: `{"errors":[{"message":"body in body should be at least 3 chars long"}]}`,
+		},
+		// primitive body of a wrong type
+		{
+			method:          http.MethodPut,
+			url:             "/v2/pet/12/name",
+			body:            `12`,
+			expectedPayload: `{"errors":[{"message":"body in body must be of type string: \"number\""}]}`,
+		},
+	}
+
+	// set up
+
+	doc := loadDoc()
+
+	okHandler := http.HandlerFunc(func(w http.ResponseWriter, req *http.Request) {
+		fmt.Fprint(w, "ok")
+	})
+	handlers := OperationHandlers{
+		"addPets":   okHandler,
+		"renamePet": okHandler,
+	}
+
+	bodyValidator := NewBodyValidator(writeErrorsToResponseWriter)
+
+	router, err := NewRouter(doc.Spec(), handlers, MiddlewareOpt(bodyValidator.Apply))
+	if err != nil {
+		t.Fatal(err)
+	}
+
+	server := httptest.NewServer(router)
+	client := server.Client()
+
+	// test
+
+	for _, c := range cases {
+		req, err := http.NewRequest(c.method, server.URL+c.url, strings.NewReader(c.body))
+		if err != nil {
+			t.Fatal(err)
+		}
+		req.Header.Set("Content-Type", "application/json")
+
+		resp, err := client.Do(req)
+		if err != nil {
+			t.Fatal(err)
+		}
+
+		respBody, err := ioutil.ReadAll(resp.Body)
+		if err != nil {
+			t.Fatal(err)
+		}
+
+		if !bytes.Equal([]byte(c.expectedPayload), respBody) {
+			t.Errorf("Expected response body to be\n%s\nbut got\n%s", c.expectedPayload, string(respBody))
+		}
+	}
+
+	// tear down
+
+	server.Close()
+}
+
+func TestBodyValidatorMiddleware_Apply_unexpectedBody(t *testing.T) {
+	cases := []struct {
+		strict          bool
+		body            string
+		expectedPayload string
+	}{
+		// lenient, GET without a body
+		{
+			strict:          false,
+			expectedPayload: "pet by id",
+		},
+		// lenient, GET with a body
+		{
+			strict:          false,
+			body:            `{"name":`,
+			expectedPayload: "pet by id",
+		},
+		// strict, GET without a body
+		{
+			strict:          true,
+			expectedPayload: "pet by id",
+		},
+		// strict, GET with a body
+		{
+			strict:          true,
+			body:            `{"name":"johndoe"}`,
+			expectedPayload: `{"errors":[{"message":"Body is not allowed for the operation"}]}`,
+		},
+	}
+
+	doc := loadDoc()
+
+	handlers := OperationHandlers{"getPetById": http.HandlerFunc(func(w http.ResponseWriter, req *http.Request) {
+		fmt.Fprint(w, "pet by id")
+	})}
+
+	for _, c := range cases {
+		bodyValidator := NewBodyValidator(writeErrorsToResponseWriter, StrictBodyOpt(c.strict))
+
+		router, err := NewRouter(doc.Spec(), handlers, MiddlewareOpt(bodyValidator.Apply))
+		if err != nil {
+			t.Fatal(err)
+		}
+
+		req := httptest.NewRequest(http.MethodGet, "/v2/pet/12", strings.NewReader(c.body))
+		if c.body == "" {
+			req.Body = http.NoBody
+		}
+		w := httptest.NewRecorder()
+
+		router.ServeHTTP(w, req)
+
+		if c.expectedPayload != w.Body.String() {
+			t.Errorf("Expected response body to be\n%s\nbut got\n%s", c.expectedPayload, w.Body.String())
+		}
+	}
+}
+
+func TestBodyValidatorMiddleware_Apply_emptyBody(t *testing.T) {
+	sw := parseSpec(`
+swagger: "2.0"
+info:
+  title: test
+  version: "1.0"
+basePath: /v1
+paths:
+  /pets:
+    post:
+      operationId: addPet
+      parameters:
+      - name: body
+        in: body
+        required: true
+        schema:
+          type: object
+      responses:
+        200:
+          description: ok
+  /pets/search:
+    post:
+      operationId: searchPets
+      parameters:
+      - name: body
+        in: body
+        schema:
+          type: object
+      responses:
+        200:
+          description: ok
+`)
+
+	cases := []struct {
+		path            string
+		body            string
+		expectedPayload string
+	}{
+		// optional body is empty
+		{
+			path:            "/v1/pets/search",
+			expectedPayload: "ok",
+		},
+		// optional body is whitespace
+		{
+			path:            "/v1/pets/search",
+			body:            " \r\n\t",
+			expectedPayload: "ok",
+		},
+		// optional body is invalid
+		{
+			path:            "/v1/pets/search",
+			body:            " {",
+			expectedPayload: `{"errors":[{"message":"Body contains invalid json"}]}`,
+		},
+		// required body is empty
+		{
+			path:            "/v1/pets",
+			expectedPayload: `{"errors":[{"message":"Body is required"}]}`,
+		},
+		// required body is whitespace
+		{
+			path:            "/v1/pets",
+			body:            "\n",
+			expectedPayload: `{"errors":[{"message":"Body is required"}]}`,
+		},
+	}
+
+	handlers := OperationHandlers{
+		"addPet":     http.HandlerFunc(func(w http.ResponseWriter, req *http.Request) { fmt.Fprint(w, "ok") }),
+		"searchPets": http.HandlerFunc(func(w http.ResponseWriter, req *http.Request) { fmt.Fprint(w, "ok") }),
+	}
+
+	router, err := NewRouter(sw, handlers, MiddlewareOpt(NewBodyValidator(writeErrorsToResponseWriter).Apply))
+	if err != nil {
+		t.Fatal(err)
+	}
+
+	for _, c := range cases {
+		// The body is zero-length rather than http.NoBody.
+		req := httptest.NewRequest(http.MethodPost, c.path, ioutil.NopCloser(strings.NewReader(c.body)))
+		req.Header.Set("Content-Type", "application/json")
+		w := httptest.NewRecorder()
+
+		router.ServeHTTP(w, req)
+
+		if c.expectedPayload != w.Body.String() {
+			t.Errorf("Expected response body for %q to be\n%s\nbut got\n%s", c.body, c.expectedPayload, w.Body.String())
+		}
+	}
+}
+
+func TestBodyValidatorMiddleware_Apply_deadline(t *testing.T) {
+	sw := parseSpec(`
+swagger: "2.0"
+info:
+  title: test
+  version: "1.0"
+basePath: /v1
+paths:
+  /pets:
+    post:
+      operationId: addPet
+      parameters:
+      - name: body
+        in: body
+        schema:
+          type: object
+      responses:
+        200:
+          description: ok
+`)
+
+	cases := []struct {
+		delay           time.Duration
+		expectedStatus  int
+		expectedPayload string
+	}{
+		// the body is received in time
+		{
+			expectedStatus:  http.StatusOK,
+			expectedPayload: "ok",
+		},
+		// the body is trickled past the deadline
+		{
+			delay:           20 * time.Millisecond,
+			expectedStatus:  http.StatusRequestTimeout,
+			expectedPayload: `{"errors":[{"message":"Body was not received before the request deadline"}]}`,
+		},
+	}
+
+	handlers := OperationHandlers{"addPet": http.HandlerFunc(func(w http.ResponseWriter, req *http.Request) {
+		fmt.Fprint(w, "ok")
+	})}
+
+	router, err := NewRouter(sw, handlers, MiddlewareOpt(NewBodyValidator(writeErrorsToResponseWriter).Apply))
+	if err != nil {
+		t.Fatal(err)
+	}
+
+	for _, c := range cases {
+		ctx, cancel := context.WithTimeout(context.Background(), 50*time.Millisecond)
+
+		body := &slowReader{r: strings.NewReader(`{"name":"Kitty","tags":["cat"]}`), delay: c.delay}
+		req := httptest.NewRequest(http.MethodPost, "/v1/pets", body).WithContext(ctx)
+		req.Header.Set("Content-Type", "application/json")
+		w := httptest.NewRecorder()
+
+		router.ServeHTTP(w, req)
+		cancel()
+
+		if w.Code != c.expectedStatus {
+			t.Errorf("Expected status to be %d but got %d", c.expectedStatus, w.Code)
+		}
+		if c.expectedPayload != w.Body.String() {
+			t.Errorf("Expected response body to be\n%s\nbut got\n%s", c.expectedPayload, w.Body.String())
+		}
+	}
+}
+
+// slowReader reads one byte at a time after the delay.
+type slowReader struct {
+	r     io.Reader
+	delay time.Duration
+}
+
+func (r *slowReader) Read(p []byte) (int, error) {
+	time.Sleep(r.delay)
+	if len(p) > 1 {
+		p = p[:1]
+	}
+	return r.r.Read(p)
+}
+
+func TestMaxJSONDepthOpt(t *testing.T) {
+	sw := parseSpec(`
+swagger: "2.0"
+info:
+  title: test
+  version: "1.0"
+basePath: /v1
+paths:
+  /documents:
+    post:
+      operationId: addDocument
+      parameters:
+      - name: body
+        in: body
+        schema: {}
+      responses:
+        200:
+          description: ok
+`)
+
+	cases := []struct {
+		options         []MiddlewareOption
+		body            string
+		expectedPayload string
+	}{
+		// nested within the limit
+		{
+			options:         []MiddlewareOption{MaxJSONDepthOpt(3)},
+			body:            `{"a":[{"b":1}]}`,
+			expectedPayload: "ok",
+		},
+		// brackets in strings do not count
+		{
+			options:         []MiddlewareOption{MaxJSONDepthOpt(1)},
+			body:            `{"a":"[[{\"[["}`,
+			expectedPayload: "ok",
+		},
+		// nested arrays exceed the limit
+		{
+			options:         []MiddlewareOption{MaxJSONDepthOpt(3)},
+			body:            `[[[[1]]]]`,
+			expectedPayload: `{"errors":[{"message":"Body exceeds maximum json nesting depth of 3"}]}`,
+		},
+		// nested objects exceed the limit
+		{
+			options:         []MiddlewareOption{MaxJSONDepthOpt(3)},
+			body:            `{"a":{"b":{"c":{"d":1}}}}`,
+			expectedPayload: `{"errors":[{"message":"Body exceeds maximum json nesting depth of 3"}]}`,
+		},
+		// default limit
+		{
+			body:            strings.Repeat("[", 100000) + strings.Repeat("]", 100000),
+			expectedPayload: `{"errors":[{"message":"Body exceeds maximum json nesting depth of 64"}]}`,
+		},
+		// no limit
+		{
+			options:         []MiddlewareOption{MaxJSONDepthOpt(0)},
+			body:            strings.Repeat("[", 100) + strings.Repeat("]", 100),
+			expectedPayload: "ok",
+		},
+	}
+
+	handlers := OperationHandlers{"addDocument": http.HandlerFunc(func(w http.ResponseWriter, req *http.Request) {
+		fmt.Fprint(w, "ok")
+	})}
+
+	for _, c := range cases {
+		bodyValidator := NewBodyValidator(writeErrorsToResponseWriter, c.options...)
+
+		router, err := NewRouter(sw, handlers, MiddlewareOpt(bodyValidator.Apply))
+		if err != nil {
+			t.Fatal(err)
+		}
+
+		req := httptest.NewRequest(http.MethodPost, "/v1/documents", strings.NewReader(c.body))
+		req.Header.Set("Content-Type", "application/json")
+		w := httptest.NewRecorder()
+
+		router.ServeHTTP(w, req)
+
+		if c.expectedPayload != w.Body.String() {
+			t.Errorf("Expected response body to be\n%s\nbut got\n%s", c.expectedPayload, w.Body.String())
+		}
+	}
+}
+
+func TestTrailingDataOpt(t *testing.T) {
+	sw := parseSpec(`
+swagger: "2.0"
+info:
+  title: test
+  version: "1.0"
+basePath: /v1
+paths:
+  /documents:
+    post:
+      operationId: addDocument
+      parameters:
+      - name: body
+        in: body
+        schema:
+          type: object
+      responses:
+        200:
+          description: ok
+`)
+
+	cases := []struct {
+		options         []MiddlewareOption
+		body            string
+		expectedPayload string
+	}{
+		// trailing garbage is passed to the handler by default
+		{
+			body:            `{"a":1}garbage`,
+			expectedPayload: `{"a":1}garbage`,
+		},
+		// trailing garbage is rejected
+		{
+			options:         []MiddlewareOption{TrailingDataOpt(TrailingDataReject)},
+			body:            `{"a":1}garbage`,
+			expectedPayload: `{"errors":[{"message":"Body contains data after the json value"}]}`,
+		},
+		// second value is rejected
+		{
+			options:         []MiddlewareOption{TrailingDataOpt(TrailingDataReject)},
+			body:            `{"a":1} {"a":2}`,
+			expectedPayload: `{"errors":[{"message":"Body contains data after the json value"}]}`,
+		},
+		// trailing whitespace is accepted
+		{
+			options:         []MiddlewareOption{TrailingDataOpt(TrailingDataReject)},
+			body:            "{\"a\":1} \n",
+			expectedPayload: "{\"a\":1} \n",
+		},
+		// trailing garbage is trimmed
+		{
+			options:         []MiddlewareOption{TrailingDataOpt(TrailingDataTrim)},
+			body:            `{"a":1}garbage`,
+			expectedPayload: `{"a":1}`,
+		},
+		// trailing whitespace is trimmed
+		{
+			options:         []MiddlewareOption{TrailingDataOpt(TrailingDataTrim)},
+			body:            "{\"a\":1} \n",
+			expectedPayload: `{"a":1}`,
+		},
+	}
+
+	handlers := OperationHandlers{"addDocument": http.HandlerFunc(func(w http.ResponseWriter, req *http.Request) {
+		io.Copy(w, req.Body)
+	})}
+
+	for _, c := range cases {
+		bodyValidator := NewBodyValidator(writeErrorsToResponseWriter, c.options...)
+
+		router, err := NewRouter(sw, handlers, MiddlewareOpt(bodyValidator.Apply))
+		if err != nil {
+			t.Fatal(err)
+		}
+
+		req := httptest.NewRequest(http.MethodPost, "/v1/documents", strings.NewReader(c.body))
+		req.Header.Set("Content-Type", "application/json")
+		w := httptest.NewRecorder()
+
+		router.ServeHTTP(w, req)
+
+		if c.expectedPayload != w.Body.String() {
+			t.Errorf("Expected response body to be\n%s\nbut got\n%s", c.expectedPayload, w.Body.String())
+		}
+	}
+}
+
+// failFastSpec has an operation with many query parameters, to validate
+// heavily invalid queries.
+const failFastSpec = `
+swagger: "2.0"
+info:
+  title: test
+  version: "1.0"
+basePath: /v1
+paths:
+  /pets:
+    get:
+      operationId: findPets
+      parameters:
+      - {name: a, in: query, type: integer, minimum: 10}
+      - {name: b, in: query, type: integer, minimum: 10}
+      - {name: c, in: query, type: integer, minimum: 10}
+      - {name: d, in: query, type: string, pattern: "^[a-z]+$"}
+      - {name: e, in: query, type: string, pattern: "^[a-z]+$"}
+      responses:
+        200:
+          description: ok
+`
+
+func TestFailFastOpt(t *testing.T) {
+	sw := parseSpec(failFastSpec)
+
+	cases := []struct {
+		enabled         bool
+		expectedPayload string
+	}{
+		{
+			expectedPayload: `{"errors":[` +
+				`{"message":"a in query should be greater than or equal to 10","field":"a","value":1},` +
+				`{"message":"b in query should be greater than or equal to 10","field":"b","value":2},` +
+				`{"message":"param c: cannot convert x to int64","field":"c","value":"x"}]}`,
+		},
+		{
+			enabled:         true,
+			expectedPayload: `{"errors":[{"message":"a in query should be greater than or equal to 10","field":"a","value":1}]}`,
+		},
+	}
+
+	handlers := OperationHandlers{"findPets": http.HandlerFunc(func(w http.ResponseWriter, req *http.Request) {})}
+
+	for _, c := range cases {
+		queryValidator := NewQueryValidator(writeErrorsToResponseWriter, FailFastOpt(c.enabled))
+
+		router, err := NewRouter(sw, handlers, MiddlewareOpt(queryValidator.Apply))
+		if err != nil {
+			t.Fatal(err)
+		}
+
+		w := httptest.NewRecorder()
+		router.ServeHTTP(w, httptest.NewRequest(http.MethodGet, "/v1/pets?a=1&b=2&c=x&d=ok", nil))
+
+		if c.expectedPayload != w.Body.String() {
+			t.Errorf("Expected response body to be\n%s\nbut got\n%s", c.expectedPayload, w.Body.String())
+		}
+	}
+}
+
+func BenchmarkFailFastOpt(b *testing.B) {
+	sw := parseSpec(failFastSpec)
+
+	handlers := OperationHandlers{"findPets": http.HandlerFunc(func(w http.ResponseWriter, req *http.Request) {})}
+
+	for _, enabled := range []bool{false, true} {
+		b.Run(fmt.Sprintf("enabled=%t", enabled), func(b *testing.B) {
+			queryValidator := NewQueryValidator(func(w http.ResponseWriter, errs []error) {}, FailFastOpt(enabled))
+
+			router, err := NewRouter(sw, handlers, MiddlewareOpt(queryValidator.Apply))
+			if err != nil {
+				b.Fatal(err)
+			}
+
+			b.ReportAllocs()
+			b.ResetTimer()
+			for i := 0; i < b.N; i++ {
+				req := httptest.NewRequest(http.MethodGet, "/v1/pets?a=1&b=2&c=3&d=X&e=Y&f=z", nil)
+				router.ServeHTTP(httptest.NewRecorder(), req)
+			}
+		})
+	}
+}
+
+func TestStreamArraysOpt(t *testing.T) {
+	sw := parseSpec(`
+swagger: "2.0"
+info:
+  title: test
+  version: "1.0"
+basePath: /v1
+paths:
+  /pets/bulk:
+    post:
+      operationId: addPets
+      parameters:
+      - name: pets
+        in: body
+        schema:
+          type: array
+          maxItems: 10000
+          items:
+            $ref: "#/definitions/Pet"
+      responses:
+        200:
+          description: ok
+definitions:
+  Pet:
+    type: object
+    required: [name]
+    properties:
+      name:
+        type: string
+      age:
+        type: integer
+`)
+
+	pets := make([]string, 5000)
+	for i := range pets {
+		pets[i] = fmt.Sprintf(`{"name":"pet%d","age":%d}`, i, i%20)
+	}
+	valid := "[" + strings.Join(pets, ",") + "]"
+	pets[3] = `{"name":"pet3","age":"three"}`
+	pets[7] = `{"age":7}`
+	invalid := "[" + strings.Join(pets, ",") + "]"
+
+	cases := []struct {
+		options         []MiddlewareOption
+		body            string
+		expectedPayload string
+	}{
+		// valid array is passed to the handler as is
+		{
+			body:            valid,
+			expectedPayload: fmt.Sprintf("received %d bytes", len(valid)),
+		},
+		// invalid items are reported by index
+		{
+			body:            invalid,
+			expectedPayload: `{"errors":[{"message":"3.age in body must be of type integer: \"string\"","field":"3.age"},{"message":"7.name in body is required","field":"7.name"}]}`,
+		},
+		// the first error only
+		{
+			options:         []MiddlewareOption{FailFastOpt(true)},
+			body:            invalid,
+			expectedPayload: `{"errors":[{"message":"3.age in body must be of type integer: \"string\"","field":"3.age"}]}`,
+		},
+		// not an array
+		{
+			body:            `{"name":"Kitty"}`,
+			expectedPayload: `{"errors":[{"message":"pets in body must be of type array","field":"pets"}]}`,
+		},
+		// invalid json after valid items
+		{
+			body:            `[{"name":"Kitty"},{"name":`,
+			expectedPayload: `{"errors":[{"message":"Body contains invalid json"}]}`,
+		},
+	}
+
+	handlers := OperationHandlers{"addPets": http.HandlerFunc(func(w http.ResponseWriter, req *http.Request) {
+		b, _ := ioutil.ReadAll(req.Body)
+		fmt.Fprintf(w, "received %d bytes", len(b))
+	})}
+
+	for _, c := range cases {
+		options := append([]MiddlewareOption{StreamArraysOpt(true)}, c.options...)
+		bodyValidator := NewBodyValidator(writeErrorsToResponseWriter, options...)
+
+		router, err := NewRouter(sw, handlers, MiddlewareOpt(bodyValidator.Apply))
+		if err != nil {
+			t.Fatal(err)
+		}
+
+		req := httptest.NewRequest(http.MethodPost, "/v1/pets/bulk", strings.NewReader(c.body))
+		req.Header.Set("Content-Type", "application/json")
+		w := httptest.NewRecorder()
+
+		router.ServeHTTP(w, req)
+
+		if c.expectedPayload != w.Body.String() {
+			t.Errorf("Expected response body to be\n%s\nbut got\n%s", c.expectedPayload, w.Body.String())
+		}
+	}
+}
+
+func TestRejectDuplicateKeysOpt(t *testing.T) {
+	sw := parseSpec(`
+swagger: "2.0"
+info:
+  title: test
+  version: "1.0"
+basePath: /v1
+paths:
+  /documents:
+    post:
+      operationId: addDocument
+      parameters:
+      - name: body
+        in: body
+        schema:
+          type: object
+      responses:
+        200:
+          description: ok
+`)
+
+	cases := []struct {
+		options         []MiddlewareOption
+		body            string
+		expectedPayload string
+	}{
+		// duplicate keys are accepted by default
+		{
+			body:            `{"role":"user","role":"admin"}`,
+			expectedPayload: "ok",
+		},
+		{
+			options:         []MiddlewareOption{RejectDuplicateKeysOpt(true)},
+			body:            `{"role":"user","role":"admin"}`,
+			expectedPayload: `{"errors":[{"message":"Body contains duplicate key role","field":"role"}]}`,
+		},
+		// duplicate keys of nested objects
+		{
+			options:         []MiddlewareOption{RejectDuplicateKeysOpt(true)},
+			body:            `{"user":{"name":"john","tags":["name"],"name":"admin"}}`,
+			expectedPayload: `{"errors":[{"message":"Body contains duplicate key name","field":"name"}]}`,
+		},
+		// same keys in different objects and values equal to keys
+		{
+			options:         []MiddlewareOption{RejectDuplicateKeysOpt(true)},
+			body:            `{"a":{"id":1},"b":{"id":"a"},"c":[{"id":2},{"id":3}]}`,
+			expectedPayload: "ok",
+		},
+	}
+
+	handlers := OperationHandlers{"addDocument": http.HandlerFunc(func(w http.ResponseWriter, req *http.Request) {
+		fmt.Fprint(w, "ok")
+	})}
+
+	for _, c := range cases {
+		bodyValidator := NewBodyValidator(writeErrorsToResponseWriter, c.options...)
+
+		router, err := NewRouter(sw, handlers, MiddlewareOpt(bodyValidator.Apply))
+		if err != nil {
+			t.Fatal(err)
+		}
+
+		req := httptest.NewRequest(http.MethodPost, "/v1/documents", strings.NewReader(c.body))
+		req.Header.Set("Content-Type", "application/json")
+		w := httptest.NewRecorder()
+
+		router.ServeHTTP(w, req)
+
+		if c.expectedPayload != w.Body.String() {
+			t.Errorf("Expected response body to be\n%s\nbut got\n%s", c.expectedPayload, w.Body.String())
+		}
+	}
+}
+
+func TestRepeatedParamOpt(t *testing.T) {
+	sw := parseSpec(`
+swagger: "2.0"
+info:
+  title: test
+  version: "1.0"
+basePath: /v1
+paths:
+  /pets:
+    get:
+      operationId: findPets
+      parameters:
+      - name: id
+        in: query
+        type: integer
+      - name: tags
+        in: query
+        type: array
+        collectionFormat: multi
+        items:
+          type: string
+      responses:
+        200:
+          description: ok
+`)
+
+	cases := []struct {
+		options         []MiddlewareOption
+		query           string
+		expectedPayload string
+	}{
+		// repeated values are rejected by default
+		{
+			query:           "id=1&id=2",
+			expectedPayload: `{"errors":[{"message":"parameter id must not be repeated","field":"id","value":["1","2"]}]}`,
+		},
+		{
+			options:         []MiddlewareOption{RepeatedParamOpt(RepeatedParamFirst)},
+			query:           "id=1&id=2&id=3",
+			expectedPayload: "id=1",
+		},
+		{
+			options:         []MiddlewareOption{RepeatedParamOpt(RepeatedParamLast)},
+			query:           "id=1&id=2&id=3",
+			expectedPayload: "id=3",
+		},
+		// arrays are not affected
+		{
+			options:         []MiddlewareOption{RepeatedParamOpt(RepeatedParamFirst)},
+			query:           "tags=cat&tags=small",
+			expectedPayload: "tags=cat&tags=small",
+		},
+	}
+
+	handlers := OperationHandlers{"findPets": http.HandlerFunc(func(w http.ResponseWriter, req *http.Request) {
+		fmt.Fprint(w, req.URL.RawQuery)
+	})}
+
+	for _, c := range cases {
+		queryValidator := NewQueryValidator(writeErrorsToResponseWriter, c.options...)
+
+		router, err := NewRouter(sw, handlers, MiddlewareOpt(queryValidator.Apply))
+		if err != nil {
+			t.Fatal(err)
+		}
+
+		w := httptest.NewRecorder()
+		router.ServeHTTP(w, httptest.NewRequest(http.MethodGet, "/v1/pets?"+c.query, nil))
+
+		if c.expectedPayload != w.Body.String() {
+			t.Errorf("Expected response body to be\n%s\nbut got\n%s", c.expectedPayload, w.Body.String())
+		}
+	}
+}
+
+func TestCanonicalQueryOpt(t *testing.T) {
+	sw := parseSpec(`
+swagger: "2.0"
+info:
+  title: test
+  version: "1.0"
+basePath: /v1
+paths:
+  /pets:
+    get:
+      operationId: findPets
+      parameters:
+      - name: limit
+        in: query
+        type: integer
+      - name: sort
+        in: query
+        type: string
+      - name: tags
+        in: query
+        type: array
+        collectionFormat: multi
+        items:
+          type: string
+      responses:
+        200:
+          description: ok
+`)
+
+	cases := []struct {
+		options         []MiddlewareOption
+		query           string
+		expectedPayload string
+	}{
+		// the query is kept as is by default
+		{
+			query:           "sort=name&limit=10",
+			expectedPayload: "sort=name&limit=10",
+		},
+		// parameters are sorted by name
+		{
+			options:         []MiddlewareOption{CanonicalQueryOpt(true)},
+			query:           "sort=name&limit=10",
+			expectedPayload: "limit=10&sort=name",
+		},
+		// repeated values keep their order
+		{
+			options:         []MiddlewareOption{CanonicalQueryOpt(true)},
+			query:           "tags=small&sort=name&tags=cat&limit=10",
+			expectedPayload: "limit=10&sort=name&tags=small&tags=cat",
+		},
+		// invalid queries are not rewritten
+		{
+			options:         []MiddlewareOption{CanonicalQueryOpt(true)},
+			query:           "sort=name&limit=ten",
+			expectedPayload: `{"errors":[{"message":"param limit: cannot convert ten to int64","field":"limit","value":"ten"}]}`,
+		},
+	}
+
+	handlers := OperationHandlers{"findPets": http.HandlerFunc(func(w http.ResponseWriter, req *http.Request) {
+		fmt.Fprint(w, req.URL.RawQuery)
+	})}
+
+	for _, c := range cases {
+		queryValidator := NewQueryValidator(writeErrorsToResponseWriter, c.options...)
+
+		router, err := NewRouter(sw, handlers, MiddlewareOpt(queryValidator.Apply))
+		if err != nil {
+			t.Fatal(err)
+		}
+
+		w := httptest.NewRecorder()
+		router.ServeHTTP(w, httptest.NewRequest(http.MethodGet, "/v1/pets?"+c.query, nil))
+
+		if c.expectedPayload != w.Body.String() {
+			t.Errorf("Expected response body to be\n%s\nbut got\n%s", c.expectedPayload, w.Body.String())
+		}
+	}
+}
+
+func TestQueryValidatorMiddleware_Apply_sharedParameter(t *testing.T) {
+	sw := parseSpec(`
+swagger: "2.0"
+info:
+  title: test
+  version: "1.0"
+basePath: /v1
+paths:
+  /pets:
+    get:
+      operationId: findPets
+      parameters:
+      - $ref: "#/parameters/Limit"
+      responses:
+        200:
+          description: ok
+parameters:
+  Limit:
+    name: limit
+    in: query
+    type: integer
+    maximum: 100
+`)
+
+	handlers := OperationHandlers{"findPets": http.HandlerFunc(func(w http.ResponseWriter, req *http.Request) {
+		fmt.Fprint(w, "ok")
+	})}
+
+	logBuffer := &bytes.Buffer{}
+	queryValidator := NewQueryValidator(errorLogger(logBuffer))
+
+	router, err := NewRouter(sw, handlers, MiddlewareOpt(queryValidator.Apply))
+	if err != nil {
+		t.Fatal(err)
+	}
+
+	w := httptest.NewRecorder()
+	router.ServeHTTP(w, httptest.NewRequest(http.MethodGet, "/v1/pets?limit=1000", nil))
+
+	expectedLogBuffer := "response data does not match the schema: field=limit value=1000 message=limit in query should be less than or equal to 100"
+	if actual := strings.TrimSpace(logBuffer.String()); actual != expectedLogBuffer {
+		t.Errorf("Expected log buffer to be\n%v\nbut got\n%v", expectedLogBuffer, actual)
+	}
+}
+
+func TestFormatValidationOpt(t *testing.T) {
+	sw := parseSpec(`
+swagger: "2.0"
+info:
+  title: test
+  version: "1.0"
+basePath: /v1
+paths:
+  /subscribers:
+    post:
+      operationId: subscribe
+      parameters:
+      - name: email
+        in: query
+        type: string
+        format: email
+      - name: body
+        in: body
+        schema:
+          type: object
+          properties:
+            homepage:
+              type: string
+              format: uri
+      responses:
+        200:
+          description: ok
+`)
+
+	cases := []struct {
+		enabled           bool
+		expectedLogBuffer string
+	}{
+		// enabled
+		{
+			enabled: true,
+			expectedLogBuffer: "response data does not match the schema: field=email value=john message=email in query must be of type email: \"john\"\n" +
+				"response data does not match the schema: field=homepage value=<nil> message=homepage in body must be of type uri: \"home\"\n",
+		},
+		// disabled
+		{
+			enabled: false,
+		},
+	}
+
+	for _, c := range cases {
+		handlers := OperationHandlers{"subscribe": http.HandlerFunc(func(w http.ResponseWriter, req *http.Request) {
+			fmt.Fprint(w, "ok")
+		})}
+
+		logBuffer := &bytes.Buffer{}
+		queryValidator := NewQueryValidator(errorLogger(logBuffer), FormatValidationOpt(c.enabled))
+		bodyValidator := NewBodyValidator(errorLogger(logBuffer), FormatValidationOpt(c.enabled))
+
+		router, err := NewRouter(sw, handlers, MiddlewareOpt(queryValidator.Apply), MiddlewareOpt(bodyValidator.Apply))
+		if err != nil {
+			t.Fatal(err)
+		}
+
+		for _, r := range []struct{ query, body string }{
+			{query: "email=john", body: `{"homepage":"https://example.com"}`},
+			{query: "email=john@example.com", body: `{"homepage":"home"}`},
+		} {
+			req := httptest.NewRequest(http.MethodPost, "/v1/subscribers?"+r.query, strings.NewReader(r.body))
+			req.Header.Set("Content-Type", "application/json")
+			router.ServeHTTP(httptest.NewRecorder(), req)
+		}
+
+		if logBuffer.String() != c.expectedLogBuffer {
+			t.Errorf("Expected log buffer with format validation %v to be\n%v\nbut got\n%v", c.enabled, c.expectedLogBuffer, logBuffer.String())
+		}
+	}
+}
+
+func TestStrictQueryMiddleware_Apply(t *testing.T) {
+	cases := []struct {
+		url             string
+		expectedPayload string
+	}{
+		// declared parameters
+		{
+			url:             "/v2/user/login?username=johndoe&password=123",
+			expectedPayload: "ok",
+		},
+		// extra parameters
+		{
+			url:             "/v2/user/login?username=johndoe&password=123&pag=2&debug=1",
+			expectedPayload: `{"errors":[{"message":"parameter debug is unknown","field":"debug","value":"1"},{"message":"parameter pag is unknown","field":"pag","value":"2"}]}`,
+		},
+		// allowlisted parameter
+		{
+			url:             "/v2/user/login?username=johndoe&password=123&_=1514764800",
+			expectedPayload: "ok",
+		},
+	}
+
+	// set up
+
+	doc := loadDoc()
+
+	handlers := OperationHandlers{"loginUser": http.HandlerFunc(func(w http.ResponseWriter, req *http.Request) {
+		fmt.Fprint(w, "ok")
+	})}
+
+	strictQuery := NewStrictQuery(writeErrorsToResponseWriter, QueryAllowlistOpt("_"))
+	opts := []RouterOption{MiddlewareOpt(strictQuery.Apply)}
+
+	router, err := NewRouter(doc.Spec(), handlers, opts...)
+	if err != nil {
+		t.Fatal(err)
+	}
+
+	server := httptest.NewServer(router)
+	client := server.Client()
+
+	// test
+
+	for _, c := range cases {
+		resp, err := client.Get(server.URL + c.url)
+		if err != nil {
+			t.Fatal(err)
+		}
+
+		respBody, err := ioutil.ReadAll(resp.Body)
+		if err != nil {
+			t.Fatal(err)
+		}
+
+		if !bytes.Equal([]byte(c.expectedPayload), respBody) {
+			t.Errorf("Expected response body to be\n%s\nbut got\n%s", c.expectedPayload, string(respBody))
+		}
+	}
+
+	// tear down
+
+	server.Close()
+}
+
+func TestStrictQueryMiddleware_Apply_queryValidator(t *testing.T) {
+	doc := loadDoc()
+
+	handlers := OperationHandlers{"loginUser": http.HandlerFunc(func(w http.ResponseWriter, req *http.Request) {
+		fmt.Fprint(w, "ok")
+	})}
+
+	options := []MiddlewareOption{QueryAllowlistOpt("_")}
+	strictQuery := NewStrictQuery(writeErrorsToResponseWriter, options...)
+	queryValidator := NewQueryValidator(writeErrorsToResponseWriter, options...)
+
+	router, err := NewRouter(doc.Spec(), handlers, MiddlewareOpt(queryValidator.Apply), MiddlewareOpt(strictQuery.Apply))
+	if err != nil {
+		t.Fatal(err)
+	}
+
+	cases := []struct {
+		url             string
+		expectedPayload string
+	}{
+		// allowlisted parameter is accepted by both middlewares
+		{
+			url:             "/v2/user/login?username=johndoe&password=123&_=1514764800",
+			expectedPayload: "ok",
+		},
+		// extra parameters are rejected by strict query first
+		{
+			url:             "/v2/user/login?username=johndoe&password=123&_=1514764800&debug=1",
+			expectedPayload: `{"errors":[{"message":"parameter debug is unknown","field":"debug","value":"1"}]}`,
+		},
+	}
+
+	for _, c := range cases {
+		req := httptest.NewRequest(http.MethodGet, c.url, nil)
+		w := httptest.NewRecorder()
+		router.ServeHTTP(w, req)
+
+		if w.Body.String() != c.expectedPayload {
+			t.Errorf("Expected response body to be\n%s\nbut got\n%s", c.expectedPayload, w.Body.String())
+		}
+	}
+}
+
 func TestBodyValidatorMiddleware_Apply(t *testing.T) {
 	cases := []struct {
 		url                string
-		body               *bytes.Buffer
+		body               *bytes.Buffer
+		expectedStatusCode int
+		expectedPayload    string
+	}{
+		// ok
+		{
+			url:                "/pet",
+			body:               bytes.NewBufferString(`{"name":"johndoe", "age":7}`),
+			expectedStatusCode: http.StatusOK,
+			expectedPayload:    "pet name: johndoe",
+		},
+		// required field "name" is missing
+		{
+			url:                "/pet",
+			body:               bytes.NewBufferString(`{"age":7}`),
+			expectedStatusCode: http.StatusBadRequest,
+			expectedPayload:    `{"errors":[{"message":"name in body is required","field":"name"}]}`,
+		},
+		// value for field "age" is incorrect
+		{
+			url:                "/pet",
+			body:               bytes.NewBufferString(`{"name":"johndoe","age":"abc"}`),
+			expectedStatusCode: http.StatusBadRequest,
+			expectedPayload:    `{"errors":[{"message":"age in body must be of type integer: \"string\"","field":"age"}]}`,
+		},
+		// no body
+		{
+			url:                "/pet",
+			body:               &bytes.Buffer{},
+			expectedStatusCode: http.StatusBadRequest,
+		},
+		// invalid json body
+		{
+			url:                "/pet",
+			body:               bytes.NewBufferString(`{"name":"johndoe`),
+			expectedStatusCode: http.StatusBadRequest,
+			expectedPayload:    `{"errors":[{"message":"Body contains invalid json"}]}`,
+		},
+		// request an url which handler does not provide operation context
+		{
+			url:                "/no_operation_resource",
+			body:               bytes.NewBufferString(`{"name":"johndoe`),
+			expectedStatusCode: http.StatusOK,
+			expectedPayload:    "hit no operation resource",
+		},
+	}
+
+	// set up
+
+	doc := loadDoc()
+
+	handlers := OperationHandlers{"addPet": http.HandlerFunc(func(w http.ResponseWriter, req *http.Request) {
+		type pet struct {
+			Name      string   `json:"name"`
+			PhotoURLs []string `json:"photoUrls"`
+		}
+
+		var p pet
+		if err := json.NewDecoder(req.Body).Decode(&p); err != nil {
+			w.WriteHeader(http.StatusBadRequest)
+			return
+		}
+
+		fmt.Fprintf(w, "pet name: %s", p.Name)
+	})}
+	noOpHandler := http.HandlerFunc(func(w http.ResponseWriter, req *http.Request) {
+		fmt.Fprint(w, "hit no operation resource")
+	})
+
+	bodyValidator := NewBodyValidator(writeErrorsToResponseWriter)
+	opts := []RouterOption{MiddlewareOpt(bodyValidator.Apply)}
+
+	operationsRouter, err := NewRouter(doc.Spec(), handlers, opts...)
+	if err != nil {
+		t.Fatal(err)
+	}
+
+	finalRouter := chi.NewRouter()
+	finalRouter.Mount("/", operationsRouter)
+	finalRouter.Handle("/no_operation_resource", bodyValidator.Apply(noOpHandler))
+
+	server := httptest.NewServer(finalRouter)
+	client := server.Client()
+
+	// test
+
+	for _, c := range cases {
+		resp, err := client.Post(server.URL+c.url, "application/json", c.body)
+		if err != nil {
+			t.Fatal(err)
+		}
+
+		respBody, err := ioutil.ReadAll(resp.Body)
+		if err != nil {
+			t.Fatal(err)
+		}
+
+		if !bytes.Equal([]byte(c.expectedPayload), respBody) {
+			t.Fatalf("Expected response body to be\n%s\nbut got\n%s", c.expectedPayload, string(respBody))
+		}
+	}
+
+	// tear down
+
+	server.Close()
+}
+
+func TestBodyValidationCacheOpt_unnamedOperations(t *testing.T) {
+	sw := parseSpec(`
+swagger: "2.0"
+info:
+  title: test
+  version: "1.0"
+paths:
+  /a:
+    post:
+      parameters:
+      - name: body
+        in: body
+        schema:
+          type: object
+          required: [a]
+      responses:
+        200:
+          description: ok
+  /b:
+    post:
+      parameters:
+      - name: body
+        in: body
+        schema:
+          type: object
+          required: [b]
+      responses:
+        200:
+          description: ok
+`)
+
+	resolver := func(req *http.Request) *spec.Operation {
+		return sw.Paths.Paths[req.URL.Path].Post
+	}
+	bodyValidator := NewBodyValidator(
+		writeErrorsToResponseWriter,
+		OperationResolverOpt(resolver),
+		BodyValidationCacheOpt(16, time.Minute),
+	)
+	handler := bodyValidator.Apply(http.HandlerFunc(func(w http.ResponseWriter, req *http.Request) {
+		fmt.Fprint(w, "ok")
+	}))
+
+	cases := []struct {
+		path            string
+		expectedPayload string
+	}{
+		{
+			path:            "/a",
+			expectedPayload: "ok",
+		},
+		// the result cached for the other operation is not used
+		{
+			path:            "/b",
+			expectedPayload: `{"errors":[{"message":"b in body is required","field":"b"}]}`,
+		},
+	}
+
+	for _, c := range cases {
+		req := httptest.NewRequest(http.MethodPost, c.path, strings.NewReader(`{"a":1}`))
+		req.Header.Set("Content-Type", "application/json")
+		w := httptest.NewRecorder()
+
+		handler.ServeHTTP(w, req)
+
+		if w.Body.String() != c.expectedPayload {
+			t.Errorf("Expected response body for %s to be\n%s\nbut got\n%s", c.path, c.expectedPayload, w.Body.String())
+		}
+	}
+}
+
+func BenchmarkBodyValidatorMiddleware_Apply(b *testing.B) {
+	benchmarkBodyValidator(b, NewBodyValidator(writeErrorsToResponseWriter))
+}
+
+func BenchmarkBodyValidatorMiddleware_Apply_cached(b *testing.B) {
+	benchmarkBodyValidator(b, NewBodyValidator(writeErrorsToResponseWriter, BodyValidationCacheOpt(128, time.Minute)))
+}
+
+func benchmarkBodyValidator(b *testing.B, bodyValidator Middleware) {
+	doc := loadDoc()
+	op := effectiveOperation(doc.Spec(), doc.Spec().Paths.Paths["/pet"].Post)
+
+	handler := operationIDMiddleware(
+		bodyValidator.Apply(http.HandlerFunc(func(w http.ResponseWriter, req *http.Request) {})),
+		op,
+	)
+	body := []byte(`{"name":"johndoe","age":7,"status":"available"}`)
+
+	b.ResetTimer()
+	for i := 0; i < b.N; i++ {
+		req := httptest.NewRequest(http.MethodPost, "/v2/pet", bytes.NewReader(body))
+		req.Header.Set("Content-Type", "application/json")
+		handler.ServeHTTP(httptest.NewRecorder(), req)
+	}
+}
+
+func TestBodyValidatorMiddleware_Apply_consumes(t *testing.T) {
+	cases := []struct {
+		url             string
+		contentType     string
+		body            string
+		expectedStatus  int
+		expectedPayload string
+	}{
+		// json
+		{
+			url:             "/pet",
+			contentType:     "application/json",
+			body:            `{"name":"johndoe","age":7}`,
+			expectedStatus:  http.StatusOK,
+			expectedPayload: "ok",
+		},
+		// well-formed xml
+		{
+			url:             "/pet",
+			contentType:     "application/xml",
+			body:            `<Pet><name>johndoe</name><age>7</age></Pet>`,
+			expectedStatus:  http.StatusOK,
+			expectedPayload: "ok",
+		},
+		// malformed xml
+		{
+			url:             "/pet",
+			contentType:     "application/xml",
+			body:            `<Pet><name>johndoe</Pet>`,
+			expectedStatus:  http.StatusOK,
+			expectedPayload: `{"errors":[{"message":"Body contains invalid xml"}]}`,
+		},
+		// form
+		{
+			url:             "/pet/12",
+			contentType:     "application/x-www-form-urlencoded",
+			body:            "name=johndoe&status=sold",
+			expectedStatus:  http.StatusOK,
+			expectedPayload: "ok",
+		},
+		// form without required field
+		{
+			url:             "/pet/12",
+			contentType:     "application/x-www-form-urlencoded",
+			body:            "status=sold",
+			expectedStatus:  http.StatusOK,
+			expectedPayload: `{"errors":[{"message":"param name is required","field":"name"}]}`,
+		},
+		// form operation does not consume json
+		{
+			url:             "/pet/12",
+			contentType:     "application/json",
+			body:            "name=johndoe",
+			expectedStatus:  http.StatusUnsupportedMediaType,
+			expectedPayload: `{"errors":[{"message":"Content type application/json is not consumed by the operation"}]}`,
+		},
+		// json operation does not consume plain text
+		{
+			url:             "/pet",
+			contentType:     "text/plain",
+			body:            `{"name":"johndoe","age":7}`,
+			expectedStatus:  http.StatusUnsupportedMediaType,
+			expectedPayload: `{"errors":[{"message":"Content type text/plain is not consumed by the operation"}]}`,
+		},
+	}
+
+	// set up
+
+	doc := loadDoc()
+
+	okHandler := http.HandlerFunc(func(w http.ResponseWriter, req *http.Request) {
+		fmt.Fprint(w, "ok")
+	})
+	handlers := OperationHandlers{
+		"addPet":            okHandler,
+		"updatePetWithForm": okHandler,
+	}
+
+	bodyValidator := NewBodyValidator(writeErrorsToResponseWriter)
+	opts := []RouterOption{MiddlewareOpt(bodyValidator.Apply)}
+
+	router, err := NewRouter(doc.Spec(), handlers, opts...)
+	if err != nil {
+		t.Fatal(err)
+	}
+
+	server := httptest.NewServer(router)
+	client := server.Client()
+
+	// test
+
+	for _, c := range cases {
+		resp, err := client.Post(server.URL+"/v2"+c.url, c.contentType, strings.NewReader(c.body))
+		if err != nil {
+			t.Fatal(err)
+		}
+
+		respBody, err := ioutil.ReadAll(resp.Body)
+		if err != nil {
+			t.Fatal(err)
+		}
+
+		if resp.StatusCode != c.expectedStatus {
+			t.Errorf("Expected status to be %d but got %d", c.expectedStatus, resp.StatusCode)
+		}
+		if !bytes.Equal([]byte(c.expectedPayload), respBody) {
+			t.Errorf("Expected response body to be\n%s\nbut got\n%s", c.expectedPayload, string(respBody))
+		}
+	}
+
+	// tear down
+
+	server.Close()
+}
+
+func TestBodyValidatorMiddleware_Apply_consumesUndecodable(t *testing.T) {
+	sw := parseSpec(`
+swagger: "2.0"
+info:
+  title: test
+  version: "1.0"
+basePath: /v1
+paths:
+  /notes:
+    post:
+      operationId: addNote
+      consumes:
+      - text/plain
+      parameters:
+      - name: note
+        in: body
+        schema:
+          type: string
+          maxLength: 3
+      responses:
+        200:
+          description: ok
+`)
+
+	handlers := OperationHandlers{"addNote": http.HandlerFunc(func(w http.ResponseWriter, req *http.Request) {
+		fmt.Fprint(w, "ok")
+	})}
+
+	bodyValidator := NewBodyValidator(writeErrorsToResponseWriter)
+	router, err := NewRouter(sw, handlers, MiddlewareOpt(bodyValidator.Apply))
+	if err != nil {
+		t.Fatal(err)
+	}
+
+	req := httptest.NewRequest(http.MethodPost, "/v1/notes", strings.NewReader("too long"))
+	req.Header.Set("Content-Type", "text/plain")
+	w := httptest.NewRecorder()
+
+	router.ServeHTTP(w, req)
+
+	if w.Code != http.StatusUnsupportedMediaType {
+		t.Errorf("Expected status to be %d but got %d", http.StatusUnsupportedMediaType, w.Code)
+	}
+	expectedPayload := `{"errors":[{"message":"Body of content type text/plain cannot be validated"}]}`
+	if w.Body.String() != expectedPayload {
+		t.Errorf("Expected response body to be\n%s\nbut got\n%s", expectedPayload, w.Body.String())
+	}
+}
+
+func TestBodyValidatorMiddleware_Apply_consumesSchema(t *testing.T) {
+	sw := parseSpec(`
+swagger: "2.0"
+info:
+  title: test
+  version: "1.0"
+basePath: /v1
+consumes:
+- application/vnd.pet.v1+json
+- application/vnd.pet.v2+json
+paths:
+  /pets:
+    post:
+      operationId: addPet
+      parameters:
+      - name: pet
+        in: body
+        schema:
+          $ref: "#/definitions/PetV1"
+        x-consumes-schema:
+          application/vnd.pet.v2+json:
+            $ref: "#/definitions/PetV2"
+      responses:
+        200:
+          description: ok
+definitions:
+  PetV1:
+    type: object
+    required: [name]
+    properties:
+      name:
+        type: string
+  PetV2:
+    type: object
+    required: [names]
+    properties:
+      names:
+        type: object
+        required: [given]
+        properties:
+          given:
+            type: string
+`)
+
+	cases := []struct {
+		contentType     string
+		body            string
+		expectedPayload string
+	}{
+		// v1 body
+		{
+			contentType:     "application/vnd.pet.v1+json",
+			body:            `{"name":"Rex"}`,
+			expectedPayload: "ok",
+		},
+		// v2 body
+		{
+			contentType:     "application/vnd.pet.v2+json; charset=utf-8",
+			body:            `{"names":{"given":"Rex"}}`,
+			expectedPayload: "ok",
+		},
+		// v1 body sent as v2
+		{
+			contentType:     "application/vnd.pet.v2+json",
+			body:            `{"name":"Rex"}`,
+			expectedPayload: `{"errors":[{"message":"names in body is required","field":"names"}]}`,
+		},
+		// v2 body sent as v1
+		{
+			contentType:     "application/vnd.pet.v1+json",
+			body:            `{"names":{"given":"Rex"}}`,
+			expectedPayload: `{"errors":[{"message":"name in body is required","field":"name"}]}`,
+		},
+	}
+
+	handlers := OperationHandlers{"addPet": http.HandlerFunc(func(w http.ResponseWriter, req *http.Request) {
+		fmt.Fprint(w, "ok")
+	})}
+
+	bodyValidator := NewBodyValidator(writeErrorsToResponseWriter)
+	router, err := NewRouter(sw, handlers, MiddlewareOpt(bodyValidator.Apply))
+	if err != nil {
+		t.Fatal(err)
+	}
+
+	for _, c := range cases {
+		req := httptest.NewRequest(http.MethodPost, "/v1/pets", strings.NewReader(c.body))
+		req.Header.Set("Content-Type", c.contentType)
+		w := httptest.NewRecorder()
+
+		router.ServeHTTP(w, req)
+
+		if c.expectedPayload != w.Body.String() {
+			t.Errorf("Expected response body for %s to be\n%s\nbut got\n%s", c.contentType, c.expectedPayload, w.Body.String())
+		}
+	}
+}
+
+func TestBodyValidatorMiddleware_Apply_multipart(t *testing.T) {
+	sw := parseSpec(`
+swagger: "2.0"
+info:
+  title: test
+  version: "1.0"
+basePath: /v1
+paths:
+  /uploads:
+    post:
+      operationId: upload
+      consumes:
+      - multipart/form-data
+      parameters:
+      - name: title
+        in: formData
+        type: string
+        required: true
+      - name: size
+        in: formData
+        type: integer
+      - name: file
+        in: formData
+        type: file
+        required: true
+      - name: thumbnail
+        in: formData
+        type: file
+        required: true
+      responses:
+        200:
+          description: ok
+`)
+
+	handlers := OperationHandlers{"upload": http.HandlerFunc(func(w http.ResponseWriter, req *http.Request) {
+		f := GetFormFile(req, "file")
+		if f == nil {
+			t.Fatal("Expected file to be available")
+		}
+		fmt.Fprintf(w, "%s: %s", GetFormValue(req, "title"), f.Filename)
+	})}
+
+	bodyValidator := NewBodyValidator(writeErrorsToResponseWriter)
+	router, err := NewRouter(sw, handlers, MiddlewareOpt(bodyValidator.Apply))
+	if err != nil {
+		t.Fatal(err)
+	}
+
+	cases := []struct {
+		fields          map[string]string
+		files           []string
+		expectedPayload string
+	}{
+		// fields and files are valid
+		{
+			fields:          map[string]string{"title": "Kitty", "size": "3"},
+			files:           []string{"file", "thumbnail"},
+			expectedPayload: "Kitty: file.txt",
+		},
+		// errors of fields and files are reported together
+		{
+			fields:          map[string]string{"size": "three"},
+			files:           []string{"file", "extra"},
+			expectedPayload: `{"errors":[{"message":"param title is required","field":"title"},{"message":"param size: cannot convert three to int64","field":"size","value":"three"},{"message":"param thumbnail is required","field":"thumbnail"},{"message":"parameter extra is unknown","field":"extra"}]}`,
+		},
+	}
+
+	for _, c := range cases {
+		body := &bytes.Buffer{}
+		mw := multipart.NewWriter(body)
+		for _, name := range []string{"title", "size"} {
+			if v, ok := c.fields[name]; ok {
+				mw.WriteField(name, v)
+			}
+		}
+		for _, name := range c.files {
+			fw, err := mw.CreateFormFile(name, name+".txt")
+			if err != nil {
+				t.Fatal(err)
+			}
+			fmt.Fprint(fw, "content")
+		}
+		mw.Close()
+
+		req := httptest.NewRequest(http.MethodPost, "/v1/uploads", body)
+		req.Header.Set("Content-Type", mw.FormDataContentType())
+
+		w := httptest.NewRecorder()
+		router.ServeHTTP(w, req)
+
+		if w.Body.String() != c.expectedPayload {
+			t.Errorf("Expected response body to be\n%s\nbut got\n%s", c.expectedPayload, w.Body.String())
+		}
+	}
+}
+
+func TestMultipartLimitsOpt(t *testing.T) {
+	sw := parseSpec(`
+swagger: "2.0"
+info:
+  title: test
+  version: "1.0"
+basePath: /v1
+paths:
+  /uploads:
+    post:
+      operationId: upload
+      consumes:
+      - multipart/form-data
+      parameters:
+      - name: tags
+        in: formData
+        type: array
+        collectionFormat: multi
+        items:
+          type: string
+      - name: file
+        in: formData
+        type: file
+      responses:
+        200:
+          description: ok
+`)
+
+	handlers := OperationHandlers{"upload": http.HandlerFunc(func(w http.ResponseWriter, req *http.Request) {
+		fmt.Fprint(w, "ok")
+	})}
+
+	limits := MultipartLimits{MaxParts: 3, MaxPartSize: 100, MaxSize: 700}
+	bodyValidator := NewBodyValidator(writeErrorsToResponseWriter, MultipartLimitsOpt(limits))
+	router, err := NewRouter(sw, handlers, MiddlewareOpt(bodyValidator.Apply))
+	if err != nil {
+		t.Fatal(err)
+	}
+
+	cases := []struct {
+		tags            int
+		tag             string
+		file            string
+		expectedStatus  int
+		expectedPayload string
+	}{
+		// within the limits
+		{
+			tags:            1,
+			file:            strings.Repeat("x", 100),
+			expectedStatus:  http.StatusOK,
+			expectedPayload: "ok",
+		},
+		// too many parts
+		{
+			tags:            3,
+			file:            "content",
+			expectedStatus:  http.StatusBadRequest,
+			expectedPayload: `{"errors":[{"message":"Body contains too many parts, at most 3 are allowed"}]}`,
+		},
+		// too large part
+		{
+			tags:            1,
+			file:            strings.Repeat("x", 101),
+			expectedStatus:  http.StatusRequestEntityTooLarge,
+			expectedPayload: `{"errors":[{"message":"Body part file is too large, at most 100 bytes are allowed"}]}`,
+		},
+		// too large body
+		{
+			tags:            2,
+			tag:             strings.Repeat("x", 100),
+			file:            strings.Repeat("x", 100),
+			expectedStatus:  http.StatusRequestEntityTooLarge,
+			expectedPayload: `{"errors":[{"message":"Body is too large, at most 700 bytes are allowed"}]}`,
+		},
+	}
+
+	for _, c := range cases {
+		body := &bytes.Buffer{}
+		mw := multipart.NewWriter(body)
+		tag := c.tag
+		if tag == "" {
+			tag = "cat"
+		}
+		for i := 0; i < c.tags; i++ {
+			mw.WriteField("tags", tag)
+		}
+		fw, err := mw.CreateFormFile("file", "file.txt")
+		if err != nil {
+			t.Fatal(err)
+		}
+		fmt.Fprint(fw, c.file)
+		mw.Close()
+
+		req := httptest.NewRequest(http.MethodPost, "/v1/uploads", body)
+		req.Header.Set("Content-Type", mw.FormDataContentType())
+
+		w := httptest.NewRecorder()
+		router.ServeHTTP(w, req)
+
+		if w.Code != c.expectedStatus {
+			t.Errorf("Expected status to be %d but got %d", c.expectedStatus, w.Code)
+		}
+		if w.Body.String() != c.expectedPayload {
+			t.Errorf("Expected response body to be\n%s\nbut got\n%s", c.expectedPayload, w.Body.String())
+		}
+	}
+}
+
+func TestMultipartLimitsOpt_form(t *testing.T) {
+	sw := parseSpec(`
+swagger: "2.0"
+info:
+  title: test
+  version: "1.0"
+basePath: /v1
+paths:
+  /uploads:
+    post:
+      operationId: upload
+      consumes:
+      - multipart/form-data
+      parameters:
+      - name: tag
+        in: formData
+        type: string
+      - name: file
+        in: formData
+        type: file
+      responses:
+        200:
+          description: ok
+`)
+
+	// The body is not kept, so handlers get the parsed form.
+	handlers := OperationHandlers{"upload": http.HandlerFunc(func(w http.ResponseWriter, req *http.Request) {
+		f, _, err := req.FormFile("file")
+		if err != nil {
+			t.Fatal(err)
+		}
+		defer f.Close()
+		content, _ := ioutil.ReadAll(f)
+		fmt.Fprintf(w, "%s %s", req.FormValue("tag"), content)
+	})}
+
+	limits := MultipartLimits{MaxParts: 2, MaxPartSize: 10, MaxSize: 1000}
+	bodyValidator := NewBodyValidator(writeErrorsToResponseWriter, MultipartLimitsOpt(limits))
+	router, err := NewRouter(sw, handlers, MiddlewareOpt(bodyValidator.Apply))
+	if err != nil {
+		t.Fatal(err)
+	}
+
+	cases := []struct {
+		file            string
+		expectedPayload string
+	}{
+		// the content may contain a partial delimiter
+		{
+			file:            "\r\n--x",
+			expectedPayload: "cat \r\n--x",
+		},
+		{
+			file:            "\r\n--x\r\n--xy",
+			expectedPayload: `{"errors":[{"message":"Body part file is too large, at most 10 bytes are allowed"}]}`,
+		},
+	}
+
+	for _, c := range cases {
+		body := &bytes.Buffer{}
+		mw := multipart.NewWriter(body)
+		mw.WriteField("tag", "cat")
+		fw, err := mw.CreateFormFile("file", "file.txt")
+		if err != nil {
+			t.Fatal(err)
+		}
+		fmt.Fprint(fw, c.file)
+		mw.Close()
+
+		// Read byte by byte, so delimiters are split between reads.
+		req := httptest.NewRequest(http.MethodPost, "/v1/uploads", iotest.OneByteReader(body))
+		req.Header.Set("Content-Type", mw.FormDataContentType())
+
+		w := httptest.NewRecorder()
+		router.ServeHTTP(w, req)
+
+		if w.Body.String() != c.expectedPayload {
+			t.Errorf("Expected response body to be\n%q\nbut got\n%q", c.expectedPayload, w.Body.String())
+		}
+	}
+}
+
+func TestMultipartLimitsOpt_streaming(t *testing.T) {
+	sw := parseSpec(`
+swagger: "2.0"
+info:
+  title: test
+  version: "1.0"
+basePath: /v1
+paths:
+  /uploads:
+    post:
+      operationId: upload
+      consumes:
+      - multipart/form-data
+      parameters:
+      - name: tags
+        in: formData
+        type: array
+        collectionFormat: multi
+        items:
+          type: string
+      responses:
+        200:
+          description: ok
+`)
+
+	handlers := OperationHandlers{"upload": http.HandlerFunc(func(w http.ResponseWriter, req *http.Request) {
+		fmt.Fprint(w, "ok")
+	})}
+
+	bodyValidator := NewBodyValidator(writeErrorsToResponseWriter, MultipartLimitsOpt(MultipartLimits{MaxParts: 3}))
+	router, err := NewRouter(sw, handlers, MiddlewareOpt(bodyValidator.Apply))
+	if err != nil {
+		t.Fatal(err)
+	}
+
+	// Many tiny parts, without the size limit.
+	part := "--b\r\nContent-Disposition: form-data; name=\"tags\"\r\n\r\ncat\r\n"
+	content := strings.Repeat(part, 100000) + "--b--\r\n"
+	body := &countingReadCloser{ReadCloser: ioutil.NopCloser(strings.NewReader(content))}
+
+	req := httptest.NewRequest(http.MethodPost, "/v1/uploads", body)
+	req.Header.Set("Content-Type", "multipart/form-data; boundary=b")
+
+	w := httptest.NewRecorder()
+	router.ServeHTTP(w, req)
+
+	if w.Code != http.StatusBadRequest {
+		t.Errorf("Expected status to be %d but got %d", http.StatusBadRequest, w.Code)
+	}
+	// Reading stops soon after the limit is exceeded.
+	if max := int64(len(content) / 10); body.n > max {
+		t.Errorf("Expected at most %d bytes to be read but got %d", max, body.n)
+	}
+}
+
+func TestPathParameterExtractor_Apply(t *testing.T) {
+	cases := []struct {
+		url                string
+		expectedStatusCode int
+		expectedPayload    string
+	}{
+		// ok
+		{
+			url:                "/pet/12",
+			expectedStatusCode: http.StatusOK,
+			expectedPayload:    "pet by id: 12",
+		},
+		// request an url which handler does not provide operation context
+		{
+			url:                "/no_operation_resource",
+			expectedStatusCode: http.StatusOK,
+			expectedPayload:    "hit no operation resource",
+		},
+	}
+
+	// set up
+
+	doc := loadDoc()
+
+	handlers := OperationHandlers{"getPetById": http.HandlerFunc(func(w http.ResponseWriter, req *http.Request) {
+		id, ok := GetPathParam(req, "petId").(int64)
+		if !ok {
+			w.WriteHeader(http.StatusNotFound)
+			return
+		}
+
+		fmt.Fprintf(w, "pet by id: %d", id)
+	})}
+	noOpHandler := http.HandlerFunc(func(w http.ResponseWriter, req *http.Request) {
+		fmt.Fprint(w, "hit no operation resource")
+	})
+
+	pathParamExtractor := NewPathParameterExtractor(chi.URLParam)
+	opts := []RouterOption{MiddlewareOpt(pathParamExtractor.Apply)}
+
+	operationsRouter, err := NewRouter(doc.Spec(), handlers, opts...)
+	if err != nil {
+		t.Fatal(err)
+	}
+
+	finalRouter := chi.NewRouter()
+	finalRouter.Mount("/", operationsRouter)
+	finalRouter.Handle("/no_operation_resource", pathParamExtractor.Apply(noOpHandler))
+
+	server := httptest.NewServer(finalRouter)
+	client := server.Client()
+
+	// test
+
+	for _, c := range cases {
+		resp, err := client.Get(server.URL + c.url)
+		if err != nil {
+			t.Fatal(err)
+		}
+
+		respBody, err := ioutil.ReadAll(resp.Body)
+		if err != nil {
+			t.Fatal(err)
+		}
+
+		if !bytes.Equal([]byte(c.expectedPayload), respBody) {
+			t.Errorf("Expected response body to be\n%s\nbut got\n%s", c.expectedPayload, string(respBody))
+		}
+	}
+
+	// tear down
+
+	server.Close()
+}
+
+func TestParamHeadersOpt(t *testing.T) {
+	sw := parseSpec(`
+swagger: "2.0"
+info:
+  title: test
+  version: "1.0"
+basePath: /v1
+paths:
+  /items/{id}:
+    get:
+      operationId: getItem
+      parameters:
+      - name: id
+        in: path
+        type: integer
+        required: true
+      - name: fields
+        in: query
+        type: array
+        items:
+          type: string
+      - name: precision
+        in: query
+        type: number
+      - name: verbose
+        in: query
+        type: boolean
+      - name: since
+        in: query
+        type: string
+        format: date
+      - name: token
+        in: query
+        type: string
+        format: byte
+      responses:
+        200:
+          description: ok
+`)
+
+	var headers http.Header
+	handlers := OperationHandlers{"getItem": http.HandlerFunc(func(w http.ResponseWriter, req *http.Request) {
+		headers = req.Header
+	})}
+
+	options := []MiddlewareOption{ParamHeadersOpt(ParamHeaderPrefix("X-Param-"))}
+	pathParamExtractor := NewPathParameterExtractor(chi.URLParam, options...)
+	queryValidator := NewQueryValidator(writeErrorsToResponseWriter, options...)
+
+	router, err := NewRouter(sw, handlers, MiddlewareOpt(queryValidator.Apply), MiddlewareOpt(pathParamExtractor.Apply))
+	if err != nil {
+		t.Fatal(err)
+	}
+
+	req := httptest.NewRequest(http.MethodGet, "/v1/items/007?fields=name,price&precision=0.50&since=2020-01-02&token=aGk=", nil)
+	// The client cannot pass a value of a parameter it does not send.
+	req.Header.Set("X-Param-Verbose", "true")
+	router.ServeHTTP(httptest.NewRecorder(), req)
+
+	expectedHeaders := map[string]string{
+		"X-Param-Id":        "7",
+		"X-Param-Fields":    "name,price",
+		"X-Param-Precision": "0.5",
+		"X-Param-Verbose":   "",
+		"X-Param-Since":     "2020-01-02",
+		"X-Param-Token":     "aGk=",
+	}
+	for name, expected := range expectedHeaders {
+		if actual := headers.Get(name); actual != expected {
+			t.Errorf("Expected header %s to be %q but got %q", name, expected, actual)
+		}
+	}
+}
+
+func TestResponseBodyValidator_Apply(t *testing.T) {
+	cases := []struct {
+		url                string
+		logBuffer          *bytes.Buffer
 		expectedStatusCode int
 		expectedPayload    string
+		expectedLogBuffer  string
 	}{
-		// ok
+		// with validation errors
 		{
-			url:                "/pet",
-			body:               bytes.NewBufferString(`{"name":"johndoe", "age":7}`),
+			url:                "/pet/12",
+			logBuffer:          &bytes.Buffer{},
 			expectedStatusCode: http.StatusOK,
-			expectedPayload:    "pet name: johndoe",
-		},
-		// required field "name" is missing
-		{
-			url:                "/pet",
-			body:               bytes.NewBufferString(`{"age":7}`),
-			expectedStatusCode: http.StatusBadRequest,
-			expectedPayload:    `{"errors":[{"message":"name in body is required","field":"name"}]}`,
+			expectedPayload:    `{"id":123,"name":"Kitty"}` + "\n",
+			expectedLogBuffer:  "response data does not match the schema: field=age value=<nil> message=age in body is required",
 		},
-		// value for field "age" is incorrect
+		// no spec for 500
 		{
-			url:                "/pet",
-			body:               bytes.NewBufferString(`{"name":"johndoe","age":"abc"}`),
-			expectedStatusCode: http.StatusBadRequest,
-			expectedPayload:    `{"errors":[{"message":"age in body must be of type integer: \"string\"","field":"age"}]}`,
+			url:                "/pet/500",
+			logBuffer:          &bytes.Buffer{},
+			expectedStatusCode: http.StatusInternalServerError,
+			expectedPayload:    "Internal Server Error\n",
 		},
-		// no body
+		// no schema for 404
 		{
-			url:                "/pet",
-			body:               &bytes.Buffer{},
-			expectedStatusCode: http.StatusBadRequest,
+			url:                "/pet/13",
+			logBuffer:          &bytes.Buffer{},
+			expectedStatusCode: http.StatusNotFound,
+			expectedPayload:    "404 page not found\n",
 		},
-		// invalid json body
 		{
-			url:                "/pet",
-			body:               bytes.NewBufferString(`{"name":"johndoe`),
-			expectedStatusCode: http.StatusBadRequest,
-			expectedPayload:    `{"errors":[{"message":"Body contains invalid json"}]}`,
+			url:                "/pet/badjson",
+			logBuffer:          &bytes.Buffer{},
+			expectedStatusCode: http.StatusOK,
+			expectedPayload:    `{"name":`,
 		},
 		// request an url which handler does not provide operation context
 		{
 			url:                "/no_operation_resource",
-			body:               bytes.NewBufferString(`{"name":"johndoe`),
+			logBuffer:          &bytes.Buffer{},
 			expectedStatusCode: http.StatusOK,
 			expectedPayload:    "hit no operation resource",
+			expectedLogBuffer:  "",
 		},
 	}
 
@@ -141,267 +2253,824 @@ func TestBodyValidatorMiddleware_Apply(t *testing.T) {
 
 	doc := loadDoc()
 
-	handlers := OperationHandlers{"addPet": http.HandlerFunc(func(w http.ResponseWriter, req *http.Request) {
-		type pet struct {
-			Name      string   `json:"name"`
-			PhotoURLs []string `json:"photoUrls"`
+	handlers := OperationHandlers{"getPetById": http.HandlerFunc(func(w http.ResponseWriter, req *http.Request) {
+		// fake not found
+		if req.URL.Path == "/pet/13" {
+			http.NotFound(w, req)
+			return
 		}
 
-		var p pet
-		if err := json.NewDecoder(req.Body).Decode(&p); err != nil {
-			w.WriteHeader(http.StatusBadRequest)
+		// fake for server error {
+		if req.URL.Path == "/pet/500" {
+			http.Error(w, http.StatusText(http.StatusInternalServerError), http.StatusInternalServerError)
 			return
 		}
 
-		fmt.Fprintf(w, "pet name: %s", p.Name)
+		// fake for bad json
+		if req.URL.Path == "/pet/badjson" {
+			w.Write([]byte(`{"name":`))
+			return
+		}
+
+		// normal
+
+		type pet struct {
+			ID   int64  `json:"id"`
+			Name string `json:"name"`
+		}
+
+		p := pet{123, "Kitty"}
+
+		if err := json.NewEncoder(w).Encode(p); err != nil {
+			t.Fatalf("Unexpected error: %s", err)
+		}
 	})}
 	noOpHandler := http.HandlerFunc(func(w http.ResponseWriter, req *http.Request) {
 		fmt.Fprint(w, "hit no operation resource")
 	})
 
-	bodyValidator := NewBodyValidator(writeErrorsToResponseWriter)
-	opts := []RouterOption{MiddlewareOpt(bodyValidator.Apply)}
+	// test
 
-	operationsRouter, err := NewRouter(doc.Spec(), handlers, opts...)
+	for _, c := range cases {
+		respBodyValidator := NewResponseBodyValidator(errorLogger(c.logBuffer))
+		opts := []RouterOption{MiddlewareOpt(respBodyValidator.Apply)}
+
+		operationsRouter, err := NewRouter(doc.Spec(), handlers, opts...)
+		if err != nil {
+			t.Fatal(err)
+		}
+
+		finalRouter := chi.NewRouter()
+		finalRouter.Mount("/", operationsRouter)
+		finalRouter.Handle("/no_operation_resource", respBodyValidator.Apply(noOpHandler))
+
+		server := httptest.NewServer(finalRouter)
+		client := server.Client()
+
+		resp, err := client.Get(server.URL + c.url)
+		if err != nil {
+			t.Fatal(err)
+		}
+
+		respBody, err := ioutil.ReadAll(resp.Body)
+		if err != nil {
+			t.Fatal(err)
+		}
+
+		if c.expectedStatusCode != resp.StatusCode {
+			t.Errorf("Expected status code to be %v but got %v", c.expectedStatusCode, resp.StatusCode)
+		}
+
+		if !bytes.Equal([]byte(c.expectedPayload), respBody) {
+			t.Errorf("Expected response body to be\n%s\nbut got\n%s", c.expectedPayload, string(respBody))
+		}
+
+		expectedLogBuff := strings.TrimSpace(c.expectedLogBuffer)
+		actualLogBuff := strings.TrimSpace(c.logBuffer.String())
+		if expectedLogBuff != actualLogBuff {
+			t.Errorf("Expected log buffer to be\n%v\nbut got\n%v\n", expectedLogBuff, actualLogBuff)
+		}
+
+		// tear down
+		server.Close()
+	}
+}
+
+func TestResponseBodyValidator_Apply_bufferLimit(t *testing.T) {
+	doc := loadDoc()
+
+	handlers := OperationHandlers{"getPetById": http.HandlerFunc(func(w http.ResponseWriter, req *http.Request) {
+		// Valid JSON that does not match the schema, so it would be
+		// reported if validated.
+		fmt.Fprintf(w, `{"id":123,"name":"%s"}`, strings.Repeat("x", 64))
+	})}
+
+	logBuffer := &bytes.Buffer{}
+	var mismatches []string
+
+	respBodyValidator := NewResponseBodyValidator(
+		errorLogger(logBuffer),
+		ResponseBufferLimitOpt(32),
+		SpecMismatchOpt(func(req *http.Request, err error) {
+			mismatches = append(mismatches, err.Error())
+		}),
+	)
+
+	router, err := NewRouter(doc.Spec(), handlers, MiddlewareOpt(respBodyValidator.Apply))
 	if err != nil {
 		t.Fatal(err)
 	}
 
-	finalRouter := chi.NewRouter()
-	finalRouter.Mount("/", operationsRouter)
-	finalRouter.Handle("/no_operation_resource", bodyValidator.Apply(noOpHandler))
+	server := httptest.NewServer(router)
+	defer server.Close()
 
-	server := httptest.NewServer(finalRouter)
-	client := server.Client()
+	resp, err := server.Client().Get(server.URL + "/v2/pet/12")
+	if err != nil {
+		t.Fatal(err)
+	}
+
+	respBody, err := ioutil.ReadAll(resp.Body)
+	if err != nil {
+		t.Fatal(err)
+	}
+
+	if len(respBody) != 84 {
+		t.Errorf("Expected the whole response body to be passed through but got %d bytes", len(respBody))
+	}
+
+	if logBuffer.Len() != 0 {
+		t.Errorf("Expected no validation errors but got\n%s", logBuffer.String())
+	}
+
+	expectedMismatches := []string{"response body exceeds 32 bytes, validation skipped"}
+	if !reflect.DeepEqual(expectedMismatches, mismatches) {
+		t.Errorf("Expected spec mismatches to be %v but got %v", expectedMismatches, mismatches)
+	}
+}
+
+func TestResponseBodyValidator_Apply_statusRanges(t *testing.T) {
+	sw := parseSpec(`
+swagger: "2.0"
+info:
+  title: test
+  version: "1.0"
+basePath: /v1
+paths:
+  /item:
+    get:
+      operationId: getItem
+      parameters:
+      - name: status
+        in: query
+        type: integer
+      responses:
+        200:
+          description: ok
+          schema:
+            type: object
+            required: [name]
+        400:
+          description: bad request
+          schema:
+            type: object
+            required: [code]
+`)
+
+	handlers := OperationHandlers{"getItem": http.HandlerFunc(func(w http.ResponseWriter, req *http.Request) {
+		var status int
+		fmt.Sscan(req.URL.Query().Get("status"), &status)
+		w.WriteHeader(status)
+		// Matches none of the response schemas.
+		fmt.Fprint(w, `{}`)
+	})}
+
+	cases := []struct {
+		ranges          []StatusRange
+		status          int
+		expectValidated bool
+	}{
+		// all statuses are validated by default
+		{
+			status:          http.StatusOK,
+			expectValidated: true,
+		},
+		{
+			status:          http.StatusBadRequest,
+			expectValidated: true,
+		},
+		// status is in the range
+		{
+			ranges:          []StatusRange{{200, 299}},
+			status:          http.StatusOK,
+			expectValidated: true,
+		},
+		// status is not in the range
+		{
+			ranges:          []StatusRange{{200, 299}},
+			status:          http.StatusBadRequest,
+			expectValidated: false,
+		},
+		// status is in one of the ranges
+		{
+			ranges:          []StatusRange{{200, 299}, {400, 400}},
+			status:          http.StatusBadRequest,
+			expectValidated: true,
+		},
+	}
+
+	for _, c := range cases {
+		logBuffer := &bytes.Buffer{}
+		respBodyValidator := NewResponseBodyValidator(errorLogger(logBuffer), ValidateStatusOpt(c.ranges...))
+
+		router, err := NewRouter(sw, handlers, MiddlewareOpt(respBodyValidator.Apply))
+		if err != nil {
+			t.Fatal(err)
+		}
+
+		server := httptest.NewServer(router)
+
+		resp, err := server.Client().Get(fmt.Sprintf("%s/v1/item?status=%d", server.URL, c.status))
+		if err != nil {
+			t.Fatal(err)
+		}
+		resp.Body.Close()
+
+		if validated := logBuffer.Len() > 0; validated != c.expectValidated {
+			t.Errorf("Expected response with status %d validated to be %v but got %v", c.status, c.expectValidated, validated)
+		}
+
+		server.Close()
+	}
+}
+
+func TestResponseBodyValidator_Apply_ndjson(t *testing.T) {
+	sw := parseSpec(`
+swagger: "2.0"
+info:
+  title: test
+  version: "1.0"
+basePath: /v1
+paths:
+  /events:
+    get:
+      operationId: streamEvents
+      produces:
+      - application/x-ndjson
+      responses:
+        200:
+          description: ok
+          schema:
+            type: array
+            items:
+              type: object
+              required: [id]
+              properties:
+                id:
+                  type: integer
+`)
+
+	const records = 1000
+
+	handlers := OperationHandlers{"streamEvents": http.HandlerFunc(func(w http.ResponseWriter, req *http.Request) {
+		w.Header().Set("Content-Type", "application/x-ndjson")
+		for i := 1; i <= records; i++ {
+			if i == 500 || i == 700 {
+				fmt.Fprintf(w, "{\"uuid\":%d}\n", i)
+				continue
+			}
+			// Split records between writes.
+			fmt.Fprintf(w, "{\"id\":")
+			fmt.Fprintf(w, "%d}\n", i)
+		}
+	})}
+
+	logBuffer := &bytes.Buffer{}
+	var mismatches []string
+
+	respBodyValidator := NewResponseBodyValidator(
+		errorLogger(logBuffer),
+		ResponseBufferLimitOpt(64),
+		SpecMismatchOpt(func(req *http.Request, err error) {
+			mismatches = append(mismatches, err.Error())
+		}),
+	)
+
+	router, err := NewRouter(sw, handlers, MiddlewareOpt(respBodyValidator.Apply))
+	if err != nil {
+		t.Fatal(err)
+	}
+
+	w := httptest.NewRecorder()
+	router.ServeHTTP(w, httptest.NewRequest(http.MethodGet, "/v1/events", nil))
+
+	if lines := strings.Count(w.Body.String(), "\n"); lines != records {
+		t.Errorf("Expected all %d records to be passed through but got %d", records, lines)
+	}
+
+	expectedMismatches := []string{"ndjson record 500 does not match the schema: id in body is required"}
+	if !reflect.DeepEqual(expectedMismatches, mismatches) {
+		t.Errorf("Expected spec mismatches to be %v but got %v", expectedMismatches, mismatches)
+	}
+
+	if logBuffer.Len() != 0 {
+		t.Errorf("Expected no validation errors but got\n%s", logBuffer.String())
+	}
+}
+
+func TestResponseTransformerOpt(t *testing.T) {
+	doc := loadDoc()
+
+	handlers := OperationHandlers{"getPetById": http.HandlerFunc(func(w http.ResponseWriter, req *http.Request) {
+		w.Header().Set("Content-Type", "application/json")
+		w.WriteHeader(http.StatusOK)
+		fmt.Fprint(w, `{"id":12,"name":"Kitty","age":3,"internal":"secret"}`)
+	})}
+
+	strip := func(field string) ResponseTransformer {
+		return func(req *http.Request, body interface{}) (interface{}, error) {
+			if m, ok := body.(map[string]interface{}); ok {
+				delete(m, field)
+			}
+			return body, nil
+		}
+	}
+
+	logBuffer := &bytes.Buffer{}
+	respBodyValidator := NewResponseBodyValidator(
+		errorLogger(logBuffer),
+		ResponseTransformerOpt(strip("internal")),
+		ResponseTransformerOpt(strip("age")),
+	)
+
+	router, err := NewRouter(doc.Spec(), handlers, MiddlewareOpt(respBodyValidator.Apply))
+	if err != nil {
+		t.Fatal(err)
+	}
+
+	w := httptest.NewRecorder()
+	router.ServeHTTP(w, httptest.NewRequest(http.MethodGet, "/v2/pet/12", nil))
+
+	if w.Code != http.StatusOK {
+		t.Errorf("Expected status code to be %d but got %d", http.StatusOK, w.Code)
+	}
+
+	expectedPayload := `{"id":12,"name":"Kitty"}`
+	if w.Body.String() != expectedPayload {
+		t.Errorf("Expected response body to be\n%s\nbut got\n%s", expectedPayload, w.Body.String())
+	}
+
+	// The transformed body is validated.
+	expectedLogBuffer := "response data does not match the schema: field=age value=<nil> message=age in body is required"
+	if actual := strings.TrimSpace(logBuffer.String()); actual != expectedLogBuffer {
+		t.Errorf("Expected log buffer to be\n%v\nbut got\n%v", expectedLogBuffer, actual)
+	}
+}
+
+func TestDefaultResponseOpt(t *testing.T) {
+	sw := parseSpec(`
+swagger: "2.0"
+info:
+  title: test
+  version: "1.0"
+basePath: /v1
+paths:
+  /item:
+    get:
+      operationId: getItem
+      responses:
+        200:
+          description: ok
+        default:
+          description: error
+          schema:
+            type: object
+            required: [code, message]
+            properties:
+              code:
+                type: integer
+              message:
+                type: string
+`)
+
+	cases := []struct {
+		enabled            bool
+		payload            string
+		expectedLogBuffer  string
+		expectedMismatches []string
+	}{
+		// default response is not used
+		{
+			payload:            `{"error":"oops"}`,
+			expectedMismatches: []string{"no response spec for status 500"},
+		},
+		// well-formed error envelope
+		{
+			enabled: true,
+			payload: `{"code":500,"message":"oops"}`,
+		},
+		// malformed error envelope
+		{
+			enabled:           true,
+			payload:           `{"error":"oops"}`,
+			expectedLogBuffer: "response data does not match the schema: field=code value=<nil> message=code in body is required\nresponse data does not match the schema: field=message value=<nil> message=message in body is required",
+		},
+	}
+
+	for _, c := range cases {
+		handlers := OperationHandlers{"getItem": http.HandlerFunc(func(w http.ResponseWriter, req *http.Request) {
+			w.WriteHeader(http.StatusInternalServerError)
+			fmt.Fprint(w, c.payload)
+		})}
+
+		logBuffer := &bytes.Buffer{}
+		var mismatches []string
+
+		respBodyValidator := NewResponseBodyValidator(
+			errorLogger(logBuffer),
+			DefaultResponseOpt(c.enabled),
+			SpecMismatchOpt(func(req *http.Request, err error) {
+				mismatches = append(mismatches, err.Error())
+			}),
+		)
+
+		router, err := NewRouter(sw, handlers, MiddlewareOpt(respBodyValidator.Apply))
+		if err != nil {
+			t.Fatal(err)
+		}
+
+		w := httptest.NewRecorder()
+		router.ServeHTTP(w, httptest.NewRequest(http.MethodGet, "/v1/item", nil))
+
+		if actual := strings.TrimSpace(logBuffer.String()); actual != c.expectedLogBuffer {
+			t.Errorf("Expected log buffer to be\n%v\nbut got\n%v", c.expectedLogBuffer, actual)
+		}
+
+		if !reflect.DeepEqual(c.expectedMismatches, mismatches) {
+			t.Errorf("Expected spec mismatches to be %v but got %v", c.expectedMismatches, mismatches)
+		}
+	}
+}
 
-	// test
+func TestSuccessFallbackOpt(t *testing.T) {
+	cases := []struct {
+		spec               string
+		enabled            bool
+		payload            string
+		expectedLogBuffer  string
+		expectedMismatches []string
+	}{
+		// undocumented success status is reported
+		{
+			spec:               "200",
+			payload:            `{"id":1}`,
+			expectedMismatches: []string{"no response spec for status 201"},
+		},
+		// validated against 200
+		{
+			spec:    "200",
+			enabled: true,
+			payload: `{"id":1}`,
+		},
+		{
+			spec:              "200",
+			enabled:           true,
+			payload:           `{"id":"1"}`,
+			expectedLogBuffer: "response data does not match the schema: field=id value=<nil> message=id in body must be of type integer: \"string\"",
+		},
+		// documented status is used as is
+		{
+			spec:    "201",
+			enabled: true,
+			payload: `{"id":"1"}`,
+		},
+	}
 
 	for _, c := range cases {
-		resp, err := client.Post(server.URL+c.url, "application/json", c.body)
+		sw := parseSpec(`
+swagger: "2.0"
+info:
+  title: test
+  version: "1.0"
+basePath: /v1
+paths:
+  /item:
+    post:
+      operationId: addItem
+      responses:
+        204:
+          description: no content
+        ` + c.spec + `:
+          description: ok
+          schema:
+            type: object
+            properties:
+              id:
+                type: ` + map[string]string{"200": "integer", "201": "string"}[c.spec] + `
+`)
+
+		handlers := OperationHandlers{"addItem": http.HandlerFunc(func(w http.ResponseWriter, req *http.Request) {
+			w.WriteHeader(http.StatusCreated)
+			fmt.Fprint(w, c.payload)
+		})}
+
+		logBuffer := &bytes.Buffer{}
+		var mismatches []string
+
+		respBodyValidator := NewResponseBodyValidator(
+			errorLogger(logBuffer),
+			SuccessFallbackOpt(c.enabled),
+			SpecMismatchOpt(func(req *http.Request, err error) {
+				mismatches = append(mismatches, err.Error())
+			}),
+		)
+
+		router, err := NewRouter(sw, handlers, MiddlewareOpt(respBodyValidator.Apply))
 		if err != nil {
 			t.Fatal(err)
 		}
 
-		respBody, err := ioutil.ReadAll(resp.Body)
-		if err != nil {
-			t.Fatal(err)
+		w := httptest.NewRecorder()
+		router.ServeHTTP(w, httptest.NewRequest(http.MethodPost, "/v1/item", nil))
+
+		if actual := strings.TrimSpace(logBuffer.String()); actual != c.expectedLogBuffer {
+			t.Errorf("Expected log buffer to be\n%v\nbut got\n%v", c.expectedLogBuffer, actual)
 		}
 
-		if !bytes.Equal([]byte(c.expectedPayload), respBody) {
-			t.Fatalf("Expected response body to be\n%s\nbut got\n%s", c.expectedPayload, string(respBody))
+		if !reflect.DeepEqual(c.expectedMismatches, mismatches) {
+			t.Errorf("Expected spec mismatches to be %v but got %v", c.expectedMismatches, mismatches)
 		}
 	}
-
-	// tear down
-
-	server.Close()
 }
 
-func TestPathParameterExtractor_Apply(t *testing.T) {
+func TestResponseValidatorOpt(t *testing.T) {
+	sw := parseSpec(`
+swagger: "2.0"
+info:
+  title: test
+  version: "1.0"
+basePath: /v1
+paths:
+  /pets:
+    get:
+      operationId: listPets
+      produces:
+      - application/json
+      - text/csv
+      - application/xml
+      responses:
+        200:
+          description: ok
+          schema:
+            type: array
+            items:
+              type: object
+              properties:
+                id:
+                  type: integer
+                name:
+                  type: string
+`)
+
 	cases := []struct {
-		url                string
-		expectedStatusCode int
-		expectedPayload    string
+		contentType        string
+		payload            string
+		expectedLogBuffer  string
+		expectedMismatches []string
 	}{
-		// ok
+		// json is validated against the schema
 		{
-			url:                "/pet/12",
-			expectedStatusCode: http.StatusOK,
-			expectedPayload:    "pet by id: 12",
+			contentType: "application/json",
+			payload:     `[{"id":1,"name":"Kitty"}]`,
 		},
-		// request an url which handler does not provide operation context
 		{
-			url:                "/no_operation_resource",
-			expectedStatusCode: http.StatusOK,
-			expectedPayload:    "hit no operation resource",
+			contentType:       "application/json",
+			payload:           `[{"id":"1","name":"Kitty"}]`,
+			expectedLogBuffer: "response data does not match the schema: field=id value=<nil> message=id in body must be of type integer: \"string\"",
+		},
+		// csv is validated by the registered validator
+		{
+			contentType: "text/csv; charset=utf-8",
+			payload:     "1,Kitty\n2,Doggy\n",
+		},
+		{
+			contentType:       "text/csv; charset=utf-8",
+			payload:           "1,Kitty\n2\n",
+			expectedLogBuffer: "response data does not match the schema: field=1 value=<nil> message=row 1 has 1 columns, want 2",
+		},
+		// xml is only checked to be well-formed
+		{
+			contentType: "application/xml",
+			payload:     `<pets><pet id="1">Kitty</pet></pets>`,
+		},
+		{
+			contentType:        "application/xml",
+			payload:            `<pets><pet></pets>`,
+			expectedMismatches: []string{"response body contains invalid xml: XML syntax error on line 1: element <pet> closed by </pets>"},
 		},
 	}
 
-	// set up
+	// csvValidator checks that rows have a column for each property.
+	csvValidator := func(sch *spec.Schema, payload []byte) []error {
+		want := len(sch.Items.Schema.Properties)
+		var errs []error
+		for i, row := range strings.Split(strings.TrimSpace(string(payload)), "\n") {
+			if n := len(strings.Split(row, ",")); n != want {
+				errs = append(errs, ValidationErrorf(strconv.Itoa(i), nil, "row %d has %d columns, want %d", i, n, want))
+			}
+		}
+		return errs
+	}
 
-	doc := loadDoc()
+	for _, c := range cases {
+		handlers := OperationHandlers{"listPets": http.HandlerFunc(func(w http.ResponseWriter, req *http.Request) {
+			w.Header().Set("Content-Type", c.contentType)
+			fmt.Fprint(w, c.payload)
+		})}
+
+		logBuffer := &bytes.Buffer{}
+		var mismatches []string
+
+		respBodyValidator := NewResponseBodyValidator(
+			errorLogger(logBuffer),
+			ResponseValidatorOpt("text/csv", csvValidator),
+			SpecMismatchOpt(func(req *http.Request, err error) {
+				mismatches = append(mismatches, err.Error())
+			}),
+		)
+
+		router, err := NewRouter(sw, handlers, MiddlewareOpt(respBodyValidator.Apply))
+		if err != nil {
+			t.Fatal(err)
+		}
 
-	handlers := OperationHandlers{"getPetById": http.HandlerFunc(func(w http.ResponseWriter, req *http.Request) {
-		id, ok := GetPathParam(req, "petId").(int64)
-		if !ok {
-			w.WriteHeader(http.StatusNotFound)
-			return
+		w := httptest.NewRecorder()
+		router.ServeHTTP(w, httptest.NewRequest(http.MethodGet, "/v1/pets", nil))
+
+		if actual := strings.TrimSpace(logBuffer.String()); actual != c.expectedLogBuffer {
+			t.Errorf("Expected log buffer for %s to be\n%v\nbut got\n%v", c.contentType, c.expectedLogBuffer, actual)
 		}
 
-		fmt.Fprintf(w, "pet by id: %d", id)
+		if !reflect.DeepEqual(c.expectedMismatches, mismatches) {
+			t.Errorf("Expected spec mismatches for %s to be %v but got %v", c.contentType, c.expectedMismatches, mismatches)
+		}
+	}
+}
+
+func TestMarkResponseValidated(t *testing.T) {
+	doc := loadDoc()
+
+	handlers := OperationHandlers{"getPetById": http.HandlerFunc(func(w http.ResponseWriter, req *http.Request) {
+		t.Fatal("Expected the request to be rejected before the handler")
 	})}
-	noOpHandler := http.HandlerFunc(func(w http.ResponseWriter, req *http.Request) {
-		fmt.Fprint(w, "hit no operation resource")
-	})
 
-	pathParamExtractor := NewPathParameterExtractor(chi.URLParam)
-	opts := []RouterOption{MiddlewareOpt(pathParamExtractor.Apply)}
+	auth := func(next http.Handler) http.Handler {
+		return http.HandlerFunc(func(w http.ResponseWriter, req *http.Request) {
+			MarkResponseValidated(req)
+			w.WriteHeader(http.StatusUnauthorized)
+			fmt.Fprint(w, `{"error":"unauthorized"}`)
+		})
+	}
 
-	operationsRouter, err := NewRouter(doc.Spec(), handlers, opts...)
+	logBuffer := &bytes.Buffer{}
+	var mismatches []string
+
+	respBodyValidator := NewResponseBodyValidator(
+		errorLogger(logBuffer),
+		SpecMismatchOpt(func(req *http.Request, err error) {
+			mismatches = append(mismatches, err.Error())
+		}),
+	)
+
+	router, err := NewRouter(doc.Spec(), handlers, MiddlewareOpt(auth), MiddlewareOpt(respBodyValidator.Apply))
 	if err != nil {
 		t.Fatal(err)
 	}
 
-	finalRouter := chi.NewRouter()
-	finalRouter.Mount("/", operationsRouter)
-	finalRouter.Handle("/no_operation_resource", pathParamExtractor.Apply(noOpHandler))
+	server := httptest.NewServer(router)
+	defer server.Close()
 
-	server := httptest.NewServer(finalRouter)
-	client := server.Client()
+	resp, err := server.Client().Get(server.URL + "/v2/pet/12")
+	if err != nil {
+		t.Fatal(err)
+	}
+	resp.Body.Close()
 
-	// test
+	if resp.StatusCode != http.StatusUnauthorized {
+		t.Errorf("Expected status code to be %d but got %d", http.StatusUnauthorized, resp.StatusCode)
+	}
 
-	for _, c := range cases {
-		resp, err := client.Get(server.URL + c.url)
-		if err != nil {
-			t.Fatal(err)
-		}
+	if logBuffer.Len() != 0 || len(mismatches) != 0 {
+		t.Errorf("Expected response not to be validated but got errors %q and spec mismatches %v", logBuffer.String(), mismatches)
+	}
 
-		respBody, err := ioutil.ReadAll(resp.Body)
-		if err != nil {
-			t.Fatal(err)
-		}
+	// Marking a request outside of response validator has no effect.
+	MarkResponseValidated(httptest.NewRequest(http.MethodGet, "/", nil))
+}
 
-		if !bytes.Equal([]byte(c.expectedPayload), respBody) {
-			t.Errorf("Expected response body to be\n%s\nbut got\n%s", c.expectedPayload, string(respBody))
+func TestDisableResponseValidation(t *testing.T) {
+	doc := loadDoc()
+
+	handlers := OperationHandlers{"getPetById": http.HandlerFunc(func(w http.ResponseWriter, req *http.Request) {
+		// The response is written to the original writer when passed
+		// through.
+		if _, ok := w.(ResponseRecorder); ok {
+			w.Header().Set("X-Buffered", "true")
 		}
+		// Valid JSON that does not match the schema, so it would be
+		// reported if validated.
+		fmt.Fprint(w, `{"id":123,"name":"Kitty"}`)
+	})}
+
+	debug := func(next http.Handler) http.Handler {
+		return http.HandlerFunc(func(w http.ResponseWriter, req *http.Request) {
+			if req.URL.Query().Get("debug") == "true" {
+				req = DisableResponseValidation(req)
+			}
+			next.ServeHTTP(w, req)
+		})
 	}
 
-	// tear down
+	logBuffer := &bytes.Buffer{}
+	respBodyValidator := NewResponseBodyValidator(
+		errorLogger(logBuffer),
+		ResponsePassthroughHeaderOpt("X-Internal-Call"),
+	)
 
-	server.Close()
-}
+	// Middleware added last runs first, so the debug flag is set before
+	// the response is validated.
+	router, err := NewRouter(doc.Spec(), handlers, MiddlewareOpt(respBodyValidator.Apply), MiddlewareOpt(debug))
+	if err != nil {
+		t.Fatal(err)
+	}
 
-func TestResponseBodyValidator_Apply(t *testing.T) {
 	cases := []struct {
-		url                string
-		logBuffer          *bytes.Buffer
-		expectedStatusCode int
-		expectedPayload    string
-		expectedLogBuffer  string
+		target          string
+		header          string
+		expectValidated bool
 	}{
-		// with validation errors
-		{
-			url:                "/pet/12",
-			logBuffer:          &bytes.Buffer{},
-			expectedStatusCode: http.StatusOK,
-			expectedPayload:    `{"id":123,"name":"Kitty"}` + "\n",
-			expectedLogBuffer:  "response data does not match the schema: field=age value=<nil> message=age in body is required",
-		},
-		// no spec for 500
-		{
-			url:                "/pet/500",
-			logBuffer:          &bytes.Buffer{},
-			expectedStatusCode: http.StatusInternalServerError,
-			expectedPayload:    "Internal Server Error\n",
-		},
-		// no schema for 404
+		// responses are validated by default
 		{
-			url:                "/pet/13",
-			logBuffer:          &bytes.Buffer{},
-			expectedStatusCode: http.StatusNotFound,
-			expectedPayload:    "404 page not found\n",
+			target:          "/v2/pet/12",
+			expectValidated: true,
 		},
+		// responses to flagged requests are passed through
 		{
-			url:                "/pet/badjson",
-			logBuffer:          &bytes.Buffer{},
-			expectedStatusCode: http.StatusOK,
-			expectedPayload:    `{"name":`,
+			target: "/v2/pet/12?debug=true",
 		},
-		// request an url which handler does not provide operation context
+		// responses to requests with the header are passed through
 		{
-			url:                "/no_operation_resource",
-			logBuffer:          &bytes.Buffer{},
-			expectedStatusCode: http.StatusOK,
-			expectedPayload:    "hit no operation resource",
-			expectedLogBuffer:  "",
+			target: "/v2/pet/12",
+			header: "1",
 		},
 	}
 
-	// set up
-
-	doc := loadDoc()
+	for _, c := range cases {
+		logBuffer.Reset()
 
-	handlers := OperationHandlers{"getPetById": http.HandlerFunc(func(w http.ResponseWriter, req *http.Request) {
-		// fake not found
-		if req.URL.Path == "/pet/13" {
-			http.NotFound(w, req)
-			return
+		req := httptest.NewRequest(http.MethodGet, c.target, nil)
+		if c.header != "" {
+			req.Header.Set("X-Internal-Call", c.header)
 		}
+		w := httptest.NewRecorder()
+		router.ServeHTTP(w, req)
 
-		// fake for server error {
-		if req.URL.Path == "/pet/500" {
-			http.Error(w, http.StatusText(http.StatusInternalServerError), http.StatusInternalServerError)
-			return
+		buffered := w.Header().Get("X-Buffered") == "true"
+		if buffered != c.expectValidated {
+			t.Errorf("Expected response to %s to be buffered: %v but got %v", c.target, c.expectValidated, buffered)
 		}
-
-		// fake for bad json
-		if req.URL.Path == "/pet/badjson" {
-			w.Write([]byte(`{"name":`))
-			return
+		if validated := logBuffer.Len() != 0; validated != c.expectValidated {
+			t.Errorf("Expected response to %s to be validated: %v but got errors %q", c.target, c.expectValidated, logBuffer.String())
 		}
-
-		// normal
-
-		type pet struct {
-			ID   int64  `json:"id"`
-			Name string `json:"name"`
+		if w.Body.String() != `{"id":123,"name":"Kitty"}` {
+			t.Errorf("Expected response body to be passed as is but got %s", w.Body.String())
 		}
+	}
+}
 
-		p := pet{123, "Kitty"}
+func TestOperationResolverOpt(t *testing.T) {
+	doc := loadDoc()
 
-		if err := json.NewEncoder(w).Encode(p); err != nil {
-			t.Fatalf("Unexpected error: %s", err)
-		}
-	})}
-	noOpHandler := http.HandlerFunc(func(w http.ResponseWriter, req *http.Request) {
-		fmt.Fprint(w, "hit no operation resource")
+	ops := make(map[string]*spec.Operation)
+	forEachOperation(doc.Spec(), func(path, method string, pi spec.PathItem, op *spec.Operation) {
+		ops[op.ID] = op
 	})
 
-	// test
-
-	for _, c := range cases {
-		respBodyValidator := NewResponseBodyValidator(errorLogger(c.logBuffer))
-		opts := []RouterOption{MiddlewareOpt(respBodyValidator.Apply)}
-
-		operationsRouter, err := NewRouter(doc.Spec(), handlers, opts...)
-		if err != nil {
-			t.Fatal(err)
-		}
-
-		finalRouter := chi.NewRouter()
-		finalRouter.Mount("/", operationsRouter)
-		finalRouter.Handle("/no_operation_resource", respBodyValidator.Apply(noOpHandler))
-
-		server := httptest.NewServer(finalRouter)
-		client := server.Client()
+	// A proxy in front of the service sets the operation id header.
+	resolver := func(req *http.Request) *spec.Operation {
+		return ops[req.Header.Get("X-Operation-Id")]
+	}
 
-		resp, err := client.Get(server.URL + c.url)
-		if err != nil {
-			t.Fatal(err)
-		}
+	qv := NewQueryValidator(writeErrorsToResponseWriter, OperationResolverOpt(resolver))
+	handler := qv.Apply(http.HandlerFunc(func(w http.ResponseWriter, req *http.Request) {
+		fmt.Fprint(w, "ok")
+	}))
 
-		respBody, err := ioutil.ReadAll(resp.Body)
-		if err != nil {
-			t.Fatal(err)
-		}
+	cases := []struct {
+		operationID     string
+		expectedPayload string
+	}{
+		// resolved operation is used for validation
+		{
+			operationID:     "loginUser",
+			expectedPayload: `{"errors":[{"message":"param password is required","field":"password"}]}`,
+		},
+		// no operation resolved
+		{
+			operationID:     "",
+			expectedPayload: "ok",
+		},
+	}
 
-		if c.expectedStatusCode != resp.StatusCode {
-			t.Errorf("Expected status code to be %v but got %v", c.expectedStatusCode, resp.StatusCode)
-		}
+	for _, c := range cases {
+		req := httptest.NewRequest(http.MethodGet, "/login?username=johndoe", nil)
+		req.Header.Set("X-Operation-Id", c.operationID)
+		w := httptest.NewRecorder()
 
-		if !bytes.Equal([]byte(c.expectedPayload), respBody) {
-			t.Errorf("Expected response body to be\n%s\nbut got\n%s", c.expectedPayload, string(respBody))
-		}
+		handler.ServeHTTP(w, req)
 
-		expectedLogBuff := strings.TrimSpace(c.expectedLogBuffer)
-		actualLogBuff := strings.TrimSpace(c.logBuffer.String())
-		if expectedLogBuff != actualLogBuff {
-			t.Errorf("Expected log buffer to be\n%v\nbut got\n%v\n", expectedLogBuff, actualLogBuff)
+		if c.expectedPayload != w.Body.String() {
+			t.Errorf("Expected response body to be\n%s\nbut got\n%s", c.expectedPayload, w.Body.String())
 		}
-
-		// tear down
-		server.Close()
 	}
 }
 
@@ -472,3 +3141,97 @@ func errorLogger(buffer *bytes.Buffer) func(w http.ResponseWriter, errs []error)
 		}
 	}
 }
+
+func TestLenientFormatsOpt(t *testing.T) {
+	sw := parseSpec(`
+swagger: "2.0"
+info:
+  title: test
+  version: "1.0"
+basePath: /v1
+paths:
+  /items:
+    get:
+      operationId: getItems
+      parameters:
+      - name: code
+        in: query
+        type: string
+        format: vendor-code
+      - name: serial
+        in: query
+        type: integer
+        format: vendor-serial
+      - name: tags
+        in: query
+        type: array
+        items:
+          type: integer
+          format: vendor-tag
+      responses:
+        200:
+          description: ok
+`)
+
+	cases := []struct {
+		options         []MiddlewareOption
+		query           string
+		expectedPayload string
+		expectedValues  map[string]interface{}
+	}{
+		// unknown formats are rejected by default
+		{
+			query:           "code=ABC",
+			expectedPayload: `{"errors":[{"message":"param code: unknown format vendor-code for type string","field":"code","value":"ABC"}]}`,
+		},
+		{
+			query:           "serial=42",
+			expectedPayload: `{"errors":[{"message":"param serial: unknown format vendor-serial for type integer","field":"serial","value":"42"}]}`,
+		},
+		// values of unknown formats are converted as of the base type
+		{
+			options:         []MiddlewareOption{LenientFormatsOpt(true)},
+			query:           "code=ABC&serial=42&tags=1,2",
+			expectedPayload: "ok",
+			expectedValues: map[string]interface{}{
+				"code":   "ABC",
+				"serial": int64(42),
+				"tags":   []interface{}{int64(1), int64(2)},
+			},
+		},
+		// the base type is still validated
+		{
+			options:         []MiddlewareOption{LenientFormatsOpt(true)},
+			query:           "serial=abc",
+			expectedPayload: `{"errors":[{"message":"param serial: cannot convert abc to int64","field":"serial","value":"abc"}]}`,
+		},
+	}
+
+	for _, c := range cases {
+		values := make(map[string]interface{})
+		handlers := OperationHandlers{"getItems": http.HandlerFunc(func(w http.ResponseWriter, req *http.Request) {
+			for _, name := range []string{"code", "serial", "tags"} {
+				if v := GetQueryParam(req, name); v != nil {
+					values[name] = v
+				}
+			}
+			fmt.Fprint(w, "ok")
+		})}
+
+		queryValidator := NewQueryValidator(writeErrorsToResponseWriter, c.options...)
+		router, err := NewRouter(sw, handlers, MiddlewareOpt(queryValidator.Apply))
+		if err != nil {
+			t.Fatal(err)
+		}
+
+		w := httptest.NewRecorder()
+		router.ServeHTTP(w, httptest.NewRequest(http.MethodGet, "/v1/items?"+c.query, nil))
+
+		if c.expectedPayload != w.Body.String() {
+			t.Errorf("Expected response body for %q to be\n%s\nbut got\n%s", c.query, c.expectedPayload, w.Body.String())
+		}
+		if c.expectedValues != nil && !reflect.DeepEqual(c.expectedValues, values) {
+			t.Errorf("Expected values for %q to be %#v but got %#v", c.query, c.expectedValues, values)
+		}
+	}
+}
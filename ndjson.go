@@ -0,0 +1,96 @@
+package oas2
+
+import (
+	"bytes"
+	"net/http"
+)
+
+// ndjsonResponseRecorder passes a response through to the origin and calls
+// onRecord for each line of a newline delimited JSON stream as soon as the
+// line is written, without buffering the whole stream. Writes block while
+// the origin blocks, so a slow client slows down the handler.
+type ndjsonResponseRecorder struct {
+	origin        http.ResponseWriter
+	status        int
+	statusWritten bool
+	partial       bytes.Buffer
+	records       int
+	skip          bool
+
+	// onRecord is called with the number of the record starting from 1
+	// and the record itself.
+	onRecord func(status, n int, record []byte)
+}
+
+func newNDJSONResponseRecorder(origin http.ResponseWriter, onRecord func(status, n int, record []byte)) *ndjsonResponseRecorder {
+	return &ndjsonResponseRecorder{
+		origin:   origin,
+		status:   http.StatusOK,
+		onRecord: onRecord,
+	}
+}
+
+func (r *ndjsonResponseRecorder) Header() http.Header {
+	return r.origin.Header()
+}
+
+func (r *ndjsonResponseRecorder) WriteHeader(status int) {
+	r.writeHeader(status)
+	r.origin.WriteHeader(status)
+}
+
+func (r *ndjsonResponseRecorder) writeHeader(status int) {
+	if r.statusWritten {
+		return
+	}
+	r.statusWritten = true
+	r.status = status
+
+	// Only NDJSON responses are split to records, e.g. errors are usually
+	// not streamed.
+	r.skip = parseMediaType(r.origin.Header().Get("Content-Type")) != mediaTypeNDJSON
+}
+
+func (r *ndjsonResponseRecorder) Write(b []byte) (int, error) {
+	r.writeHeader(http.StatusOK)
+
+	n, err := r.origin.Write(b)
+	if r.skip {
+		return n, err
+	}
+
+	r.partial.Write(b[:n])
+	for {
+		i := bytes.IndexByte(r.partial.Bytes(), '\n')
+		if i < 0 {
+			break
+		}
+		r.record(r.partial.Next(i + 1))
+	}
+
+	return n, err
+}
+
+// Flush implements http.Flusher, so handlers can stream records.
+func (r *ndjsonResponseRecorder) Flush() {
+	if f, ok := r.origin.(http.Flusher); ok {
+		f.Flush()
+	}
+}
+
+// finish handles the last record not terminated by a newline.
+func (r *ndjsonResponseRecorder) finish() {
+	if !r.skip && r.partial.Len() > 0 {
+		r.record(r.partial.Bytes())
+		r.partial.Reset()
+	}
+}
+
+func (r *ndjsonResponseRecorder) record(line []byte) {
+	line = bytes.TrimSpace(line)
+	if len(line) == 0 {
+		return
+	}
+	r.records++
+	r.onRecord(r.status, r.records, line)
+}
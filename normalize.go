@@ -0,0 +1,95 @@
+package oas2
+
+import (
+	"fmt"
+	"net/http"
+	"net/url"
+	"strings"
+
+	"github.com/go-openapi/spec"
+)
+
+// extNormalize is a parameter extension that lists normalizers applied to
+// raw values of the parameter before they are converted and validated, e.g.
+// "trim,lower" or a list of names.
+const extNormalize = "x-normalize"
+
+// Normalizer transforms a raw parameter value, e.g. trims whitespace.
+type Normalizer func(val string) string
+
+// builtinNormalizers are normalizers available by default.
+var builtinNormalizers = map[string]Normalizer{
+	"trim":  strings.TrimSpace,
+	"lower": strings.ToLower,
+	"upper": strings.ToUpper,
+}
+
+// NormalizerOpt returns an option that registers a normalizer by name, so
+// parameters can list it in "x-normalize" extension. Built-in normalizers
+// are "trim", "lower" and "upper"; a normalizer with the same name replaces
+// the built-in one.
+func NormalizerOpt(name string, n Normalizer) MiddlewareOption {
+	return func(args *MiddlewareOptions) {
+		if args.normalizers == nil {
+			args.normalizers = make(map[string]Normalizer)
+		}
+		args.normalizers[name] = n
+	}
+}
+
+// normalizerNames returns names of normalizers listed in the parameter
+// extension.
+func normalizerNames(p spec.Parameter) []string {
+	var names []string
+	if s, ok := p.Extensions.GetString(extNormalize); ok {
+		names = strings.Split(s, ",")
+	} else {
+		names, _ = p.Extensions.GetStringSlice(extNormalize)
+	}
+
+	res := make([]string, 0, len(names))
+	for _, name := range names {
+		if name = strings.TrimSpace(name); name != "" {
+			res = append(res, name)
+		}
+	}
+	return res
+}
+
+// normalize applies normalizers listed by the parameter to the value in
+// order. Unknown normalizers are reported by SpecMismatchFn and skipped.
+func (opts MiddlewareOptions) normalize(req *http.Request, p spec.Parameter, val string) string {
+	for _, name := range normalizerNames(p) {
+		n, ok := opts.normalizers[name]
+		if !ok {
+			n, ok = builtinNormalizers[name]
+		}
+		if !ok {
+			opts.specMismatchFn(req, fmt.Errorf("parameter %s: unknown normalizer %s", p.Name, name))
+			continue
+		}
+		val = n(val)
+	}
+	return val
+}
+
+// normalizeValues normalizes values of the parameters in the location in
+// place and reports whether any value changed.
+func (opts MiddlewareOptions) normalizeValues(req *http.Request, ps []spec.Parameter, in string, vals url.Values) (changed bool) {
+	for _, p := range ps {
+		if p.In != in {
+			continue
+		}
+		if _, ok := p.Extensions[extNormalize]; !ok {
+			continue
+		}
+
+		for i, val := range vals[p.Name] {
+			if normalized := opts.normalize(req, p, val); normalized != val {
+				vals[p.Name][i] = normalized
+				changed = true
+			}
+		}
+	}
+	return changed
+}
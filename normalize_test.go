@@ -0,0 +1,84 @@
+package oas2
+
+import (
+	"fmt"
+	"net/http"
+	"net/http/httptest"
+	"net/url"
+	"strings"
+	"testing"
+
+	"github.com/go-chi/chi"
+)
+
+func TestNormalizerOpt(t *testing.T) {
+	sw := parseSpec(`
+swagger: "2.0"
+info:
+  title: test
+  version: "1.0"
+basePath: /v1
+paths:
+  /pets/{id}:
+    post:
+      operationId: updatePet
+      consumes:
+      - application/x-www-form-urlencoded
+      parameters:
+      - name: id
+        in: path
+        type: integer
+        required: true
+        x-normalize: strip-prefix
+      - name: email
+        in: query
+        type: string
+        format: email
+        x-normalize: trim,lower
+      - name: status
+        in: formData
+        type: string
+        enum: [AVAILABLE, SOLD]
+        x-normalize: [trim, upper]
+      responses:
+        200:
+          description: ok
+`)
+
+	handlers := OperationHandlers{"updatePet": http.HandlerFunc(func(w http.ResponseWriter, req *http.Request) {
+		fmt.Fprintf(w, "%v %s %s", GetPathParam(req, "id"), req.URL.Query().Get("email"), GetFormValue(req, "status"))
+	})}
+
+	options := []MiddlewareOption{
+		NormalizerOpt("strip-prefix", func(val string) string {
+			return strings.TrimPrefix(val, "pet-")
+		}),
+	}
+	pathParamExtractor := NewPathParameterExtractor(chi.URLParam, options...)
+	queryValidator := NewQueryValidator(writeErrorsToResponseWriter, options...)
+	bodyValidator := NewBodyValidator(writeErrorsToResponseWriter, options...)
+
+	router, err := NewRouter(
+		sw,
+		handlers,
+		MiddlewareOpt(pathParamExtractor.Apply),
+		MiddlewareOpt(bodyValidator.Apply),
+		MiddlewareOpt(queryValidator.Apply),
+	)
+	if err != nil {
+		t.Fatal(err)
+	}
+
+	query := url.Values{"email": {"  John@Example.COM "}}
+	form := url.Values{"status": {" sold"}}
+	req := httptest.NewRequest(http.MethodPost, "/v1/pets/pet-12?"+query.Encode(), strings.NewReader(form.Encode()))
+	req.Header.Set("Content-Type", "application/x-www-form-urlencoded")
+
+	w := httptest.NewRecorder()
+	router.ServeHTTP(w, req)
+
+	expectedPayload := "12 john@example.com SOLD"
+	if w.Body.String() != expectedPayload {
+		t.Errorf("Expected response body to be\n%s\nbut got\n%s", expectedPayload, w.Body.String())
+	}
+}
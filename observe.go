@@ -0,0 +1,60 @@
+package oas2
+
+import (
+	"context"
+	"net/http"
+
+	"github.com/go-openapi/spec"
+)
+
+// ValidationRecord is a record of a validation made by a validator
+// middleware, e.g. for an audit log.
+type ValidationRecord struct {
+	// OperationID is the ID of the operation validated against.
+	OperationID string
+	// In is the part validated: "query", "body", "formData" or "response".
+	In string
+	// Passed reports whether the validation passed.
+	Passed bool
+	// Errors are the validation errors, if any.
+	Errors []error
+}
+
+// ValidationObserver is called after each validation made by validator
+// middlewares, before the response is decided.
+type ValidationObserver func(req *http.Request, rec ValidationRecord)
+
+// ValidationObserverOpt returns an option that sets a function to call after
+// each validation made by validator middlewares for router operations.
+func ValidationObserverOpt(observer ValidationObserver) RouterOption {
+	return func(args *RouterOptions) {
+		args.validationObserver = observer
+	}
+}
+
+type contextKeyValidationObserver struct{}
+
+func validationObserverMiddleware(next http.Handler, observer ValidationObserver) http.Handler {
+	return http.HandlerFunc(func(w http.ResponseWriter, req *http.Request) {
+		req = req.WithContext(
+			context.WithValue(req.Context(), contextKeyValidationObserver{}, observer),
+		)
+		next.ServeHTTP(w, req)
+	})
+}
+
+// observeValidation calls the validation observer set for the request, if
+// any, with the outcome of validation of the part of the request.
+func observeValidation(req *http.Request, op *spec.Operation, in string, errs []error) {
+	observer, ok := req.Context().Value(contextKeyValidationObserver{}).(ValidationObserver)
+	if !ok {
+		return
+	}
+
+	observer(req, ValidationRecord{
+		OperationID: op.ID,
+		In:          in,
+		Passed:      len(errs) == 0,
+		Errors:      errs,
+	})
+}
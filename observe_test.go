@@ -0,0 +1,218 @@
+package oas2
+
+import (
+	"context"
+	"io"
+	"net/http"
+	"net/http/httptest"
+	"reflect"
+	"strings"
+	"testing"
+	"time"
+)
+
+func TestValidationObserverOpt(t *testing.T) {
+	sw := parseSpec(`
+swagger: "2.0"
+info:
+  title: test
+  version: "1.0"
+basePath: /v1
+paths:
+  /pets:
+    post:
+      operationId: addPet
+      consumes:
+      - application/json
+      parameters:
+      - name: limit
+        in: query
+        type: integer
+      - name: body
+        in: body
+        required: true
+        schema:
+          type: object
+          required:
+          - name
+          properties:
+            name:
+              type: string
+      responses:
+        200:
+          description: ok
+`)
+
+	handlers := OperationHandlers{"addPet": http.HandlerFunc(func(w http.ResponseWriter, req *http.Request) {})}
+
+	var records []ValidationRecord
+	observer := func(req *http.Request, rec ValidationRecord) {
+		records = append(records, rec)
+	}
+
+	router, err := NewRouter(
+		sw,
+		handlers,
+		MiddlewareOpt(NewBodyValidator(writeErrorsToResponseWriter).Apply),
+		MiddlewareOpt(NewQueryValidator(writeErrorsToResponseWriter).Apply),
+		ValidationObserverOpt(observer),
+	)
+	if err != nil {
+		t.Fatal(err)
+	}
+
+	req := httptest.NewRequest(http.MethodPost, "/v1/pets?limit=10", strings.NewReader(`{}`))
+	req.Header.Set("Content-Type", "application/json")
+	w := httptest.NewRecorder()
+	router.ServeHTTP(w, req)
+
+	expectedPayload := `{"errors":[{"message":"name in body is required","field":"name"}]}`
+	if w.Body.String() != expectedPayload {
+		t.Errorf("Expected response body to be\n%s\nbut got\n%s", expectedPayload, w.Body.String())
+	}
+
+	if len(records) != 2 {
+		t.Fatalf("Expected 2 validation records but got %d: %v", len(records), records)
+	}
+
+	expectedQuery := ValidationRecord{OperationID: "addPet", In: "query", Passed: true}
+	if !reflect.DeepEqual(expectedQuery, records[0]) {
+		t.Errorf("Expected query record to be %v but got %v", expectedQuery, records[0])
+	}
+
+	body := records[1]
+	if body.OperationID != "addPet" || body.In != "body" || body.Passed {
+		t.Errorf("Expected failed body record of addPet but got %v", body)
+	}
+	expectedErrors := []string{"name in body is required"}
+	var actualErrors []string
+	for _, err := range body.Errors {
+		actualErrors = append(actualErrors, err.Error())
+	}
+	if !reflect.DeepEqual(expectedErrors, actualErrors) {
+		t.Errorf("Expected body errors to be %v but got %v", expectedErrors, actualErrors)
+	}
+}
+
+func TestValidationObserverOpt_bodyRead(t *testing.T) {
+	sw := parseSpec(`
+swagger: "2.0"
+info:
+  title: test
+  version: "1.0"
+basePath: /v1
+paths:
+  /pets:
+    post:
+      operationId: addPet
+      consumes:
+      - application/json
+      parameters:
+      - name: body
+        in: body
+        required: true
+        schema:
+          type: object
+      responses:
+        200:
+          description: ok
+  /notes:
+    post:
+      operationId: addNote
+      consumes:
+      - application/xml
+      parameters:
+      - name: body
+        in: body
+        required: true
+        schema:
+          type: object
+      responses:
+        200:
+          description: ok
+`)
+
+	cases := []struct {
+		path            string
+		contentType     string
+		body            io.Reader
+		timeout         time.Duration
+		expectedPayload string
+		expectedErrors  []string
+	}{
+		// the body fails to be read after the JSON value
+		{
+			path:            "/v1/pets",
+			contentType:     "application/json",
+			body:            io.MultiReader(strings.NewReader(`{"name":"Kitty"} `), failingReader{}),
+			expectedPayload: `{"errors":[{"message":"Body contains invalid json"}]}`,
+			expectedErrors:  []string{"Body contains invalid json"},
+		},
+		// reading of the XML body is aborted by the request deadline
+		{
+			path:            "/v1/notes",
+			contentType:     "application/xml",
+			body:            &slowReader{r: strings.NewReader(`<note>hello</note>`), delay: 20 * time.Millisecond},
+			timeout:         50 * time.Millisecond,
+			expectedPayload: `{"errors":[{"message":"Body was not received before the request deadline"}]}`,
+			expectedErrors:  []string{"Body was not received before the request deadline"},
+		},
+	}
+
+	handlers := OperationHandlers{
+		"addPet":  http.HandlerFunc(func(w http.ResponseWriter, req *http.Request) {}),
+		"addNote": http.HandlerFunc(func(w http.ResponseWriter, req *http.Request) {}),
+	}
+
+	var records []ValidationRecord
+	observer := func(req *http.Request, rec ValidationRecord) {
+		records = append(records, rec)
+	}
+
+	router, err := NewRouter(
+		sw,
+		handlers,
+		MiddlewareOpt(NewBodyValidator(writeErrorsToResponseWriter, TrailingDataOpt(TrailingDataTrim)).Apply),
+		ValidationObserverOpt(observer),
+	)
+	if err != nil {
+		t.Fatal(err)
+	}
+
+	for _, c := range cases {
+		records = nil
+
+		ctx, cancel := context.Background(), func() {}
+		if c.timeout > 0 {
+			ctx, cancel = context.WithTimeout(ctx, c.timeout)
+		}
+		req := httptest.NewRequest(http.MethodPost, c.path, c.body).WithContext(ctx)
+		req.Header.Set("Content-Type", c.contentType)
+		w := httptest.NewRecorder()
+
+		router.ServeHTTP(w, req)
+		cancel()
+
+		if w.Body.String() != c.expectedPayload {
+			t.Errorf("Expected response body for %s to be\n%s\nbut got\n%s", c.path, c.expectedPayload, w.Body.String())
+		}
+
+		if len(records) != 1 || records[0].In != "body" || records[0].Passed {
+			t.Fatalf("Expected a failed body record for %s but got %v", c.path, records)
+		}
+		var actualErrors []string
+		for _, err := range records[0].Errors {
+			actualErrors = append(actualErrors, err.Error())
+		}
+		if !reflect.DeepEqual(c.expectedErrors, actualErrors) {
+			t.Errorf("Expected body errors for %s to be %v but got %v", c.path, c.expectedErrors, actualErrors)
+		}
+	}
+}
+
+// failingReader fails every read.
+type failingReader struct{}
+
+func (failingReader) Read(p []byte) (int, error) {
+	return 0, io.ErrUnexpectedEOF
+}
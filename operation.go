@@ -3,6 +3,7 @@ package oas2
 import (
 	"context"
 	"net/http"
+	"net/url"
 
 	"github.com/go-openapi/spec"
 )
@@ -30,11 +31,134 @@ func GetOperation(req *http.Request) *spec.Operation {
 
 type contextKeyOperation struct{}
 
+// OperationResolver resolves the operation of a request. It allows to use
+// middlewares with a router other than oas2 router.
+type OperationResolver func(req *http.Request) *spec.Operation
+
 func operationIDMiddleware(next http.Handler, op *spec.Operation) http.Handler {
 	return http.HandlerFunc(func(w http.ResponseWriter, req *http.Request) {
+		if state, ok := req.Context().Value(contextKeyRoutedOperation{}).(*routedOperationState); ok {
+			state.op = op
+		}
 		req = req.WithContext(
 			context.WithValue(req.Context(), contextKeyOperation{}, op),
 		)
 		next.ServeHTTP(w, req)
 	})
 }
+
+// routedOperationState is shared between a middleware applied around the
+// router and the router, so the middleware gets the operation the request is
+// routed to, as it cannot see the request context the router creates.
+type routedOperationState struct {
+	op *spec.Operation
+}
+
+type contextKeyRoutedOperation struct{}
+
+// GetPathTemplate returns the spec path template matched by the request,
+// e.g. "/pet/{petId}", or an empty string if the request was not routed by
+// oas2 router. The template does not include the spec's basePath. Unlike
+// the request path, it has low cardinality, so it is suitable for metric
+// labels and cache keys.
+func GetPathTemplate(req *http.Request) string {
+	path, _ := req.Context().Value(contextKeyPathTemplate{}).(string)
+	return path
+}
+
+type contextKeyPathTemplate struct{}
+
+func pathTemplateMiddleware(next http.Handler, path string) http.Handler {
+	return http.HandlerFunc(func(w http.ResponseWriter, req *http.Request) {
+		req = req.WithContext(
+			context.WithValue(req.Context(), contextKeyPathTemplate{}, path),
+		)
+		next.ServeHTTP(w, req)
+	})
+}
+
+// ValidateOperation returns a handler that validates requests to next against
+// the operation of the spec, so a single operation can be served without
+// oas2 router. It sets the operation to the request's context, with
+// parameters merged with the path item ones and references resolved, see
+// EffectiveParameters, and validates header, path and query parameters and
+// body, calling errHandler on validation errors. Path parameters are taken
+// by the extractor set by PathParamExtractorOpt, as there is no path
+// template to match; without it, they are not validated.
+func ValidateOperation(
+	sw *spec.Swagger,
+	op *spec.Operation,
+	next http.Handler,
+	errHandler func(w http.ResponseWriter, errs []error),
+	options ...MiddlewareOption,
+) http.Handler {
+	opts := newMiddlewareOptions(options)
+
+	handler := NewBodyValidator(errHandler, options...).Apply(next)
+	handler = NewQueryValidator(errHandler, options...).Apply(handler)
+	handler = paramValidatorMiddleware(handler, "header", headerValues, errHandler, opts)
+	if extractor := opts.pathParamExtractor; extractor != nil {
+		handler = NewPathParameterExtractor(extractor, options...).Apply(handler)
+		handler = paramValidatorMiddleware(handler, "path", pathValues(extractor), errHandler, opts)
+	}
+	return operationIDMiddleware(handler, effectiveOperation(sw, op))
+}
+
+// paramValidatorMiddleware validates parameters located in "in", which
+// values are taken from the request by values.
+func paramValidatorMiddleware(
+	next http.Handler,
+	in string,
+	values func(req *http.Request, ps []spec.Parameter) url.Values,
+	errHandler func(w http.ResponseWriter, errs []error),
+	opts MiddlewareOptions,
+) http.Handler {
+	return http.HandlerFunc(func(w http.ResponseWriter, req *http.Request) {
+		op := opts.operationResolver(req)
+		if op == nil {
+			next.ServeHTTP(w, req)
+			return
+		}
+
+		errs := validateValues(op.Parameters, in, values(req, op.Parameters), opts)
+		observeValidation(req, op, in, errs)
+		if len(errs) > 0 {
+			operationErrHandler(req, errHandler)(w, errs)
+			return
+		}
+
+		next.ServeHTTP(w, req)
+	})
+}
+
+// headerValues returns values of the request headers declared as header
+// parameters. Other headers are not parameters, so they are left out.
+func headerValues(req *http.Request, ps []spec.Parameter) url.Values {
+	vals := make(url.Values)
+	for _, p := range ps {
+		if p.In != "header" {
+			continue
+		}
+		if v, ok := req.Header[http.CanonicalHeaderKey(p.Name)]; ok {
+			vals[p.Name] = v
+		}
+	}
+	return vals
+}
+
+// pathValues returns a function that returns non-empty values of path
+// parameters taken by the extractor.
+func pathValues(extractor func(r *http.Request, key string) string) func(req *http.Request, ps []spec.Parameter) url.Values {
+	return func(req *http.Request, ps []spec.Parameter) url.Values {
+		vals := make(url.Values)
+		for _, p := range ps {
+			if p.In != "path" {
+				continue
+			}
+			if v := extractor(req, p.Name); v != "" {
+				vals.Set(p.Name, v)
+			}
+		}
+		return vals
+	}
+}
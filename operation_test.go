@@ -2,7 +2,11 @@ package oas2
 
 import (
 	"fmt"
+	"net/http"
+	"net/http/httptest"
 	"os"
+	"strings"
+	"testing"
 )
 
 func ExampleOperationID_String() {
@@ -13,3 +17,182 @@ func ExampleOperationID_String() {
 	// Output:
 	// addPet
 }
+
+func TestValidateOperation(t *testing.T) {
+	doc := loadDoc()
+	op := doc.Spec().Paths.Paths["/pet"].Post
+
+	mux := http.NewServeMux()
+	mux.Handle("/pets/special", ValidateOperation(
+		doc.Spec(),
+		op,
+		http.HandlerFunc(func(w http.ResponseWriter, req *http.Request) {
+			fmt.Fprintf(w, "operation: %s", GetOperation(req).ID)
+		}),
+		writeErrorsToResponseWriter,
+	))
+
+	cases := []struct {
+		url             string
+		body            string
+		expectedPayload string
+	}{
+		// ok
+		{
+			url:             "/pets/special",
+			body:            `{"name":"johndoe","age":7}`,
+			expectedPayload: "operation: addPet",
+		},
+		// invalid body
+		{
+			url:             "/pets/special",
+			body:            `{"age":7}`,
+			expectedPayload: `{"errors":[{"message":"name in body is required","field":"name"}]}`,
+		},
+		// invalid query
+		{
+			url:             "/pets/special?debug=true&foo=bar",
+			body:            `{"name":"johndoe","age":7}`,
+			expectedPayload: `{"errors":[{"message":"parameter foo is unknown","field":"foo","value":"bar"}]}`,
+		},
+	}
+
+	for _, c := range cases {
+		req := httptest.NewRequest(http.MethodPost, c.url, strings.NewReader(c.body))
+		req.Header.Set("Content-Type", "application/json")
+		w := httptest.NewRecorder()
+
+		mux.ServeHTTP(w, req)
+
+		if c.expectedPayload != w.Body.String() {
+			t.Errorf("Expected response body to be\n%s\nbut got\n%s", c.expectedPayload, w.Body.String())
+		}
+	}
+}
+
+func TestValidateOperation_parameters(t *testing.T) {
+	sw := parseSpec(`
+swagger: "2.0"
+info:
+  title: test
+  version: "1.0"
+paths:
+  /pets/{petId}:
+    parameters:
+    - name: petId
+      in: path
+      required: true
+      type: integer
+      minimum: 1
+    get:
+      operationId: getPet
+      parameters:
+      - $ref: "#/parameters/requestId"
+      responses:
+        200:
+          description: ok
+parameters:
+  requestId:
+    name: X-Request-Id
+    in: header
+    required: true
+    type: string
+    pattern: "^[a-z0-9]+$"
+`)
+
+	mux := http.NewServeMux()
+	mux.Handle("/pets/special/", ValidateOperation(
+		sw,
+		sw.Paths.Paths["/pets/{petId}"].Get,
+		http.HandlerFunc(func(w http.ResponseWriter, req *http.Request) {
+			petID := GetPathParam(req, "petId")
+			fmt.Fprintf(w, "pet: %T %v", petID, petID)
+		}),
+		writeErrorsToResponseWriter,
+		PathParamExtractorOpt(func(req *http.Request, key string) string {
+			return strings.TrimPrefix(req.URL.Path, "/pets/special/")
+		}),
+	))
+
+	cases := []struct {
+		url             string
+		requestID       string
+		expectedPayload string
+	}{
+		// ok
+		{
+			url:             "/pets/special/12",
+			requestID:       "abc1",
+			expectedPayload: "pet: int64 12",
+		},
+		// invalid path parameter of the path item
+		{
+			url:             "/pets/special/0",
+			requestID:       "abc1",
+			expectedPayload: `{"errors":[{"message":"petId in path should be greater than or equal to 1","field":"petId","value":0}]}`,
+		},
+		// missing path parameter
+		{
+			url:             "/pets/special/",
+			requestID:       "abc1",
+			expectedPayload: `{"errors":[{"message":"param petId is required","field":"petId"}]}`,
+		},
+		// missing referenced header parameter
+		{
+			url:             "/pets/special/12",
+			expectedPayload: `{"errors":[{"message":"param X-Request-Id is required","field":"X-Request-Id"}]}`,
+		},
+		// invalid header parameter
+		{
+			url:             "/pets/special/12",
+			requestID:       "ABC",
+			expectedPayload: `{"errors":[{"message":"X-Request-Id in header should match '^[a-z0-9]+$'","field":"X-Request-Id","value":"ABC"}]}`,
+		},
+	}
+
+	for _, c := range cases {
+		req := httptest.NewRequest(http.MethodGet, c.url, nil)
+		if c.requestID != "" {
+			req.Header.Set("X-Request-Id", c.requestID)
+		}
+		w := httptest.NewRecorder()
+
+		mux.ServeHTTP(w, req)
+
+		if c.expectedPayload != w.Body.String() {
+			t.Errorf("Expected response body for %s to be\n%s\nbut got\n%s", c.url, c.expectedPayload, w.Body.String())
+		}
+	}
+}
+
+func TestGetPathTemplate(t *testing.T) {
+	doc := loadDoc()
+
+	var template string
+	handlers := OperationHandlers{"getPetById": http.HandlerFunc(func(w http.ResponseWriter, req *http.Request) {
+		template = GetPathTemplate(req)
+	})}
+
+	router, err := NewRouter(doc.Spec(), handlers)
+	if err != nil {
+		t.Fatal(err)
+	}
+
+	server := httptest.NewServer(router)
+	defer server.Close()
+
+	resp, err := server.Client().Get(server.URL + "/v2/pet/12")
+	if err != nil {
+		t.Fatal(err)
+	}
+	resp.Body.Close()
+
+	if template != "/pet/{petId}" {
+		t.Errorf("Expected path template to be %s but got %s", "/pet/{petId}", template)
+	}
+
+	req := httptest.NewRequest(http.MethodGet, "/pet/12", nil)
+	if template := GetPathTemplate(req); template != "" {
+		t.Errorf("Expected no path template for a request not routed by oas2 router but got %s", template)
+	}
+}
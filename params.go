@@ -0,0 +1,167 @@
+package oas2
+
+import (
+	"fmt"
+
+	"github.com/go-openapi/spec"
+)
+
+// primitiveTypes lists types allowed for items of non-body array parameters.
+var primitiveTypes = map[string]struct{}{
+	"string":  {},
+	"number":  {},
+	"integer": {},
+	"boolean": {},
+	"array":   {},
+}
+
+// EffectiveParameters returns parameters of the operation merged with
+// parameters of its path item. Operation parameters override path item
+// parameters with the same name and location. Parameters referencing
+// definitions are resolved.
+func EffectiveParameters(sw *spec.Swagger, op *spec.Operation) []spec.Parameter {
+	var pathItem spec.PathItem
+	forEachOperation(sw, func(path, method string, pi spec.PathItem, o *spec.Operation) {
+		if o == op {
+			pathItem = pi
+		}
+	})
+
+	return effectiveParameters(sw, pathItem, op)
+}
+
+func effectiveParameters(sw *spec.Swagger, pi spec.PathItem, op *spec.Operation) []spec.Parameter {
+	return mergeParameters(
+		resolveParameters(sw, pi.Parameters),
+		resolveParameters(sw, op.Parameters),
+	)
+}
+
+// mergeParameters returns operation parameters followed by inherited
+// parameters not overridden by the operation.
+func mergeParameters(inherited, own []spec.Parameter) []spec.Parameter {
+	type key struct{ name, in string }
+
+	merged := make([]spec.Parameter, 0, len(own)+len(inherited))
+	seen := make(map[key]struct{}, len(own))
+	for _, p := range own {
+		merged = append(merged, p)
+		seen[key{p.Name, p.In}] = struct{}{}
+	}
+	for _, p := range inherited {
+		if _, ok := seen[key{p.Name, p.In}]; !ok {
+			merged = append(merged, p)
+		}
+	}
+	return merged
+}
+
+// resolveParameters returns parameters with references to definitions
+// replaced by the definitions. Unresolvable references are kept as is.
+func resolveParameters(sw *spec.Swagger, ps []spec.Parameter) []spec.Parameter {
+	resolved := make([]spec.Parameter, len(ps))
+	for i, p := range ps {
+		if p.Ref.String() != "" {
+			if rp, err := spec.ResolveParameter(sw, p.Ref); err == nil {
+				p = *rp
+			}
+		}
+		resolved[i] = p
+	}
+	return resolved
+}
+
+// maxRefDepth is the maximum depth of references followed when resolving
+// items.
+const maxRefDepth = 10
+
+// resolveParamItems returns parameters with array items that reference
+// definitions replaced by the definitions themselves.
+func resolveParamItems(sw *spec.Swagger, ps []spec.Parameter) []spec.Parameter {
+	resolved := make([]spec.Parameter, len(ps))
+	for i, p := range ps {
+		if p.Type == "array" && p.Items != nil {
+			if items, err := resolveItems(sw, p.Items); err == nil {
+				p.Items = items
+			}
+		}
+		resolved[i] = p
+	}
+	return resolved
+}
+
+// resolveItems returns items with the reference to a definition, if any,
+// resolved, including nested items. It returns an error if the items are
+// not of a primitive type.
+func resolveItems(sw *spec.Swagger, items *spec.Items) (*spec.Items, error) {
+	resolved := *items
+
+	// Definitions may reference other definitions, limit the depth to not
+	// loop forever on circular references.
+	for depth := 0; resolved.Ref.String() != ""; depth++ {
+		ref := resolved.Ref.String()
+		if depth == maxRefDepth {
+			return nil, fmt.Errorf("items reference %s is too deep", ref)
+		}
+
+		sch, err := spec.ResolveRef(sw, &resolved.Ref)
+		if err != nil {
+			return nil, fmt.Errorf("items reference %s cannot be resolved", ref)
+		}
+		if sch.Ref.String() == "" && len(sch.Type) != 1 {
+			return nil, fmt.Errorf("items reference %s is not of a primitive type", ref)
+		}
+		resolved = schemaItems(sch)
+	}
+
+	if _, ok := primitiveTypes[resolved.Type]; !ok {
+		return nil, fmt.Errorf("items of type %q are not of a primitive type", resolved.Type)
+	}
+
+	if resolved.Type == "array" {
+		if resolved.Items == nil {
+			return nil, fmt.Errorf("nested items are not declared")
+		}
+		nested, err := resolveItems(sw, resolved.Items)
+		if err != nil {
+			return nil, err
+		}
+		resolved.Items = nested
+	}
+
+	return &resolved, nil
+}
+
+// schemaItems converts a schema of a primitive type to items.
+func schemaItems(sch *spec.Schema) spec.Items {
+	items := spec.Items{
+		Refable: spec.Refable{Ref: sch.Ref},
+		SimpleSchema: spec.SimpleSchema{
+			Format:  sch.Format,
+			Default: sch.Default,
+			Example: sch.Example,
+		},
+		CommonValidations: spec.CommonValidations{
+			Maximum:          sch.Maximum,
+			ExclusiveMaximum: sch.ExclusiveMaximum,
+			Minimum:          sch.Minimum,
+			ExclusiveMinimum: sch.ExclusiveMinimum,
+			MaxLength:        sch.MaxLength,
+			MinLength:        sch.MinLength,
+			Pattern:          sch.Pattern,
+			MaxItems:         sch.MaxItems,
+			MinItems:         sch.MinItems,
+			UniqueItems:      sch.UniqueItems,
+			MultipleOf:       sch.MultipleOf,
+			Enum:             sch.Enum,
+		},
+	}
+	if len(sch.Type) == 1 {
+		items.Type = sch.Type[0]
+	}
+	if sch.Items != nil && sch.Items.Schema != nil {
+		nested := schemaItems(sch.Items.Schema)
+		items.Items = &nested
+	}
+	return items
+}
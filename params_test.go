@@ -0,0 +1,112 @@
+package oas2
+
+import (
+	"net/url"
+	"reflect"
+	"testing"
+)
+
+func TestEffectiveParameters(t *testing.T) {
+	sw := parseSpec(`
+swagger: "2.0"
+info:
+  title: test
+  version: "1.0"
+paths:
+  /pets/{petId}:
+    parameters:
+    - name: petId
+      in: path
+      required: true
+      type: integer
+    - name: fields
+      in: query
+      type: string
+    get:
+      operationId: getPet
+      parameters:
+      - name: fields
+        in: query
+        type: string
+        enum: [name, age]
+      - $ref: "#/parameters/Limit"
+      responses:
+        200:
+          description: ok
+parameters:
+  Limit:
+    name: limit
+    in: query
+    type: integer
+    maximum: 100
+`)
+
+	ps := EffectiveParameters(sw, sw.Paths.Paths["/pets/{petId}"].Get)
+
+	type param struct {
+		name, in, typ string
+		enum          int
+	}
+	var actual []param
+	for _, p := range ps {
+		actual = append(actual, param{p.Name, p.In, p.Type, len(p.Enum)})
+	}
+
+	expected := []param{
+		// overridden by the operation
+		{"fields", "query", "string", 2},
+		// resolved reference
+		{"limit", "query", "integer", 0},
+		// inherited from the path item
+		{"petId", "path", "integer", 0},
+	}
+	if !reflect.DeepEqual(expected, actual) {
+		t.Errorf("Expected parameters to be\n%v\nbut got\n%v", expected, actual)
+	}
+}
+
+func TestResolveParamItems(t *testing.T) {
+	sw := parseSpec(`
+swagger: "2.0"
+info:
+  title: test
+  version: "1.0"
+paths:
+  /pets:
+    get:
+      operationId: findPets
+      parameters:
+      - name: ids
+        in: query
+        type: array
+        items:
+          $ref: "#/definitions/PetID"
+      responses:
+        200:
+          description: ok
+definitions:
+  PetID:
+    type: integer
+    format: int64
+    minimum: 1
+`)
+
+	ps := resolveParamItems(sw, sw.Paths.Paths["/pets"].Get.Parameters)
+
+	if ps[0].Items.Type != "integer" || ps[0].Items.Format != "int64" {
+		t.Fatalf("Expected items to be resolved to integer int64 but got %s %s", ps[0].Items.Type, ps[0].Items.Format)
+	}
+
+	errs := ValidateQuery(ps, url.Values{"ids": {"1,2"}})
+	if len(errs) > 0 {
+		t.Errorf("Unexpected errors: %v", errs)
+	}
+
+	errs = ValidateQuery(ps, url.Values{"ids": {"1,0"}})
+	expectedErrors := []error{
+		ValidationErrorf("ids", []interface{}{int64(1), int64(0)}, "ids.1 in query should be greater than or equal to 1"),
+	}
+	if !reflect.DeepEqual(expectedErrors, errs) {
+		t.Errorf("Expected errors to be\n%#v\n but got\n%#v", expectedErrors, errs)
+	}
+}
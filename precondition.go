@@ -0,0 +1,90 @@
+package oas2
+
+import (
+	"net/http"
+	"strings"
+	"time"
+)
+
+// Preconditions are conditions of a request to modify a resource only if it
+// is in the state the client expects, for optimistic concurrency control.
+type Preconditions struct {
+	// IfMatch is a list of entity tags from If-Match header, e.g. `"xyzzy"`.
+	// A single "*" matches any current entity.
+	IfMatch []string
+
+	// IfUnmodifiedSince is a time from If-Unmodified-Since header. It is zero
+	// if the header is not set or invalid.
+	IfUnmodifiedSince time.Time
+}
+
+// GetPreconditions returns preconditions from If-Match and
+// If-Unmodified-Since headers of the request.
+func GetPreconditions(req *http.Request) Preconditions {
+	var p Preconditions
+
+	for _, v := range req.Header[http.CanonicalHeaderKey("If-Match")] {
+		for _, tag := range strings.Split(v, ",") {
+			if tag = strings.TrimSpace(tag); tag != "" {
+				p.IfMatch = append(p.IfMatch, tag)
+			}
+		}
+	}
+
+	// An invalid date must be ignored, see RFC 7232, section 3.4.
+	if v := req.Header.Get("If-Unmodified-Since"); v != "" {
+		if t, err := convertHTTPDate(v); err == nil {
+			p.IfUnmodifiedSince = t
+		}
+	}
+
+	return p
+}
+
+// Check returns an error if the resource with the entity tag and the time of
+// last modification does not satisfy the preconditions. The time can be zero
+// if the resource does not have it. Empty entity tag means the resource has
+// no current representation, so it does not satisfy even "If-Match: *", see
+// RFC 7232, section 3.1. If-Unmodified-Since is only checked when there is
+// no If-Match, see RFC 7232, section 6.
+func (p Preconditions) Check(etag string, modified time.Time) error {
+	if len(p.IfMatch) > 0 {
+		if etag == "" {
+			return ValidationErrorf("If-Match", strings.Join(p.IfMatch, ", "), "resource has no current representation")
+		}
+		for _, tag := range p.IfMatch {
+			if tag == "*" || strongMatch(tag, etag) {
+				return nil
+			}
+		}
+		return ValidationErrorf("If-Match", strings.Join(p.IfMatch, ", "), "entity tag %s does not match", etag)
+	}
+
+	if !p.IfUnmodifiedSince.IsZero() && !modified.IsZero() {
+		// HTTP dates have a resolution of seconds.
+		if modified.Truncate(time.Second).After(p.IfUnmodifiedSince) {
+			return ValidationErrorf(
+				"If-Unmodified-Since",
+				p.IfUnmodifiedSince.Format(http.TimeFormat),
+				"resource is modified at %s", modified.UTC().Format(http.TimeFormat),
+			)
+		}
+	}
+
+	return nil
+}
+
+// strongMatch compares entity tags using strong comparison: weak tags never
+// match.
+func strongMatch(a, b string) bool {
+	return a != "" && a == b && !strings.HasPrefix(a, "W/")
+}
+
+// NewPreconditionFailed returns a handler that responds with 412 Precondition
+// Failed. The body is written by errHandler, or by the error handler of the
+// request's operation if set, so it is consistent with other error responses.
+func NewPreconditionFailed(errHandler func(w http.ResponseWriter, errs []error), errs ...error) http.Handler {
+	return http.HandlerFunc(func(w http.ResponseWriter, req *http.Request) {
+		writeErrorsWithStatus(w, http.StatusPreconditionFailed, operationErrHandler(req, errHandler), errs)
+	})
+}
@@ -0,0 +1,121 @@
+package oas2
+
+import (
+	"net/http"
+	"net/http/httptest"
+	"reflect"
+	"testing"
+	"time"
+)
+
+func TestGetPreconditions(t *testing.T) {
+	req := httptest.NewRequest(http.MethodPut, "/v2/pet/12", nil)
+	req.Header.Add("If-Match", `"a", W/"b"`)
+	req.Header.Add("If-Match", `"c"`)
+	req.Header.Set("If-Unmodified-Since", "Wed, 21 Oct 2015 07:28:00 GMT")
+
+	expected := Preconditions{
+		IfMatch:           []string{`"a"`, `W/"b"`, `"c"`},
+		IfUnmodifiedSince: time.Date(2015, 10, 21, 7, 28, 0, 0, time.UTC),
+	}
+	if actual := GetPreconditions(req); !reflect.DeepEqual(expected, actual) {
+		t.Errorf("Expected preconditions to be %#v but got %#v", expected, actual)
+	}
+
+	// invalid date is ignored
+	req = httptest.NewRequest(http.MethodPut, "/v2/pet/12", nil)
+	req.Header.Set("If-Unmodified-Since", "yesterday")
+
+	if actual := GetPreconditions(req); !reflect.DeepEqual(Preconditions{}, actual) {
+		t.Errorf("Expected preconditions to be empty but got %#v", actual)
+	}
+}
+
+func TestPreconditions_Check(t *testing.T) {
+	since := time.Date(2015, 10, 21, 7, 28, 0, 0, time.UTC)
+
+	cases := []struct {
+		preconditions Preconditions
+		etag          string
+		modified      time.Time
+		expectedError error
+	}{
+		// no preconditions
+		{
+			etag:     `"a"`,
+			modified: since.Add(time.Hour),
+		},
+		// matching entity tag
+		{
+			preconditions: Preconditions{IfMatch: []string{`"a"`, `"b"`}},
+			etag:          `"b"`,
+		},
+		// any entity tag
+		{
+			preconditions: Preconditions{IfMatch: []string{"*"}},
+			etag:          `"b"`,
+		},
+		// any entity tag of a resource that does not exist
+		{
+			preconditions: Preconditions{IfMatch: []string{"*"}},
+			expectedError: ValidationErrorf("If-Match", "*", "resource has no current representation"),
+		},
+		// non-matching entity tag
+		{
+			preconditions: Preconditions{IfMatch: []string{`"a"`}},
+			etag:          `"b"`,
+			expectedError: ValidationErrorf("If-Match", `"a"`, `entity tag "b" does not match`),
+		},
+		// weak entity tags never match
+		{
+			preconditions: Preconditions{IfMatch: []string{`W/"a"`}},
+			etag:          `W/"a"`,
+			expectedError: ValidationErrorf("If-Match", `W/"a"`, `entity tag W/"a" does not match`),
+		},
+		// not modified since, up to a second
+		{
+			preconditions: Preconditions{IfUnmodifiedSince: since},
+			modified:      since.Add(500 * time.Millisecond),
+		},
+		// modified since
+		{
+			preconditions: Preconditions{IfUnmodifiedSince: since},
+			modified:      since.Add(time.Hour),
+			expectedError: ValidationErrorf(
+				"If-Unmodified-Since",
+				"Wed, 21 Oct 2015 07:28:00 GMT",
+				"resource is modified at Wed, 21 Oct 2015 08:28:00 GMT",
+			),
+		},
+		// If-Match takes precedence over If-Unmodified-Since
+		{
+			preconditions: Preconditions{IfMatch: []string{`"a"`}, IfUnmodifiedSince: since},
+			etag:          `"a"`,
+			modified:      since.Add(time.Hour),
+		},
+	}
+
+	for _, c := range cases {
+		err := c.preconditions.Check(c.etag, c.modified)
+		if !reflect.DeepEqual(c.expectedError, err) {
+			t.Errorf("Expected error to be %v but got %v", c.expectedError, err)
+		}
+	}
+}
+
+func TestNewPreconditionFailed(t *testing.T) {
+	err := ValidationErrorf("If-Match", `"a"`, `entity tag "b" does not match`)
+
+	w := httptest.NewRecorder()
+	req := httptest.NewRequest(http.MethodPut, "/v2/pet/12", nil)
+	NewPreconditionFailed(writeErrorsToResponseWriter, err).ServeHTTP(w, req)
+
+	if w.Code != http.StatusPreconditionFailed {
+		t.Errorf("Expected status to be %d but got %d", http.StatusPreconditionFailed, w.Code)
+	}
+
+	expectedBody := `{"errors":[{"message":"entity tag \"b\" does not match","field":"If-Match","value":"\"a\""}]}`
+	if w.Body.String() != expectedBody {
+		t.Errorf("Expected response body to be\n%s\nbut got\n%s", expectedBody, w.Body.String())
+	}
+}
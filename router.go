@@ -1,20 +1,25 @@
 package oas2
 
 import (
+	"context"
+	"fmt"
 	"io/ioutil"
 	"net/http"
+	"sort"
+	"strings"
+	"sync"
 
 	"github.com/go-openapi/analysis"
 	"github.com/go-openapi/spec"
 	"github.com/sirupsen/logrus"
 )
 
-// NewRouter returns http.Handler that routes requests based on OAS 2.0 spec.
+// NewRouter returns a Router that routes requests based on OAS 2.0 spec.
 func NewRouter(
 	sw *spec.Swagger,
 	handlers OperationHandlers,
 	options ...RouterOption,
-) (http.Handler, error) {
+) (*Router, error) {
 	// Default options.
 	opts := RouterOptions{
 		logger:     &logrus.Logger{Out: ioutil.Discard},
@@ -27,8 +32,34 @@ func NewRouter(
 		o(&opts)
 	}
 
+	// Check the spec for authoring mistakes. Patterns are compiled at
+	// setup, so invalid ones fail early instead of failing validation of
+	// every request. Collection formats that cannot be parsed fail early
+	// too, instead of misparsing values.
+	errs, fatal := checkSpec(sw)
+	if len(fatal) > 0 {
+		return nil, specErrors(fatal)
+	}
+	if len(errs) > 0 {
+		if opts.validateSpec {
+			return nil, specErrors(errs)
+		}
+		for _, err := range errs {
+			opts.logger.Warnf("oas2 router: %s", err)
+		}
+	}
+
+	basePath, err := resolveBasePath(sw.BasePath, opts)
+	if err != nil {
+		return nil, err
+	}
+
 	// Subrouter handles all the spec operations.
 	subrouter := opts.baseRouter
+	var schemaErrs []error
+	var allowed allowedMethods
+	chains := make(map[OperationID]*operationChain)
+	var routes []route
 	for method, pathOps := range analysis.New(sw).Operations() {
 		for path, op := range pathOps {
 			handler, ok := handlers[OperationID(op.ID)]
@@ -43,23 +74,222 @@ func NewRouter(
 				handler = mwf(handler)
 			}
 
+			// The chain lets middleware be added after the router is
+			// created, see Router.Use.
+			chain := &operationChain{current: handler}
+			chains[OperationID(op.ID)] = chain
+			handler = chain
+
+			// Operation error handler is set before custom middleware, so
+			// validators can use it.
+			if errHandler, ok := opts.errHandlers[OperationID(op.ID)]; ok {
+				handler = errHandlerMiddleware(handler, errHandler)
+			}
+			if opts.validationObserver != nil {
+				handler = validationObserverMiddleware(handler, opts.validationObserver)
+			}
+
+			// Schemas are compiled at setup, so requests are validated
+			// without compilation and broken schemas fail early.
+			eop := effectiveOperation(sw, op)
+			schemas, errs := compileOperationSchemas(sw, eop)
+			schemaErrs = append(schemaErrs, errs...)
+			handler = operationSchemasMiddleware(handler, schemas)
+
 			opts.logger.Debugf("oas2 router: handle: %s %s", method, path)
-			handler = operationIDMiddleware(handler, op)
+			handler = operationIDMiddleware(handler, eop)
+			handler = pathTemplateMiddleware(handler, path)
 			subrouter.Route(method, path, handler)
+			allowed.add(path, method)
+			routes = append(routes, route{method: method, path: mountedPath(basePath, path), operation: op.ID})
 		}
 	}
 
+	// Operations with the same route shadow each other, so requests would
+	// be routed unpredictably.
+	if errs := routeCollisions(routes); len(errs) > 0 {
+		return nil, specErrors(errs)
+	}
+
+	if len(schemaErrs) > 0 {
+		sort.Slice(schemaErrs, func(i, j int) bool {
+			return schemaErrs[i].Error() < schemaErrs[j].Error()
+		})
+		return nil, specErrors(schemaErrs)
+	}
+
+	if opts.notFound != nil {
+		nf, ok := opts.baseRouter.(NotFoundRouter)
+		if !ok {
+			return nil, fmt.Errorf("base router does not support a not found handler")
+		}
+		nf.NotFound(opts.notFound.ServeHTTP)
+	}
+
+	if opts.methodNotAllowed != nil {
+		mna, ok := opts.baseRouter.(MethodNotAllowedRouter)
+		if !ok {
+			return nil, fmt.Errorf("base router does not support a method not allowed handler")
+		}
+		mna.MethodNotAllowed(methodNotAllowedHandler(basePath, &allowed, opts.methodNotAllowed))
+	}
+
 	// Mount the subrouter under the spec's basePath.
 	router := opts.baseRouter
-	router.Mount(sw.BasePath, subrouter)
-	return router, nil
+	router.Mount(basePath, subrouter)
+
+	// Method override is applied before routing, so the overridden method
+	// selects the operation.
+	handler := http.Handler(router)
+	if opts.methodOverride != nil {
+		handler = opts.methodOverride.Apply(handler)
+	}
+
+	return &Router{
+		handler: handler,
+		chains:  chains,
+		drained: make(chan struct{}),
+	}, nil
+}
+
+// Router is a http.Handler that routes requests based on OAS 2.0 spec.
+// It keeps track of requests in flight, so it can be drained before
+// shutdown.
+type Router struct {
+	handler http.Handler
+	chains  map[OperationID]*operationChain
+
+	mu       sync.Mutex
+	inFlight int
+	draining bool
+	drained  chan struct{}
+}
+
+// ServeHTTP implements http.Handler. When the router is draining, it responds
+// with 503 Service Unavailable.
+func (r *Router) ServeHTTP(w http.ResponseWriter, req *http.Request) {
+	if !r.acquire() {
+		http.Error(w, http.StatusText(http.StatusServiceUnavailable), http.StatusServiceUnavailable)
+		return
+	}
+	defer r.release()
+
+	r.handler.ServeHTTP(w, req)
+}
+
+// Use adds the middleware to the operation after the router is created,
+// e.g. to enable extra validation for a canary. The middleware wraps the
+// middleware set by MiddlewareOpt and the previously used ones, and applies
+// to requests served after Use returns. It returns an error if the router
+// does not serve the operation.
+func (r *Router) Use(id OperationID, mw MiddlewareFn) error {
+	chain, ok := r.chains[id]
+	if !ok {
+		return fmt.Errorf("operation %s is not served by the router", id)
+	}
+	chain.use(mw)
+	return nil
+}
+
+// operationChain is a handler of an operation with middleware that can be
+// added at runtime.
+type operationChain struct {
+	mu      sync.RWMutex
+	current http.Handler
+}
+
+func (c *operationChain) ServeHTTP(w http.ResponseWriter, req *http.Request) {
+	c.mu.RLock()
+	handler := c.current
+	c.mu.RUnlock()
+
+	handler.ServeHTTP(w, req)
+}
+
+func (c *operationChain) use(mw MiddlewareFn) {
+	c.mu.Lock()
+	defer c.mu.Unlock()
+
+	c.current = mw(c.current)
+}
+
+// InFlight returns the number of requests currently being served.
+func (r *Router) InFlight() int {
+	r.mu.Lock()
+	defer r.mu.Unlock()
+	return r.inFlight
+}
+
+// Drain stops accepting new requests and waits until the requests in flight
+// are served or ctx is done. In the latter case it returns ctx error.
+func (r *Router) Drain(ctx context.Context) error {
+	r.mu.Lock()
+	if !r.draining {
+		r.draining = true
+		if r.inFlight == 0 {
+			close(r.drained)
+		}
+	}
+	r.mu.Unlock()
+
+	select {
+	case <-r.drained:
+		return nil
+	case <-ctx.Done():
+		return ctx.Err()
+	}
+}
+
+func (r *Router) acquire() bool {
+	r.mu.Lock()
+	defer r.mu.Unlock()
+
+	if r.draining {
+		return false
+	}
+	r.inFlight++
+	return true
+}
+
+func (r *Router) release() {
+	r.mu.Lock()
+	defer r.mu.Unlock()
+
+	r.inFlight--
+	if r.draining && r.inFlight == 0 {
+		close(r.drained)
+	}
+}
+
+// effectiveOperation returns a copy of the operation that inherits
+// spec-level consumes and produces if the operation does not declare its own.
+// Its parameters are the effective parameters, see EffectiveParameters, with
+// items of array parameters referencing definitions resolved.
+func effectiveOperation(sw *spec.Swagger, op *spec.Operation) *spec.Operation {
+	eop := *op
+	if len(eop.Consumes) == 0 {
+		eop.Consumes = sw.Consumes
+	}
+	if len(eop.Produces) == 0 {
+		eop.Produces = sw.Produces
+	}
+	eop.Parameters = resolveParamItems(sw, EffectiveParameters(sw, op))
+	return &eop
 }
 
 // RouterOptions is options for oas2 router.
 type RouterOptions struct {
-	logger     logrus.FieldLogger
-	baseRouter BaseRouter
-	mws        []MiddlewareFn
+	logger             logrus.FieldLogger
+	baseRouter         BaseRouter
+	mws                []MiddlewareFn
+	validateSpec       bool
+	errHandlers        map[OperationID]func(w http.ResponseWriter, errs []error)
+	basePath           *string
+	basePathVars       map[string]string
+	notFound           http.Handler
+	methodOverride     Middleware
+	methodNotAllowed   func(w http.ResponseWriter, errs []error)
+	validationObserver ValidationObserver
 }
 
 // RouterOption is an option for oas2 router.
@@ -87,9 +317,199 @@ func MiddlewareOpt(mw MiddlewareFn) RouterOption {
 	}
 }
 
+// ValidateSpecOpt returns an option that makes the router fail on spec
+// authoring mistakes found by ValidateSpec. Otherwise the mistakes are only
+// logged as warnings.
+func ValidateSpecOpt(validate bool) RouterOption {
+	return func(args *RouterOptions) {
+		args.validateSpec = validate
+	}
+}
+
+// OperationErrHandlerOpt returns an option that sets an error handler for
+// the operation. Request validators use it instead of their own error handler
+// for requests to the operation.
+func OperationErrHandlerOpt(id OperationID, errHandler func(w http.ResponseWriter, errs []error)) RouterOption {
+	return func(args *RouterOptions) {
+		if args.errHandlers == nil {
+			args.errHandlers = make(map[OperationID]func(w http.ResponseWriter, errs []error))
+		}
+		args.errHandlers[id] = errHandler
+	}
+}
+
+// BasePathOverrideOpt returns an option that sets the path the router serves
+// operations under instead of the spec's basePath, e.g. to serve the same
+// spec under different paths in different environments.
+func BasePathOverrideOpt(basePath string) RouterOption {
+	return func(args *RouterOptions) {
+		args.basePath = &basePath
+	}
+}
+
+// BasePathVarOpt returns an option that sets a value of the variable used in
+// a templated basePath. For example, basePath "/{stage}" with variable stage
+// set to "dev" makes the router serve operations under "/dev".
+func BasePathVarOpt(name, value string) RouterOption {
+	return func(args *RouterOptions) {
+		if args.basePathVars == nil {
+			args.basePathVars = make(map[string]string)
+		}
+		args.basePathVars[name] = value
+	}
+}
+
+// NotFoundHandlerOpt returns an option that sets a handler for requests
+// that match no spec operation path, e.g. to forward them upstream. The base
+// router must implement NotFoundRouter.
+func NotFoundHandlerOpt(handler http.Handler) RouterOption {
+	return func(args *RouterOptions) {
+		args.notFound = handler
+	}
+}
+
+// MethodOverrideOpt returns an option that makes the router take the method
+// of POST requests from X-HTTP-Method-Override header, for clients behind
+// proxies that only pass GET and POST. See NewMethodOverride for the methods
+// allowed.
+func MethodOverrideOpt(methods ...string) RouterOption {
+	return func(args *RouterOptions) {
+		args.methodOverride = NewMethodOverride(methods...)
+	}
+}
+
+// NotFoundResponderOpt returns an option that makes the router respond to
+// requests that match no spec operation path with 404 Not Found and the body
+// written by errHandler, so clients get errors of the same shape as
+// validation errors. By default, the base router responds. The base router
+// must implement NotFoundRouter.
+func NotFoundResponderOpt(errHandler func(w http.ResponseWriter, errs []error)) RouterOption {
+	return NotFoundHandlerOpt(http.HandlerFunc(func(w http.ResponseWriter, req *http.Request) {
+		writeErrorsWithStatus(w, http.StatusNotFound, errHandler, []error{
+			fmt.Errorf("Path %s is not found", req.URL.Path),
+		})
+	}))
+}
+
+// MethodNotAllowedResponderOpt returns an option that makes the router
+// respond to requests that match a spec operation path but none of its
+// methods with 405 Method Not Allowed and the body written by errHandler.
+// Allow header lists the methods routed for the path in the canonical order:
+// GET, HEAD, POST, PUT, PATCH, DELETE, OPTIONS. The base router must
+// implement MethodNotAllowedRouter.
+func MethodNotAllowedResponderOpt(errHandler func(w http.ResponseWriter, errs []error)) RouterOption {
+	return func(args *RouterOptions) {
+		args.methodNotAllowed = errHandler
+	}
+}
+
+func methodNotAllowedHandler(basePath string, allowed *allowedMethods, errHandler func(w http.ResponseWriter, errs []error)) http.HandlerFunc {
+	return func(w http.ResponseWriter, req *http.Request) {
+		path := strings.TrimPrefix(req.URL.Path, strings.TrimSuffix(basePath, "/"))
+		if methods := allowed.lookup(path); len(methods) > 0 {
+			w.Header().Set("Allow", strings.Join(methods, ", "))
+		}
+		writeErrorsWithStatus(w, http.StatusMethodNotAllowed, errHandler, []error{
+			fmt.Errorf("Method %s is not allowed for path %s", req.Method, req.URL.Path),
+		})
+	}
+}
+
+// resolveBasePath returns the path to serve operations under, with variables
+// of a templated basePath replaced by their values.
+func resolveBasePath(basePath string, opts RouterOptions) (string, error) {
+	if opts.basePath != nil {
+		basePath = *opts.basePath
+	}
+
+	var err error
+	resolved := pathTemplateParam.ReplaceAllStringFunc(basePath, func(placeholder string) string {
+		name := placeholder[1 : len(placeholder)-1]
+		value, ok := opts.basePathVars[name]
+		if !ok && err == nil {
+			err = fmt.Errorf("base path %s: variable %s is not set", basePath, name)
+		}
+		return value
+	})
+	return resolved, err
+}
+
+// CheckRouteCollisions returns an error if operations of the specs collide,
+// i.e. have the same method and path under the basePath of their spec,
+// regardless of names of path parameters. It is useful when routers of
+// several specs are mounted together, as NewRouter checks operations of
+// a single spec only.
+func CheckRouteCollisions(specs ...*spec.Swagger) error {
+	var routes []route
+	for _, sw := range specs {
+		forEachOperation(sw, func(path, method string, pi spec.PathItem, op *spec.Operation) {
+			routes = append(routes, route{method: method, path: mountedPath(sw.BasePath, path), operation: operationName(method, path, op)})
+		})
+	}
+
+	if errs := routeCollisions(routes); len(errs) > 0 {
+		return specErrors(errs)
+	}
+	return nil
+}
+
+// route is an operation served at the method and the path.
+type route struct {
+	method    string
+	path      string
+	operation string
+}
+
+// routeCollisions returns errors for routes with the same method and path,
+// regardless of names of path parameters.
+func routeCollisions(routes []route) (errs []error) {
+	sort.Slice(routes, func(i, j int) bool {
+		if routes[i].method != routes[j].method {
+			return routes[i].method < routes[j].method
+		}
+		if routes[i].path != routes[j].path {
+			return routes[i].path < routes[j].path
+		}
+		return routes[i].operation < routes[j].operation
+	})
+
+	first := make(map[string]route, len(routes))
+	for _, r := range routes {
+		key := r.method + " " + pathTemplateParam.ReplaceAllString(r.path, "{}")
+		f, ok := first[key]
+		if !ok {
+			first[key] = r
+			continue
+		}
+		errs = append(errs, fmt.Errorf(
+			"operations %s and %s collide on %s %s", f.operation, r.operation, r.method, f.path,
+		))
+	}
+	return errs
+}
+
+// mountedPath returns the path template served under the base path.
+func mountedPath(basePath, path string) string {
+	return strings.TrimSuffix(basePath, "/") + path
+}
+
 // BaseRouter is an underlying router used in oas2 router.
 type BaseRouter interface {
 	Route(method string, pathPattern string, handler http.Handler)
 	Mount(path string, handler http.Handler)
 	ServeHTTP(w http.ResponseWriter, req *http.Request)
 }
+
+// NotFoundRouter is a BaseRouter that supports a custom handler for
+// requests that match no route.
+type NotFoundRouter interface {
+	BaseRouter
+	NotFound(handler http.HandlerFunc)
+}
+
+// MethodNotAllowedRouter is a BaseRouter that supports a custom handler for
+// requests that match a route path but none of its methods.
+type MethodNotAllowedRouter interface {
+	BaseRouter
+	MethodNotAllowed(handler http.HandlerFunc)
+}
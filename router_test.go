@@ -2,12 +2,15 @@ package oas2
 
 import (
 	"bytes"
+	"context"
 	"fmt"
 	"io/ioutil"
 	"net/http"
 	"net/http/httptest"
 	"reflect"
+	"strings"
 	"testing"
+	"time"
 
 	"github.com/sirupsen/logrus"
 )
@@ -75,3 +78,880 @@ func TestBaseRouterOpt(t *testing.T) {
 		t.Fatalf("Expected base router to be %v but got %v", baseRouter, opts.baseRouter)
 	}
 }
+
+func TestValidateSpecOpt(t *testing.T) {
+	sw := parseSpec(`
+swagger: "2.0"
+info:
+  title: test
+  version: "1.0"
+basePath: /v1
+paths:
+  /pet/{id}:
+    get:
+      operationId: getPet
+      responses:
+        200:
+          description: ok
+`)
+
+	if _, err := NewRouter(sw, OperationHandlers{}); err != nil {
+		t.Fatalf("Expected no error without the option but got %v", err)
+	}
+
+	_, err := NewRouter(sw, OperationHandlers{}, ValidateSpecOpt(true))
+	expected := "invalid spec: operation getPet: path parameter id is not declared"
+	if err == nil || err.Error() != expected {
+		t.Fatalf("Expected error to be %q but got %v", expected, err)
+	}
+}
+
+func TestNewRouter_invalidPattern(t *testing.T) {
+	sw := parseSpec(`
+swagger: "2.0"
+info:
+  title: test
+  version: "1.0"
+basePath: /v1
+paths:
+  /pets:
+    get:
+      operationId: findPets
+      parameters:
+      - name: name
+        in: query
+        type: string
+        pattern: "^[a-z"
+      responses:
+        200:
+          description: ok
+          schema:
+            type: object
+            properties:
+              tag:
+                type: string
+                pattern: "(unclosed"
+`)
+
+	_, err := NewRouter(sw, OperationHandlers{})
+	expected := "invalid spec: " +
+		"operation findPets: parameter name: invalid pattern \"^[a-z\": error parsing regexp: missing closing ]: `[a-z`; " +
+		"operation findPets: response 200: schema tag: invalid pattern \"(unclosed\": error parsing regexp: missing closing ): `(unclosed`"
+	if err == nil || err.Error() != expected {
+		t.Fatalf("Expected error to be\n%s\nbut got\n%v", expected, err)
+	}
+}
+
+func TestNewRouter_collectionFormat(t *testing.T) {
+	src := func(in string) string {
+		return `
+swagger: "2.0"
+info:
+  title: test
+  version: "1.0"
+basePath: /v1
+paths:
+  /pets:
+    get:
+      operationId: findPets
+      parameters:
+      - name: tags
+        in: ` + in + `
+        type: array
+        collectionFormat: multi
+        items:
+          type: string
+      responses:
+        200:
+          description: ok
+`
+	}
+
+	_, err := NewRouter(parseSpec(src("header")), OperationHandlers{})
+	expected := "invalid spec: operation findPets: parameter tags: collection format multi is not allowed in header"
+	if err == nil || err.Error() != expected {
+		t.Fatalf("Expected error to be\n%s\nbut got\n%v", expected, err)
+	}
+
+	handlers := OperationHandlers{"findPets": http.HandlerFunc(func(w http.ResponseWriter, req *http.Request) {
+		fmt.Fprint(w, GetQueryParam(req, "tags"))
+	})}
+
+	router, err := NewRouter(parseSpec(src("query")), handlers, MiddlewareOpt(NewQueryValidator(writeErrorsToResponseWriter).Apply))
+	if err != nil {
+		t.Fatal(err)
+	}
+
+	w := httptest.NewRecorder()
+	router.ServeHTTP(w, httptest.NewRequest(http.MethodGet, "/v1/pets?tags=cat&tags=dog,fish", nil))
+
+	expectedPayload := "[cat dog,fish]"
+	if w.Body.String() != expectedPayload {
+		t.Errorf("Expected response body to be %s but got %s", expectedPayload, w.Body.String())
+	}
+}
+
+func TestNewRouter_routeCollisions(t *testing.T) {
+	sw := parseSpec(`
+swagger: "2.0"
+info:
+  title: test
+  version: "1.0"
+basePath: /v1
+paths:
+  /pets/{id}:
+    get:
+      operationId: getPetById
+      parameters:
+      - name: id
+        in: path
+        required: true
+        type: integer
+      responses:
+        200:
+          description: ok
+    delete:
+      operationId: deletePet
+      parameters:
+      - name: id
+        in: path
+        required: true
+        type: integer
+      responses:
+        204:
+          description: deleted
+  /pets/{name}:
+    get:
+      operationId: getPetByName
+      parameters:
+      - name: name
+        in: path
+        required: true
+        type: string
+      responses:
+        200:
+          description: ok
+  /pets/me:
+    get:
+      operationId: getMyPet
+      responses:
+        200:
+          description: ok
+`)
+
+	h := http.NotFoundHandler()
+	handlers := OperationHandlers{"getPetById": h, "deletePet": h, "getPetByName": h, "getMyPet": h}
+
+	_, err := NewRouter(sw, handlers)
+	expected := "invalid spec: operations getPetById and getPetByName collide on GET /v1/pets/{id}"
+	if err == nil || err.Error() != expected {
+		t.Fatalf("Expected error to be\n%s\nbut got\n%v", expected, err)
+	}
+
+	// Operations without handlers are not routed.
+	delete(handlers, "getPetByName")
+	if _, err := NewRouter(sw, handlers); err != nil {
+		t.Errorf("Unexpected error: %v", err)
+	}
+}
+
+func TestCheckRouteCollisions(t *testing.T) {
+	pets := parseSpec(`
+swagger: "2.0"
+info:
+  title: pets
+  version: "1.0"
+basePath: /v1
+paths:
+  /pets/{id}/photo:
+    get:
+      operationId: getPetPhoto
+      responses:
+        200:
+          description: ok
+  /pets/{id}:
+    get:
+      operationId: getPet
+      responses:
+        200:
+          description: ok
+`)
+	photos := parseSpec(`
+swagger: "2.0"
+info:
+  title: photos
+  version: "1.0"
+basePath: /v1/pets/
+paths:
+  /{petId}/photo:
+    get:
+      operationId: getPhoto
+      responses:
+        200:
+          description: ok
+    put:
+      operationId: putPhoto
+      responses:
+        200:
+          description: ok
+`)
+
+	if err := CheckRouteCollisions(pets); err != nil {
+		t.Errorf("Unexpected error: %v", err)
+	}
+
+	err := CheckRouteCollisions(pets, photos)
+	expected := "invalid spec: operations getPetPhoto and getPhoto collide on GET /v1/pets/{id}/photo"
+	if err == nil || err.Error() != expected {
+		t.Errorf("Expected error to be\n%s\nbut got\n%v", expected, err)
+	}
+}
+
+func TestNewRouter_compileSchemas(t *testing.T) {
+	src := `
+swagger: "2.0"
+info:
+  title: test
+  version: "1.0"
+basePath: /v1
+paths:
+  /pets:
+    post:
+      operationId: addPet
+      parameters:
+      - name: pet
+        in: body
+        schema:
+          $ref: "#/definitions/Pet"
+      responses:
+        200:
+          description: ok
+          schema:
+            $ref: "#/definitions/%s"
+definitions:
+  Pet:
+    type: object
+    required: [id, name]
+    properties:
+      id:
+        type: integer
+        readOnly: true
+      name:
+        type: string
+`
+
+	handlers := OperationHandlers{"addPet": http.HandlerFunc(func(w http.ResponseWriter, req *http.Request) {
+		fmt.Fprint(w, `{"id":1,"name":"Rex"}`)
+	})}
+
+	// unresolvable reference fails the setup
+	_, err := NewRouter(parseSpec(fmt.Sprintf(src, "Missing")), handlers)
+	expectedPrefix := "invalid spec: operation addPet: response 200: schema: "
+	if err == nil || !strings.HasPrefix(err.Error(), expectedPrefix) {
+		t.Fatalf("Expected error to start with\n%s\nbut got\n%v", expectedPrefix, err)
+	}
+
+	// references of unexpanded spec are resolved at setup
+	sw := parseSpec(fmt.Sprintf(src, "Pet"))
+	bodyValidator := NewBodyValidator(writeErrorsToResponseWriter)
+	router, err := NewRouter(sw, handlers, MiddlewareOpt(bodyValidator.Apply))
+	if err != nil {
+		t.Fatal(err)
+	}
+
+	cases := []struct {
+		body            string
+		expectedPayload string
+	}{
+		// readOnly property of the referenced definition is not required
+		{
+			body:            `{"name":"Rex"}`,
+			expectedPayload: `{"id":1,"name":"Rex"}`,
+		},
+		{
+			body:            `{}`,
+			expectedPayload: `{"errors":[{"message":"name in body is required","field":"name"}]}`,
+		},
+	}
+
+	for _, c := range cases {
+		req := httptest.NewRequest(http.MethodPost, "/v1/pets", strings.NewReader(c.body))
+		req.Header.Set("Content-Type", "application/json")
+		w := httptest.NewRecorder()
+		router.ServeHTTP(w, req)
+
+		if w.Body.String() != c.expectedPayload {
+			t.Errorf("Expected response body to be\n%s\nbut got\n%s", c.expectedPayload, w.Body.String())
+		}
+	}
+
+	// the spec is not modified
+	if ref := sw.Paths.Paths["/pets"].Post.Parameters[0].Schema.Ref.String(); ref != "#/definitions/Pet" {
+		t.Errorf("Expected body schema to reference %s but got %s", "#/definitions/Pet", ref)
+	}
+}
+
+// BenchmarkRouter_bodyValidator measures requests validated by schemas
+// compiled at setup, compare with BenchmarkValidateBySchema.
+func BenchmarkRouter_bodyValidator(b *testing.B) {
+	doc := loadDoc()
+
+	handlers := OperationHandlers{"addPet": http.HandlerFunc(func(w http.ResponseWriter, req *http.Request) {})}
+	bodyValidator := NewBodyValidator(func(w http.ResponseWriter, errs []error) {
+		b.Fatal(errs)
+	})
+
+	router, err := NewRouter(doc.Spec(), handlers, MiddlewareOpt(bodyValidator.Apply))
+	if err != nil {
+		b.Fatal(err)
+	}
+
+	body := `{"name":"Kitty","age":3}`
+
+	b.ReportAllocs()
+	b.ResetTimer()
+	for i := 0; i < b.N; i++ {
+		req := httptest.NewRequest(http.MethodPost, "/v2/pet", strings.NewReader(body))
+		req.Header.Set("Content-Type", "application/json")
+		router.ServeHTTP(httptest.NewRecorder(), req)
+	}
+}
+
+func TestBasePathOverrideOpt(t *testing.T) {
+	sw := parseSpec(`
+swagger: "2.0"
+info:
+  title: test
+  version: "1.0"
+basePath: /{stage}/api
+paths:
+  /ping:
+    get:
+      operationId: ping
+      responses:
+        200:
+          description: ok
+`)
+
+	handlers := OperationHandlers{
+		"ping": http.HandlerFunc(func(w http.ResponseWriter, req *http.Request) {
+			fmt.Fprint(w, "pong")
+		}),
+	}
+
+	cases := []struct {
+		options        []RouterOption
+		path           string
+		expectedStatus int
+		expectError    bool
+	}{
+		// variable is resolved
+		{
+			options:        []RouterOption{BasePathVarOpt("stage", "dev")},
+			path:           "/dev/api/ping",
+			expectedStatus: http.StatusOK,
+		},
+		{
+			options:        []RouterOption{BasePathVarOpt("stage", "dev")},
+			path:           "/prod/api/ping",
+			expectedStatus: http.StatusNotFound,
+		},
+		// override wins over the spec's basePath
+		{
+			options:        []RouterOption{BasePathOverrideOpt("/prod"), BasePathVarOpt("stage", "dev")},
+			path:           "/prod/ping",
+			expectedStatus: http.StatusOK,
+		},
+		// override can be templated too
+		{
+			options:        []RouterOption{BasePathOverrideOpt("/{stage}"), BasePathVarOpt("stage", "prod")},
+			path:           "/prod/ping",
+			expectedStatus: http.StatusOK,
+		},
+		// variable is not set
+		{
+			expectError: true,
+		},
+	}
+
+	for _, c := range cases {
+		router, err := NewRouter(sw, handlers, c.options...)
+		if c.expectError {
+			if err == nil {
+				t.Errorf("Expected error but got nil")
+			}
+			continue
+		}
+		if err != nil {
+			t.Fatal(err)
+		}
+
+		w := httptest.NewRecorder()
+		router.ServeHTTP(w, httptest.NewRequest(http.MethodGet, c.path, nil))
+
+		if w.Code != c.expectedStatus {
+			t.Errorf("Expected status of %s to be %d but got %d", c.path, c.expectedStatus, w.Code)
+		}
+	}
+}
+
+func TestNotFoundHandlerOpt(t *testing.T) {
+	doc := loadDoc()
+
+	handlers := OperationHandlers{
+		"getPetById": http.HandlerFunc(func(w http.ResponseWriter, req *http.Request) {
+			fmt.Fprint(w, "pet")
+		}),
+	}
+
+	fallback := http.HandlerFunc(func(w http.ResponseWriter, req *http.Request) {
+		fmt.Fprint(w, "upstream")
+	})
+
+	router, err := NewRouter(doc.Spec(), handlers, NotFoundHandlerOpt(fallback))
+	if err != nil {
+		t.Fatal(err)
+	}
+
+	cases := []struct {
+		path            string
+		expectedPayload string
+	}{
+		// documented path
+		{
+			path:            "/v2/pet/12",
+			expectedPayload: "pet",
+		},
+		// undocumented path
+		{
+			path:            "/v2/unknown",
+			expectedPayload: "upstream",
+		},
+	}
+
+	for _, c := range cases {
+		w := httptest.NewRecorder()
+		router.ServeHTTP(w, httptest.NewRequest(http.MethodGet, c.path, nil))
+
+		if w.Body.String() != c.expectedPayload {
+			t.Errorf("Expected response body for %s to be %s but got %s", c.path, c.expectedPayload, w.Body.String())
+		}
+	}
+}
+
+func TestMethodOverrideOpt(t *testing.T) {
+	sw := parseSpec(`
+swagger: "2.0"
+info:
+  title: test
+  version: "1.0"
+basePath: /v1
+paths:
+  /items:
+    get:
+      operationId: listItems
+      responses:
+        200:
+          description: ok
+    post:
+      operationId: addItem
+      responses:
+        200:
+          description: ok
+    delete:
+      operationId: deleteItems
+      responses:
+        200:
+          description: ok
+`)
+
+	handlers := OperationHandlers{}
+	for _, id := range []OperationID{"listItems", "addItem", "deleteItems"} {
+		id := id
+		handlers[id] = http.HandlerFunc(func(w http.ResponseWriter, req *http.Request) {
+			fmt.Fprint(w, id)
+		})
+	}
+
+	router, err := NewRouter(sw, handlers, MethodOverrideOpt())
+	if err != nil {
+		t.Fatal(err)
+	}
+
+	cases := []struct {
+		method          string
+		override        string
+		expectedPayload string
+	}{
+		// no override
+		{
+			method:          http.MethodPost,
+			expectedPayload: "addItem",
+		},
+		// allowed override
+		{
+			method:          http.MethodPost,
+			override:        "delete",
+			expectedPayload: "deleteItems",
+		},
+		// override to a method not allowed is ignored
+		{
+			method:          http.MethodPost,
+			override:        http.MethodGet,
+			expectedPayload: "addItem",
+		},
+		// only POST requests can be overridden
+		{
+			method:          http.MethodGet,
+			override:        http.MethodDelete,
+			expectedPayload: "listItems",
+		},
+	}
+
+	for _, c := range cases {
+		req := httptest.NewRequest(c.method, "/v1/items", nil)
+		if c.override != "" {
+			req.Header.Set("X-HTTP-Method-Override", c.override)
+		}
+
+		w := httptest.NewRecorder()
+		router.ServeHTTP(w, req)
+
+		if w.Body.String() != c.expectedPayload {
+			t.Errorf("Expected response body for %s overridden with %q to be %s but got %s", c.method, c.override, c.expectedPayload, w.Body.String())
+		}
+	}
+}
+
+func TestNotFoundResponderOpt(t *testing.T) {
+	doc := loadDoc()
+
+	router, err := NewRouter(doc.Spec(), OperationHandlers{}, NotFoundResponderOpt(writeErrorsToResponseWriter))
+	if err != nil {
+		t.Fatal(err)
+	}
+
+	w := httptest.NewRecorder()
+	router.ServeHTTP(w, httptest.NewRequest(http.MethodGet, "/v2/unknown", nil))
+
+	if w.Code != http.StatusNotFound {
+		t.Errorf("Expected status to be %d but got %d", http.StatusNotFound, w.Code)
+	}
+
+	expectedPayload := `{"errors":[{"message":"Path /v2/unknown is not found"}]}`
+	if w.Body.String() != expectedPayload {
+		t.Errorf("Expected response body to be\n%s\nbut got\n%s", expectedPayload, w.Body.String())
+	}
+}
+
+func TestMethodNotAllowedResponderOpt(t *testing.T) {
+	sw := parseSpec(`
+swagger: "2.0"
+info:
+  title: test
+  version: "1.0"
+basePath: /v1
+paths:
+  /pets/{id}:
+    parameters:
+    - name: id
+      in: path
+      required: true
+      type: integer
+    options:
+      operationId: petOptions
+      responses:
+        200:
+          description: ok
+    delete:
+      operationId: deletePet
+      responses:
+        204:
+          description: deleted
+    put:
+      operationId: replacePet
+      responses:
+        200:
+          description: ok
+    patch:
+      operationId: updatePet
+      responses:
+        200:
+          description: ok
+    get:
+      operationId: getPet
+      responses:
+        200:
+          description: ok
+`)
+
+	ok := http.HandlerFunc(func(w http.ResponseWriter, req *http.Request) {})
+	handlers := OperationHandlers{
+		"petOptions": ok,
+		"deletePet":  ok,
+		"replacePet": ok,
+		"updatePet":  ok,
+		"getPet":     ok,
+	}
+
+	router, err := NewRouter(sw, handlers, MethodNotAllowedResponderOpt(writeErrorsToResponseWriter))
+	if err != nil {
+		t.Fatal(err)
+	}
+
+	w := httptest.NewRecorder()
+	router.ServeHTTP(w, httptest.NewRequest(http.MethodPost, "/v1/pets/12", nil))
+
+	if w.Code != http.StatusMethodNotAllowed {
+		t.Errorf("Expected status to be %d but got %d", http.StatusMethodNotAllowed, w.Code)
+	}
+
+	expectedAllow := "GET, PUT, PATCH, DELETE, OPTIONS"
+	if actual := w.Header().Get("Allow"); actual != expectedAllow {
+		t.Errorf("Expected Allow header to be %q but got %q", expectedAllow, actual)
+	}
+
+	expectedPayload := `{"errors":[{"message":"Method POST is not allowed for path /v1/pets/12"}]}`
+	if w.Body.String() != expectedPayload {
+		t.Errorf("Expected response body to be\n%s\nbut got\n%s", expectedPayload, w.Body.String())
+	}
+}
+
+func TestMethodNotAllowedResponderOpt_overlappingPaths(t *testing.T) {
+	sw := parseSpec(`
+swagger: "2.0"
+info:
+  title: test
+  version: "1.0"
+basePath: /v1
+paths:
+  /pets/{id}:
+    parameters:
+    - name: id
+      in: path
+      required: true
+      type: string
+    get:
+      operationId: getPet
+      responses:
+        200:
+          description: ok
+    delete:
+      operationId: deletePet
+      responses:
+        204:
+          description: deleted
+  /pets/mine:
+    get:
+      operationId: getMyPets
+      responses:
+        200:
+          description: ok
+`)
+
+	ok := http.HandlerFunc(func(w http.ResponseWriter, req *http.Request) {})
+	handlers := OperationHandlers{
+		"getPet":    ok,
+		"deletePet": ok,
+		"getMyPets": ok,
+	}
+
+	// Templates are collected from a map, so repeat to catch the order
+	// dependence.
+	for i := 0; i < 10; i++ {
+		router, err := NewRouter(sw, handlers, MethodNotAllowedResponderOpt(writeErrorsToResponseWriter))
+		if err != nil {
+			t.Fatal(err)
+		}
+
+		w := httptest.NewRecorder()
+		router.ServeHTTP(w, httptest.NewRequest(http.MethodPost, "/v1/pets/mine", nil))
+
+		if w.Code != http.StatusMethodNotAllowed {
+			t.Fatalf("Expected status to be %d but got %d", http.StatusMethodNotAllowed, w.Code)
+		}
+		if actual := w.Header().Get("Allow"); actual != "GET" {
+			t.Fatalf("Expected Allow header to be %q but got %q", "GET", actual)
+		}
+	}
+}
+
+func TestRouter_Use(t *testing.T) {
+	doc := loadDoc()
+
+	handlers := OperationHandlers{
+		"getPetById": http.HandlerFunc(func(w http.ResponseWriter, req *http.Request) {
+			fmt.Fprint(w, "pet")
+		}),
+		"loginUser": http.HandlerFunc(func(w http.ResponseWriter, req *http.Request) {
+			fmt.Fprint(w, "user")
+		}),
+	}
+
+	router, err := NewRouter(doc.Spec(), handlers)
+	if err != nil {
+		t.Fatal(err)
+	}
+
+	serve := func(target string) *httptest.ResponseRecorder {
+		w := httptest.NewRecorder()
+		router.ServeHTTP(w, httptest.NewRequest(http.MethodGet, target, nil))
+		return w
+	}
+
+	if w := serve("/v2/pet/12"); w.Header().Get("X-Canary") != "" {
+		t.Errorf("Expected no canary header before Use but got %q", w.Header().Get("X-Canary"))
+	}
+
+	canary := func(next http.Handler) http.Handler {
+		return http.HandlerFunc(func(w http.ResponseWriter, req *http.Request) {
+			w.Header().Set("X-Canary", GetOperation(req).ID)
+			next.ServeHTTP(w, req)
+		})
+	}
+	if err := router.Use("getPetById", canary); err != nil {
+		t.Fatal(err)
+	}
+
+	w := serve("/v2/pet/12")
+	if actual := w.Header().Get("X-Canary"); actual != "getPetById" {
+		t.Errorf("Expected canary header to be %q but got %q", "getPetById", actual)
+	}
+	if w.Body.String() != "pet" {
+		t.Errorf("Expected response body to be %q but got %q", "pet", w.Body.String())
+	}
+
+	if actual := serve("/v2/user/login?username=john&password=123").Header().Get("X-Canary"); actual != "" {
+		t.Errorf("Expected other operations not to be affected but got canary header %q", actual)
+	}
+
+	if err := router.Use("addPet", canary); err == nil {
+		t.Error("Expected error for operation without handler but got nil")
+	}
+}
+
+func TestRouter_Drain(t *testing.T) {
+	doc := loadDoc()
+
+	started := make(chan struct{})
+	finish := make(chan struct{})
+	handlers := OperationHandlers{
+		"getPetById": http.HandlerFunc(func(w http.ResponseWriter, req *http.Request) {
+			close(started)
+			<-finish
+			fmt.Fprint(w, "pet")
+		}),
+	}
+
+	router, err := NewRouter(doc.Spec(), handlers)
+	if err != nil {
+		t.Fatal(err)
+	}
+
+	server := httptest.NewServer(router)
+	defer server.Close()
+	client := server.Client()
+
+	// Start a request that is in flight until finish is closed.
+	done := make(chan string)
+	go func() {
+		resp, err := client.Get(server.URL + "/v2/pet/12")
+		if err != nil {
+			done <- err.Error()
+			return
+		}
+		b, _ := ioutil.ReadAll(resp.Body)
+		done <- string(b)
+	}()
+	<-started
+
+	if router.InFlight() != 1 {
+		t.Fatalf("Expected 1 request in flight but got %d", router.InFlight())
+	}
+
+	// Drain is interrupted by the context while the request is in flight.
+	ctx, cancel := context.WithTimeout(context.Background(), 10*time.Millisecond)
+	defer cancel()
+	if err := router.Drain(ctx); err != context.DeadlineExceeded {
+		t.Fatalf("Expected drain error to be %v but got %v", context.DeadlineExceeded, err)
+	}
+
+	// New requests are rejected while draining.
+	resp, err := client.Get(server.URL + "/v2/pet/13")
+	if err != nil {
+		t.Fatal(err)
+	}
+	if resp.StatusCode != http.StatusServiceUnavailable {
+		t.Fatalf("Expected status code to be %d but got %d", http.StatusServiceUnavailable, resp.StatusCode)
+	}
+
+	// Drain completes when the request in flight is served.
+	drainErr := make(chan error)
+	go func() {
+		drainErr <- router.Drain(context.Background())
+	}()
+	close(finish)
+
+	if body := <-done; body != "pet" {
+		t.Fatalf("Expected in flight request to be served but got %q", body)
+	}
+	if err := <-drainErr; err != nil {
+		t.Fatalf("Unexpected drain error: %v", err)
+	}
+	if router.InFlight() != 0 {
+		t.Fatalf("Expected no requests in flight but got %d", router.InFlight())
+	}
+}
+
+func TestOperationErrHandlerOpt(t *testing.T) {
+	doc := loadDoc()
+
+	okHandler := http.HandlerFunc(func(w http.ResponseWriter, req *http.Request) {
+		fmt.Fprint(w, "ok")
+	})
+	handlers := OperationHandlers{
+		"loginUser":  okHandler,
+		"getPetById": okHandler,
+	}
+
+	legacyErrHandler := func(w http.ResponseWriter, errs []error) {
+		w.WriteHeader(http.StatusBadRequest)
+		fmt.Fprintf(w, "error: %s", errs[0])
+	}
+
+	qv := NewQueryValidator(writeErrorsToResponseWriter)
+	router, err := NewRouter(
+		doc.Spec(),
+		handlers,
+		MiddlewareOpt(qv.Apply),
+		OperationErrHandlerOpt("loginUser", legacyErrHandler),
+	)
+	if err != nil {
+		t.Fatal(err)
+	}
+
+	cases := []struct {
+		url             string
+		expectedPayload string
+	}{
+		// operation error handler
+		{
+			url:             "/v2/user/login?username=johndoe",
+			expectedPayload: "error: param password is required",
+		},
+		// global error handler
+		{
+			url:             "/v2/pet/12?foo=bar",
+			expectedPayload: `{"errors":[{"message":"parameter foo is unknown","field":"foo","value":"bar"}]}`,
+		},
+	}
+
+	for _, c := range cases {
+		w := httptest.NewRecorder()
+		router.ServeHTTP(w, httptest.NewRequest(http.MethodGet, c.url, nil))
+
+		if c.expectedPayload != w.Body.String() {
+			t.Errorf("Expected response body to be\n%s\nbut got\n%s", c.expectedPayload, w.Body.String())
+		}
+	}
+}
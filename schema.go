@@ -0,0 +1,765 @@
+package oas2
+
+import (
+	"bytes"
+	"context"
+	"encoding/json"
+	"fmt"
+	"net/http"
+	"regexp"
+	"strings"
+	"sync"
+
+	"github.com/go-openapi/errors"
+	"github.com/go-openapi/spec"
+	"github.com/go-openapi/strfmt"
+	"github.com/go-openapi/validate"
+)
+
+// CompiledSchema is a schema prepared for validation once, so it can be
+// reused to validate data many times. It is safe for concurrent use.
+type CompiledSchema struct {
+	schema    *spec.Schema
+	validator *validate.SchemaValidator
+}
+
+// CompileSchema prepares the schema for validation and returns an error if
+// the schema cannot be used for it, e.g. it has an invalid pattern.
+func CompileSchema(sch *spec.Schema) (*CompiledSchema, error) {
+	return compileSchema(sch, strfmt.Default)
+}
+
+// compileSchema is like CompileSchema but validates formats known to the
+// registry only.
+func compileSchema(sch *spec.Schema, formats strfmt.Registry) (*CompiledSchema, error) {
+	if sch == nil {
+		return nil, fmt.Errorf("schema is nil")
+	}
+
+	if err := checkSchemaPatterns(sch, ""); err != nil {
+		return nil, err
+	}
+
+	sch = nullableItemsSchema(sch)
+	return &CompiledSchema{
+		schema:    sch,
+		validator: validate.NewSchemaValidator(sch, nil, "", formats),
+	}, nil
+}
+
+// Validate validates data by the schema and returns errors if any.
+func (c *CompiledSchema) Validate(data interface{}) []error {
+	return plainSchemaErrors(c.validate(data, "body")).Errors()
+}
+
+// validate is like Validate but names the data itself in errors as root.
+func (c *CompiledSchema) validate(data interface{}, root string) ValidationErrors {
+	return schemaErrors(c.schema, data, c.validator.Validate(data).AsError(), root)
+}
+
+// schemaErrors converts errors of validation of data by schema to
+// ValidationErrors. root is used to name the data itself in errors, e.g.
+// when data is a primitive or an array.
+func schemaErrors(sch *spec.Schema, data interface{}, err error, root string) (errs ValidationErrors) {
+	ves, ok := err.(*errors.CompositeError)
+	if !ok {
+		return nil
+	}
+
+	for _, e := range ves.Errors {
+		ve, ok := e.(*errors.Validation)
+		if !ok {
+			errs = append(errs, ValidationErrorf("", nil, "%s", e.Error()))
+			continue
+		}
+
+		field := strings.TrimPrefix(ve.Name, ".")
+		message := strings.TrimPrefix(ve.Error(), ".")
+		if field == "" && ve.Code() != errors.UnallowedPropertyCode {
+			// Errors on the root value have no name. Messages of
+			// forbidden properties name the property instead.
+			message = root + message
+		}
+
+		sub := schemaAt(sch, field)
+		if ve.Code() == errors.EnumFailCode && ve.Value == nil && hasNullableEnumItems(sub) {
+			continue
+		}
+
+		keyword, expected := schemaKeyword(ve, sub)
+
+		actual := ve.Value
+		if isSensitiveSchema(sub) {
+			message = sensitiveMessage(field, keyword)
+			actual = nil
+		}
+
+		if keyword == "minProperties" || keyword == "maxProperties" {
+			// The value of these errors is the limit, so the object
+			// is looked up to report the actual count.
+			actual = nil
+			if obj, ok := valueAt(data, field).(map[string]interface{}); ok {
+				message = fmt.Sprintf("%s, has %d", message, len(obj))
+				if !isSensitiveSchema(sub) {
+					actual = obj
+				}
+			}
+		}
+
+		errs = append(errs, schemaErr{
+			valErr: valErr{
+				message: message,
+				field:   field,
+			},
+			path:     jsonPointer(field),
+			keyword:  keyword,
+			expected: expected,
+			actual:   actual,
+		})
+	}
+
+	return errs
+}
+
+// SchemaValidationError is a ValidationError of data validated by schema.
+// It describes the failed schema keyword in a structured way. Errors of
+// ValidateBySchemaDetailed and of the body and response validators
+// implement it.
+type SchemaValidationError interface {
+	ValidationError
+
+	// Path returns JSON pointer to the invalid value in the data, e.g.
+	// "/tags/0". It is empty for the data itself.
+	Path() string
+
+	// Keyword returns the schema keyword the value fails, e.g. "required"
+	// or "maximum". It is empty if unknown.
+	Keyword() string
+
+	// Expected returns the value of the keyword in the schema, e.g. 100
+	// for "maximum", or nil if there is none.
+	Expected() interface{}
+
+	// Actual returns the invalid value, or nil if it is unknown or
+	// sensitive.
+	Actual() interface{}
+}
+
+type schemaErr struct {
+	valErr
+	path     string
+	keyword  string
+	expected interface{}
+	actual   interface{}
+}
+
+func (e schemaErr) Path() string {
+	return e.path
+}
+
+func (e schemaErr) Keyword() string {
+	return e.keyword
+}
+
+func (e schemaErr) Expected() interface{} {
+	return e.expected
+}
+
+func (e schemaErr) Actual() interface{} {
+	return e.actual
+}
+
+// plainSchemaErrors returns errs with schema validation errors replaced by
+// plain ValidationError values, so they compare equal to errors made by
+// ValidationErrorf.
+func plainSchemaErrors(errs ValidationErrors) ValidationErrors {
+	for i, err := range errs {
+		if se, ok := err.(schemaErr); ok {
+			errs[i] = se.valErr
+		}
+	}
+	return errs
+}
+
+// ErrorsToJSONPointers returns JSON pointers to the invalid values of errs,
+// e.g. "/address/zip", in the same order. Errors that are not
+// SchemaValidationError, e.g. errors of query parameters, map to an empty
+// string, as do errors of the data itself. Errors of array items point to the
+// array, unless validation reports indexes of the items, see StreamArraysOpt.
+func ErrorsToJSONPointers(errs []error) []string {
+	pointers := make([]string, len(errs))
+	for i, err := range errs {
+		if se, ok := err.(SchemaValidationError); ok {
+			pointers[i] = se.Path()
+		}
+	}
+	return pointers
+}
+
+// schemaKeywords maps codes of go-openapi validation errors to keywords.
+var schemaKeywords = map[int32]string{
+	errors.InvalidTypeCode:           "type",
+	errors.RequiredFailCode:          "required",
+	errors.TooLongFailCode:           "maxLength",
+	errors.TooShortFailCode:          "minLength",
+	errors.PatternFailCode:           "pattern",
+	errors.EnumFailCode:              "enum",
+	errors.MultipleOfFailCode:        "multipleOf",
+	errors.MaxFailCode:               "maximum",
+	errors.MinFailCode:               "minimum",
+	errors.UniqueFailCode:            "uniqueItems",
+	errors.MaxItemsFailCode:          "maxItems",
+	errors.MinItemsFailCode:          "minItems",
+	errors.NoAdditionalItemsCode:     "additionalItems",
+	errors.TooFewPropertiesCode:      "minProperties",
+	errors.TooManyPropertiesCode:     "maxProperties",
+	errors.UnallowedPropertyCode:     "additionalProperties",
+	errors.FailedAllPatternPropsCode: "patternProperties",
+	errors.ReadOnlyFailCode:          "readOnly",
+}
+
+// invalidTypeName matches the type name in messages of go-openapi invalid
+// type errors.
+var invalidTypeName = regexp.MustCompile(`must be of type ([^:]+)`)
+
+// jsonTypes are types of JSON Schema.
+var jsonTypes = map[string]struct{}{
+	"string":  {},
+	"number":  {},
+	"integer": {},
+	"boolean": {},
+	"array":   {},
+	"object":  {},
+	"null":    {},
+}
+
+// schemaKeyword returns the keyword of the schema sub the error is for and
+// its value in the schema.
+func schemaKeyword(ve *errors.Validation, sub *spec.Schema) (keyword string, expected interface{}) {
+	keyword = schemaKeywords[ve.Code()]
+	if keyword == "type" {
+		// Invalid formats are reported as invalid types, e.g. "must be of
+		// type email", and the schema can be the one of array items.
+		if m := invalidTypeName.FindStringSubmatch(ve.Error()); m != nil {
+			if _, ok := jsonTypes[m[1]]; !ok {
+				return "format", m[1]
+			}
+		}
+	}
+	return keyword, schemaExpected(keyword, sub)
+}
+
+// schemaExpected returns the value of the keyword in the schema.
+func schemaExpected(keyword string, sub *spec.Schema) interface{} {
+	if sub == nil {
+		return nil
+	}
+
+	switch keyword {
+	case "type":
+		return []string(sub.Type)
+	case "maxLength":
+		return derefInt(sub.MaxLength)
+	case "minLength":
+		return derefInt(sub.MinLength)
+	case "pattern":
+		return sub.Pattern
+	case "enum":
+		return sub.Enum
+	case "multipleOf":
+		return derefFloat(sub.MultipleOf)
+	case "maximum":
+		return derefFloat(sub.Maximum)
+	case "minimum":
+		return derefFloat(sub.Minimum)
+	case "uniqueItems":
+		return sub.UniqueItems
+	case "maxItems":
+		return derefInt(sub.MaxItems)
+	case "minItems":
+		return derefInt(sub.MinItems)
+	case "maxProperties":
+		return derefInt(sub.MaxProperties)
+	case "minProperties":
+		return derefInt(sub.MinProperties)
+	default:
+		return nil
+	}
+}
+
+func derefInt(v *int64) interface{} {
+	if v == nil {
+		return nil
+	}
+	return *v
+}
+
+func derefFloat(v *float64) interface{} {
+	if v == nil {
+		return nil
+	}
+	return *v
+}
+
+// jsonPointer converts the field name of a validation error, e.g.
+// "tags.0", to JSON pointer, e.g. "/tags/0".
+func jsonPointer(field string) string {
+	if field == "" {
+		return ""
+	}
+
+	var b bytes.Buffer
+	for _, name := range strings.Split(field, ".") {
+		name = strings.Replace(name, "~", "~0", -1)
+		name = strings.Replace(name, "/", "~1", -1)
+		b.WriteString("/")
+		b.WriteString(name)
+	}
+	return b.String()
+}
+
+// checkSchemaPatterns checks that all patterns in the schema and its
+// subschemas are valid regular expressions and compiles them.
+func checkSchemaPatterns(sch *spec.Schema, field string) error {
+	if sch.Pattern != "" {
+		if err := compilePattern(sch.Pattern); err != nil {
+			return fmt.Errorf("schema%s: invalid pattern %q: %s", fieldSuffix(field), sch.Pattern, err)
+		}
+	}
+
+	for name, prop := range sch.Properties {
+		prop := prop
+		if err := checkSchemaPatterns(&prop, joinField(field, name)); err != nil {
+			return err
+		}
+	}
+
+	for pattern, prop := range sch.PatternProperties {
+		if err := compilePattern(pattern); err != nil {
+			return fmt.Errorf("schema%s: invalid property pattern %q: %s", fieldSuffix(field), pattern, err)
+		}
+		prop := prop
+		if err := checkSchemaPatterns(&prop, joinField(field, pattern)); err != nil {
+			return err
+		}
+	}
+
+	if sch.AdditionalProperties != nil && sch.AdditionalProperties.Schema != nil {
+		if err := checkSchemaPatterns(sch.AdditionalProperties.Schema, field); err != nil {
+			return err
+		}
+	}
+
+	if sch.Items != nil {
+		if sch.Items.Schema != nil {
+			if err := checkSchemaPatterns(sch.Items.Schema, joinField(field, "items")); err != nil {
+				return err
+			}
+		}
+		for i := range sch.Items.Schemas {
+			if err := checkSchemaPatterns(&sch.Items.Schemas[i], joinField(field, "items")); err != nil {
+				return err
+			}
+		}
+	}
+
+	for _, subs := range [][]spec.Schema{sch.AllOf, sch.AnyOf, sch.OneOf} {
+		for i := range subs {
+			if err := checkSchemaPatterns(&subs[i], field); err != nil {
+				return err
+			}
+		}
+	}
+
+	return nil
+}
+
+// compilePattern checks that the pattern is a valid regular expression and
+// compiles it into the cache shared by go-openapi validators, so it is not
+// compiled per request. It is also cached for matching names of pattern
+// properties, see patternRegexp.
+func compilePattern(pattern string) error {
+	if _, err := patternRegexp(pattern); err != nil {
+		return err
+	}
+	validate.Pattern("", "", "", pattern)
+	return nil
+}
+
+// patterns caches compiled patterns by their source.
+var patterns sync.Map
+
+// patternRegexp returns the compiled pattern, compiling it once.
+func patternRegexp(pattern string) (*regexp.Regexp, error) {
+	if re, ok := patterns.Load(pattern); ok {
+		return re.(*regexp.Regexp), nil
+	}
+
+	re, err := regexp.Compile(pattern)
+	if err != nil {
+		return nil, err
+	}
+	patterns.Store(pattern, re)
+	return re, nil
+}
+
+func joinField(field, name string) string {
+	if field == "" {
+		return name
+	}
+	return field + "." + name
+}
+
+func fieldSuffix(field string) string {
+	if field == "" {
+		return ""
+	}
+	return " " + field
+}
+
+// requestSchema returns a copy of the schema for validation of requests:
+// readOnly properties are not required, as they are only sent in responses.
+func requestSchema(sch *spec.Schema) *spec.Schema {
+	if sch == nil {
+		return nil
+	}
+	rs := withoutReadOnlyRequired(*sch)
+	return &rs
+}
+
+func withoutReadOnlyRequired(sch spec.Schema) spec.Schema {
+	if len(sch.Properties) > 0 {
+		props := make(map[string]spec.Schema, len(sch.Properties))
+		readOnly := make(map[string]struct{})
+		for name, prop := range sch.Properties {
+			props[name] = withoutReadOnlyRequired(prop)
+			if prop.ReadOnly {
+				readOnly[name] = struct{}{}
+			}
+		}
+		sch.Properties = props
+
+		if len(readOnly) > 0 {
+			required := make([]string, 0, len(sch.Required))
+			for _, name := range sch.Required {
+				if _, ok := readOnly[name]; !ok {
+					required = append(required, name)
+				}
+			}
+			sch.Required = required
+		}
+	}
+
+	if sch.Items != nil {
+		items := *sch.Items
+		if items.Schema != nil {
+			s := withoutReadOnlyRequired(*items.Schema)
+			items.Schema = &s
+		}
+		items.Schemas = withoutReadOnlyRequiredAll(items.Schemas)
+		sch.Items = &items
+	}
+
+	if sch.AdditionalProperties != nil && sch.AdditionalProperties.Schema != nil {
+		ap := *sch.AdditionalProperties
+		s := withoutReadOnlyRequired(*ap.Schema)
+		ap.Schema = &s
+		sch.AdditionalProperties = &ap
+	}
+
+	sch.AllOf = withoutReadOnlyRequiredAll(sch.AllOf)
+	sch.AnyOf = withoutReadOnlyRequiredAll(sch.AnyOf)
+	sch.OneOf = withoutReadOnlyRequiredAll(sch.OneOf)
+
+	return sch
+}
+
+func withoutReadOnlyRequiredAll(schs []spec.Schema) []spec.Schema {
+	if schs == nil {
+		return nil
+	}
+	res := make([]spec.Schema, len(schs))
+	for i, sch := range schs {
+		res[i] = withoutReadOnlyRequired(sch)
+	}
+	return res
+}
+
+// extNullable is a schema extension that allows null values when set to
+// true. It is respected for items of arrays, so arrays may contain null
+// elements.
+const extNullable = "x-nullable"
+
+// nullableItemsSchema returns a copy of the schema where items declared
+// x-nullable accept null elements.
+func nullableItemsSchema(sch *spec.Schema) *spec.Schema {
+	if sch == nil {
+		return nil
+	}
+	ns := withNullableItems(*sch)
+	return &ns
+}
+
+func withNullableItems(sch spec.Schema) spec.Schema {
+	if len(sch.Properties) > 0 {
+		props := make(map[string]spec.Schema, len(sch.Properties))
+		for name, prop := range sch.Properties {
+			props[name] = withNullableItems(prop)
+		}
+		sch.Properties = props
+	}
+
+	if sch.Items != nil {
+		items := *sch.Items
+		if items.Schema != nil {
+			s := asNullableItem(withNullableItems(*items.Schema))
+			items.Schema = &s
+		}
+		if items.Schemas != nil {
+			schs := make([]spec.Schema, len(items.Schemas))
+			for i, s := range items.Schemas {
+				schs[i] = asNullableItem(withNullableItems(s))
+			}
+			items.Schemas = schs
+		}
+		sch.Items = &items
+	}
+
+	if sch.AdditionalProperties != nil && sch.AdditionalProperties.Schema != nil {
+		ap := *sch.AdditionalProperties
+		s := withNullableItems(*ap.Schema)
+		ap.Schema = &s
+		sch.AdditionalProperties = &ap
+	}
+
+	sch.AllOf = withNullableItemsAll(sch.AllOf)
+	sch.AnyOf = withNullableItemsAll(sch.AnyOf)
+	sch.OneOf = withNullableItemsAll(sch.OneOf)
+
+	return sch
+}
+
+func withNullableItemsAll(schs []spec.Schema) []spec.Schema {
+	if schs == nil {
+		return nil
+	}
+	res := make([]spec.Schema, len(schs))
+	for i, sch := range schs {
+		res[i] = withNullableItems(sch)
+	}
+	return res
+}
+
+// asNullableItem makes the item schema accept null if it is declared
+// x-nullable.
+func asNullableItem(sch spec.Schema) spec.Schema {
+	if nullable, _ := sch.Extensions.GetBool(extNullable); nullable {
+		sch.Nullable = true
+	}
+	return sch
+}
+
+// hasNullableEnumItems reports whether the array schema has nullable items
+// with enum. Enum rejects null elements of such items anyway, and as errors
+// of items are named after the array, they are recognized by the schema.
+func hasNullableEnumItems(sch *spec.Schema) bool {
+	if sch == nil || sch.Items == nil || len(sch.Enum) > 0 {
+		return false
+	}
+	items := sch.Items.Schemas
+	if sch.Items.Schema != nil {
+		items = []spec.Schema{*sch.Items.Schema}
+	}
+	for _, item := range items {
+		if item.Nullable && len(item.Enum) > 0 {
+			return true
+		}
+	}
+	return false
+}
+
+// schemaCache compiles schemas on first use and keeps them for reuse.
+type schemaCache struct {
+	m sync.Map // *spec.Schema -> *CompiledSchema
+
+	// prepare, if set, returns the schema to compile instead of the
+	// original one, e.g. requestSchema.
+	prepare func(sch *spec.Schema) *spec.Schema
+
+	// formats are formats validated, strfmt.Default if nil.
+	formats strfmt.Registry
+}
+
+// validate validates data by the schema, compiling it if not done yet.
+// Schemas compiled by the router are used if found in compiled, unless the
+// cache validates other formats. Schemas that cannot be compiled are
+// validated without compilation.
+func (c *schemaCache) validate(compiled compiledSchemas, sch *spec.Schema, data interface{}, root string) ValidationErrors {
+	if cs, ok := compiled[sch]; ok && (c.formats == nil || c.formats == strfmt.Default) {
+		return cs.validate(data, root)
+	}
+
+	if v, ok := c.m.Load(sch); ok {
+		return v.(*CompiledSchema).validate(data, root)
+	}
+
+	prepared := sch
+	if c.prepare != nil {
+		prepared = c.prepare(sch)
+	}
+
+	formats := c.formats
+	if formats == nil {
+		formats = strfmt.Default
+	}
+
+	cs, err := compileSchema(prepared, formats)
+	if err != nil {
+		return validatebySchema(prepared, data, root, formats)
+	}
+
+	v, _ := c.m.LoadOrStore(sch, cs)
+	return v.(*CompiledSchema).validate(data, root)
+}
+
+// compiledSchemas maps schemas of an operation to their compiled versions.
+type compiledSchemas map[*spec.Schema]*CompiledSchema
+
+// operationSchemas are schemas of an operation compiled by the router, so
+// requests are validated without compilation.
+type operationSchemas struct {
+	// request are schemas of body parameters, compiled for validation of
+	// requests, see requestSchema.
+	request compiledSchemas
+
+	// response are schemas of responses and, for operations producing
+	// NDJSON streams, their records.
+	response compiledSchemas
+
+	// consumes are schemas of body parameters by media type, declared by
+	// x-consumes-schema extension. They are compiled in request.
+	consumes map[string]map[string]*spec.Schema
+}
+
+// getOperationSchemas returns schemas of the request's operation compiled
+// by the router, if any.
+func getOperationSchemas(req *http.Request) *operationSchemas {
+	if s, ok := req.Context().Value(contextKeyOperationSchemas{}).(*operationSchemas); ok {
+		return s
+	}
+	return &operationSchemas{}
+}
+
+type contextKeyOperationSchemas struct{}
+
+func operationSchemasMiddleware(next http.Handler, schemas *operationSchemas) http.Handler {
+	return http.HandlerFunc(func(w http.ResponseWriter, req *http.Request) {
+		req = req.WithContext(
+			context.WithValue(req.Context(), contextKeyOperationSchemas{}, schemas),
+		)
+		next.ServeHTTP(w, req)
+	})
+}
+
+// compileOperationSchemas compiles body parameter and response schemas of
+// the operation. References in the schemas are resolved against the spec.
+// It returns errors for schemas that cannot be compiled.
+func compileOperationSchemas(sw *spec.Swagger, op *spec.Operation) (*operationSchemas, []error) {
+	schemas := &operationSchemas{
+		request:  make(compiledSchemas),
+		response: make(compiledSchemas),
+	}
+
+	var errs []error
+	for _, p := range op.Parameters {
+		if p.In != "body" || p.Schema == nil {
+			continue
+		}
+		cs, err := compileSpecSchema(sw, p.Schema, requestSchema)
+		if err != nil {
+			errs = append(errs, fmt.Errorf("operation %s: parameter %s: %s", op.ID, p.Name, err))
+			continue
+		}
+		schemas.request[p.Schema] = cs
+
+		// Items of arrays are compiled for validation of streamed arrays,
+		// see StreamArraysOpt.
+		if items := p.Schema.Items; items != nil && items.Schema != nil {
+			if cs, err := compileSpecSchema(sw, items.Schema, requestSchema); err == nil {
+				schemas.request[items.Schema] = cs
+			}
+		}
+
+		byMediaType, err := consumesSchemas(p)
+		if err != nil {
+			errs = append(errs, fmt.Errorf("operation %s: parameter %s: %s", op.ID, p.Name, err))
+			continue
+		}
+		for mt, sch := range byMediaType {
+			cs, err := compileSpecSchema(sw, sch, requestSchema)
+			if err != nil {
+				errs = append(errs, fmt.Errorf("operation %s: parameter %s: %s %s", op.ID, p.Name, mt, err))
+				continue
+			}
+			schemas.request[sch] = cs
+		}
+		if len(byMediaType) > 0 {
+			if schemas.consumes == nil {
+				schemas.consumes = make(map[string]map[string]*spec.Schema)
+			}
+			schemas.consumes[p.Name] = byMediaType
+		}
+	}
+
+	forEachResponseSchema(op, func(name string, sch *spec.Schema) {
+		cs, err := compileSpecSchema(sw, sch, nil)
+		if err != nil {
+			errs = append(errs, fmt.Errorf("operation %s: %s: %s", op.ID, name, err))
+			return
+		}
+		schemas.response[sch] = cs
+
+		if producesNDJSON(op.Produces) {
+			if rs := recordSchema(sch); rs != sch {
+				if cs, err := compileSpecSchema(sw, rs, nil); err == nil {
+					schemas.response[rs] = cs
+				}
+			}
+		}
+	})
+
+	return schemas, errs
+}
+
+// compileSpecSchema compiles a copy of the schema with references resolved
+// against the spec, prepared by prepare if set.
+func compileSpecSchema(sw *spec.Swagger, sch *spec.Schema, prepare func(*spec.Schema) *spec.Schema) (*CompiledSchema, error) {
+	prepared, err := expandSpecSchema(sw, sch)
+	if err != nil {
+		return nil, err
+	}
+	if prepare != nil {
+		prepared = prepare(prepared)
+	}
+	return CompileSchema(prepared)
+}
+
+// expandSpecSchema returns a copy of the schema with references resolved
+// against the spec.
+func expandSpecSchema(sw *spec.Swagger, sch *spec.Schema) (*spec.Schema, error) {
+	// The schema is expanded in place, so expand a copy to keep the spec
+	// intact.
+	b, err := json.Marshal(sch)
+	if err != nil {
+		return nil, err
+	}
+	var expanded spec.Schema
+	if err := json.Unmarshal(b, &expanded); err != nil {
+		return nil, err
+	}
+
+	if err := spec.ExpandSchema(&expanded, sw, nil); err != nil {
+		return nil, fmt.Errorf("schema: %s", err)
+	}
+	return &expanded, nil
+}
@@ -0,0 +1,149 @@
+package oas2
+
+import (
+	"reflect"
+	"sync"
+	"testing"
+
+	"github.com/go-openapi/spec"
+)
+
+func TestCompileSchema(t *testing.T) {
+	t.Run("nil schema", func(t *testing.T) {
+		if _, err := CompileSchema(nil); err == nil {
+			t.Errorf("Expected error but got nil")
+		}
+	})
+
+	t.Run("invalid pattern", func(t *testing.T) {
+		sch := &spec.Schema{
+			SchemaProps: spec.SchemaProps{
+				Type: spec.StringOrArray{"object"},
+				Properties: map[string]spec.Schema{
+					"name": {
+						SchemaProps: spec.SchemaProps{
+							Type:    spec.StringOrArray{"string"},
+							Pattern: "[a-z",
+						},
+					},
+				},
+			},
+		}
+
+		if _, err := CompileSchema(sch); err == nil {
+			t.Errorf("Expected error but got nil")
+		}
+	})
+
+	t.Run("invalid property pattern", func(t *testing.T) {
+		sch := &spec.Schema{
+			SchemaProps: spec.SchemaProps{
+				Type: spec.StringOrArray{"object"},
+				PatternProperties: map[string]spec.Schema{
+					"^(x-": {
+						SchemaProps: spec.SchemaProps{
+							Type: spec.StringOrArray{"string"},
+						},
+					},
+				},
+			},
+		}
+
+		if _, err := CompileSchema(sch); err == nil {
+			t.Errorf("Expected error but got nil")
+		}
+	})
+
+	t.Run("validates like ValidateBySchema", func(t *testing.T) {
+		sch := petSchema()
+
+		compiled, err := CompileSchema(sch)
+		if err != nil {
+			t.Fatalf("Unexpected error: %s", err)
+		}
+
+		data := []interface{}{
+			map[string]interface{}{"name": "Kitty", "age": 3},
+			map[string]interface{}{"name": "Kitty", "age": "three"},
+			map[string]interface{}{"age": -1},
+			"Kitty",
+		}
+
+		for _, d := range data {
+			expectedErrors := ValidateBySchema(sch, d)
+			errs := compiled.Validate(d)
+			if !reflect.DeepEqual(expectedErrors, errs) {
+				t.Errorf("Expected errors to be\n%#v\n but got\n%#v", expectedErrors, errs)
+			}
+		}
+	})
+
+	t.Run("concurrent use", func(t *testing.T) {
+		compiled, err := CompileSchema(petSchema())
+		if err != nil {
+			t.Fatalf("Unexpected error: %s", err)
+		}
+
+		var wg sync.WaitGroup
+		for i := 0; i < 8; i++ {
+			wg.Add(1)
+			go func() {
+				defer wg.Done()
+				if errs := compiled.Validate(map[string]interface{}{"age": "three"}); len(errs) != 2 {
+					t.Errorf("Expected 2 errors but got %v", errs)
+				}
+			}()
+		}
+		wg.Wait()
+	})
+}
+
+func BenchmarkValidateBySchema(b *testing.B) {
+	sch := petSchema()
+	data := map[string]interface{}{"name": "Kitty", "age": 3}
+
+	b.ReportAllocs()
+	b.ResetTimer()
+	for i := 0; i < b.N; i++ {
+		ValidateBySchema(sch, data)
+	}
+}
+
+func BenchmarkCompiledSchema_Validate(b *testing.B) {
+	compiled, err := CompileSchema(petSchema())
+	if err != nil {
+		b.Fatalf("Unexpected error: %s", err)
+	}
+	data := map[string]interface{}{"name": "Kitty", "age": 3}
+
+	b.ReportAllocs()
+	b.ResetTimer()
+	for i := 0; i < b.N; i++ {
+		compiled.Validate(data)
+	}
+}
+
+func petSchema() *spec.Schema {
+	var minAge float64
+
+	return &spec.Schema{
+		SchemaProps: spec.SchemaProps{
+			Type:     spec.StringOrArray{"object"},
+			Required: []string{"name"},
+			Properties: map[string]spec.Schema{
+				"name": {
+					SchemaProps: spec.SchemaProps{
+						Type:    spec.StringOrArray{"string"},
+						Pattern: "^[A-Z][a-z]+$",
+					},
+				},
+				"age": {
+					SchemaProps: spec.SchemaProps{
+						Type:    spec.StringOrArray{"integer"},
+						Minimum: &minAge,
+					},
+				},
+			},
+		},
+	}
+}
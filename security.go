@@ -0,0 +1,166 @@
+package oas2
+
+import (
+	"fmt"
+	"net/http"
+	"sort"
+
+	"github.com/go-openapi/spec"
+)
+
+// SecurityRequirement maps names of security schemes to scopes required for
+// them. A request satisfies the requirement if it satisfies all of its schemes.
+type SecurityRequirement map[string][]string
+
+// EffectiveSecurity returns security requirements of the operation.
+// Requirements declared by the operation override ones declared by the spec,
+// and an empty list declared by the operation disables security for it.
+// A request must satisfy one of the returned requirements; nil means no
+// security is applied.
+func EffectiveSecurity(sw *spec.Swagger, op *spec.Operation) []SecurityRequirement {
+	declared := op.Security
+	if declared == nil {
+		declared = sw.Security
+	}
+	if len(declared) == 0 {
+		return nil
+	}
+
+	requirements := make([]SecurityRequirement, len(declared))
+	for i, requirement := range declared {
+		requirements[i] = requirement
+	}
+	return requirements
+}
+
+// APIKeyAuthenticator authenticates a request by the API key of the security
+// scheme with the name. It returns an error if the key is not valid.
+type APIKeyAuthenticator func(req *http.Request, scheme string, key string) error
+
+// NewAPIKeyAuth returns new Middleware that authenticates requests by API
+// keys of apiKey security schemes required for the operation, or by the spec
+// if the operation does not declare its own requirements. Keys are read from
+// the header, the query parameter or, as an extension of OAS 2.0, the cookie
+// named by the scheme, depending on its "in". Requests that satisfy none of
+// the requirements are responded with 401 Unauthorized and the body written
+// by errHandler. Requirements with schemes of other types are not checked;
+// if there are only such requirements, SpecMismatchFn is called and the
+// request is responded with 401 Unauthorized too, unless passing through is
+// enabled by UncheckedSecurityOpt.
+func NewAPIKeyAuth(
+	sw *spec.Swagger,
+	authenticator APIKeyAuthenticator,
+	errHandler func(w http.ResponseWriter, errs []error),
+	options ...MiddlewareOption,
+) Middleware {
+	return apiKeyAuth{
+		sw:            sw,
+		authenticator: authenticator,
+		errHandler:    errHandler,
+		opts:          newMiddlewareOptions(options),
+	}
+}
+
+type apiKeyAuth struct {
+	sw            *spec.Swagger
+	authenticator APIKeyAuthenticator
+	errHandler    func(w http.ResponseWriter, errs []error)
+	opts          MiddlewareOptions
+}
+
+func (m apiKeyAuth) Apply(next http.Handler) http.Handler {
+	return http.HandlerFunc(func(w http.ResponseWriter, req *http.Request) {
+		op := m.opts.operationResolver(req)
+		if op == nil {
+			next.ServeHTTP(w, req)
+			return
+		}
+
+		requirements := EffectiveSecurity(m.sw, op)
+		if len(requirements) == 0 {
+			next.ServeHTTP(w, req)
+			return
+		}
+
+		checked := false
+		var errs []error
+		for _, requirement := range requirements {
+			if !m.checkable(requirement) {
+				continue
+			}
+			checked = true
+
+			reqErrs := m.authenticate(req, requirement)
+			if len(reqErrs) == 0 {
+				next.ServeHTTP(w, req)
+				return
+			}
+			errs = append(errs, reqErrs...)
+		}
+
+		if !checked {
+			m.opts.specMismatchFn(req, fmt.Errorf("no apiKey security requirement to check"))
+			if m.opts.uncheckedSecurity {
+				next.ServeHTTP(w, req)
+				return
+			}
+			errs = []error{fmt.Errorf("Security requirements of the operation cannot be checked")}
+		}
+
+		writeErrorsWithStatus(w, http.StatusUnauthorized, operationErrHandler(req, m.errHandler), errs)
+	})
+}
+
+// checkable reports whether all schemes of the requirement are apiKey
+// schemes.
+func (m apiKeyAuth) checkable(requirement SecurityRequirement) bool {
+	for name := range requirement {
+		scheme, ok := m.sw.SecurityDefinitions[name]
+		if !ok || scheme.Type != "apiKey" {
+			return false
+		}
+	}
+	return true
+}
+
+// authenticate authenticates the request by keys of all schemes of the
+// requirement.
+func (m apiKeyAuth) authenticate(req *http.Request, requirement SecurityRequirement) []error {
+	names := make([]string, 0, len(requirement))
+	for name := range requirement {
+		names = append(names, name)
+	}
+	sort.Strings(names)
+
+	var errs []error
+	for _, name := range names {
+		scheme := m.sw.SecurityDefinitions[name]
+
+		key, ok := apiKey(req, scheme)
+		if !ok {
+			errs = append(errs, ValidationErrorf(scheme.Name, nil, "API key %s is required", scheme.Name))
+			continue
+		}
+
+		if err := m.authenticator(req, name, key); err != nil {
+			errs = append(errs, ValidationErrorf(scheme.Name, nil, "API key %s is not valid: %s", scheme.Name, err))
+		}
+	}
+	return errs
+}
+
+// apiKey returns the API key of the scheme sent with the request.
+func apiKey(req *http.Request, scheme *spec.SecurityScheme) (string, bool) {
+	var key string
+	switch scheme.In {
+	case "header":
+		key = req.Header.Get(scheme.Name)
+	case "query":
+		key = req.URL.Query().Get(scheme.Name)
+	case "cookie":
+		if c, err := req.Cookie(scheme.Name); err == nil {
+			key = c.Value
+		}
+	}
+	return key, key != ""
+}
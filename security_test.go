@@ -0,0 +1,243 @@
+package oas2
+
+import (
+	"errors"
+	"fmt"
+	"net/http"
+	"net/http/httptest"
+	"reflect"
+	"testing"
+)
+
+func TestAPIKeyAuth_Apply(t *testing.T) {
+	sw := parseSpec(`
+swagger: "2.0"
+info:
+  title: test
+  version: "1.0"
+basePath: /v1
+securityDefinitions:
+  session:
+    type: apiKey
+    name: session_id
+    in: cookie
+  token:
+    type: apiKey
+    name: X-Token
+    in: header
+  oauth:
+    type: oauth2
+    flow: implicit
+    authorizationUrl: http://example.com/oauth
+    scopes: {}
+security:
+- session: []
+paths:
+  /profile:
+    get:
+      operationId: getProfile
+      responses:
+        200:
+          description: ok
+  /keys:
+    get:
+      operationId: getKeys
+      security:
+      - token: []
+      responses:
+        200:
+          description: ok
+  /public:
+    get:
+      operationId: getPublic
+      security: []
+      responses:
+        200:
+          description: ok
+  /oauth:
+    get:
+      operationId: getOAuth
+      security:
+      - oauth: []
+      responses:
+        200:
+          description: ok
+`)
+
+	cases := []struct {
+		path            string
+		cookie          *http.Cookie
+		header          string
+		options         []MiddlewareOption
+		expectedStatus  int
+		expectedPayload string
+	}{
+		// cookie key
+		{
+			path:            "/v1/profile",
+			cookie:          &http.Cookie{Name: "session_id", Value: "valid"},
+			expectedStatus:  http.StatusOK,
+			expectedPayload: "ok",
+		},
+		{
+			path:            "/v1/profile",
+			cookie:          &http.Cookie{Name: "session_id", Value: "expired"},
+			expectedStatus:  http.StatusUnauthorized,
+			expectedPayload: `{"errors":[{"message":"API key session_id is not valid: key is unknown","field":"session_id"}]}`,
+		},
+		{
+			path:            "/v1/profile",
+			expectedStatus:  http.StatusUnauthorized,
+			expectedPayload: `{"errors":[{"message":"API key session_id is required","field":"session_id"}]}`,
+		},
+		// operation requirements replace the spec ones
+		{
+			path:            "/v1/keys",
+			header:          "valid",
+			expectedStatus:  http.StatusOK,
+			expectedPayload: "ok",
+		},
+		{
+			path:            "/v1/keys",
+			cookie:          &http.Cookie{Name: "session_id", Value: "valid"},
+			expectedStatus:  http.StatusUnauthorized,
+			expectedPayload: `{"errors":[{"message":"API key X-Token is required","field":"X-Token"}]}`,
+		},
+		// no requirements
+		{
+			path:            "/v1/public",
+			expectedStatus:  http.StatusOK,
+			expectedPayload: "ok",
+		},
+		// requirements of other types are not checked
+		{
+			path:            "/v1/oauth",
+			expectedStatus:  http.StatusUnauthorized,
+			expectedPayload: `{"errors":[{"message":"Security requirements of the operation cannot be checked"}]}`,
+		},
+		{
+			path:            "/v1/oauth",
+			options:         []MiddlewareOption{UncheckedSecurityOpt(true)},
+			expectedStatus:  http.StatusOK,
+			expectedPayload: "ok",
+		},
+	}
+
+	ok := http.HandlerFunc(func(w http.ResponseWriter, req *http.Request) {
+		fmt.Fprint(w, "ok")
+	})
+	handlers := OperationHandlers{"getProfile": ok, "getKeys": ok, "getPublic": ok, "getOAuth": ok}
+
+	authenticator := func(req *http.Request, scheme string, key string) error {
+		if key != "valid" {
+			return errors.New("key is unknown")
+		}
+		return nil
+	}
+
+	for _, c := range cases {
+		auth := NewAPIKeyAuth(sw, authenticator, writeErrorsToResponseWriter, c.options...)
+
+		router, err := NewRouter(sw, handlers, MiddlewareOpt(auth.Apply))
+		if err != nil {
+			t.Fatal(err)
+		}
+
+		req := httptest.NewRequest(http.MethodGet, c.path, nil)
+		if c.cookie != nil {
+			req.AddCookie(c.cookie)
+		}
+		if c.header != "" {
+			req.Header.Set("X-Token", c.header)
+		}
+		w := httptest.NewRecorder()
+
+		router.ServeHTTP(w, req)
+
+		if w.Code != c.expectedStatus {
+			t.Errorf("Expected status for %s to be %d but got %d", c.path, c.expectedStatus, w.Code)
+		}
+		if w.Body.String() != c.expectedPayload {
+			t.Errorf("Expected response body to be\n%s\nbut got\n%s", c.expectedPayload, w.Body.String())
+		}
+	}
+}
+
+func TestEffectiveSecurity(t *testing.T) {
+	sw := parseSpec(`
+swagger: "2.0"
+info:
+  title: test
+  version: "1.0"
+basePath: /v1
+securityDefinitions:
+  session:
+    type: apiKey
+    name: session_id
+    in: cookie
+  oauth:
+    type: oauth2
+    flow: implicit
+    authorizationUrl: http://example.com/oauth
+    scopes:
+      read: read access
+security:
+- session: []
+paths:
+  /inherited:
+    get:
+      responses:
+        200:
+          description: ok
+  /overridden:
+    get:
+      security:
+      - oauth: [read]
+      - session: []
+        oauth: []
+      responses:
+        200:
+          description: ok
+  /disabled:
+    get:
+      security: []
+      responses:
+        200:
+          description: ok
+`)
+
+	cases := []struct {
+		path     string
+		expected []SecurityRequirement
+	}{
+		{
+			path:     "/inherited",
+			expected: []SecurityRequirement{{"session": {}}},
+		},
+		{
+			path: "/overridden",
+			expected: []SecurityRequirement{
+				{"oauth": {"read"}},
+				{"session": {}, "oauth": {}},
+			},
+		},
+		{
+			path:     "/disabled",
+			expected: nil,
+		},
+	}
+
+	for _, c := range cases {
+		op := sw.Paths.Paths[c.path].Get
+		actual := EffectiveSecurity(sw, op)
+		if !reflect.DeepEqual(c.expected, actual) {
+			t.Errorf("Expected security of %s to be %v but got %v", c.path, c.expected, actual)
+		}
+	}
+
+	// Nothing is declared by the spec.
+	sw.Security = nil
+	if actual := EffectiveSecurity(sw, sw.Paths.Paths["/inherited"].Get); actual != nil {
+		t.Errorf("Expected security to be nil but got %v", actual)
+	}
+}
@@ -0,0 +1,126 @@
+package oas2
+
+import (
+	"net/http"
+	"sync"
+)
+
+// SingleflightKey returns the key of a request. Concurrent requests with the
+// same key are considered identical.
+type SingleflightKey func(req *http.Request) string
+
+// NewSingleflight returns new Middleware that coalesces concurrent identical
+// GET and HEAD requests, so the handler is called once and its response is
+// written to all of them. Requests are identical if they have the same key.
+// If key is nil, requests with the same method, path and query are
+// identical; use a key that includes e.g. the authenticated user if the
+// response depends on it.
+//
+// Responses exceeding ResponseBufferLimitOpt are not shared, the requests
+// waiting for them are handled separately.
+func NewSingleflight(key SingleflightKey, options ...MiddlewareOption) Middleware {
+	if key == nil {
+		key = defaultSingleflightKey
+	}
+	return singleflight{
+		key:     key,
+		opts:    newMiddlewareOptions(options),
+		flights: &flightGroup{calls: make(map[string]*flightCall)},
+	}
+}
+
+type singleflight struct {
+	key     SingleflightKey
+	opts    MiddlewareOptions
+	flights *flightGroup
+}
+
+// flightGroup tracks handler calls in flight by request keys.
+type flightGroup struct {
+	mu    sync.Mutex
+	calls map[string]*flightCall
+}
+
+// flightCall is a handler call shared by identical requests. resp is set
+// before done is closed, and stays nil if the response cannot be shared.
+type flightCall struct {
+	done chan struct{}
+	resp *recordedResponse
+}
+
+// recordedResponse is a response recorded to be written again.
+type recordedResponse struct {
+	status int
+	header http.Header
+	body   []byte
+}
+
+func (m singleflight) Apply(next http.Handler) http.Handler {
+	return http.HandlerFunc(func(w http.ResponseWriter, req *http.Request) {
+		if req.Method != http.MethodGet && req.Method != http.MethodHead {
+			next.ServeHTTP(w, req)
+			return
+		}
+
+		if m.opts.operationResolver(req) == nil {
+			next.ServeHTTP(w, req)
+			return
+		}
+
+		key := m.key(req)
+
+		m.flights.mu.Lock()
+		if c, ok := m.flights.calls[key]; ok {
+			m.flights.mu.Unlock()
+
+			select {
+			case <-c.done:
+			case <-req.Context().Done():
+				// The client is gone, there is nobody to respond to.
+				return
+			}
+
+			if c.resp == nil {
+				next.ServeHTTP(w, req)
+				return
+			}
+
+			for name, vals := range c.resp.header {
+				w.Header()[name] = append([]string(nil), vals...)
+			}
+			w.WriteHeader(c.resp.status)
+			w.Write(c.resp.body)
+			return
+		}
+
+		c := &flightCall{done: make(chan struct{})}
+		m.flights.calls[key] = c
+		m.flights.mu.Unlock()
+
+		// Release waiting requests even if the handler panics, they are
+		// handled separately then.
+		defer func() {
+			m.flights.mu.Lock()
+			delete(m.flights.calls, key)
+			m.flights.mu.Unlock()
+			close(c.done)
+		}()
+
+		rr := NewLimitedResponseRecorder(w, m.opts.responseBufferLimit)
+		next.ServeHTTP(rr, req)
+
+		if !overflowed(rr) {
+			c.resp = &recordedResponse{
+				status: rr.Status(),
+				header: cloneHeader(rr.Header()),
+				body:   append([]byte(nil), rr.Payload()...),
+			}
+		}
+	})
+}
+
+// defaultSingleflightKey returns the method, the path and the query of the
+// request.
+func defaultSingleflightKey(req *http.Request) string {
+	return req.Method + " " + req.URL.RequestURI()
+}
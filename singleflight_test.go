@@ -0,0 +1,107 @@
+package oas2
+
+import (
+	"context"
+	"fmt"
+	"net/http"
+	"net/http/httptest"
+	"sync"
+	"sync/atomic"
+	"testing"
+	"time"
+)
+
+func TestSingleflight_Apply(t *testing.T) {
+	sw := parseSpec(`
+swagger: "2.0"
+info:
+  title: test
+  version: "1.0"
+basePath: /v1
+paths:
+  /reports:
+    get:
+      operationId: getReport
+      responses:
+        200:
+          description: ok
+`)
+
+	var calls int32
+	release := make(chan struct{})
+	handlers := OperationHandlers{"getReport": http.HandlerFunc(func(w http.ResponseWriter, req *http.Request) {
+		n := atomic.AddInt32(&calls, 1)
+		<-release
+		w.Header().Set("X-Call", fmt.Sprint(n))
+		w.WriteHeader(http.StatusAccepted)
+		fmt.Fprint(w, "report")
+	})}
+
+	router, err := NewRouter(sw, handlers, MiddlewareOpt(NewSingleflight(nil).Apply))
+	if err != nil {
+		t.Fatal(err)
+	}
+
+	const requests = 5
+	var waiting int32
+	ctx := waitingContext{Context: context.Background(), waiting: &waiting}
+	recorders := make([]*httptest.ResponseRecorder, requests)
+	var wg sync.WaitGroup
+	for i := range recorders {
+		recorders[i] = httptest.NewRecorder()
+		wg.Add(1)
+		go func(w http.ResponseWriter) {
+			defer wg.Done()
+			req := httptest.NewRequest(http.MethodGet, "/v1/reports?year=2020", nil).WithContext(ctx)
+			router.ServeHTTP(w, req)
+		}(recorders[i])
+	}
+
+	// Release the handler when it is called and all other requests wait
+	// for it.
+	for deadline := time.Now().Add(5 * time.Second); ; {
+		if atomic.LoadInt32(&calls) == 1 && atomic.LoadInt32(&waiting) == requests-1 {
+			break
+		}
+		if time.Now().After(deadline) {
+			t.Fatal("Expected requests to wait for the handler")
+		}
+		time.Sleep(time.Millisecond)
+	}
+	close(release)
+	wg.Wait()
+
+	if calls != 1 {
+		t.Errorf("Expected handler to be called once but got %d calls", calls)
+	}
+
+	for _, w := range recorders {
+		if w.Code != http.StatusAccepted {
+			t.Errorf("Expected status to be %d but got %d", http.StatusAccepted, w.Code)
+		}
+		if w.Body.String() != "report" {
+			t.Errorf("Expected response body to be %s but got %s", "report", w.Body.String())
+		}
+		if actual := w.Header().Get("X-Call"); actual != "1" {
+			t.Errorf("Expected X-Call header to be %s but got %s", "1", actual)
+		}
+	}
+
+	// Requests after the call are not coalesced with it.
+	router.ServeHTTP(httptest.NewRecorder(), httptest.NewRequest(http.MethodGet, "/v1/reports?year=2020", nil))
+	if calls != 2 {
+		t.Errorf("Expected handler to be called again but got %d calls", calls)
+	}
+}
+
+// waitingContext counts requests waiting for a shared handler call, as
+// they wait for Done of the request context too.
+type waitingContext struct {
+	context.Context
+	waiting *int32
+}
+
+func (c waitingContext) Done() <-chan struct{} {
+	atomic.AddInt32(c.waiting, 1)
+	return c.Context.Done()
+}
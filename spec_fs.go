@@ -0,0 +1,70 @@
+//go:build go1.16
+// +build go1.16
+
+package oas2
+
+import (
+	"encoding/json"
+	"fmt"
+	"io/fs"
+	"net/url"
+	"path"
+	"strings"
+
+	"github.com/go-openapi/loads"
+	"github.com/go-openapi/spec"
+	"github.com/go-openapi/swag"
+)
+
+// LoadSpecFS loads the spec document from the file at path in fsys, e.g.
+// embedded with embed.FS, and expands its references. The document and the
+// documents it references can be either YAML or JSON. References to other
+// documents are resolved within fsys.
+func LoadSpecFS(fsys fs.FS, path string) (*loads.Document, error) {
+	load := fsLoader(fsys)
+
+	doc, err := loads.Spec(fsRoot+path, loads.WithDocLoader(load))
+	if err != nil {
+		return nil, fmt.Errorf("load spec %s: %s", path, err)
+	}
+
+	doc, err = doc.Expanded(&spec.ExpandOptions{
+		RelativeBase: fsRoot + path,
+		PathLoader:   load,
+	})
+	if err != nil {
+		return nil, fmt.Errorf("expand spec %s: %s", path, err)
+	}
+
+	return doc, nil
+}
+
+// fsRoot is a prefix of paths passed to go-openapi loaders, so they are
+// resolved as absolute and stay within the file system.
+const fsRoot = "/"
+
+// fsLoader returns a go-openapi document loader that reads documents from
+// fsys and converts YAML documents to JSON.
+func fsLoader(fsys fs.FS) func(string) (json.RawMessage, error) {
+	return func(p string) (json.RawMessage, error) {
+		if u, err := url.Parse(p); err == nil && u.Scheme == "file" {
+			p = u.Path
+		}
+		name := strings.TrimPrefix(path.Clean(p), fsRoot)
+
+		data, err := fs.ReadFile(fsys, name)
+		if err != nil {
+			return nil, err
+		}
+
+		if json.Valid(data) {
+			return data, nil
+		}
+
+		yml, err := swag.BytesToYAMLDoc(data)
+		if err != nil {
+			return nil, err
+		}
+		return swag.YAMLToJSON(yml)
+	}
+}
@@ -0,0 +1,38 @@
+//go:build go1.16
+// +build go1.16
+
+package oas2
+
+import (
+	"embed"
+	"net/http"
+	"reflect"
+	"testing"
+)
+
+//go:embed testdata/specfs
+var specFS embed.FS
+
+func TestLoadSpecFS(t *testing.T) {
+	doc, err := LoadSpecFS(specFS, "testdata/specfs/swagger.yaml")
+	if err != nil {
+		t.Fatal(err)
+	}
+
+	resp := doc.Spec().Paths.Paths["/pets/{id}"].Get.Responses.StatusCodeResponses[http.StatusOK]
+	if resp.Schema == nil {
+		t.Fatal("Expected response schema to be set")
+	}
+	if resp.Schema.Ref.String() != "" {
+		t.Errorf("Expected response schema reference to be expanded but got %s", resp.Schema.Ref.String())
+	}
+
+	expectedRequired := []string{"name"}
+	if !reflect.DeepEqual(expectedRequired, resp.Schema.Required) {
+		t.Errorf("Expected required properties to be %v but got %v", expectedRequired, resp.Schema.Required)
+	}
+
+	if _, err := LoadSpecFS(specFS, "testdata/specfs/missing.yaml"); err == nil {
+		t.Errorf("Expected error for missing spec but got nil")
+	}
+}
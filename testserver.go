@@ -0,0 +1,104 @@
+package oas2
+
+import (
+	"net/http"
+	"net/http/httptest"
+	"strings"
+
+	"github.com/go-chi/chi"
+	"github.com/go-openapi/spec"
+)
+
+// TestReporter reports test failures, e.g. *testing.T.
+type TestReporter interface {
+	Errorf(format string, args ...interface{})
+}
+
+// TestServerOptions is options for oas2 test server.
+type TestServerOptions struct {
+	routerOptions     []RouterOption
+	middlewareOptions []MiddlewareOption
+	reporter          TestReporter
+}
+
+// TestServerOption is an option for oas2 test server.
+type TestServerOption func(*TestServerOptions)
+
+// TestServerRouterOpt returns an option that adds options of the test server
+// router, e.g. extra middleware.
+func TestServerRouterOpt(options ...RouterOption) TestServerOption {
+	return func(args *TestServerOptions) {
+		args.routerOptions = append(args.routerOptions, options...)
+	}
+}
+
+// TestServerMiddlewareOpt returns an option that adds options of the test
+// server validators.
+func TestServerMiddlewareOpt(options ...MiddlewareOption) TestServerOption {
+	return func(args *TestServerOptions) {
+		args.middlewareOptions = append(args.middlewareOptions, options...)
+	}
+}
+
+// FailOnResponseErrorsOpt returns an option that makes the test server
+// report responses that do not match the spec to t, so the test fails.
+// Otherwise such responses are sent silently.
+func FailOnResponseErrorsOpt(t TestReporter) TestServerOption {
+	return func(args *TestServerOptions) {
+		args.reporter = t
+	}
+}
+
+// NewTestServer returns a started httptest.Server that serves the spec
+// operations by handlers, for tests that send real HTTP requests. Path
+// parameters are extracted, see GetPathParam, query and body are validated,
+// and invalid requests are responded with 400 Bad Request listing
+// the errors. Responses are validated, see FailOnResponseErrorsOpt. The
+// caller must close the server.
+func NewTestServer(sw *spec.Swagger, handlers OperationHandlers, options ...TestServerOption) (*httptest.Server, error) {
+	var opts TestServerOptions
+	for _, o := range options {
+		o(&opts)
+	}
+
+	mwOpts := opts.middlewareOptions
+	if t := opts.reporter; t != nil {
+		mwOpts = append([]MiddlewareOption{SpecMismatchOpt(func(req *http.Request, err error) {
+			t.Errorf("oas2 test server: %s %s: %s", req.Method, req.URL.Path, err)
+		})}, mwOpts...)
+	}
+
+	responseErrHandler := func(w http.ResponseWriter, errs []error) {
+		if opts.reporter == nil {
+			return
+		}
+		for _, err := range errs {
+			opts.reporter.Errorf("oas2 test server: response does not match the spec: %s", err)
+		}
+	}
+
+	// Middleware added last runs first, so invalid requests are responded
+	// before responses are validated.
+	routerOpts := []RouterOption{
+		MiddlewareOpt(NewResponseBodyValidator(responseErrHandler, mwOpts...).Apply),
+		MiddlewareOpt(NewBodyValidator(writeTestServerErrors, mwOpts...).Apply),
+		MiddlewareOpt(NewQueryValidator(writeTestServerErrors, mwOpts...).Apply),
+		MiddlewareOpt(NewPathParameterExtractor(chi.URLParam, mwOpts...).Apply),
+	}
+
+	router, err := NewRouter(sw, handlers, append(routerOpts, opts.routerOptions...)...)
+	if err != nil {
+		return nil, err
+	}
+	return httptest.NewServer(router), nil
+}
+
+// writeTestServerErrors responds with 400 Bad Request listing errors one per
+// line.
+func writeTestServerErrors(w http.ResponseWriter, errs []error) {
+	msgs := make([]string, len(errs))
+	for i, err := range errs {
+		msgs[i] = err.Error()
+	}
+	http.Error(w, strings.Join(msgs, "\n"), http.StatusBadRequest)
+}
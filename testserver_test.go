@@ -0,0 +1,114 @@
+package oas2
+
+import (
+	"fmt"
+	"io/ioutil"
+	"net/http"
+	"reflect"
+	"strings"
+	"testing"
+)
+
+const testServerSpec = `
+swagger: "2.0"
+info:
+  title: test
+  version: "1.0"
+basePath: /v1
+produces:
+- application/json
+paths:
+  /pets/{id}:
+    get:
+      operationId: getPet
+      parameters:
+      - name: id
+        in: path
+        required: true
+        type: integer
+      - name: fields
+        in: query
+        type: string
+        enum: [name, all]
+      responses:
+        200:
+          description: ok
+          schema:
+            type: object
+            required: [id, name]
+            properties:
+              id:
+                type: integer
+              name:
+                type: string
+`
+
+func ExampleNewTestServer() {
+	handlers := OperationHandlers{"getPet": http.HandlerFunc(func(w http.ResponseWriter, req *http.Request) {
+		w.Header().Set("Content-Type", "application/json")
+		fmt.Fprintf(w, `{"id":%d,"name":"Kitty"}`, GetPathParam(req, "id"))
+	})}
+
+	server, err := NewTestServer(parseSpec(testServerSpec), handlers)
+	if err != nil {
+		panic(err)
+	}
+	defer server.Close()
+
+	for _, path := range []string{"/v1/pets/12", "/v1/pets/12?fields=owner"} {
+		resp, err := server.Client().Get(server.URL + path)
+		if err != nil {
+			panic(err)
+		}
+		body, _ := ioutil.ReadAll(resp.Body)
+		resp.Body.Close()
+
+		fmt.Printf("%d %s\n", resp.StatusCode, strings.TrimSpace(string(body)))
+	}
+
+	// Output:
+	// 200 {"id":12,"name":"Kitty"}
+	// 400 fields in query should be one of [name all]
+}
+
+type testReporter struct {
+	errs []string
+}
+
+func (r *testReporter) Errorf(format string, args ...interface{}) {
+	r.errs = append(r.errs, fmt.Sprintf(format, args...))
+}
+
+func TestFailOnResponseErrorsOpt(t *testing.T) {
+	handlers := OperationHandlers{"getPet": http.HandlerFunc(func(w http.ResponseWriter, req *http.Request) {
+		w.Header().Set("Content-Type", "application/json")
+		if GetPathParam(req, "id") == int64(404) {
+			w.WriteHeader(http.StatusNotFound)
+			return
+		}
+		fmt.Fprint(w, `{"id":12}`)
+	})}
+
+	reporter := &testReporter{}
+	server, err := NewTestServer(parseSpec(testServerSpec), handlers, FailOnResponseErrorsOpt(reporter))
+	if err != nil {
+		t.Fatal(err)
+	}
+	defer server.Close()
+
+	for _, path := range []string{"/v1/pets/12", "/v1/pets/404"} {
+		resp, err := server.Client().Get(server.URL + path)
+		if err != nil {
+			t.Fatal(err)
+		}
+		resp.Body.Close()
+	}
+
+	expected := []string{
+		"oas2 test server: response does not match the spec: name in body is required",
+		"oas2 test server: GET /v1/pets/404: no response spec for status 404",
+	}
+	if !reflect.DeepEqual(expected, reporter.errs) {
+		t.Errorf("Expected reported errors to be\n%s\nbut got\n%s", strings.Join(expected, "\n"), strings.Join(reporter.errs, "\n"))
+	}
+}
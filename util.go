@@ -14,20 +14,48 @@ type ResponseRecorder interface {
 	Payload() []byte
 }
 
+// overflower is implemented by response recorders that stop buffering the
+// payload when it exceeds a limit.
+type overflower interface {
+	// Overflowed reports whether the payload exceeded the buffer limit, so
+	// it is not buffered anymore.
+	Overflowed() bool
+}
+
+// overflowed reports whether the recorder stopped buffering the payload.
+// Recorders that do not implement overflower are considered to buffer the
+// whole payload.
+func overflowed(rr ResponseRecorder) bool {
+	o, ok := rr.(overflower)
+	return ok && o.Overflowed()
+}
+
 type responseRecorder struct {
 	origin        http.ResponseWriter
 	status        int
 	statusWritten bool
 	payload       *bytes.Buffer
+	limit         int
+	overflowed    bool
 }
 
 // NewResponseRecorder returns a new ResponseRecorder.
 func NewResponseRecorder(origin http.ResponseWriter) ResponseRecorder {
+	return NewLimitedResponseRecorder(origin, 0)
+}
+
+// NewLimitedResponseRecorder returns a new ResponseRecorder that buffers
+// at most limit bytes of payload. When the limit is exceeded, the recorder
+// drops the buffered payload and only passes writes through to the origin.
+// Zero limit means no limit. The returned recorder has an Overflowed() bool
+// method reporting whether the limit was exceeded.
+func NewLimitedResponseRecorder(origin http.ResponseWriter, limit int) ResponseRecorder {
 	return &responseRecorder{
 		origin:        origin,
 		status:        http.StatusOK,
 		statusWritten: false,
 		payload:       new(bytes.Buffer),
+		limit:         limit,
 	}
 }
 
@@ -36,6 +64,16 @@ func (r *responseRecorder) Header() http.Header {
 }
 
 func (r *responseRecorder) Write(b []byte) (int, error) {
+	if r.overflowed {
+		return r.origin.Write(b)
+	}
+
+	if r.limit > 0 && r.payload.Len()+len(b) > r.limit {
+		r.overflowed = true
+		r.payload = new(bytes.Buffer)
+		return r.origin.Write(b)
+	}
+
 	return io.MultiWriter(r.origin, r.payload).Write(b)
 }
 
@@ -53,3 +91,108 @@ func (r *responseRecorder) Status() int {
 func (r *responseRecorder) Payload() []byte {
 	return r.payload.Bytes()
 }
+
+func (r *responseRecorder) Overflowed() bool {
+	return r.overflowed
+}
+
+// bufferedResponseRecorder is a ResponseRecorder that holds the status and
+// payload back from the origin until flushed, so the payload can be
+// modified. When the limit is exceeded, the buffered payload is flushed and
+// the rest of writes are passed through to the origin.
+type bufferedResponseRecorder struct {
+	origin     http.ResponseWriter
+	status     int
+	payload    *bytes.Buffer
+	limit      int
+	overflowed bool
+	flushed    bool
+}
+
+func newBufferedResponseRecorder(origin http.ResponseWriter, limit int) *bufferedResponseRecorder {
+	return &bufferedResponseRecorder{
+		origin:  origin,
+		payload: new(bytes.Buffer),
+		limit:   limit,
+	}
+}
+
+func (r *bufferedResponseRecorder) Header() http.Header {
+	return r.origin.Header()
+}
+
+func (r *bufferedResponseRecorder) Write(b []byte) (int, error) {
+	if r.overflowed {
+		return r.origin.Write(b)
+	}
+
+	if r.limit > 0 && r.payload.Len()+len(b) > r.limit {
+		r.overflowed = true
+		r.flush(r.payload.Bytes())
+		r.payload = new(bytes.Buffer)
+		return r.origin.Write(b)
+	}
+
+	return r.payload.Write(b)
+}
+
+func (r *bufferedResponseRecorder) WriteHeader(status int) {
+	if r.status == 0 {
+		r.status = status
+	}
+}
+
+func (r *bufferedResponseRecorder) Status() int {
+	if r.status == 0 {
+		return http.StatusOK
+	}
+	return r.status
+}
+
+func (r *bufferedResponseRecorder) Payload() []byte {
+	return r.payload.Bytes()
+}
+
+func (r *bufferedResponseRecorder) Overflowed() bool {
+	return r.overflowed
+}
+
+// flush writes the status and the payload to the origin, once.
+func (r *bufferedResponseRecorder) flush(payload []byte) {
+	if r.flushed {
+		return
+	}
+	r.flushed = true
+
+	r.origin.WriteHeader(r.Status())
+	r.origin.Write(payload)
+}
+
+// writeErrorsWithStatus responds with the status and the body written by
+// errHandler for errs, regardless of the status errHandler writes.
+func writeErrorsWithStatus(w http.ResponseWriter, status int, errHandler func(w http.ResponseWriter, errs []error), errs []error) {
+	sw := &statusResponseWriter{ResponseWriter: w, status: status}
+	errHandler(sw, errs)
+	// The status is written even if errHandler writes nothing.
+	sw.WriteHeader(status)
+}
+
+// statusResponseWriter writes the status regardless of the status written
+// by the handler.
+type statusResponseWriter struct {
+	http.ResponseWriter
+	status      int
+	wroteHeader bool
+}
+
+func (w *statusResponseWriter) WriteHeader(int) {
+	if !w.wroteHeader {
+		w.wroteHeader = true
+		w.ResponseWriter.WriteHeader(w.status)
+	}
+}
+
+func (w *statusResponseWriter) Write(b []byte) (int, error) {
+	w.WriteHeader(w.status)
+	return w.ResponseWriter.Write(b)
+}
@@ -27,3 +27,53 @@ func TestResponseRecorder(t *testing.T) {
 		t.Error("Expected status to be equal")
 	}
 }
+
+func TestLimitedResponseRecorder(t *testing.T) {
+	w := httptest.NewRecorder()
+	rr := NewLimitedResponseRecorder(w, 8)
+
+	rr.Write([]byte("1234"))
+	if overflowed(rr) {
+		t.Fatal("Expected recorder not to be overflowed")
+	}
+
+	rr.Write([]byte("56789"))
+	if !overflowed(rr) {
+		t.Fatal("Expected recorder to be overflowed")
+	}
+	if len(rr.Payload()) != 0 {
+		t.Errorf("Expected payload to be dropped but got %q", rr.Payload())
+	}
+	if w.Body.String() != "123456789" {
+		t.Errorf("Expected origin body to be %q but got %q", "123456789", w.Body.String())
+	}
+}
+
+func TestBufferedResponseRecorder(t *testing.T) {
+	w := httptest.NewRecorder()
+	rr := newBufferedResponseRecorder(w, 8)
+
+	rr.WriteHeader(http.StatusCreated)
+	rr.Write([]byte("1234"))
+	if w.Body.Len() != 0 || w.Code != http.StatusOK {
+		t.Fatal("Expected response to be held back")
+	}
+
+	rr.flush([]byte("abcd"))
+	rr.flush([]byte("efgh"))
+	if w.Code != http.StatusCreated || w.Body.String() != "abcd" {
+		t.Errorf("Expected origin to get %d %q but got %d %q", http.StatusCreated, "abcd", w.Code, w.Body.String())
+	}
+
+	w = httptest.NewRecorder()
+	rr = newBufferedResponseRecorder(w, 8)
+
+	rr.Write([]byte("1234"))
+	rr.Write([]byte("56789"))
+	if !rr.Overflowed() {
+		t.Fatal("Expected recorder to be overflowed")
+	}
+	if w.Body.String() != "123456789" {
+		t.Errorf("Expected origin body to be %q but got %q", "123456789", w.Body.String())
+	}
+}
@@ -1,9 +1,15 @@
 package oas2
 
 import (
+	"encoding/json"
 	"fmt"
+	"io"
+	"mime/multipart"
 	"net/url"
+	"sort"
+	"strconv"
 	"strings"
+	"time"
 
 	"github.com/go-openapi/errors"
 	"github.com/go-openapi/spec"
@@ -14,29 +20,52 @@ import (
 // ValidateQuery validates request query parameters by spec and returns errors
 // if any.
 func ValidateQuery(ps []spec.Parameter, q url.Values) []error {
+	return validateValues(ps, "query", q, newMiddlewareOptions(nil))
+}
+
+// ValidateFormData validates request form data parameters by spec and returns
+// errors if any.
+func ValidateFormData(ps []spec.Parameter, f url.Values) []error {
+	return validateValues(ps, "formData", f, newMiddlewareOptions(nil))
+}
+
+// ValidateFormFiles validates files of request multipart form data by spec
+// and returns errors if any.
+func ValidateFormFiles(ps []spec.Parameter, files map[string][]*multipart.FileHeader) []error {
 	errs := make(ValidationErrors, 0)
 
-	// Iterate over spec parameters and validate each against the spec.
+	declared := make(map[string]struct{})
 	for _, p := range ps {
-		if p.In != "query" {
-			// Validating only "query" parameters.
+		if p.In != "formData" || p.Type != "file" {
 			continue
 		}
+		declared[p.Name] = struct{}{}
 
-		errs = append(errs, validateQueryParam(p, q)...)
-
-		delete(q, p.Name) // to check not described parameters passed
+		if len(files[p.Name]) == 0 && p.Required {
+			errs = append(errs, ValidationErrorf(p.Name, nil, "param %s is required", p.Name))
+		}
 	}
 
-	// Check that no additional parameters passed.
-	for name := range q {
-		errs = append(errs, ValidationErrorf(name, q.Get(name), "parameter %s is unknown", name))
+	// Check that no additional files passed.
+	names := make([]string, 0, len(files))
+	for name := range files {
+		names = append(names, name)
+	}
+	sort.Strings(names)
+	for _, name := range names {
+		if _, ok := declared[name]; !ok {
+			errs = append(errs, ValidationErrorf(name, nil, "parameter %s is unknown", name))
+		}
 	}
 
 	return errs.Errors()
 }
 
 // ValidateBody validates request body by spec and returns errors if any.
+// Properties marked as readOnly are not required in the request body.
+// Schemas must not be references, see ValidateBodyBySpec. Bodies of schemas
+// with references left are rejected with an error, as they cannot be
+// validated.
 func ValidateBody(ps []spec.Parameter, data interface{}) []error {
 	errs := make(ValidationErrors, 0)
 
@@ -52,9 +81,150 @@ func ValidateBody(ps []spec.Parameter, data interface{}) []error {
 	return errs.Errors()
 }
 
-// ValidateBySchema validates data by spec and returns errors if any.
+// ValidateBodyBySpec is like ValidateBody, but resolves references in schemas
+// of the body parameters against the spec, e.g. a schema declared as
+// {$ref: '#/definitions/Pet'}. Recursive schemas are not supported: a
+// reference to the schema itself, e.g. of Node children to
+// '#/definitions/Node', stays in the expanded schema, so bodies of such
+// schemas are rejected with an error.
+func ValidateBodyBySpec(sw *spec.Swagger, ps []spec.Parameter, data interface{}) []error {
+	errs := make(ValidationErrors, 0)
+
+	for _, p := range ps {
+		if p.In != "body" {
+			// Validating only "body" parameters.
+			continue
+		}
+
+		if p.Schema != nil {
+			sch, err := expandSpecSchema(sw, p.Schema)
+			if err != nil {
+				errs = append(errs, ValidationErrorf(p.Name, nil, "param %s: %s", p.Name, err))
+				continue
+			}
+			p.Schema = sch
+		}
+
+		errs = append(errs, validateBodyParam(p, data)...)
+	}
+
+	return errs.Errors()
+}
+
+// DecodeAndValidateBody decodes JSON body from r and validates it against
+// the operation body parameters. Numbers are decoded as json.Number, so they
+// do not lose precision. Bodies nested deeper than DefaultMaxJSONDepth are
+// rejected. Empty or whitespace-only bodies are treated as no body, which is
+// an error only if a body parameter is required. Schemas must not be
+// references, nor recursive, see ValidateBody and ValidateBodyBySpec. It
+// returns the decoded body and errors if any.
+func DecodeAndValidateBody(op *spec.Operation, r io.Reader) (interface{}, []error) {
+	return decodeAndValidateBody(op, r, DefaultMaxJSONDepth, false, validateBodyParam)
+}
+
+// decodeAndValidateBody is like DecodeAndValidateBody, but rejects bodies
+// nested deeper than maxDepth, if positive, and validates body parameters
+// with validateParam. If rejectTrailing is set, bodies with data after the
+// JSON value are rejected.
+func decodeAndValidateBody(
+	op *spec.Operation,
+	r io.Reader,
+	maxDepth int,
+	rejectTrailing bool,
+	validateParam func(p spec.Parameter, body interface{}) ValidationErrors,
+) (interface{}, []error) {
+	var limit *depthLimitReader
+	if maxDepth > 0 {
+		limit = &depthLimitReader{r: r, max: maxDepth}
+		r = limit
+	}
+
+	var body interface{}
+	d := json.NewDecoder(r)
+	d.UseNumber()
+	if err := d.Decode(&body); err != nil {
+		if limit != nil && limit.exceeded {
+			return nil, []error{jsonDepthError(maxDepth)}
+		}
+		if err == io.EOF {
+			// The body is empty or contains only whitespace, which is
+			// the same as no body.
+			return nil, missingBodyErrors(op.Parameters)
+		}
+		return nil, []error{fmt.Errorf("Body contains invalid json")}
+	}
+	if rejectTrailing && hasTrailingData(d) {
+		return nil, []error{fmt.Errorf("Body contains data after the json value")}
+	}
+
+	// Validators report numbers decoded as floats best, the precision does
+	// not matter for validation.
+	data := plainNumbers(body)
+
+	errs := make(ValidationErrors, 0)
+	for _, p := range op.Parameters {
+		if p.In != "body" {
+			continue
+		}
+		errs = append(errs, validateParam(p, data)...)
+	}
+	return body, errs.Errors()
+}
+
+// missingBodyErrors returns an error if a body parameter is required.
+func missingBodyErrors(ps []spec.Parameter) []error {
+	for _, p := range ps {
+		if p.In == "body" && p.Required {
+			return []error{fmt.Errorf("Body is required")}
+		}
+	}
+	return nil
+}
+
+// maxExactFloat is the largest integer float64 holds exactly.
+const maxExactFloat = 1 << 53
+
+// plainNumbers returns a copy of the decoded JSON value with json.Number
+// values replaced by float64, as if decoded without UseNumber. Integers
+// that float64 cannot hold exactly are replaced by int64 instead.
+func plainNumbers(v interface{}) interface{} {
+	switch v := v.(type) {
+	case json.Number:
+		if i, err := v.Int64(); err == nil && (i > maxExactFloat || i < -maxExactFloat) {
+			return i
+		}
+		f, err := v.Float64()
+		if err != nil {
+			return v
+		}
+		return f
+	case map[string]interface{}:
+		m := make(map[string]interface{}, len(v))
+		for k, e := range v {
+			m[k] = plainNumbers(e)
+		}
+		return m
+	case []interface{}:
+		a := make([]interface{}, len(v))
+		for i, e := range v {
+			a[i] = plainNumbers(e)
+		}
+		return a
+	default:
+		return v
+	}
+}
+
+// ValidateBySchema validates data by spec and returns errors if any. Items
+// of arrays declared x-nullable accept null elements.
 func ValidateBySchema(sch *spec.Schema, data interface{}) []error {
-	return validatebySchema(sch, data).Errors()
+	return plainSchemaErrors(validatebySchema(sch, data, "body", strfmt.Default)).Errors()
+}
+
+// ValidateBySchemaDetailed is like ValidateBySchema, but the errors
+// implement SchemaValidationError, so they describe the failed keywords.
+func ValidateBySchemaDetailed(sch *spec.Schema, data interface{}) []error {
+	return validatebySchema(sch, data, "body", strfmt.Default).Errors()
 }
 
 // ValidationError describes validation error.
@@ -94,7 +264,49 @@ func (es ValidationErrors) Errors() []error {
 	return errs
 }
 
-func validateQueryParam(p spec.Parameter, q url.Values) (errs ValidationErrors) {
+// validateValues validates values of parameters located in "in" and returns
+// errors if any. Numbers are parsed as formatted in the number locale, if
+// set, and dates that must be in the future are compared with the clock
+// time. If fail fast is set, validation stops at the first error. Undeclared
+// query parameters are rejected unless allowlisted.
+func validateValues(ps []spec.Parameter, in string, vals url.Values, opts MiddlewareOptions) []error {
+	errs := make(ValidationErrors, 0)
+
+	// Iterate over spec parameters and validate each against the spec.
+	for _, p := range ps {
+		if p.In != in {
+			// Validating only parameters of the requested location.
+			continue
+		}
+
+		if p.Type == "file" {
+			// Files are not passed as values.
+			continue
+		}
+
+		errs = append(errs, validateParam(p, vals, opts)...)
+		if opts.failFast && len(errs) > 0 {
+			return errs[:1].Errors()
+		}
+
+		delete(vals, p.Name) // to check not described parameters passed
+	}
+
+	// Check that no additional parameters passed.
+	for name := range vals {
+		if _, ok := opts.queryAllowlist[name]; ok && in == "query" {
+			continue
+		}
+		errs = append(errs, ValidationErrorf(name, vals.Get(name), "parameter %s is unknown", name))
+		if opts.failFast {
+			break
+		}
+	}
+
+	return errs.Errors()
+}
+
+func validateParam(p spec.Parameter, q url.Values, opts MiddlewareOptions) (errs ValidationErrors) {
 	_, ok := q[p.Name]
 	if !ok {
 		if p.Required {
@@ -103,36 +315,280 @@ func validateQueryParam(p spec.Parameter, q url.Values) (errs ValidationErrors)
 		return errs
 	}
 
-	value, err := ConvertParameter(q[p.Name], p.Type, p.Format)
+	if len(q[p.Name]) > 1 && p.Type != "array" {
+		return append(errs, ValidationErrorf(p.Name, exposedValue(p, q[p.Name]), "parameter %s must not be repeated", p.Name))
+	}
+
+	value, err := opts.convertParam(p, q[p.Name])
 	if err != nil {
 		// TODO: q.Get(p.Name) relies on type that is not array/file.
+		if isSensitive(p) {
+			return append(errs, ValidationErrorf(p.Name, redacted, "%s", sensitiveMessage(p.Name, "type")))
+		}
 		return append(errs, ValidationErrorf(p.Name, q.Get(p.Name), "param %s: %s", p.Name, err))
 	}
 
-	if result := validate.NewParamValidator(&p, strfmt.Default).Validate(value); result != nil {
+	if _, ok := p.Extensions[extItemsTuple]; ok {
+		// Items of tuples are validated by conversion.
+		p.Items = nil
+	}
+	p = withValueType(p, value)
+
+	if result := validate.NewParamValidator(&p, opts.formats).Validate(validationValue(value, p.Type, p.Format, p.Items)); result != nil {
 		for _, e := range result.Errors {
-			errs = append(errs, ValidationErrorf(p.Name, value, e.Error()))
+			message := e.Error()
+			if isSensitive(p) {
+				message = sensitiveMessage(p.Name, errorKeyword(e))
+			}
+			errs = append(errs, ValidationErrorf(p.Name, exposedValue(p, value), "%s", message))
+		}
+	}
+
+	if future, _ := p.Extensions.GetBool(extFuture); future {
+		if t, ok := dateValue(value, p.Format); ok && !t.After(opts.clock.Now()) {
+			errs = append(errs, ValidationErrorf(p.Name, exposedValue(p, value), "param %s must be in the future", p.Name))
 		}
 	}
 
 	return errs
 }
 
+// extFuture is a parameter extension that requires date and date-time
+// values to be in the future when set to true.
+const extFuture = "x-future"
+
+// dateValue returns the time of the value of a string parameter of date or
+// date-time format.
+func dateValue(value interface{}, format string) (time.Time, bool) {
+	s, ok := value.(string)
+	if !ok || (format != "date" && format != "date-time") {
+		return time.Time{}, false
+	}
+	t, err := parseDate(s, format)
+	return t, err == nil
+}
+
+// validationValue returns the value converted by the parameter spec in the
+// form the validator expects: values of string parameters, e.g. durations,
+// are formatted back to strings, as formats are validated on strings.
+func validationValue(value interface{}, typ, format string, items *spec.Items) interface{} {
+	if elems, ok := value.([]interface{}); ok {
+		if items == nil {
+			return elems
+		}
+		res := make([]interface{}, len(elems))
+		for i, e := range elems {
+			res[i] = validationValue(e, items.Type, items.Format, items.Items)
+		}
+		return res
+	}
+
+	if typ == "string" {
+		if s, err := formatPrimitive(value, typ, format); err == nil {
+			return s
+		}
+	}
+	return value
+}
+
+// redacted replaces values of sensitive parameters.
+const redacted = "***"
+
+// isSensitive reports whether the parameter value must not be exposed,
+// e.g. in errors or logs. A parameter is sensitive if it has "password"
+// format or "x-sensitive" extension set to true.
+func isSensitive(p spec.Parameter) bool {
+	sensitive, _ := p.Extensions.GetBool("x-sensitive")
+	return sensitive || p.Format == "password"
+}
+
+// exposedValue returns the value of the parameter that can be exposed.
+func exposedValue(p spec.Parameter, value interface{}) interface{} {
+	if isSensitive(p) {
+		return redacted
+	}
+	return value
+}
+
+// isSensitiveSchema is like isSensitive but for schemas.
+func isSensitiveSchema(sch *spec.Schema) bool {
+	if sch == nil {
+		return false
+	}
+	sensitive, _ := sch.Extensions.GetBool("x-sensitive")
+	return sensitive || sch.Format == "password"
+}
+
+// sensitiveMessage returns the message of an error of the sensitive field.
+// It names only the failed keyword, as messages of validation errors may
+// contain the value, quoted or escaped in any way.
+func sensitiveMessage(field, keyword string) string {
+	if keyword == "" {
+		return fmt.Sprintf("param %s is invalid", field)
+	}
+	return fmt.Sprintf("param %s is invalid (%s)", field, keyword)
+}
+
+// errorKeyword returns the schema keyword failed by a go-openapi validation
+// error, or an empty string if unknown.
+func errorKeyword(err error) string {
+	ve, ok := err.(*errors.Validation)
+	if !ok {
+		return ""
+	}
+	keyword, _ := schemaKeyword(ve, nil)
+	return keyword
+}
+
+// schemaAt returns a schema of the data located by the dot-separated field
+// path, e.g. "user.tokens.0", or nil if the path cannot be followed.
+func schemaAt(sch *spec.Schema, field string) *spec.Schema {
+	if field == "" {
+		return sch
+	}
+
+	for _, name := range strings.Split(field, ".") {
+		if sch == nil {
+			return nil
+		}
+
+		if prop, ok := sch.Properties[name]; ok {
+			sch = &prop
+			continue
+		}
+
+		if prop := patternPropertySchema(sch, name); prop != nil {
+			sch = prop
+			continue
+		}
+
+		if sch.AdditionalProperties != nil && sch.AdditionalProperties.Schema != nil {
+			// Numeric names are indexes if the schema has items.
+			if _, err := strconv.Atoi(name); err != nil || sch.Items == nil {
+				sch = sch.AdditionalProperties.Schema
+				continue
+			}
+		}
+
+		if sch.Items != nil && sch.Items.Schema != nil {
+			if _, err := strconv.Atoi(name); err == nil {
+				sch = sch.Items.Schema
+				continue
+			}
+		}
+
+		return nil
+	}
+
+	return sch
+}
+
+// valueAt returns the value of the field in data, or nil if there is none.
+// Numeric names of the field are indexes of arrays.
+func valueAt(data interface{}, field string) interface{} {
+	if field == "" {
+		return data
+	}
+
+	for _, name := range strings.Split(field, ".") {
+		switch v := data.(type) {
+		case map[string]interface{}:
+			data = v[name]
+		case []interface{}:
+			i, err := strconv.Atoi(name)
+			if err != nil || i < 0 || i >= len(v) {
+				return nil
+			}
+			data = v[i]
+		default:
+			return nil
+		}
+	}
+
+	return data
+}
+
+// patternPropertySchema returns the schema of the pattern property that
+// matches the name, or nil if there is none. Patterns are tried in lexical
+// order.
+func patternPropertySchema(sch *spec.Schema, name string) *spec.Schema {
+	for _, pattern := range sortedPatterns(sch) {
+		if re, err := patternRegexp(pattern); err == nil && re.MatchString(name) {
+			prop := sch.PatternProperties[pattern]
+			return &prop
+		}
+	}
+	return nil
+}
+
+// sortedPatterns returns patterns of the schema pattern properties in
+// lexical order.
+func sortedPatterns(sch *spec.Schema) []string {
+	patterns := make([]string, 0, len(sch.PatternProperties))
+	for pattern := range sch.PatternProperties {
+		patterns = append(patterns, pattern)
+	}
+	sort.Strings(patterns)
+	return patterns
+}
+
 func validateBodyParam(p spec.Parameter, data interface{}) (errs ValidationErrors) {
-	return validatebySchema(p.Schema, data)
+	if ref := unresolvedRef(p.Schema); ref != "" {
+		// The validator cannot resolve references without the spec, and
+		// the body must not pass unvalidated.
+		return append(errs, ValidationErrorf(p.Name, nil, "param %s: schema reference %s is not resolved", p.Name, ref))
+	}
+	return plainSchemaErrors(validatebySchema(requestSchema(p.Schema), data, p.Name, strfmt.Default))
 }
 
-func validatebySchema(sch *spec.Schema, data interface{}) (errs ValidationErrors) {
-	err := validate.AgainstSchema(sch, data, strfmt.Default)
-	ves, ok := err.(*errors.CompositeError)
-	if ok && len(ves.Errors) > 0 {
-		for _, e := range ves.Errors {
-			ve := e.(*errors.Validation)
-			errs = append(errs, ValidationErrorf(strings.TrimPrefix(ve.Name, "."), nil, strings.TrimPrefix(ve.Error(), ".")))
+// unresolvedRef returns the first reference found in the schema or its
+// subschemas, or an empty string if there is none.
+func unresolvedRef(sch *spec.Schema) string {
+	if sch == nil {
+		return ""
+	}
+	if ref := sch.Ref.String(); ref != "" {
+		return ref
+	}
+
+	var subs []*spec.Schema
+	for _, name := range sortedProperties(sch) {
+		prop := sch.Properties[name]
+		subs = append(subs, &prop)
+	}
+	for _, pattern := range sortedPatterns(sch) {
+		prop := sch.PatternProperties[pattern]
+		subs = append(subs, &prop)
+	}
+	if sch.AdditionalProperties != nil {
+		subs = append(subs, sch.AdditionalProperties.Schema)
+	}
+	if sch.Items != nil {
+		subs = append(subs, sch.Items.Schema)
+		for i := range sch.Items.Schemas {
+			subs = append(subs, &sch.Items.Schemas[i])
+		}
+	}
+	for _, all := range [][]spec.Schema{sch.AllOf, sch.AnyOf, sch.OneOf} {
+		for i := range all {
+			subs = append(subs, &all[i])
 		}
 	}
+	subs = append(subs, sch.Not)
 
-	return errs
+	for _, sub := range subs {
+		if ref := unresolvedRef(sub); ref != "" {
+			return ref
+		}
+	}
+	return ""
+}
+
+// validatebySchema validates data by schema. root is used to name the data
+// itself in errors, e.g. when data is a primitive or an array.
+func validatebySchema(sch *spec.Schema, data interface{}, root string, formats strfmt.Registry) ValidationErrors {
+	sch = nullableItemsSchema(sch)
+	return schemaErrors(sch, data, validate.AgainstSchema(sch, data, formats), root)
 }
 
 // valErr implements ValidationError.
@@ -0,0 +1,574 @@
+package oas2
+
+import (
+	"fmt"
+	"net/http"
+	"reflect"
+	"regexp"
+	"sort"
+	"strings"
+
+	"github.com/go-openapi/spec"
+	"github.com/go-openapi/strfmt"
+	"github.com/go-openapi/validate"
+)
+
+// ValidateSpec checks the spec for authoring mistakes that make routing or
+// validation silently ineffective and returns errors if any.
+func ValidateSpec(sw *spec.Swagger) []error {
+	errs, _ := checkSpec(sw)
+	return errs
+}
+
+// checkSpec is like ValidateSpec, but also returns the errors that make the
+// spec unusable as fatal: invalid patterns, which would fail validation of
+// every request, and collection formats that cannot be parsed, which would
+// misparse values.
+func checkSpec(sw *spec.Swagger) (errs, fatal []error) {
+	forEachOperation(sw, func(path, method string, pi spec.PathItem, op *spec.Operation) {
+		patternErrs := validatePatterns(sw, pi, op)
+		formatErrs := validateCollectionFormats(sw, pi, op)
+		fatal = append(fatal, patternErrs...)
+		fatal = append(fatal, formatErrs...)
+
+		errs = append(errs, validatePathTemplate(sw, path, pi, op)...)
+		errs = append(errs, validateParamItems(sw, pi, op)...)
+		errs = append(errs, validateParamRefs(sw, pi, op)...)
+		errs = append(errs, patternErrs...)
+		errs = append(errs, formatErrs...)
+		errs = append(errs, validateDeclaredValues(sw, pi, op)...)
+		errs = append(errs, validateMediaTypes(sw, pi, op)...)
+		errs = append(errs, validateRequiredProperties(sw, pi, op)...)
+	})
+
+	return errs, fatal
+}
+
+// specErrors joins spec errors into a single error.
+func specErrors(errs []error) error {
+	msgs := make([]string, len(errs))
+	for i, err := range errs {
+		msgs[i] = err.Error()
+	}
+	return fmt.Errorf("invalid spec: %s", strings.Join(msgs, "; "))
+}
+
+var pathTemplateParam = regexp.MustCompile(`{([^{}]+)}`)
+
+// validatePathTemplate checks that path template placeholders match path
+// parameters declared for the operation.
+func validatePathTemplate(sw *spec.Swagger, path string, pi spec.PathItem, op *spec.Operation) (errs []error) {
+	declared := make(map[string]struct{})
+	for _, p := range effectiveParameters(sw, pi, op) {
+		if p.In == "path" {
+			declared[p.Name] = struct{}{}
+		}
+	}
+
+	placeholders := make(map[string]struct{})
+	for _, m := range pathTemplateParam.FindAllStringSubmatch(path, -1) {
+		name := m[1]
+		placeholders[name] = struct{}{}
+		if _, ok := declared[name]; !ok {
+			errs = append(errs, fmt.Errorf(
+				"operation %s: path parameter %s is not declared", op.ID, name,
+			))
+		}
+	}
+
+	for _, name := range sortedKeys(declared) {
+		if _, ok := placeholders[name]; !ok {
+			errs = append(errs, fmt.Errorf(
+				"operation %s: path parameter %s is not in path %s", op.ID, name, path,
+			))
+		}
+	}
+
+	return errs
+}
+
+// validateParamItems checks that items of array parameters are of
+// a primitive type, as OAS 2.0 requires for non-body parameters.
+func validateParamItems(sw *spec.Swagger, pi spec.PathItem, op *spec.Operation) (errs []error) {
+	for _, p := range effectiveParameters(sw, pi, op) {
+		if p.In == "body" || p.Type != "array" {
+			continue
+		}
+
+		if p.Items == nil {
+			errs = append(errs, fmt.Errorf(
+				"operation %s: parameter %s: items are not declared", op.ID, p.Name,
+			))
+			continue
+		}
+
+		if _, err := resolveItems(sw, p.Items); err != nil {
+			errs = append(errs, fmt.Errorf(
+				"operation %s: parameter %s: %s", op.ID, p.Name, err,
+			))
+		}
+	}
+
+	return errs
+}
+
+// validateCollectionFormats checks that "multi" collection format is used
+// only for query and form data parameters, as values of other parameters
+// cannot be repeated. Nested items cannot use it either.
+func validateCollectionFormats(sw *spec.Swagger, pi spec.PathItem, op *spec.Operation) (errs []error) {
+	for _, p := range effectiveParameters(sw, pi, op) {
+		if p.CollectionFormat == "multi" && p.In != "query" && p.In != "formData" {
+			errs = append(errs, fmt.Errorf(
+				"operation %s: parameter %s: collection format multi is not allowed in %s", op.ID, p.Name, p.In,
+			))
+		}
+
+		for items := p.Items; items != nil; items = items.Items {
+			if items.CollectionFormat == "multi" {
+				errs = append(errs, fmt.Errorf(
+					"operation %s: parameter %s: collection format multi is not allowed for items", op.ID, p.Name,
+				))
+			}
+		}
+	}
+
+	return errs
+}
+
+// validateMediaTypes checks that media types the operation consumes and
+// produces, including inherited from the spec, are well-formed, as
+// malformed ones never match content types of requests and responses.
+func validateMediaTypes(sw *spec.Swagger, pi spec.PathItem, op *spec.Operation) (errs []error) {
+	eop := effectiveOperation(sw, op)
+	for _, list := range []struct {
+		name       string
+		mediaTypes []string
+	}{
+		{"consumes", eop.Consumes},
+		{"produces", eop.Produces},
+	} {
+		for _, mt := range list.mediaTypes {
+			if err := checkMediaType(mt); err != nil {
+				errs = append(errs, fmt.Errorf(
+					"operation %s: %s: invalid media type %q: %s", op.ID, list.name, mt, err,
+				))
+			}
+		}
+	}
+
+	return errs
+}
+
+// validateParamRefs checks that parameter references can be resolved, as
+// unresolved parameters are not validated.
+func validateParamRefs(sw *spec.Swagger, pi spec.PathItem, op *spec.Operation) (errs []error) {
+	for _, ps := range [][]spec.Parameter{pi.Parameters, op.Parameters} {
+		for _, p := range ps {
+			if p.Ref.String() == "" {
+				continue
+			}
+
+			if _, err := spec.ResolveParameter(sw, p.Ref); err != nil {
+				errs = append(errs, fmt.Errorf(
+					"operation %s: parameter reference %s cannot be resolved", op.ID, p.Ref.String(),
+				))
+			}
+		}
+	}
+
+	return errs
+}
+
+// validatePatterns compiles patterns of the operation parameters and
+// response schemas, and returns errors for invalid ones.
+func validatePatterns(sw *spec.Swagger, pi spec.PathItem, op *spec.Operation) (errs []error) {
+	for _, p := range effectiveParameters(sw, pi, op) {
+		if p.In == "body" {
+			if p.Schema != nil {
+				if err := checkSchemaPatterns(p.Schema, ""); err != nil {
+					errs = append(errs, fmt.Errorf("operation %s: parameter %s: %s", op.ID, p.Name, err))
+				}
+			}
+			continue
+		}
+
+		if p.Pattern != "" {
+			if err := compilePattern(p.Pattern); err != nil {
+				errs = append(errs, fmt.Errorf(
+					"operation %s: parameter %s: invalid pattern %q: %s", op.ID, p.Name, p.Pattern, err,
+				))
+			}
+		}
+
+		for items := p.Items; items != nil; items = items.Items {
+			if items.Pattern == "" {
+				continue
+			}
+			if err := compilePattern(items.Pattern); err != nil {
+				errs = append(errs, fmt.Errorf(
+					"operation %s: parameter %s: invalid items pattern %q: %s", op.ID, p.Name, items.Pattern, err,
+				))
+			}
+		}
+	}
+
+	forEachResponseSchema(op, func(name string, sch *spec.Schema) {
+		if err := checkSchemaPatterns(sch, ""); err != nil {
+			errs = append(errs, fmt.Errorf("operation %s: %s: %s", op.ID, name, err))
+		}
+	})
+
+	return errs
+}
+
+// validateDeclaredValues checks that defaults and examples of the operation
+// parameters and of body and response schemas satisfy constraints they are
+// declared with, and that defaults of parameter items are members of their
+// enums, if any.
+func validateDeclaredValues(sw *spec.Swagger, pi spec.PathItem, op *spec.Operation) (errs []error) {
+	for _, p := range effectiveParameters(sw, pi, op) {
+		if p.In == "body" {
+			if p.Schema != nil {
+				for _, err := range schemaValueErrors(p.Schema, "") {
+					errs = append(errs, fmt.Errorf("operation %s: parameter %s: %s", op.ID, p.Name, err))
+				}
+			}
+			continue
+		}
+
+		for _, err := range paramValueErrors(p) {
+			errs = append(errs, fmt.Errorf("operation %s: parameter %s: %s", op.ID, p.Name, err))
+		}
+
+		for items := p.Items; items != nil; items = items.Items {
+			if items.Default != nil && !inEnum(items.Default, items.Enum) {
+				errs = append(errs, fmt.Errorf(
+					"operation %s: parameter %s: items default %v is not in enum", op.ID, p.Name, items.Default,
+				))
+			}
+		}
+	}
+
+	forEachResponseSchema(op, func(name string, sch *spec.Schema) {
+		for _, err := range schemaValueErrors(sch, "") {
+			errs = append(errs, fmt.Errorf("operation %s: %s: %s", op.ID, name, err))
+		}
+	})
+
+	return errs
+}
+
+// paramValueErrors returns errors for the default and the example of the
+// parameter that do not satisfy its constraints. They are checked by the same
+// validator as request values.
+func paramValueErrors(p spec.Parameter) (errs []error) {
+	for _, v := range []struct {
+		name  string
+		value interface{}
+	}{
+		{name: "default", value: p.Default},
+		{name: "example", value: p.Example},
+	} {
+		if v.value == nil {
+			continue
+		}
+
+		if !inEnum(v.value, p.Enum) {
+			errs = append(errs, fmt.Errorf("%s %v is not in enum", v.name, v.value))
+			continue
+		}
+
+		if result := validate.NewParamValidator(&p, strfmt.Default).Validate(v.value); result != nil {
+			for _, err := range result.Errors {
+				errs = append(errs, fmt.Errorf("%s %v: %s", v.name, v.value, err))
+			}
+		}
+	}
+	return errs
+}
+
+// schemaValueErrors returns errors for defaults and examples of the schema
+// and its subschemas that do not satisfy constraints of their schemas.
+func schemaValueErrors(sch *spec.Schema, field string) (errs []error) {
+	for _, v := range []struct {
+		name  string
+		value interface{}
+	}{
+		{name: "default", value: sch.Default},
+		{name: "example", value: sch.Example},
+	} {
+		if v.value == nil {
+			continue
+		}
+
+		if !inEnum(v.value, sch.Enum) {
+			errs = append(errs, fmt.Errorf("schema%s: %s %v is not in enum", fieldSuffix(field), v.name, v.value))
+			continue
+		}
+
+		for _, err := range validatebySchema(sch, v.value, v.name, strfmt.Default) {
+			errs = append(errs, fmt.Errorf("schema%s: %s", fieldSuffix(field), err))
+		}
+	}
+
+	names := make([]string, 0, len(sch.Properties))
+	for name := range sch.Properties {
+		names = append(names, name)
+	}
+	sort.Strings(names)
+	for _, name := range names {
+		prop := sch.Properties[name]
+		errs = append(errs, schemaValueErrors(&prop, joinField(field, name))...)
+	}
+
+	if sch.Items != nil {
+		if sch.Items.Schema != nil {
+			errs = append(errs, schemaValueErrors(sch.Items.Schema, joinField(field, "items"))...)
+		}
+		for i := range sch.Items.Schemas {
+			errs = append(errs, schemaValueErrors(&sch.Items.Schemas[i], joinField(field, "items"))...)
+		}
+	}
+
+	for _, subs := range [][]spec.Schema{sch.AllOf, sch.AnyOf, sch.OneOf} {
+		for i := range subs {
+			errs = append(errs, schemaValueErrors(&subs[i], field)...)
+		}
+	}
+
+	return errs
+}
+
+// inEnum reports whether the value is a member of the enum. Any value is
+// a member of an empty enum. Numbers are compared by value regardless of
+// their types.
+func inEnum(value interface{}, enum []interface{}) bool {
+	if len(enum) == 0 {
+		return true
+	}
+
+	for _, e := range enum {
+		if reflect.DeepEqual(value, e) {
+			return true
+		}
+		if v, ok := toFloat(value); ok {
+			if f, ok := toFloat(e); ok && v == f {
+				return true
+			}
+		}
+	}
+	return false
+}
+
+func toFloat(v interface{}) (float64, bool) {
+	switch n := v.(type) {
+	case float64:
+		return n, true
+	case float32:
+		return float64(n), true
+	case int:
+		return float64(n), true
+	case int32:
+		return float64(n), true
+	case int64:
+		return float64(n), true
+	default:
+		return 0, false
+	}
+}
+
+// validateRequiredProperties checks that properties listed as required by
+// body and response schemas of the operation are declared, as a misspelled
+// name makes the real property optional. Schemas are checked with references
+// resolved, so properties merged by allOf are considered. Schemas whose
+// references cannot be resolved are skipped.
+func validateRequiredProperties(sw *spec.Swagger, pi spec.PathItem, op *spec.Operation) (errs []error) {
+	check := func(prefix string, sch *spec.Schema) {
+		expanded, err := expandSpecSchema(sw, sch)
+		if err != nil {
+			return
+		}
+		for _, err := range requiredPropertyErrors(expanded, "", nil) {
+			errs = append(errs, fmt.Errorf("operation %s: %s: %s", op.ID, prefix, err))
+		}
+	}
+
+	for _, p := range effectiveParameters(sw, pi, op) {
+		if p.In == "body" && p.Schema != nil {
+			check("parameter "+p.Name, p.Schema)
+		}
+	}
+
+	forEachResponseSchema(op, check)
+
+	return errs
+}
+
+// requiredPropertyErrors returns errors for required properties of the
+// schema and its subschemas that are not declared by the schema, its allOf
+// subschemas or the schema it is a subschema of, passed as declared.
+// Schemas that declare no properties or allow properties not declared by
+// name, e.g. with patternProperties, may require any property.
+func requiredPropertyErrors(sch *spec.Schema, field string, declared map[string]struct{}) (errs []error) {
+	merged := make(map[string]struct{}, len(declared))
+	for name := range declared {
+		merged[name] = struct{}{}
+	}
+	open := collectProperties(sch, merged)
+
+	if !open && len(merged) > 0 {
+		for _, name := range sch.Required {
+			if _, ok := merged[name]; !ok {
+				errs = append(errs, fmt.Errorf("schema%s: required property %s is not declared", fieldSuffix(field), name))
+			}
+		}
+	}
+
+	for _, subs := range [][]spec.Schema{sch.AllOf, sch.AnyOf, sch.OneOf} {
+		for i := range subs {
+			errs = append(errs, requiredPropertyErrors(&subs[i], field, merged)...)
+		}
+	}
+
+	names := make([]string, 0, len(sch.Properties))
+	for name := range sch.Properties {
+		names = append(names, name)
+	}
+	sort.Strings(names)
+	for _, name := range names {
+		prop := sch.Properties[name]
+		errs = append(errs, requiredPropertyErrors(&prop, joinField(field, name), nil)...)
+	}
+
+	if sch.AdditionalProperties != nil && sch.AdditionalProperties.Schema != nil {
+		errs = append(errs, requiredPropertyErrors(sch.AdditionalProperties.Schema, field, nil)...)
+	}
+
+	if sch.Items != nil {
+		if sch.Items.Schema != nil {
+			errs = append(errs, requiredPropertyErrors(sch.Items.Schema, joinField(field, "items"), nil)...)
+		}
+		for i := range sch.Items.Schemas {
+			errs = append(errs, requiredPropertyErrors(&sch.Items.Schemas[i], joinField(field, "items"), nil)...)
+		}
+	}
+
+	return errs
+}
+
+// collectProperties adds names of properties declared by the schema and its
+// allOf subschemas to names. It reports whether the schema allows properties
+// not declared by name.
+func collectProperties(sch *spec.Schema, names map[string]struct{}) (open bool) {
+	for name := range sch.Properties {
+		names[name] = struct{}{}
+	}
+	if len(sch.PatternProperties) > 0 || sch.Ref.String() != "" {
+		open = true
+	}
+	if ap := sch.AdditionalProperties; ap != nil && (ap.Schema != nil || ap.Allows) {
+		open = true
+	}
+	for i := range sch.AllOf {
+		if collectProperties(&sch.AllOf[i], names) {
+			open = true
+		}
+	}
+	return open
+}
+
+// specMethods lists HTTP methods of OAS 2.0 path item operations.
+var specMethods = []string{
+	http.MethodGet,
+	http.MethodPut,
+	http.MethodPost,
+	http.MethodDelete,
+	http.MethodOptions,
+	http.MethodHead,
+	http.MethodPatch,
+}
+
+// forEachOperation calls fn for each operation in the spec in a stable order.
+func forEachOperation(sw *spec.Swagger, fn func(path, method string, pi spec.PathItem, op *spec.Operation)) {
+	if sw.Paths == nil {
+		return
+	}
+
+	paths := make([]string, 0, len(sw.Paths.Paths))
+	for path := range sw.Paths.Paths {
+		paths = append(paths, path)
+	}
+	sort.Strings(paths)
+
+	for _, path := range paths {
+		pi := sw.Paths.Paths[path]
+		for _, method := range specMethods {
+			if op := pathItemOperation(pi, method); op != nil {
+				fn(path, method, pi, op)
+			}
+		}
+	}
+}
+
+// forEachResponse calls fn for the default response of the operation, if
+// any, and then for each response by status in ascending order. name
+// describes the response, e.g. "response 200".
+func forEachResponse(op *spec.Operation, fn func(name string, r spec.Response)) {
+	if op.Responses == nil {
+		return
+	}
+
+	if op.Responses.Default != nil {
+		fn("default response", *op.Responses.Default)
+	}
+
+	statuses := make([]int, 0, len(op.Responses.StatusCodeResponses))
+	for status := range op.Responses.StatusCodeResponses {
+		statuses = append(statuses, status)
+	}
+	sort.Ints(statuses)
+	for _, status := range statuses {
+		fn(fmt.Sprintf("response %d", status), op.Responses.StatusCodeResponses[status])
+	}
+}
+
+// forEachResponseSchema is like forEachResponse, but calls fn with schemas
+// of the responses that declare one.
+func forEachResponseSchema(op *spec.Operation, fn func(name string, sch *spec.Schema)) {
+	forEachResponse(op, func(name string, r spec.Response) {
+		if r.Schema != nil {
+			fn(name, r.Schema)
+		}
+	})
+}
+
+// pathItemOperation returns the path item operation for the method.
+func pathItemOperation(pi spec.PathItem, method string) *spec.Operation {
+	switch method {
+	case http.MethodGet:
+		return pi.Get
+	case http.MethodPut:
+		return pi.Put
+	case http.MethodPost:
+		return pi.Post
+	case http.MethodDelete:
+		return pi.Delete
+	case http.MethodOptions:
+		return pi.Options
+	case http.MethodHead:
+		return pi.Head
+	case http.MethodPatch:
+		return pi.Patch
+	default:
+		return nil
+	}
+}
+
+func sortedKeys(m map[string]struct{}) []string {
+	keys := make([]string, 0, len(m))
+	for k := range m {
+		keys = append(keys, k)
+	}
+	sort.Strings(keys)
+	return keys
+}
@@ -0,0 +1,399 @@
+package oas2
+
+import (
+	"fmt"
+	"reflect"
+	"testing"
+)
+
+func TestValidateSpec(t *testing.T) {
+	cases := []struct {
+		src            string
+		expectedErrors []error
+	}{
+		// path parameters match the template
+		{
+			src: `
+swagger: "2.0"
+info:
+  title: test
+  version: "1.0"
+paths:
+  /pet/{petId}:
+    parameters:
+    - name: petId
+      in: path
+      required: true
+      type: integer
+    get:
+      operationId: getPet
+      responses:
+        200:
+          description: ok
+`,
+		},
+		// placeholder is not declared and declared parameter is not in path
+		{
+			src: `
+swagger: "2.0"
+info:
+  title: test
+  version: "1.0"
+paths:
+  /pet/{id}:
+    get:
+      operationId: getPet
+      parameters:
+      - name: petId
+        in: path
+        required: true
+        type: integer
+      responses:
+        200:
+          description: ok
+`,
+			expectedErrors: []error{
+				fmt.Errorf("operation getPet: path parameter id is not declared"),
+				fmt.Errorf("operation getPet: path parameter petId is not in path /pet/{id}"),
+			},
+		},
+		// parameter reference cannot be resolved
+		{
+			src: `
+swagger: "2.0"
+info:
+  title: test
+  version: "1.0"
+paths:
+  /pets:
+    get:
+      operationId: findPets
+      parameters:
+      - $ref: "#/parameters/Limit"
+      responses:
+        200:
+          description: ok
+parameters:
+  Offset:
+    name: offset
+    in: query
+    type: integer
+`,
+			expectedErrors: []error{
+				fmt.Errorf("operation findPets: parameter reference #/parameters/Limit cannot be resolved"),
+			},
+		},
+		// array items reference a primitive definition
+		{
+			src: `
+swagger: "2.0"
+info:
+  title: test
+  version: "1.0"
+paths:
+  /pets:
+    get:
+      operationId: findPets
+      parameters:
+      - name: ids
+        in: query
+        type: array
+        items:
+          $ref: "#/definitions/PetID"
+      responses:
+        200:
+          description: ok
+definitions:
+  PetID:
+    type: integer
+    format: int64
+`,
+		},
+		// array items are not of a primitive type
+		{
+			src: `
+swagger: "2.0"
+info:
+  title: test
+  version: "1.0"
+paths:
+  /pets:
+    get:
+      operationId: findPets
+      parameters:
+      - name: pets
+        in: query
+        type: array
+        items:
+          $ref: "#/definitions/Pet"
+      - name: owners
+        in: query
+        type: array
+        items:
+          type: object
+      responses:
+        200:
+          description: ok
+definitions:
+  Pet:
+    type: object
+    properties:
+      name:
+        type: string
+`,
+			expectedErrors: []error{
+				fmt.Errorf(`operation findPets: parameter pets: items of type "object" are not of a primitive type`),
+				fmt.Errorf(`operation findPets: parameter owners: items of type "object" are not of a primitive type`),
+			},
+		},
+		// defaults are not in enums
+		{
+			src: `
+swagger: "2.0"
+info:
+  title: test
+  version: "1.0"
+paths:
+  /pets:
+    post:
+      operationId: addPet
+      parameters:
+      - name: sort
+        in: query
+        type: string
+        enum: [name, age]
+        default: id
+      - name: limit
+        in: query
+        type: integer
+        enum: [10, 50]
+        default: 10
+      - name: tags
+        in: query
+        type: array
+        items:
+          type: string
+          enum: [cat, dog]
+          default: fish
+      - name: pet
+        in: body
+        schema:
+          type: object
+          properties:
+            status:
+              type: string
+              enum: [available, sold]
+              default: pending
+      responses:
+        200:
+          description: ok
+          schema:
+            type: object
+            properties:
+              owner:
+                type: object
+                properties:
+                  kind:
+                    type: string
+                    enum: [person, shelter]
+                    default: shelter
+                  size:
+                    type: integer
+                    enum: [1, 2]
+                    default: 3
+`,
+			expectedErrors: []error{
+				fmt.Errorf(`operation addPet: parameter sort: default id is not in enum`),
+				fmt.Errorf(`operation addPet: parameter tags: items default fish is not in enum`),
+				fmt.Errorf(`operation addPet: parameter pet: schema status: default pending is not in enum`),
+				fmt.Errorf(`operation addPet: response 200: schema owner.size: default 3 is not in enum`),
+			},
+		},
+		// multi collection format is not allowed
+		{
+			src: `
+swagger: "2.0"
+info:
+  title: test
+  version: "1.0"
+paths:
+  /pets/{ids}:
+    get:
+      operationId: getPets
+      parameters:
+      - name: ids
+        in: path
+        required: true
+        type: array
+        collectionFormat: multi
+        items:
+          type: integer
+      - name: tags
+        in: query
+        type: array
+        collectionFormat: multi
+        items:
+          type: array
+          collectionFormat: multi
+          items:
+            type: string
+      responses:
+        200:
+          description: ok
+`,
+			expectedErrors: []error{
+				fmt.Errorf(`operation getPets: parameter ids: collection format multi is not allowed in path`),
+				fmt.Errorf(`operation getPets: parameter tags: collection format multi is not allowed for items`),
+			},
+		},
+		// media types are malformed
+		{
+			src: `
+swagger: "2.0"
+info:
+  title: test
+  version: "1.0"
+produces:
+- application/json; charset
+paths:
+  /pets:
+    post:
+      operationId: addPet
+      consumes:
+      - aplication/json
+      - application/vnd.pets+json; version=2
+      - text
+      responses:
+        200:
+          description: ok
+    get:
+      operationId: getPets
+      produces:
+      - text/plain; charset=utf-8
+      - "*/*"
+      responses:
+        200:
+          description: ok
+`,
+			expectedErrors: []error{
+				fmt.Errorf(`operation addPet: consumes: invalid media type "aplication/json": unknown top-level type aplication`),
+				fmt.Errorf(`operation addPet: consumes: invalid media type "text": subtype is missing`),
+				fmt.Errorf(`operation addPet: produces: invalid media type "application/json; charset": mime: invalid media parameter`),
+			},
+		},
+		// examples and defaults violate their constraints
+		{
+			src: `
+swagger: "2.0"
+info:
+  title: test
+  version: "1.0"
+paths:
+  /pets:
+    post:
+      operationId: addPet
+      parameters:
+      - name: code
+        in: query
+        type: string
+        pattern: ^[a-z]+$
+        default: abc
+        example: ABC
+      - name: limit
+        in: query
+        type: integer
+        maximum: 100
+        default: 10
+      - name: pet
+        in: body
+        schema:
+          type: object
+          properties:
+            name:
+              type: string
+              maxLength: 5
+              example: Kittykitty
+            age:
+              type: integer
+              maximum: 30
+              example: 3
+      responses:
+        200:
+          description: ok
+          schema:
+            type: object
+            properties:
+              score:
+                type: number
+                maximum: 10
+                default: 11
+`,
+			expectedErrors: []error{
+				fmt.Errorf(`operation addPet: parameter code: example ABC: code in query should match '^[a-z]+$'`),
+				fmt.Errorf(`operation addPet: parameter pet: schema name: example in body should be at most 5 chars long`),
+				fmt.Errorf(`operation addPet: response 200: schema score: default in body should be less than or equal to 10`),
+			},
+		},
+		// required properties are not declared
+		{
+			src: `
+swagger: "2.0"
+info:
+  title: test
+  version: "1.0"
+paths:
+  /pets:
+    post:
+      operationId: addPet
+      parameters:
+      - name: pet
+        in: body
+        schema:
+          allOf:
+          - $ref: "#/definitions/Named"
+          - type: object
+            required: [name, age]
+            properties:
+              age:
+                type: integer
+              address:
+                type: object
+                required: [zipp]
+                properties:
+                  zip:
+                    type: string
+              labels:
+                type: object
+                required: [color]
+                additionalProperties:
+                  type: string
+      responses:
+        200:
+          description: ok
+          schema:
+            type: array
+            items:
+              $ref: "#/definitions/Named"
+definitions:
+  Named:
+    type: object
+    required: [nmae]
+    properties:
+      name:
+        type: string
+`,
+			expectedErrors: []error{
+				fmt.Errorf(`operation addPet: parameter pet: schema: required property nmae is not declared`),
+				fmt.Errorf(`operation addPet: parameter pet: schema address: required property zipp is not declared`),
+				fmt.Errorf(`operation addPet: response 200: schema items: required property nmae is not declared`),
+			},
+		},
+	}
+
+	for _, c := range cases {
+		errs := ValidateSpec(parseSpec(c.src))
+		if !reflect.DeepEqual(c.expectedErrors, errs) {
+			t.Errorf("Expected errors to be\n%v\nbut got\n%v", c.expectedErrors, errs)
+		}
+	}
+}
@@ -1,15 +1,41 @@
 package oas2
 
 import (
+	"encoding/json"
+	"fmt"
 	"net/url"
 	"reflect"
+	"sort"
+	"strings"
 	"testing"
 
 	"github.com/go-openapi/spec"
 )
 
 func TestValidateQuery(t *testing.T) {
+	colorsParam := spec.Parameter{
+		ParamProps: spec.ParamProps{
+			Name: "color",
+			In:   "query",
+		},
+		SimpleSchema: spec.SimpleSchema{
+			Type:             "array",
+			CollectionFormat: "multi",
+			Items: &spec.Items{
+				SimpleSchema: spec.SimpleSchema{
+					Type: "string",
+				},
+				CommonValidations: spec.CommonValidations{
+					Enum: []interface{}{"red", "green", "blue"},
+				},
+			},
+		},
+	}
+
 	var maxAge float64 = 18
+	var pageSize float64 = 10
+	var priceStep = 0.05
+	var minPasswordLength int64 = 8
 
 	cases := []struct {
 		ps             []spec.Parameter
@@ -93,6 +119,167 @@ func TestValidateQuery(t *testing.T) {
 				ValidationErrorf("age", int32(17), "age in query should be greater than or equal to 18"),
 			},
 		},
+		// integer multipleOf
+		{
+			ps: []spec.Parameter{
+				{
+					ParamProps: spec.ParamProps{
+						Name: "limit",
+						In:   "query",
+					},
+					SimpleSchema: spec.SimpleSchema{
+						Type:   "integer",
+						Format: "int32",
+					},
+					CommonValidations: spec.CommonValidations{
+						MultipleOf: &pageSize,
+					},
+				},
+			},
+			q: url.Values{"limit": {"25"}},
+			expectedErrors: []error{
+				ValidationErrorf("limit", int32(25), "limit in query should be a multiple of 10"),
+			},
+		},
+		// fractional multipleOf is compared with tolerance
+		{
+			ps: []spec.Parameter{
+				{
+					ParamProps: spec.ParamProps{
+						Name: "price",
+						In:   "query",
+					},
+					SimpleSchema: spec.SimpleSchema{
+						Type: "number",
+					},
+					CommonValidations: spec.CommonValidations{
+						MultipleOf: &priceStep,
+					},
+				},
+			},
+			q: url.Values{"price": {"1.1"}},
+		},
+		// fractional multipleOf
+		{
+			ps: []spec.Parameter{
+				{
+					ParamProps: spec.ParamProps{
+						Name: "price",
+						In:   "query",
+					},
+					SimpleSchema: spec.SimpleSchema{
+						Type: "number",
+					},
+					CommonValidations: spec.CommonValidations{
+						MultipleOf: &priceStep,
+					},
+				},
+			},
+			q: url.Values{"price": {"1.12"}},
+			expectedErrors: []error{
+				ValidationErrorf("price", 1.12, "price in query should be a multiple of 0.05"),
+			},
+		},
+		// password values are validated but not exposed
+		{
+			ps: []spec.Parameter{
+				{
+					ParamProps: spec.ParamProps{
+						Name: "password",
+						In:   "query",
+					},
+					SimpleSchema: spec.SimpleSchema{
+						Type:   "string",
+						Format: "password",
+					},
+					CommonValidations: spec.CommonValidations{
+						MinLength: &minPasswordLength,
+					},
+				},
+			},
+			q: url.Values{"password": {"s3cr3t"}},
+			expectedErrors: []error{
+				ValidationErrorf("password", "***", "param password is invalid (minLength)"),
+			},
+		},
+		// values of parameters marked sensitive are not exposed
+		{
+			ps: []spec.Parameter{
+				{
+					VendorExtensible: spec.VendorExtensible{
+						Extensions: spec.Extensions{"x-sensitive": true},
+					},
+					ParamProps: spec.ParamProps{
+						Name: "pin",
+						In:   "query",
+					},
+					SimpleSchema: spec.SimpleSchema{
+						Type: "integer",
+					},
+				},
+			},
+			q: url.Values{"pin": {"12x4"}},
+			expectedErrors: []error{
+				ValidationErrorf("pin", "***", "param pin is invalid (type)"),
+			},
+		},
+		// messages are not mangled by short sensitive values
+		{
+			ps: []spec.Parameter{
+				{
+					VendorExtensible: spec.VendorExtensible{
+						Extensions: spec.Extensions{"x-sensitive": true},
+					},
+					ParamProps: spec.ParamProps{
+						Name: "pin",
+						In:   "query",
+					},
+					SimpleSchema: spec.SimpleSchema{
+						Type:   "integer",
+						Format: "int32",
+					},
+				},
+			},
+			q: url.Values{"pin": {"a"}},
+			expectedErrors: []error{
+				ValidationErrorf("pin", "***", "param pin is invalid (type)"),
+			},
+		},
+		// format errors do not expose sensitive values
+		{
+			ps: []spec.Parameter{
+				{
+					VendorExtensible: spec.VendorExtensible{
+						Extensions: spec.Extensions{"x-sensitive": true},
+					},
+					ParamProps: spec.ParamProps{
+						Name: "tok",
+						In:   "query",
+					},
+					SimpleSchema: spec.SimpleSchema{
+						Type:   "string",
+						Format: "email",
+					},
+				},
+			},
+			q: url.Values{"tok": {"s3cr3t-value"}},
+			expectedErrors: []error{
+				ValidationErrorf("tok", "***", "param tok is invalid (format)"),
+			},
+		},
+		// array elements are members of the items enum
+		{
+			ps: []spec.Parameter{colorsParam},
+			q:  url.Values{"color": {"red", "blue"}},
+		},
+		// array element is not a member of the items enum
+		{
+			ps: []spec.Parameter{colorsParam},
+			q:  url.Values{"color": {"red", "purple"}},
+			expectedErrors: []error{
+				ValidationErrorf("color", []interface{}{"red", "purple"}, "color.1 in query should be one of [red green blue]"),
+			},
+		},
 	}
 
 	for _, c := range cases {
@@ -102,3 +289,1132 @@ func TestValidateQuery(t *testing.T) {
 		}
 	}
 }
+
+func TestValidateQuery_tuple(t *testing.T) {
+	sw := parseSpec(`
+swagger: "2.0"
+info:
+  title: test
+  version: "1.0"
+basePath: /v1
+paths:
+  /places:
+    get:
+      operationId: findPlaces
+      parameters:
+      - name: point
+        in: query
+        type: array
+        items:
+          type: string
+        x-items-tuple:
+        - type: number
+        - type: number
+      responses:
+        200:
+          description: ok
+`)
+	ps := sw.Paths.Paths["/places"].Get.Parameters
+
+	if errs := ValidateQuery(ps, url.Values{"point": {"52.5,13.4"}}); len(errs) > 0 {
+		t.Errorf("Expected no errors but got %v", errs)
+	}
+
+	errs := ValidateQuery(ps, url.Values{"point": {"52.5"}})
+	expectedErrors := []error{ValidationErrorf("point", "52.5", "param point: items count is 1, want 2")}
+	if !reflect.DeepEqual(expectedErrors, errs) {
+		t.Errorf("Expected errors to be %v but got %v", expectedErrors, errs)
+	}
+}
+
+func TestValidateQuery_formats(t *testing.T) {
+	cases := []struct {
+		format        string
+		valid         string
+		invalid       string
+		expectedError error
+	}{
+		{
+			format:        "uri",
+			valid:         "https://example.com/pets?id=1",
+			invalid:       "not a uri",
+			expectedError: ValidationErrorf("v", "not a uri", `v in query must be of type uri: "not a uri"`),
+		},
+		{
+			format:        "email",
+			valid:         "john@example.com",
+			invalid:       "john.example.com",
+			expectedError: ValidationErrorf("v", "john.example.com", `v in query must be of type email: "john.example.com"`),
+		},
+		{
+			format:        "hostname",
+			valid:         "api.example.com",
+			invalid:       "api_example..com",
+			expectedError: ValidationErrorf("v", "api_example..com", `v in query must be of type hostname: "api_example..com"`),
+		},
+		{
+			format:        "ipv4",
+			valid:         "192.168.0.1",
+			invalid:       "192.168.0.256",
+			expectedError: ValidationErrorf("v", "192.168.0.256", `v in query must be of type ipv4: "192.168.0.256"`),
+		},
+		{
+			format:        "ipv6",
+			valid:         "2001:db8::1",
+			invalid:       "2001:db8:::1",
+			expectedError: ValidationErrorf("v", "2001:db8:::1", `v in query must be of type ipv6: "2001:db8:::1"`),
+		},
+		{
+			format:        "date",
+			valid:         "2018-03-01",
+			invalid:       "2018-02-30",
+			expectedError: ValidationErrorf("v", "2018-02-30", "param v: cannot convert 2018-02-30 to date"),
+		},
+		{
+			format:        "duration",
+			valid:         "PT1H30M",
+			invalid:       "soon",
+			expectedError: ValidationErrorf("v", "soon", "param v: cannot convert soon to duration, want e.g. 2h45m or PT2H45M"),
+		},
+		{
+			format:        "byte",
+			valid:         "aGVsbG8=",
+			invalid:       "hello!",
+			expectedError: ValidationErrorf("v", "hello!", "param v: cannot convert hello! to byte"),
+		},
+	}
+
+	for _, c := range cases {
+		ps := []spec.Parameter{
+			{
+				ParamProps: spec.ParamProps{
+					Name: "v",
+					In:   "query",
+				},
+				SimpleSchema: spec.SimpleSchema{
+					Type:   "string",
+					Format: c.format,
+				},
+			},
+		}
+
+		if errs := ValidateQuery(ps, url.Values{"v": {c.valid}}); len(errs) > 0 {
+			t.Errorf("Expected no errors for %s %q but got %v", c.format, c.valid, errs)
+		}
+
+		errs := ValidateQuery(ps, url.Values{"v": {c.invalid}})
+		expectedErrors := []error{c.expectedError}
+		if !reflect.DeepEqual(expectedErrors, errs) {
+			t.Errorf("Expected errors for %s %q to be\n%#v\n but got\n%#v", c.format, c.invalid, expectedErrors, errs)
+		}
+	}
+}
+
+func TestValidateBySchema(t *testing.T) {
+	var priceStep = 0.05
+	var quantityStep float64 = 6
+
+	sch := &spec.Schema{
+		SchemaProps: spec.SchemaProps{
+			Type: spec.StringOrArray{"object"},
+			Properties: map[string]spec.Schema{
+				"price": {
+					SchemaProps: spec.SchemaProps{
+						Type:       spec.StringOrArray{"number"},
+						MultipleOf: &priceStep,
+					},
+				},
+				"quantity": {
+					SchemaProps: spec.SchemaProps{
+						Type:       spec.StringOrArray{"integer"},
+						MultipleOf: &quantityStep,
+					},
+				},
+			},
+		},
+	}
+
+	cases := []struct {
+		data           interface{}
+		expectedErrors []error
+	}{
+		// multipleOf is satisfied
+		{
+			data: map[string]interface{}{"price": 0.15, "quantity": 12},
+		},
+		// fractional multipleOf is violated
+		{
+			data: map[string]interface{}{"price": 0.07},
+			expectedErrors: []error{
+				ValidationErrorf("price", nil, "price in body should be a multiple of 0.05"),
+			},
+		},
+		// integer multipleOf is violated
+		{
+			data: map[string]interface{}{"quantity": 8},
+			expectedErrors: []error{
+				ValidationErrorf("quantity", nil, "quantity in body should be a multiple of 6"),
+			},
+		},
+	}
+
+	for _, c := range cases {
+		errs := ValidateBySchema(sch, c.data)
+		if !reflect.DeepEqual(c.expectedErrors, errs) {
+			t.Errorf("Expected errors to be\n%#v\n but got\n%#v", c.expectedErrors, errs)
+		}
+	}
+}
+
+func TestValidateBySchema_additionalProperties(t *testing.T) {
+	sw := parseSpec(`
+swagger: "2.0"
+info:
+  title: test
+  version: "1.0"
+paths: {}
+definitions:
+  Labels:
+    type: object
+    properties:
+      name:
+        type: string
+    patternProperties:
+      ^x-:
+        type: integer
+    additionalProperties:
+      type: string
+      maxLength: 3
+  Credentials:
+    type: object
+    additionalProperties:
+      type: object
+      properties:
+        token:
+          type: string
+          format: password
+          maxLength: 3
+`)
+
+	cases := []struct {
+		schema           string
+		data             interface{}
+		expectedErrors   []error
+		expectedExpected []interface{}
+		expectedActual   []interface{}
+	}{
+		// all the values are valid
+		{
+			schema: "Labels",
+			data:   map[string]interface{}{"name": "pets", "x-count": 3.0, "env": "dev"},
+		},
+		// additional values are of a wrong type or too long
+		{
+			schema: "Labels",
+			data:   map[string]interface{}{"name": "pets", "env": 1.0, "tier": "backend"},
+			expectedErrors: []error{
+				ValidationErrorf("env", nil, `env in body must be of type string: "number"`),
+				ValidationErrorf("tier", nil, "tier in body should be at most 3 chars long"),
+			},
+			expectedExpected: []interface{}{[]string{"string"}, int64(3)},
+			expectedActual:   []interface{}{"number", "backend"},
+		},
+		// pattern property values are not additional
+		{
+			schema: "Labels",
+			data:   map[string]interface{}{"x-count": "three"},
+			expectedErrors: []error{
+				ValidationErrorf("x-count", nil, `x-count in body must be of type integer: "string"`),
+			},
+			expectedExpected: []interface{}{[]string{"integer"}},
+			expectedActual:   []interface{}{"string"},
+		},
+		// sensitive values of additional properties are redacted
+		{
+			schema: "Credentials",
+			data:   map[string]interface{}{"github": map[string]interface{}{"token": "s3cr3t"}},
+			expectedErrors: []error{
+				ValidationErrorf("github.token", nil, "param github.token is invalid (maxLength)"),
+			},
+			expectedExpected: []interface{}{int64(3)},
+			expectedActual:   []interface{}{nil},
+		},
+	}
+
+	for _, c := range cases {
+		sch := sw.Definitions[c.schema]
+		errs := ValidateBySchemaDetailed(&sch, c.data)
+		sort.Slice(errs, func(i, j int) bool {
+			return errs[i].(ValidationError).Field() < errs[j].(ValidationError).Field()
+		})
+		if !reflect.DeepEqual(c.expectedErrors, plainErrors(errs)) {
+			t.Errorf("Expected errors to be\n%#v\n but got\n%#v", c.expectedErrors, errs)
+			continue
+		}
+
+		for i, err := range errs {
+			se := err.(SchemaValidationError)
+			if actual := se.Expected(); !reflect.DeepEqual(c.expectedExpected[i], actual) {
+				t.Errorf("Expected expected value of %s to be %#v but got %#v", err, c.expectedExpected[i], actual)
+			}
+			if actual := se.Actual(); !reflect.DeepEqual(c.expectedActual[i], actual) {
+				t.Errorf("Expected actual value of %s to be %#v but got %#v", err, c.expectedActual[i], actual)
+			}
+		}
+	}
+}
+
+func TestValidateBySchema_patternProperties(t *testing.T) {
+	sw := parseSpec(`
+swagger: "2.0"
+info:
+  title: test
+  version: "1.0"
+paths: {}
+definitions:
+  Headers:
+    type: object
+    properties:
+      id:
+        type: integer
+    patternProperties:
+      ^x-:
+        type: string
+        maxLength: 5
+      ^[0-9]+$:
+        type: boolean
+    additionalProperties: false
+`)
+	sch := sw.Definitions["Headers"]
+
+	cases := []struct {
+		data           interface{}
+		expectedErrors []error
+	}{
+		// properties match patterns
+		{
+			data: map[string]interface{}{"id": 1.0, "x-trace": "abc", "42": true},
+		},
+		// values of matched properties are invalid
+		{
+			data: map[string]interface{}{"x-trace": "abcdef", "42": "yes"},
+			expectedErrors: []error{
+				ValidationErrorf("42", nil, `42 in body must be of type boolean: "string"`),
+				ValidationErrorf("x-trace", nil, "x-trace in body should be at most 5 chars long"),
+			},
+		},
+		// property matches no pattern
+		{
+			data: map[string]interface{}{"trace": "abc"},
+			expectedErrors: []error{
+				ValidationErrorf("", nil, "trace in body is a forbidden property"),
+			},
+		},
+	}
+
+	for _, c := range cases {
+		errs := ValidateBySchema(&sch, c.data)
+		sort.Slice(errs, func(i, j int) bool {
+			return errs[i].(ValidationError).Field() < errs[j].(ValidationError).Field()
+		})
+		if !reflect.DeepEqual(c.expectedErrors, errs) {
+			t.Errorf("Expected errors to be\n%#v\n but got\n%#v", c.expectedErrors, errs)
+		}
+	}
+}
+
+func TestValidateBySchema_freeFormObject(t *testing.T) {
+	sch := &spec.Schema{SchemaProps: spec.SchemaProps{Type: spec.StringOrArray{"object"}}}
+
+	cases := []struct {
+		data           interface{}
+		expectedErrors []error
+	}{
+		// any object is accepted
+		{
+			data: map[string]interface{}{"name": "Kitty", "tags": []interface{}{"cat"}, "owner": map[string]interface{}{}},
+		},
+		{
+			data: map[string]interface{}{},
+		},
+		// other types are rejected
+		{
+			data: []interface{}{"Kitty"},
+			expectedErrors: []error{
+				ValidationErrorf("", nil, `body in body must be of type object: "array"`),
+			},
+		},
+		{
+			data: "Kitty",
+			expectedErrors: []error{
+				ValidationErrorf("", nil, `body in body must be of type object: "string"`),
+			},
+		},
+		{
+			data: nil,
+			expectedErrors: []error{
+				ValidationErrorf("", nil, `body in body must be of type object: "null"`),
+			},
+		},
+	}
+
+	for _, c := range cases {
+		errs := ValidateBySchema(sch, c.data)
+		if !reflect.DeepEqual(c.expectedErrors, errs) {
+			t.Errorf("Expected errors for %#v to be\n%#v\n but got\n%#v", c.data, c.expectedErrors, errs)
+		}
+	}
+}
+
+func TestValidateBySchema_propertiesCount(t *testing.T) {
+	sw := parseSpec(`
+swagger: "2.0"
+info:
+  title: test
+  version: "1.0"
+paths: {}
+definitions:
+  Pet:
+    type: object
+    minProperties: 1
+    properties:
+      labels:
+        type: object
+        minProperties: 1
+        maxProperties: 2
+        additionalProperties:
+          type: string
+`)
+	sch := sw.Definitions["Pet"]
+
+	cases := []struct {
+		data           interface{}
+		expectedErrors []error
+		expectedActual interface{}
+	}{
+		// counts are within the limits
+		{
+			data: map[string]interface{}{"labels": map[string]interface{}{"color": "red"}},
+		},
+		// too few properties
+		{
+			data: map[string]interface{}{},
+			expectedErrors: []error{
+				ValidationErrorf("", nil, "body in body should have at least 1 properties, has 0"),
+			},
+			expectedActual: map[string]interface{}{},
+		},
+		{
+			data: map[string]interface{}{"labels": map[string]interface{}{}},
+			expectedErrors: []error{
+				ValidationErrorf("labels", nil, "labels in body should have at least 1 properties, has 0"),
+			},
+			expectedActual: map[string]interface{}{},
+		},
+		// too many properties
+		{
+			data: map[string]interface{}{"labels": map[string]interface{}{"color": "red", "size": "S", "owner": "John"}},
+			expectedErrors: []error{
+				ValidationErrorf("labels", nil, "labels in body should have at most 2 properties, has 3"),
+			},
+			expectedActual: map[string]interface{}{"color": "red", "size": "S", "owner": "John"},
+		},
+	}
+
+	for _, c := range cases {
+		errs := ValidateBySchemaDetailed(&sch, c.data)
+		if !reflect.DeepEqual(c.expectedErrors, plainErrors(errs)) {
+			t.Errorf("Expected errors to be\n%#v\n but got\n%#v", c.expectedErrors, errs)
+			continue
+		}
+		if len(errs) == 0 {
+			continue
+		}
+		if actual := errs[0].(SchemaValidationError).Actual(); !reflect.DeepEqual(c.expectedActual, actual) {
+			t.Errorf("Expected actual value to be %v but got %v", c.expectedActual, actual)
+		}
+	}
+}
+
+func TestValidateBySchema_nullableItems(t *testing.T) {
+	sw := parseSpec(`
+swagger: "2.0"
+info:
+  title: test
+  version: "1.0"
+paths: {}
+definitions:
+  Pet:
+    type: object
+    properties:
+      tags:
+        type: array
+        items:
+          type: string
+          enum: [cat, dog]
+          x-nullable: true
+      names:
+        type: array
+        items:
+          type: string
+`)
+	sch := sw.Definitions["Pet"]
+
+	cases := []struct {
+		data           interface{}
+		expectedErrors []error
+	}{
+		// null elements of nullable items are accepted
+		{
+			data: map[string]interface{}{"tags": []interface{}{"cat", nil}},
+		},
+		// other elements of nullable items are still validated
+		{
+			data: map[string]interface{}{"tags": []interface{}{"cow", nil}},
+			expectedErrors: []error{
+				ValidationErrorf("tags", nil, "tags in body should be one of [cat dog]"),
+			},
+		},
+		// null elements of non-nullable items are rejected
+		{
+			data: map[string]interface{}{"names": []interface{}{"Kitty", nil}},
+			expectedErrors: []error{
+				ValidationErrorf("names", nil, `names in body must be of type string: "null"`),
+			},
+		},
+	}
+
+	compiled, err := CompileSchema(&sch)
+	if err != nil {
+		t.Fatal(err)
+	}
+
+	for _, c := range cases {
+		errs := ValidateBySchema(&sch, c.data)
+		if !reflect.DeepEqual(c.expectedErrors, errs) {
+			t.Errorf("Expected errors for %v to be\n%#v\n but got\n%#v", c.data, c.expectedErrors, errs)
+		}
+
+		errs = compiled.Validate(c.data)
+		if !reflect.DeepEqual(c.expectedErrors, errs) {
+			t.Errorf("Expected errors of compiled schema for %v to be\n%#v\n but got\n%#v", c.data, c.expectedErrors, errs)
+		}
+	}
+}
+
+func TestValidateBySchema_formats(t *testing.T) {
+	cases := []struct {
+		format          string
+		valid           string
+		invalid         string
+		expectedMessage string
+	}{
+		{
+			format:          "uri",
+			valid:           "https://example.com/pets?id=1",
+			invalid:         "not a uri",
+			expectedMessage: `v in body must be of type uri: "not a uri"`,
+		},
+		{
+			format:          "email",
+			valid:           "john@example.com",
+			invalid:         "john.example.com",
+			expectedMessage: `v in body must be of type email: "john.example.com"`,
+		},
+		{
+			format:          "hostname",
+			valid:           "api.example.com",
+			invalid:         "api_example..com",
+			expectedMessage: `v in body must be of type hostname: "api_example..com"`,
+		},
+		{
+			format:          "ipv4",
+			valid:           "192.168.0.1",
+			invalid:         "192.168.0.256",
+			expectedMessage: `v in body must be of type ipv4: "192.168.0.256"`,
+		},
+		{
+			format:          "ipv6",
+			valid:           "2001:db8::1",
+			invalid:         "2001:db8:::1",
+			expectedMessage: `v in body must be of type ipv6: "2001:db8:::1"`,
+		},
+	}
+
+	for _, c := range cases {
+		sch := &spec.Schema{
+			SchemaProps: spec.SchemaProps{
+				Type: spec.StringOrArray{"object"},
+				Properties: map[string]spec.Schema{
+					"v": {
+						SchemaProps: spec.SchemaProps{
+							Type:   spec.StringOrArray{"string"},
+							Format: c.format,
+						},
+					},
+				},
+			},
+		}
+
+		if errs := ValidateBySchema(sch, map[string]interface{}{"v": c.valid}); len(errs) > 0 {
+			t.Errorf("Expected no errors for %s %q but got %v", c.format, c.valid, errs)
+		}
+
+		errs := ValidateBySchema(sch, map[string]interface{}{"v": c.invalid})
+		expectedErrors := []error{ValidationErrorf("v", nil, "%s", c.expectedMessage)}
+		if !reflect.DeepEqual(expectedErrors, errs) {
+			t.Errorf("Expected errors for %s %q to be\n%#v\n but got\n%#v", c.format, c.invalid, expectedErrors, errs)
+		}
+	}
+}
+
+func TestValidateBySchema_uniqueItems(t *testing.T) {
+	sch := &spec.Schema{
+		SchemaProps: spec.SchemaProps{
+			Type: spec.StringOrArray{"object"},
+			Properties: map[string]spec.Schema{
+				"pets": {
+					SchemaProps: spec.SchemaProps{
+						Type:        spec.StringOrArray{"array"},
+						UniqueItems: true,
+						Items: &spec.SchemaOrArray{
+							Schema: &spec.Schema{
+								SchemaProps: spec.SchemaProps{
+									Type: spec.StringOrArray{"object"},
+								},
+							},
+						},
+					},
+				},
+				"tags": {
+					SchemaProps: spec.SchemaProps{
+						Type:        spec.StringOrArray{"array"},
+						UniqueItems: true,
+						Items: &spec.SchemaOrArray{
+							Schema: spec.StringProperty(),
+						},
+					},
+				},
+			},
+		},
+	}
+
+	cases := []struct {
+		data           interface{}
+		expectedErrors []error
+	}{
+		// unique objects and primitives
+		{
+			data: map[string]interface{}{
+				"pets": []interface{}{
+					map[string]interface{}{"id": 1, "tags": []interface{}{"a"}},
+					map[string]interface{}{"id": 1, "tags": []interface{}{"b"}},
+				},
+				"tags": []interface{}{"a", "b"},
+			},
+		},
+		// duplicate objects are compared by value
+		{
+			data: map[string]interface{}{
+				"pets": []interface{}{
+					map[string]interface{}{"id": 1, "tags": []interface{}{"a"}},
+					map[string]interface{}{"id": 1, "tags": []interface{}{"a"}},
+				},
+			},
+			expectedErrors: []error{
+				ValidationErrorf("pets", nil, "pets in body shouldn't contain duplicates"),
+			},
+		},
+		// duplicate primitives
+		{
+			data: map[string]interface{}{
+				"tags": []interface{}{"a", "b", "a"},
+			},
+			expectedErrors: []error{
+				ValidationErrorf("tags", nil, "tags in body shouldn't contain duplicates"),
+			},
+		},
+	}
+
+	for _, c := range cases {
+		errs := ValidateBySchema(sch, c.data)
+		if !reflect.DeepEqual(c.expectedErrors, errs) {
+			t.Errorf("Expected errors to be\n%#v\n but got\n%#v", c.expectedErrors, errs)
+		}
+	}
+}
+
+func TestDecodeAndValidateBody(t *testing.T) {
+	op := &spec.Operation{
+		OperationProps: spec.OperationProps{
+			Parameters: []spec.Parameter{
+				{
+					ParamProps: spec.ParamProps{
+						Name:   "pet",
+						In:     "body",
+						Schema: petSchema(),
+					},
+				},
+			},
+		},
+	}
+
+	cases := []struct {
+		body           string
+		expectedBody   interface{}
+		expectedErrors []error
+	}{
+		// valid, numbers keep precision
+		{
+			body: `{"name":"Kitty","age":9007199254740993}`,
+			expectedBody: map[string]interface{}{
+				"name": "Kitty",
+				"age":  json.Number("9007199254740993"),
+			},
+		},
+		// malformed json
+		{
+			body: `{"name":`,
+			expectedErrors: []error{
+				fmt.Errorf("Body contains invalid json"),
+			},
+		},
+		// schema failure
+		{
+			body: `{"name":"Kitty","age":"three"}`,
+			expectedBody: map[string]interface{}{
+				"name": "Kitty",
+				"age":  "three",
+			},
+			expectedErrors: []error{
+				ValidationErrorf("age", nil, "age in body must be of type integer: \"string\""),
+			},
+		},
+	}
+
+	for _, c := range cases {
+		body, errs := DecodeAndValidateBody(op, strings.NewReader(c.body))
+		if !reflect.DeepEqual(c.expectedBody, body) {
+			t.Errorf("Expected body to be\n%#v\n but got\n%#v", c.expectedBody, body)
+		}
+		if !reflect.DeepEqual(c.expectedErrors, errs) {
+			t.Errorf("Expected errors to be\n%#v\n but got\n%#v", c.expectedErrors, errs)
+		}
+	}
+}
+
+func TestValidateBody_readOnly(t *testing.T) {
+	sch := &spec.Schema{
+		SchemaProps: spec.SchemaProps{
+			Type:     spec.StringOrArray{"object"},
+			Required: []string{"id", "name"},
+			Properties: map[string]spec.Schema{
+				"id": {
+					SchemaProps: spec.SchemaProps{
+						Type: spec.StringOrArray{"integer"},
+					},
+					SwaggerSchemaProps: spec.SwaggerSchemaProps{
+						ReadOnly: true,
+					},
+				},
+				"name": {
+					SchemaProps: spec.SchemaProps{
+						Type: spec.StringOrArray{"string"},
+					},
+				},
+			},
+		},
+	}
+
+	ps := []spec.Parameter{
+		{
+			ParamProps: spec.ParamProps{
+				Name:   "pet",
+				In:     "body",
+				Schema: sch,
+			},
+		},
+	}
+
+	data := map[string]interface{}{"name": "Kitty"}
+
+	// readOnly field is not required in the request
+	if errs := ValidateBody(ps, data); len(errs) > 0 {
+		t.Errorf("Expected no errors in request context but got %v", errs)
+	}
+
+	// but it is required in the response
+	expectedErrors := []error{
+		ValidationErrorf("id", nil, "id in body is required"),
+	}
+	if errs := ValidateBySchema(sch, data); !reflect.DeepEqual(expectedErrors, errs) {
+		t.Errorf("Expected errors in response context to be\n%#v\n but got\n%#v", expectedErrors, errs)
+	}
+
+	// other required fields are still required in the request
+	expectedErrors = []error{
+		ValidationErrorf("name", nil, "name in body is required"),
+	}
+	if errs := ValidateBody(ps, map[string]interface{}{"id": 1}); !reflect.DeepEqual(expectedErrors, errs) {
+		t.Errorf("Expected errors in request context to be\n%#v\n but got\n%#v", expectedErrors, errs)
+	}
+}
+
+func TestValidateBodyBySpec(t *testing.T) {
+	sw := parseSpec(`
+swagger: "2.0"
+info:
+  title: test
+  version: "1.0"
+paths:
+  /pets:
+    post:
+      operationId: addPet
+      parameters:
+      - name: pet
+        in: body
+        schema:
+          $ref: "#/definitions/Pet"
+      responses:
+        200:
+          description: ok
+definitions:
+  Pet:
+    type: object
+    required: [name]
+    properties:
+      name:
+        type: string
+      owner:
+        $ref: "#/definitions/Owner"
+  Owner:
+    type: object
+    properties:
+      age:
+        type: integer
+`)
+	ps := sw.Paths.Paths["/pets"].Post.Parameters
+
+	cases := []struct {
+		data           interface{}
+		expectedErrors []error
+	}{
+		// valid
+		{
+			data: map[string]interface{}{"name": "Kitty", "owner": map[string]interface{}{"age": 30}},
+		},
+		// invalid by the referenced schema
+		{
+			data: map[string]interface{}{"name": 1, "owner": map[string]interface{}{"age": "old"}},
+			expectedErrors: []error{
+				ValidationErrorf("name", nil, `name in body must be of type string: "integer"`),
+				ValidationErrorf("owner.age", nil, `owner.age in body must be of type integer: "string"`),
+			},
+		},
+	}
+
+	for _, c := range cases {
+		errs := ValidateBodyBySpec(sw, ps, c.data)
+		sort.Slice(errs, func(i, j int) bool {
+			return errs[i].(ValidationError).Field() < errs[j].(ValidationError).Field()
+		})
+		if !reflect.DeepEqual(c.expectedErrors, errs) {
+			t.Errorf("Expected errors to be\n%#v\n but got\n%#v", c.expectedErrors, errs)
+		}
+	}
+
+	// Without the spec the reference is not resolved, so the body is not
+	// passed unvalidated.
+	expectedErrors := []error{
+		ValidationErrorf("pet", nil, "param pet: schema reference #/definitions/Pet is not resolved"),
+	}
+	if errs := ValidateBody(ps, map[string]interface{}{"name": "Kitty"}); !reflect.DeepEqual(expectedErrors, errs) {
+		t.Errorf("Expected errors to be\n%#v\n but got\n%#v", expectedErrors, errs)
+	}
+
+	// Nested references are not resolved either.
+	nested := []spec.Parameter{{
+		ParamProps: spec.ParamProps{
+			Name: "order",
+			In:   "body",
+			Schema: &spec.Schema{SchemaProps: spec.SchemaProps{
+				Type: spec.StringOrArray{"object"},
+				Properties: map[string]spec.Schema{
+					"pet": *spec.RefSchema("#/definitions/Pet"),
+				},
+			}},
+		},
+	}}
+	expectedErrors = []error{
+		ValidationErrorf("order", nil, "param order: schema reference #/definitions/Pet is not resolved"),
+	}
+	errs := ValidateBody(nested, map[string]interface{}{"pet": map[string]interface{}{"name": "Kitty"}})
+	if !reflect.DeepEqual(expectedErrors, errs) {
+		t.Errorf("Expected errors to be\n%#v\n but got\n%#v", expectedErrors, errs)
+	}
+
+	// The reference reported is the same every time.
+	patterns := []spec.Parameter{{
+		ParamProps: spec.ParamProps{
+			Name: "tags",
+			In:   "body",
+			Schema: &spec.Schema{SchemaProps: spec.SchemaProps{
+				Type: spec.StringOrArray{"object"},
+				PatternProperties: map[string]spec.Schema{
+					"^b": *spec.RefSchema("#/definitions/B"),
+					"^a": *spec.RefSchema("#/definitions/A"),
+					"^c": *spec.RefSchema("#/definitions/C"),
+				},
+			}},
+		},
+	}}
+	expectedErrors = []error{
+		ValidationErrorf("tags", nil, "param tags: schema reference #/definitions/A is not resolved"),
+	}
+	for i := 0; i < 20; i++ {
+		errs := ValidateBody(patterns, map[string]interface{}{})
+		if !reflect.DeepEqual(expectedErrors, errs) {
+			t.Fatalf("Expected errors to be\n%#v\n but got\n%#v", expectedErrors, errs)
+		}
+	}
+}
+
+func TestValidateBodyBySpec_recursive(t *testing.T) {
+	sw := parseSpec(`
+swagger: "2.0"
+info:
+  title: test
+  version: "1.0"
+paths: {}
+definitions:
+  Node:
+    type: object
+    properties:
+      children:
+        type: array
+        items:
+          $ref: "#/definitions/Node"
+`)
+
+	ps := []spec.Parameter{*spec.BodyParam("node", spec.RefSchema("#/definitions/Node"))}
+
+	// The reference of the schema to itself cannot be expanded, so the body
+	// is rejected rather than passed unvalidated.
+	expectedErrors := []error{
+		ValidationErrorf("node", nil, "param node: schema reference #/definitions/Node is not resolved"),
+	}
+	if errs := ValidateBodyBySpec(sw, ps, map[string]interface{}{}); !reflect.DeepEqual(expectedErrors, errs) {
+		t.Errorf("Expected errors to be\n%#v\n but got\n%#v", expectedErrors, errs)
+	}
+}
+
+func TestValidateBySchema_sensitive(t *testing.T) {
+	sch := &spec.Schema{
+		SchemaProps: spec.SchemaProps{
+			Type: spec.StringOrArray{"object"},
+			Properties: map[string]spec.Schema{
+				"token": {
+					VendorExtensible: spec.VendorExtensible{
+						Extensions: spec.Extensions{"x-sensitive": true},
+					},
+					SchemaProps: spec.SchemaProps{
+						Type:   spec.StringOrArray{"string"},
+						Format: "uuid",
+					},
+				},
+				"session": {
+					SchemaProps: spec.SchemaProps{
+						Type:   spec.StringOrArray{"string"},
+						Format: "uuid",
+					},
+				},
+			},
+		},
+	}
+
+	errs := ValidateBySchema(sch, map[string]interface{}{"token": "leaked-token"})
+	expectedErrors := []error{
+		ValidationErrorf("token", nil, "param token is invalid (format)"),
+	}
+	if !reflect.DeepEqual(expectedErrors, errs) {
+		t.Errorf("Expected errors to be\n%#v\n but got\n%#v", expectedErrors, errs)
+	}
+
+	errs = ValidateBySchema(sch, map[string]interface{}{"session": "not-a-uuid"})
+	expectedErrors = []error{
+		ValidationErrorf("session", nil, "session in body must be of type uuid: \"not-a-uuid\""),
+	}
+	if !reflect.DeepEqual(expectedErrors, errs) {
+		t.Errorf("Expected errors to be\n%#v\n but got\n%#v", expectedErrors, errs)
+	}
+}
+
+func TestValidateBySchema_schemaValidationError(t *testing.T) {
+	var maxAge float64 = 30
+
+	sch := &spec.Schema{
+		SchemaProps: spec.SchemaProps{
+			Type:     spec.StringOrArray{"object"},
+			Required: []string{"name"},
+			Properties: map[string]spec.Schema{
+				"age": {
+					SchemaProps: spec.SchemaProps{
+						Type:    spec.StringOrArray{"integer"},
+						Maximum: &maxAge,
+					},
+				},
+				"emails": {
+					SchemaProps: spec.SchemaProps{
+						Type: spec.StringOrArray{"array"},
+						Items: &spec.SchemaOrArray{
+							Schema: &spec.Schema{
+								SchemaProps: spec.SchemaProps{
+									Type:   spec.StringOrArray{"string"},
+									Format: "email",
+								},
+							},
+						},
+					},
+				},
+				"password": {
+					SchemaProps: spec.SchemaProps{
+						Type:   spec.StringOrArray{"string"},
+						Format: "password",
+						Enum:   []interface{}{"secret"},
+					},
+				},
+			},
+		},
+	}
+
+	type details struct {
+		Path     string
+		Keyword  string
+		Expected interface{}
+		Actual   interface{}
+	}
+
+	data := map[string]interface{}{
+		"age":      int64(42),
+		"emails":   []interface{}{"john@example.com", "john"},
+		"password": "qwerty",
+	}
+
+	expected := map[string]details{
+		"age":    {Path: "/age", Keyword: "maximum", Expected: maxAge, Actual: int64(42)},
+		"emails": {Path: "/emails", Keyword: "format", Expected: "email", Actual: "john"},
+		"name":   {Path: "/name", Keyword: "required"},
+		// sensitive values are not exposed
+		"password": {Path: "/password", Keyword: "enum", Expected: []interface{}{"secret"}},
+	}
+
+	errs := ValidateBySchemaDetailed(sch, data)
+	if len(errs) != len(expected) {
+		t.Fatalf("Expected %d errors but got %v", len(expected), errs)
+	}
+
+	for _, err := range errs {
+		se, ok := err.(SchemaValidationError)
+		if !ok {
+			t.Fatalf("Expected error to be SchemaValidationError but got %#v", err)
+		}
+
+		actual := details{Path: se.Path(), Keyword: se.Keyword(), Expected: se.Expected(), Actual: se.Actual()}
+		if !reflect.DeepEqual(expected[se.Field()], actual) {
+			t.Errorf("Expected details of error on %s to be %#v but got %#v", se.Field(), expected[se.Field()], actual)
+		}
+	}
+}
+
+func TestErrorsToJSONPointers(t *testing.T) {
+	sw := parseSpec(`
+swagger: "2.0"
+info:
+  title: test
+  version: "1.0"
+paths: {}
+definitions:
+  Person:
+    type: object
+    required: [name, address]
+    properties:
+      name:
+        type: string
+      address:
+        type: object
+        required: [zip]
+        properties:
+          zip:
+            type: string
+          lines:
+            type: array
+            items:
+              type: string
+              maxLength: 5
+`)
+	sch := sw.Definitions["Person"]
+
+	errs := ValidateBySchemaDetailed(&sch, map[string]interface{}{
+		"address": map[string]interface{}{
+			"lines": []interface{}{"Main St"},
+		},
+	})
+	sort.Slice(errs, func(i, j int) bool {
+		return errs[i].(ValidationError).Field() < errs[j].(ValidationError).Field()
+	})
+	errs = append(errs,
+		ValidateBySchemaDetailed(&sch, "John")[0],
+		ValidationErrorf("limit", "ten", "param limit: cannot convert ten to int64"),
+		fmt.Errorf("Body contains invalid json"),
+	)
+
+	// Indexes of array items are not reported by the schema validator.
+	expected := []string{"/address/lines", "/address/zip", "/name", "", "", ""}
+	if actual := ErrorsToJSONPointers(errs); !reflect.DeepEqual(expected, actual) {
+		t.Errorf("Expected JSON pointers of %v to be %q but got %q", errs, expected, actual)
+	}
+}
+
+// plainErrors returns errors with details of schema validation errors
+// stripped, so they can be compared with errors made by ValidationErrorf.
+func plainErrors(errs []error) []error {
+	if errs == nil {
+		return nil
+	}
+	plain := make([]error, len(errs))
+	for i, err := range errs {
+		if se, ok := err.(schemaErr); ok {
+			err = se.valErr
+		}
+		plain[i] = err
+	}
+	return plain
+}
+
+func BenchmarkValidateQuery_pattern(b *testing.B) {
+	ps := []spec.Parameter{
+		{
+			ParamProps: spec.ParamProps{
+				Name: "name",
+				In:   "query",
+			},
+			SimpleSchema: spec.SimpleSchema{
+				Type: "string",
+			},
+			CommonValidations: spec.CommonValidations{
+				Pattern: "^[A-Z][a-z]+$",
+			},
+		},
+	}
+
+	// The pattern is compiled once, as NewRouter does.
+	if err := compilePattern(ps[0].Pattern); err != nil {
+		b.Fatal(err)
+	}
+
+	b.ReportAllocs()
+	b.ResetTimer()
+	for i := 0; i < b.N; i++ {
+		ValidateQuery(ps, url.Values{"name": {"Kitty"}})
+	}
+}
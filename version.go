@@ -0,0 +1,92 @@
+package oas2
+
+import (
+	"fmt"
+	"net/http"
+
+	"github.com/go-openapi/spec"
+)
+
+// versionHeaders are request headers a client sends the API version in,
+// in order of precedence.
+var versionHeaders = []string{"Accept-Version", "X-API-Version"}
+
+// servedVersionHeader is the response header the served API version is
+// echoed in.
+const servedVersionHeader = "X-API-Version"
+
+// NewVersionGate returns new Middleware that requires requests to send the
+// API version in Accept-Version or X-API-Version header. The version must be
+// the spec's info.version or one of versions set by AcceptedVersionsOpt.
+// Requests without the header or with another version are responded with
+// 400 Bad Request and the body written by errHandler. The served version is
+// echoed in X-API-Version response header.
+func NewVersionGate(sw *spec.Swagger, errHandler func(w http.ResponseWriter, errs []error), options ...MiddlewareOption) Middleware {
+	opts := newMiddlewareOptions(options)
+
+	accepted := opts.acceptedVersions
+	if len(accepted) == 0 && sw.Info != nil && sw.Info.Version != "" {
+		accepted = []string{sw.Info.Version}
+	}
+
+	return versionGate{
+		errHandler: errHandler,
+		opts:       opts,
+		accepted:   accepted,
+	}
+}
+
+type versionGate struct {
+	errHandler func(w http.ResponseWriter, errs []error)
+	opts       MiddlewareOptions
+	accepted   []string
+}
+
+func (m versionGate) Apply(next http.Handler) http.Handler {
+	return http.HandlerFunc(func(w http.ResponseWriter, req *http.Request) {
+		if m.opts.operationResolver(req) == nil {
+			next.ServeHTTP(w, req)
+			return
+		}
+
+		errHandler := operationErrHandler(req, m.errHandler)
+
+		version, header := requestedVersion(req)
+		if header == "" {
+			writeErrorsWithStatus(w, http.StatusBadRequest, errHandler, []error{
+				fmt.Errorf("API version is required in %s header", versionHeaders[0]),
+			})
+			return
+		}
+
+		if !m.accepts(version) {
+			writeErrorsWithStatus(w, http.StatusBadRequest, errHandler, []error{
+				ValidationErrorf(header, version, "API version %s is not supported", version),
+			})
+			return
+		}
+
+		w.Header().Set(servedVersionHeader, version)
+		next.ServeHTTP(w, req)
+	})
+}
+
+func (m versionGate) accepts(version string) bool {
+	for _, v := range m.accepted {
+		if v == version {
+			return true
+		}
+	}
+	return false
+}
+
+// requestedVersion returns the API version requested and the header it is
+// sent in, or empty strings if there is none.
+func requestedVersion(req *http.Request) (version, header string) {
+	for _, h := range versionHeaders {
+		if v := req.Header.Get(h); v != "" {
+			return v, h
+		}
+	}
+	return "", ""
+}
@@ -0,0 +1,97 @@
+package oas2
+
+import (
+	"fmt"
+	"net/http"
+	"net/http/httptest"
+	"testing"
+)
+
+func TestVersionGate_Apply(t *testing.T) {
+	cases := []struct {
+		options         []MiddlewareOption
+		header          string
+		version         string
+		expectedStatus  int
+		expectedPayload string
+		expectedVersion string
+	}{
+		// missing version
+		{
+			expectedStatus:  http.StatusBadRequest,
+			expectedPayload: `{"errors":[{"message":"API version is required in Accept-Version header"}]}`,
+		},
+		// spec version
+		{
+			header:          "Accept-Version",
+			version:         "1.0.0",
+			expectedStatus:  http.StatusOK,
+			expectedPayload: "pet",
+			expectedVersion: "1.0.0",
+		},
+		// spec version in the alternative header
+		{
+			header:          "X-API-Version",
+			version:         "1.0.0",
+			expectedStatus:  http.StatusOK,
+			expectedPayload: "pet",
+			expectedVersion: "1.0.0",
+		},
+		// unsupported version
+		{
+			header:          "Accept-Version",
+			version:         "2.0.0",
+			expectedStatus:  http.StatusBadRequest,
+			expectedPayload: `{"errors":[{"message":"API version 2.0.0 is not supported","field":"Accept-Version","value":"2.0.0"}]}`,
+		},
+		// configured versions replace the spec version
+		{
+			options:         []MiddlewareOption{AcceptedVersionsOpt("0.9.0", "2.0.0")},
+			header:          "Accept-Version",
+			version:         "0.9.0",
+			expectedStatus:  http.StatusOK,
+			expectedPayload: "pet",
+			expectedVersion: "0.9.0",
+		},
+		{
+			options:         []MiddlewareOption{AcceptedVersionsOpt("0.9.0", "2.0.0")},
+			header:          "Accept-Version",
+			version:         "1.0.0",
+			expectedStatus:  http.StatusBadRequest,
+			expectedPayload: `{"errors":[{"message":"API version 1.0.0 is not supported","field":"Accept-Version","value":"1.0.0"}]}`,
+		},
+	}
+
+	doc := loadDoc()
+
+	handlers := OperationHandlers{"getPetById": http.HandlerFunc(func(w http.ResponseWriter, req *http.Request) {
+		fmt.Fprint(w, "pet")
+	})}
+
+	for _, c := range cases {
+		versionGate := NewVersionGate(doc.Spec(), writeErrorsToResponseWriter, c.options...)
+
+		router, err := NewRouter(doc.Spec(), handlers, MiddlewareOpt(versionGate.Apply))
+		if err != nil {
+			t.Fatal(err)
+		}
+
+		req := httptest.NewRequest(http.MethodGet, "/v2/pet/12", nil)
+		if c.header != "" {
+			req.Header.Set(c.header, c.version)
+		}
+		w := httptest.NewRecorder()
+
+		router.ServeHTTP(w, req)
+
+		if w.Code != c.expectedStatus {
+			t.Errorf("Expected status to be %d but got %d", c.expectedStatus, w.Code)
+		}
+		if w.Body.String() != c.expectedPayload {
+			t.Errorf("Expected response body to be\n%s\nbut got\n%s", c.expectedPayload, w.Body.String())
+		}
+		if actual := w.Header().Get("X-API-Version"); actual != c.expectedVersion {
+			t.Errorf("Expected served version to be %q but got %q", c.expectedVersion, actual)
+		}
+	}
+}